@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// LoginAttempt counts the failed logins of a key , an email or a client ip , since FirstFailureAt.
+// LockedUntil blocks every login of the key until that time.
+type LoginAttempt struct {
+	Key            string    `gorm:"primaryKey;size:320"`
+	Failures       int       `gorm:"not null"`
+	FirstFailureAt time.Time `gorm:"not null"`
+	LastFailureAt  time.Time `gorm:"index;not null"`
+	LockedUntil    *time.Time
+}
+
+// IsLocked reports whether the key is locked at now
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
@@ -0,0 +1,13 @@
+package entity
+
+import (
+	"time"
+)
+
+// MigrationSetting is a value the startup migrations keep between runs ,
+// e.g. the rule the stored canonical emails were made with
+type MigrationSetting struct {
+	Name      string `gorm:"primaryKey;size:64"`
+	Value     string `gorm:"size:255;not null"`
+	UpdatedAt time.Time
+}
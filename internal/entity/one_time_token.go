@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OneTimeTokenPurpose is what a one time token can be used for
+type OneTimeTokenPurpose string
+
+const (
+	OneTimeTokenPasswordReset OneTimeTokenPurpose = "password_reset"
+)
+
+// OneTimeToken is a single use token sent to the user by email , e.g. to reset the password.
+// Only the sha256 hash of the token is stored.
+type OneTimeToken struct {
+	ID        uuid.UUID           `gorm:"primaryKey"`
+	UserID    uuid.UUID           `gorm:"index;not null"`
+	Purpose   OneTimeTokenPurpose `gorm:"size:32;not null"`
+	TokenHash string              `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time           `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsExpired reports whether the token is expired at now
+func (t *OneTimeToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
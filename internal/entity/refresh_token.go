@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is an opaque token that exchanges for a new access token.
+// Only the sha256 hash of the token is stored. Every rotation creates a new token in the same family ,
+// a reused token revokes the whole family.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"primaryKey"`
+	UserID    uuid.UUID  `gorm:"index;not null"`
+	FamilyID  uuid.UUID  `gorm:"index;not null"`
+	DeviceID  string     `gorm:"size:255;not null"`
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // rotated to a new token
+	AuthTime  *time.Time // the login of the family , the access tokens carry it as auth_time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// IsExpired reports whether the token is expired at now
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
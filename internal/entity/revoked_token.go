@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedToken is an access token revoked before it expires , the row can be deleted after ExpiresAt
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	UserID    uuid.UUID `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// UserTokenRevocation invalidates every access token of the user issued before RevokedBefore
+type UserTokenRevocation struct {
+	UserID        uuid.UUID `gorm:"primaryKey"`
+	RevokedBefore time.Time `gorm:"not null"`
+	UpdatedAt     time.Time
+}
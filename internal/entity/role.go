@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleNameMaxLength        = 64
+	RoleDescriptionMaxLength = 255
+)
+
+// Permission allows the calls of some rpcs , the permissions are defined by the code and synced into the table
+type Permission struct {
+	Name        string `gorm:"primaryKey;size:64"`
+	Description string `gorm:"size:255;not null"`
+}
+
+// Role is a named set of permissions , the users get the permissions of their roles in their access tokens
+type Role struct {
+	ID          uuid.UUID `gorm:"primaryKey"`
+	Name        string    `gorm:"uniqueIndex;size:64;not null"`
+	Description string    `gorm:"size:255;not null"`
+	Permissions []RolePermission
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// PermissionNames returns the names of the permissions of the role
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, permission := range r.Permissions {
+		names = append(names, permission.Permission)
+	}
+	return names
+}
+
+// RolePermission grants a permission to a role
+type RolePermission struct {
+	RoleID     uuid.UUID `gorm:"primaryKey"`
+	Permission string    `gorm:"primaryKey;size:64"`
+}
+
+// UserRole assigns a role to a user
+type UserRole struct {
+	UserID    uuid.UUID `gorm:"primaryKey"`
+	RoleID    uuid.UUID `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
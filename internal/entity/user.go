@@ -2,24 +2,105 @@ package entity
 
 import (
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// column sizes of User , the request validation uses the same limits
+const (
+	UserNameMaxLength      = 20
+	UserEmailMaxLength     = 255
+	UserAvatarURLMaxLength = 255
+	UserBioMaxLength       = 255
 )
 
+// User is an account.
+// The indexes on created_at and name serve the pages of ListUsers , InnoDB appends the primary key to them ,
+// so they are sorted by (column , id) like the pages.
 type User struct {
-	gorm.Model `gorm:"embedded"`
-	ID         uuid.UUID `gorm:"primaryKey"`
-	Name       string    `gorm:"size:20;not null"`
-	Email      string    `gorm:"uniqueIndex;size:255;not null"`
-	Password   string    `gorm:"size:255;not null"`
+	ID        uuid.UUID `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string         `gorm:"size:20;not null;index"`
+	Email     string         `gorm:"size:255;not null"`
+	Password  string         `gorm:"size:255;not null"`
+	AvatarURL string         `gorm:"size:255"`
+	Bio       string         `gorm:"size:255"`
+	// Status is changed with UsersRepository.ChangeStatus , which records the change
+	Status UserStatus `gorm:"size:32;not null;default:active;index"`
+
+	// EmailCanonical is the unique lookup key of Email , see utils.NormalizeEmail.
+	// Email keeps what the user typed , the legacy sha512 password hashes include it.
+	EmailCanonical string `gorm:"uniqueIndex;size:255;not null"`
+	// DeletedEmailCanonical keeps EmailCanonical of a deleted user for RestoreAccount ,
+	// EmailCanonical itself is replaced so the email can be registered again
+	DeletedEmailCanonical *string `gorm:"index;size:255"`
+
+	EmailVerifiedAt         *time.Time
+	EmailVerificationSentAt *time.Time
+}
+
+// IsEmailVerified reports whether the user confirmed the email
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// UsableStatus is the status the account returns to when it is enabled , unlocked or restored ,
+// pending_verification until the email is verified
+func (u *User) UsableStatus() UserStatus {
+	if u.IsEmailVerified() {
+		return UserStatusActive
+	}
+	return UserStatusPendingVerification
 }
 
-func (u *User) HashPassword() {
+// HashPassword replaces the plain text password with its argon2id hash
+func (u *User) HashPassword() error {
+	hashed, err := utils.HashPassword(u.Password)
+	if err != nil {
+		return err
+	}
+
+	u.Password = hashed
+
+	return nil
+}
+
+// CheckPassword checks a plain text password against the stored hash.
+// needsRehash is true when the stored hash is a legacy sha512 hash or uses outdated parameters.
+func (u *User) CheckPassword(password string) (match bool, needsRehash bool) {
+	if isLegacyPasswordHash(u.Password) {
+		legacy := legacyHashPassword(password, u.Email)
+		return subtle.ConstantTimeCompare([]byte(legacy), []byte(u.Password)) == 1, true
+	}
+
+	match, needsRehash, err := utils.VerifyPassword(u.Password, password)
+	if err != nil {
+		return false, false
+	}
+
+	return match, needsRehash
+}
+
+// legacyHashPassword is the unsalted sha512(password + email) hash used before argon2id
+func legacyHashPassword(password string, email string) string {
+	passwordBytes := sha512.Sum512([]byte(password + email))
+	return fmt.Sprintf("%x", passwordBytes)
+}
 
-	passwordBytes := sha512.Sum512([]byte(u.Password + u.Email))
-	password := fmt.Sprintf("%x", passwordBytes)
+func isLegacyPasswordHash(hash string) bool {
+	if len(hash) != sha512.Size*2 {
+		return false
+	}
 
-	u.Password = password
+	_, err := hex.DecodeString(hash)
+	return err == nil
 }
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserStatusReasonMaxLength is the column size of UserStatusChange.Reason
+const UserStatusReasonMaxLength = 255
+
+// UserStatus is the lifecycle state of an account , only active and pending_verification accounts can log in
+type UserStatus string
+
+const (
+	UserStatusActive UserStatus = "active"
+	// UserStatusPendingVerification is a new account that didn't verify the email yet ,
+	// it can log in unless AUTH_REQUIRE_EMAIL_VERIFIED is on
+	UserStatusPendingVerification UserStatus = "pending_verification"
+	// UserStatusDisabled is an account disabled by an admin , only an admin enables it again
+	UserStatusDisabled UserStatus = "disabled"
+	// UserStatusLocked is an account locked by the service , e.g. when a used refresh token comes back ,
+	// a password reset or an admin unlocks it
+	UserStatusLocked UserStatus = "locked"
+	// UserStatusDeleted is an account deleted with DeleteAccount , it can be restored within the grace period
+	UserStatusDeleted UserStatus = "deleted"
+)
+
+// userStatusTransitions are the statuses each status can change to
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPendingVerification: {UserStatusActive, UserStatusDisabled, UserStatusLocked, UserStatusDeleted},
+	UserStatusActive:              {UserStatusDisabled, UserStatusLocked, UserStatusDeleted},
+	UserStatusLocked:              {UserStatusActive, UserStatusPendingVerification, UserStatusDisabled},
+	UserStatusDisabled:            {UserStatusActive, UserStatusPendingVerification},
+	UserStatusDeleted:             {UserStatusActive, UserStatusPendingVerification},
+}
+
+// IsValid reports whether s is a known status
+func (s UserStatus) IsValid() bool {
+	_, ok := userStatusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an account in status s may change to status to
+func (s UserStatus) CanTransitionTo(to UserStatus) bool {
+	return slices.Contains(userStatusTransitions[s], to)
+}
+
+// UserStatusChange records a change of User.Status
+type UserStatusChange struct {
+	ID         uuid.UUID  `gorm:"primaryKey"`
+	UserID     uuid.UUID  `gorm:"index;not null"`
+	FromStatus UserStatus `gorm:"size:32;not null"`
+	ToStatus   UserStatus `gorm:"size:32;not null"`
+	Reason     string     `gorm:"size:255;not null"`
+	// ActorID is the user who made the change , nil for the changes made by the service itself
+	ActorID   *uuid.UUID
+	CreatedAt time.Time
+}
+
+// NewUserStatusChange returns the change of the user to status to , the user is not changed
+func NewUserStatusChange(user *User, to UserStatus, reason string, actorID *uuid.UUID) *UserStatusChange {
+	return &UserStatusChange{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		FromStatus: user.Status,
+		ToStatus:   to,
+		Reason:     reason,
+		ActorID:    actorID,
+		CreatedAt:  time.Now(),
+	}
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserStatus_CanTransitionTo(t *testing.T) {
+
+	assert.True(t, UserStatusPendingVerification.CanTransitionTo(UserStatusActive))
+	assert.True(t, UserStatusActive.CanTransitionTo(UserStatusDisabled))
+	assert.True(t, UserStatusActive.CanTransitionTo(UserStatusLocked))
+	assert.True(t, UserStatusLocked.CanTransitionTo(UserStatusActive))
+	assert.True(t, UserStatusDisabled.CanTransitionTo(UserStatusActive))
+	assert.True(t, UserStatusDeleted.CanTransitionTo(UserStatusPendingVerification))
+
+	// a disabled account stays disabled until an admin enables it
+	assert.False(t, UserStatusDisabled.CanTransitionTo(UserStatusLocked))
+	assert.False(t, UserStatusDisabled.CanTransitionTo(UserStatusDeleted))
+	assert.False(t, UserStatusActive.CanTransitionTo(UserStatusActive))
+	assert.False(t, UserStatusActive.CanTransitionTo(UserStatusPendingVerification))
+	assert.False(t, UserStatus("unknown").CanTransitionTo(UserStatusActive))
+}
+
+func TestUserStatus_IsValid(t *testing.T) {
+
+	assert.True(t, UserStatusActive.IsValid())
+	assert.True(t, UserStatusDeleted.IsValid())
+	assert.False(t, UserStatus("").IsValid())
+	assert.False(t, UserStatus("banned").IsValid())
+}
+
+func TestUser_UsableStatus(t *testing.T) {
+
+	user := &User{}
+	assert.Equal(t, UserStatusPendingVerification, user.UsableStatus())
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	assert.Equal(t, UserStatusActive, user.UsableStatus())
+}
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,7 +13,70 @@ func TestUser_HashPassword(t *testing.T) {
 		Password: "password",
 	}
 
-	user.HashPassword()
+	err := user.HashPassword()
 
-	assert.Equal(t, "b109f3bbbc244eb82441917ed06d618b9008dd09b3befd1b5e07394c706a8bb980b1d7785e5976ec049b46df5f1326af5a2ea6d103fd07c95385ffab0cacbc86", user.Password)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.Password, "$argon2id$v=19$m=19456,t=2,p=1$"))
+	assert.NotContains(t, user.Password, "password")
+
+	// same password, different salt
+	other := &User{
+		Password: "password",
+	}
+	assert.NoError(t, other.HashPassword())
+	assert.NotEqual(t, user.Password, other.Password)
+}
+
+func TestUser_CheckPassword(t *testing.T) {
+
+	user := &User{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+	assert.NoError(t, user.HashPassword())
+
+	match, needsRehash := user.CheckPassword("password")
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+
+	match, _ = user.CheckPassword("wrong_password")
+	assert.False(t, match)
+}
+
+func TestUser_CheckPassword_Legacy(t *testing.T) {
+
+	// sha512("password")
+	user := &User{
+		Password: "b109f3bbbc244eb82441917ed06d618b9008dd09b3befd1b5e07394c706a8bb980b1d7785e5976ec049b46df5f1326af5a2ea6d103fd07c95385ffab0cacbc86",
+	}
+
+	match, needsRehash := user.CheckPassword("password")
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+
+	match, _ = user.CheckPassword("wrong_password")
+	assert.False(t, match)
+}
+
+func TestUser_CheckPassword_OutdatedParams(t *testing.T) {
+
+	// argon2id m=65536,t=1,p=4 , password: "password"
+	user := &User{
+		Password: "$argon2id$v=19$m=65536,t=1,p=4$c29tZXNhbHRzb21lc2FsdA$z0z532WG3Ej2Lcmtcn3WAdfL6IfQYwUi7vPTkoozU40",
+	}
+
+	match, needsRehash := user.CheckPassword("password")
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+}
+
+func TestUser_CheckPassword_InvalidHash(t *testing.T) {
+
+	user := &User{
+		Password: "$argon2id$invalid",
+	}
+
+	match, needsRehash := user.CheckPassword("password")
+	assert.False(t, match)
+	assert.False(t, needsRehash)
 }
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserTOTP is the TOTP second factor of a user , see utils.ValidateTOTP.
+// The secret is encrypted with utils.SecretBox and the user id as additional data.
+// It is only enabled after ConfirmedAt is set , an enrollment that is not confirmed can be replaced.
+type UserTOTP struct {
+	UserID          uuid.UUID `gorm:"primaryKey"`
+	SecretEncrypted string    `gorm:"size:255;not null"`
+	ConfirmedAt     *time.Time
+	// LastUsedStep is the time step of the last accepted code , a code is only accepted once
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsConfirmed reports whether the user confirmed the enrollment with a code
+func (t *UserTOTP) IsConfirmed() bool {
+	return t.ConfirmedAt != nil
+}
+
+// RecoveryCode is a single use code that replaces the TOTP code when the authenticator is lost.
+// Only the sha256 hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	UserID    uuid.UUID `gorm:"index;not null"`
+	CodeHash  string    `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey of a user , the public key is a COSE key as sent by the authenticator
+type WebAuthnCredential struct {
+	ID              uuid.UUID `gorm:"primaryKey"`
+	UserID          uuid.UUID `gorm:"index;not null"`
+	CredentialID    []byte    `gorm:"uniqueIndex;size:255;not null"`
+	PublicKey       []byte    `gorm:"size:1024;not null"`
+	AttestationType string    `gorm:"size:32;not null"`
+	// Transports are the hints how the client reaches the authenticator , comma separated , e.g. internal,hybrid
+	Transports     string `gorm:"size:255;not null"`
+	AAGUID         []byte `gorm:"size:16"`
+	SignCount      uint32 `gorm:"not null"`
+	BackupEligible bool   `gorm:"not null"`
+	BackupState    bool   `gorm:"not null"`
+	Name           string `gorm:"size:64;not null"`
+	LastUsedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// TransportList returns Transports as a list
+func (c *WebAuthnCredential) TransportList() []string {
+	if c.Transports == "" {
+		return []string{}
+	}
+
+	return strings.Split(c.Transports, ",")
+}
+
+// WebAuthnSessionPurpose is the ceremony a WebAuthnSession belongs to
+type WebAuthnSessionPurpose string
+
+const (
+	WebAuthnSessionRegistration WebAuthnSessionPurpose = "registration"
+	WebAuthnSessionLogin        WebAuthnSessionPurpose = "login"
+)
+
+// WebAuthnSession keeps the challenge of a registration or login between the begin and the finish rpc.
+// The client only gets the session token , only its sha256 hash is stored.
+type WebAuthnSession struct {
+	ID        uuid.UUID              `gorm:"primaryKey"`
+	UserID    uuid.UUID              `gorm:"not null"` // uuid.Nil for a login , the user is known at the finish
+	Purpose   WebAuthnSessionPurpose `gorm:"size:16;not null"`
+	TokenHash string                 `gorm:"uniqueIndex;size:64;not null"`
+	Data      string                 `gorm:"type:text;not null"` // the json encoded webauthn.SessionData
+	ExpiresAt time.Time              `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// IsExpired reports whether the session is expired at now
+func (s *WebAuthnSession) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
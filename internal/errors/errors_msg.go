@@ -1,7 +1,47 @@
 package errors
 
 const (
-	ErrInternalServerError = "internal server error"
-	ErrInvalidLoginInfo    = "invalid login info"
-	ErrEmailAlreadyExists  = "email already exists"
+	ErrInternalServerError      = "internal server error"
+	ErrInvalidArgument          = "invalid argument"
+	ErrInvalidLoginInfo         = "invalid login info"
+	ErrEmailAlreadyExists       = "email already exists"
+	ErrMissingToken             = "missing token"
+	ErrInvalidToken             = "invalid token"
+	ErrInvalidRefreshToken      = "invalid refresh token"
+	ErrPermissionDenied         = "permission denied"
+	ErrInvalidUserID            = "invalid user id"
+	ErrUserNotFound             = "user not found"
+	ErrIncorrectPassword        = "incorrect password"
+	ErrSamePassword             = "new password must be different"
+	ErrInvalidResetToken        = "invalid or expired reset token"
+	ErrEmailNotVerified         = "email not verified"
+	ErrInvalidVerifyToken       = "invalid or expired verification token"
+	ErrTooManyLoginAttempts     = "too many login attempts , try again later"
+	ErrRateLimited              = "rate limit exceeded"
+	ErrServerBusy               = "server is busy , try again later"
+	ErrMFANotConfigured         = "two factor authentication is not configured"
+	ErrMFAAlreadyEnabled        = "two factor authentication already enabled"
+	ErrMFANotEnrolled           = "totp not enrolled"
+	ErrMFANotEnabled            = "two factor authentication not enabled"
+	ErrInvalidMFACode           = "invalid two factor code"
+	ErrInvalidMFAToken          = "invalid or expired mfa token"
+	ErrPasskeyNotConfigured     = "passkeys are not configured"
+	ErrRolesNotConfigured       = "roles are not configured"
+	ErrInvalidPasskeySession    = "invalid or expired passkey session"
+	ErrInvalidPasskey           = "invalid passkey"
+	ErrPasskeyAlreadyExists     = "passkey already registered"
+	ErrReauthenticationRequired = "password or a recent login required"
+	ErrInvalidPageToken         = "invalid page token"
+	ErrRoleNotFound             = "role not found"
+	ErrRoleAlreadyExists        = "role already exists"
+	ErrPermissionNotGrantable   = "only the permissions you have can be granted"
+	ErrAccountDisabled          = "account disabled"
+	ErrAccountLocked            = "account locked , reset the password to unlock it"
+	ErrAccountDeleted           = "account deleted , it can be restored with RestoreAccount"
+	ErrAccountStatusChanged     = "account status changed , try again"
+	ErrAccountAlreadyDisabled   = "account already disabled"
+	ErrAccountNotDisabled       = "account not disabled"
+	ErrAccountNotLocked         = "account not locked"
+	ErrCannotDisableSelf        = "you can't disable your own account"
+	ErrInvalidStatusTransition  = "the account status doesn't allow this change"
 )
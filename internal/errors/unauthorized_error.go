@@ -12,4 +12,5 @@ func (e *UnauthorizedError) Error() string {
 var (
 	Err401Unauthorized = UnauthorizedError{Msg: "invalid token"}
 	Err401TokenExpired = UnauthorizedError{Msg: "token expired"}
+	Err401TokenRevoked = UnauthorizedError{Msg: "token revoked"}
 )
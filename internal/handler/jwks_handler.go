@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const JwksPath = "/.well-known/jwks.json"
+
+type jwksHandler struct {
+	keyRing *utils.KeyRing
+}
+
+// NewJwksHandler serves the public keys of the key ring as a JSON Web Key Set
+func NewJwksHandler(keyRing *utils.KeyRing) http.Handler {
+	return &jwksHandler{
+		keyRing: keyRing,
+	}
+}
+
+func (h *jwksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := json.Marshal(utils.NewJWKS(h.keyRing.VerificationKeys()...))
+	if err != nil {
+		log.Error().Err(err).Msg("marshal jwks error")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,188 @@
+package infra
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+type jwtKeyConfig struct {
+	Kid            string `mapstructure:"kid"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	SecretKey      string `mapstructure:"secret_key"`
+	RetireAfter    string `mapstructure:"retire_after"`
+}
+
+// InitKeyRing loads the jwt keys , JWT_KEY_ID is the kid of the key that signs new tokens.
+// The keys come from the first one that is set:
+//   - JWT_KEY_DIR: a directory of <kid>.pem private or public keys , a key is retired by removing its file.
+//     Without JWT_KEY_ID the newest private key signs.
+//   - JWT_KEYS: a list of keys with kid , private_key_file or secret_key , and an optional RFC 3339 retire_after.
+//     Without JWT_KEY_ID the first key signs.
+//   - JWT_PRIVATE_KEY_FILE: a single PEM encoded RSA , ECDSA or Ed25519 private key.
+//   - JWT_SECRET_KEY: a single HS512 secret.
+func InitKeyRing() (*utils.KeyRing, error) {
+	current, verifyKeys, err := loadJwtKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.NewKeyRing(current, verifyKeys...)
+}
+
+// ReloadKeyRing loads the jwt keys again and replaces the keys of ring
+func ReloadKeyRing(ring *utils.KeyRing) error {
+	current, verifyKeys, err := loadJwtKeys()
+	if err != nil {
+		return err
+	}
+
+	return ring.Replace(current, verifyKeys...)
+}
+
+func loadJwtKeys() (current *utils.SigningKey, verifyKeys []*utils.SigningKey, err error) {
+
+	currentKid := viper.GetString("JWT_KEY_ID")
+
+	if keyDir := viper.GetString("JWT_KEY_DIR"); keyDir != "" {
+		return loadJwtKeyDir(keyDir, currentKid)
+	}
+
+	var keyConfigs []jwtKeyConfig
+	if err = viper.UnmarshalKey("JWT_KEYS", &keyConfigs); err != nil {
+		return nil, nil, err
+	}
+	if len(keyConfigs) > 0 {
+		return loadJwtKeyConfigs(keyConfigs, currentKid)
+	}
+
+	if privateKeyFile := viper.GetString("JWT_PRIVATE_KEY_FILE"); privateKeyFile != "" {
+		pemBytes, err := os.ReadFile(privateKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		current, err = utils.ParsePrivateKeyPEM(currentKid, pemBytes)
+		return current, nil, err
+	}
+
+	return utils.NewHMACKey(currentKid, viper.GetString("JWT_SECRET_KEY")), nil, nil
+}
+
+func loadJwtKeyDir(keyDir string, currentKid string) (current *utils.SigningKey, verifyKeys []*utils.SigningKey, err error) {
+	entries, err := os.ReadDir(keyDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var currentModTime time.Time
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+
+		pemBytes, err := os.ReadFile(filepath.Join(keyDir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key, err := utils.ParseKeyPEM(kid, pemBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("jwt key %s: %w", entry.Name(), err)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		verifyKeys = append(verifyKeys, key)
+
+		// without JWT_KEY_ID the newest private key signs
+		if currentKid == "" && key.PrivateKey != nil && info.ModTime().After(currentModTime) {
+			current = key
+			currentModTime = info.ModTime()
+		}
+		if currentKid != "" && kid == currentKid {
+			current = key
+		}
+	}
+
+	if current == nil {
+		return nil, nil, fmt.Errorf("no current jwt key %q in %s", currentKid, keyDir)
+	}
+
+	return current, withoutKey(verifyKeys, current), nil
+}
+
+func loadJwtKeyConfigs(keyConfigs []jwtKeyConfig, currentKid string) (current *utils.SigningKey, verifyKeys []*utils.SigningKey, err error) {
+	for i, keyConfig := range keyConfigs {
+		key, err := loadJwtKeyConfig(keyConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("jwt key %q: %w", keyConfig.Kid, err)
+		}
+
+		verifyKeys = append(verifyKeys, key)
+
+		if (currentKid == "" && i == 0) || (currentKid != "" && key.ID == currentKid) {
+			current = key
+		}
+	}
+
+	if current == nil {
+		return nil, nil, fmt.Errorf("no current jwt key %q in JWT_KEYS", currentKid)
+	}
+
+	return current, withoutKey(verifyKeys, current), nil
+}
+
+func loadJwtKeyConfig(keyConfig jwtKeyConfig) (key *utils.SigningKey, err error) {
+	if keyConfig.Kid == "" {
+		return nil, fmt.Errorf("kid is required")
+	}
+
+	switch {
+	case keyConfig.PrivateKeyFile != "":
+		pemBytes, err := os.ReadFile(keyConfig.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err = utils.ParseKeyPEM(keyConfig.Kid, pemBytes)
+		if err != nil {
+			return nil, err
+		}
+	case keyConfig.SecretKey != "":
+		key = utils.NewHMACKey(keyConfig.Kid, keyConfig.SecretKey)
+	default:
+		return nil, fmt.Errorf("private_key_file or secret_key is required")
+	}
+
+	if keyConfig.RetireAfter != "" {
+		key.RetireAfter, err = time.Parse(time.RFC3339, keyConfig.RetireAfter)
+		if err != nil {
+			return nil, fmt.Errorf("retire_after: %w", err)
+		}
+	}
+
+	return key, nil
+}
+
+func withoutKey(keys []*utils.SigningKey, exclude *utils.SigningKey) []*utils.SigningKey {
+	result := make([]*utils.SigningKey, 0, len(keys))
+	for _, key := range keys {
+		if key != exclude {
+			result = append(result, key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,172 @@
+package infra
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestPrivateKey(t *testing.T, path string, privateKey any) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+}
+
+func writeTestPublicKey(t *testing.T, path string, publicKey any) {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+}
+
+func resetViper(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+}
+
+func TestInitKeyRing_SecretKey(t *testing.T) {
+	resetViper(t)
+	viper.Set("JWT_SECRET_KEY", "secret")
+
+	ring, err := InitKeyRing()
+
+	require.NoError(t, err)
+	assert.Equal(t, "HS512", ring.SigningKey().Method.Alg())
+	assert.Equal(t, "", ring.SigningKey().ID)
+}
+
+func TestInitKeyRing_KeyDir(t *testing.T) {
+	resetViper(t)
+
+	dir := t.TempDir()
+
+	oldPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeTestPublicKey(t, filepath.Join(dir, "old.pem"), oldPublicKey)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	writeTestPrivateKey(t, filepath.Join(dir, "current.pem"), ecKey)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0o600))
+
+	viper.Set("JWT_KEY_DIR", dir)
+
+	ring, err := InitKeyRing()
+
+	require.NoError(t, err)
+	assert.Equal(t, "current", ring.SigningKey().ID)
+	assert.Equal(t, "ES256", ring.SigningKey().Method.Alg())
+	assert.Len(t, ring.VerificationKeys(), 2)
+
+	// rotate: add a newer key and remove the old one , then reload
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeTestPrivateKey(t, filepath.Join(dir, "new.pem"), newKey)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "new.pem"), future, future))
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.pem")))
+
+	require.NoError(t, ReloadKeyRing(ring))
+
+	assert.Equal(t, "new", ring.SigningKey().ID)
+	_, ok := ring.VerificationKey("current")
+	assert.True(t, ok)
+	_, ok = ring.VerificationKey("old")
+	assert.False(t, ok)
+}
+
+func TestInitKeyRing_KeyDir_CurrentKid(t *testing.T) {
+	resetViper(t)
+
+	dir := t.TempDir()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	writeTestPrivateKey(t, filepath.Join(dir, "a.pem"), ecKey)
+
+	viper.Set("JWT_KEY_DIR", dir)
+	viper.Set("JWT_KEY_ID", "missing")
+
+	_, err = InitKeyRing()
+	assert.Error(t, err)
+
+	viper.Set("JWT_KEY_ID", "a")
+
+	ring, err := InitKeyRing()
+	require.NoError(t, err)
+	assert.Equal(t, "a", ring.SigningKey().ID)
+}
+
+func TestInitKeyRing_Keys(t *testing.T) {
+	resetViper(t)
+
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeTestPrivateKey(t, filepath.Join(dir, "key.pem"), edKey)
+
+	viper.Set("JWT_KEY_ID", "2025-03")
+	viper.Set("JWT_KEYS", []map[string]any{
+		{
+			"kid":          "2024-12",
+			"secret_key":   "old_secret",
+			"retire_after": "2025-03-31T00:00:00Z",
+		},
+		{
+			"kid":              "2025-03",
+			"private_key_file": filepath.Join(dir, "key.pem"),
+		},
+	})
+
+	ring, err := InitKeyRing()
+
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03", ring.SigningKey().ID)
+	assert.Equal(t, "EdDSA", ring.SigningKey().Method.Alg())
+
+	// retired on 2025-03-31
+	_, ok := ring.VerificationKey("2024-12")
+	assert.False(t, ok)
+}
+
+func TestInitKeyRing_Keys_Invalid(t *testing.T) {
+
+	tests := []struct {
+		name string
+		keys []map[string]any
+	}{
+		{name: "no kid", keys: []map[string]any{{"secret_key": "secret"}}},
+		{name: "no key", keys: []map[string]any{{"kid": "a"}}},
+		{name: "bad retire_after", keys: []map[string]any{{"kid": "a", "secret_key": "secret", "retire_after": "tomorrow"}}},
+		{name: "missing file", keys: []map[string]any{{"kid": "a", "private_key_file": "/not/exists.pem"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper(t)
+			viper.Set("JWT_KEYS", tt.keys)
+
+			_, err := InitKeyRing()
+
+			assert.Error(t, err)
+		})
+	}
+}
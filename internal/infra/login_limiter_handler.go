@@ -0,0 +1,45 @@
+package infra
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/repository"
+)
+
+// InitLoginLimiter creates the login limiter of the LOGIN_LIMIT_* settings , LOGIN_LIMIT_STORE is where the failures are counted:
+//   - mysql: the login_attempts table , shared by all instances. The default.
+//   - memory: per instance , the limits multiply with the number of instances.
+func InitLoginLimiter(db *gorm.DB) (*limiter.LoginLimiter, error) {
+	var store repository.LoginAttemptsRepository
+
+	switch driver := viper.GetString("LOGIN_LIMIT_STORE"); driver {
+	case "mysql", "":
+		store = repository.NewLoginAttemptsRepository(db)
+	case "memory":
+		store = repository.NewLoginAttemptsMemoryRepository()
+	default:
+		return nil, fmt.Errorf("unknown login limit store %q", driver)
+	}
+
+	config := limiter.LoginLimitConfig{
+		Window:             viper.GetDuration("LOGIN_LIMIT_WINDOW"),
+		Lockout:            viper.GetDuration("LOGIN_LIMIT_LOCKOUT"),
+		MaxAccountFailures: viper.GetInt("LOGIN_LIMIT_ACCOUNT_MAX_FAILURES"),
+		MaxIPFailures:      viper.GetInt("LOGIN_LIMIT_IP_MAX_FAILURES"),
+		Delay:              viper.GetDuration("LOGIN_LIMIT_DELAY"),
+		MaxDelay:           viper.GetDuration("LOGIN_LIMIT_MAX_DELAY"),
+	}
+
+	if config.Window <= 0 {
+		return nil, fmt.Errorf("LOGIN_LIMIT_WINDOW must be positive , got %s", config.Window)
+	}
+	if (config.MaxAccountFailures > 0 || config.MaxIPFailures > 0) && config.Lockout <= 0 {
+		return nil, fmt.Errorf("LOGIN_LIMIT_LOCKOUT must be positive , got %s", config.Lockout)
+	}
+
+	return limiter.NewLoginLimiter(store, config), nil
+}
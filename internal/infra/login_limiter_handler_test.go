@@ -0,0 +1,55 @@
+package infra
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitLoginLimiter(t *testing.T) {
+	resetViper(t)
+	viper.Set("LOGIN_LIMIT_STORE", "memory")
+	viper.Set("LOGIN_LIMIT_WINDOW", "15m")
+	viper.Set("LOGIN_LIMIT_LOCKOUT", "15m")
+	viper.Set("LOGIN_LIMIT_ACCOUNT_MAX_FAILURES", 5)
+
+	loginLimiter, err := InitLoginLimiter(nil)
+
+	require.NoError(t, err)
+	assert.NotNil(t, loginLimiter)
+}
+
+func TestInitLoginLimiter_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+	}{
+		{
+			name:   "unknown store",
+			config: map[string]any{"LOGIN_LIMIT_STORE": "redis", "LOGIN_LIMIT_WINDOW": "15m"},
+		},
+		{
+			name:   "no window",
+			config: map[string]any{"LOGIN_LIMIT_STORE": "memory"},
+		},
+		{
+			name:   "lock without lockout",
+			config: map[string]any{"LOGIN_LIMIT_STORE": "memory", "LOGIN_LIMIT_WINDOW": "15m", "LOGIN_LIMIT_ACCOUNT_MAX_FAILURES": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetViper(t)
+			for key, value := range tt.config {
+				viper.Set(key, value)
+			}
+
+			_, err := InitLoginLimiter(nil)
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package infra
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/itmrchow/todolist-user/internal/mailer"
+	"github.com/itmrchow/todolist-user/internal/worker"
+)
+
+// InitMailer creates the mailer of MAIL_DRIVER:
+//   - smtp: sends with MAIL_SMTP_HOST , MAIL_SMTP_PORT , MAIL_SMTP_USERNAME and MAIL_SMTP_PASSWORD.
+//   - log: writes the emails to MAIL_LOG_FILE , or stdout when it is empty. The default.
+func InitMailer() (mailer.Mailer, error) {
+	from := viper.GetString("MAIL_FROM")
+
+	switch driver := viper.GetString("MAIL_DRIVER"); driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     viper.GetString("MAIL_SMTP_HOST"),
+			Port:     viper.GetString("MAIL_SMTP_PORT"),
+			Username: viper.GetString("MAIL_SMTP_USERNAME"),
+			Password: viper.GetString("MAIL_SMTP_PASSWORD"),
+			From:     from,
+			Timeout:  viper.GetDuration("MAIL_SMTP_TIMEOUT"),
+		}), nil
+	case "log", "":
+		var w io.Writer = os.Stdout
+		if logFile := viper.GetString("MAIL_LOG_FILE"); logFile != "" {
+			file, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return nil, err
+			}
+			w = file
+		}
+		return mailer.NewLogMailer(w, from), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
+
+// InitMailWorkerPool creates the pool sending the emails after the rpc returns ,
+// MAIL_WORKERS goroutines take at most MAIL_QUEUE_SIZE waiting emails , each has MAIL_TASK_TIMEOUT to be sent.
+func InitMailWorkerPool() (*worker.Pool, error) {
+	return worker.NewPool(
+		viper.GetInt("MAIL_WORKERS"),
+		viper.GetInt("MAIL_QUEUE_SIZE"),
+		viper.GetDuration("MAIL_TASK_TIMEOUT"),
+	)
+}
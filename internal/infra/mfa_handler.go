@@ -0,0 +1,26 @@
+package infra
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// InitMFASecretBox creates the SecretBox that encrypts the TOTP secrets from MFA_ENCRYPTION_KEY ,
+// a base64 encoded 32 byte key. Without the key two factor authentication is off and nil is returned.
+func InitMFASecretBox() (*utils.SecretBox, error) {
+	encodedKey := viper.GetString("MFA_ENCRYPTION_KEY")
+	if encodedKey == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY: %w", err)
+	}
+
+	return utils.NewSecretBox(key)
+}
@@ -0,0 +1,48 @@
+package infra
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitMFASecretBox_Disabled(t *testing.T) {
+	resetViper(t)
+
+	box, err := InitMFASecretBox()
+
+	require.NoError(t, err)
+	assert.Nil(t, box)
+}
+
+func TestInitMFASecretBox(t *testing.T) {
+	resetViper(t)
+	viper.Set("MFA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+
+	box, err := InitMFASecretBox()
+
+	require.NoError(t, err)
+	require.NotNil(t, box)
+
+	sealed, err := box.Seal("secret", "user")
+	require.NoError(t, err)
+	plaintext, err := box.Open(sealed, "user")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+}
+
+func TestInitMFASecretBox_InvalidKey(t *testing.T) {
+	resetViper(t)
+
+	viper.Set("MFA_ENCRYPTION_KEY", "not base64!")
+	_, err := InitMFASecretBox()
+	assert.Error(t, err)
+
+	viper.Set("MFA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString([]byte("too short")))
+	_, err = InitMFASecretBox()
+	assert.Error(t, err)
+}
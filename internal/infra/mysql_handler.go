@@ -9,17 +9,45 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/repository/migrate"
 )
 
 func InitMysqlDb() (*gorm.DB, error) {
 
-	db, err := gorm.Open(mysql.Open(getDNS()), &gorm.Config{})
+	// TranslateError turns the driver errors of unique index violations into gorm.ErrDuplicatedKey
+	db, err := gorm.Open(mysql.Open(getDNS()), &gorm.Config{TranslateError: true})
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&entity.User{})
+	err = migrate.EmailCanonical(db, viper.GetBool("EMAIL_LOWERCASE_LOCAL_PART"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrate.UserStatus(db)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.AutoMigrate(
+		&entity.User{},
+		&entity.RefreshToken{},
+		&entity.RevokedToken{},
+		&entity.UserTokenRevocation{},
+		&entity.OneTimeToken{},
+		&entity.LoginAttempt{},
+		&entity.UserTOTP{},
+		&entity.RecoveryCode{},
+		&entity.WebAuthnCredential{},
+		&entity.WebAuthnSession{},
+		&entity.Permission{},
+		&entity.Role{},
+		&entity.RolePermission{},
+		&entity.UserRole{},
+		&entity.UserStatusChange{},
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,31 @@
+package infra
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// InitPasswordPolicy creates the password policy of PASSWORD_MIN_LENGTH , PASSWORD_MAX_LENGTH and PASSWORD_REQUIRED_CLASSES.
+// PASSWORD_BLOCKLIST_FILE is a file of passwords , one per line , blocked on top of the built in common passwords.
+func InitPasswordPolicy() (*utils.PasswordPolicy, error) {
+	var blocked []string
+
+	if blocklistFile := viper.GetString("PASSWORD_BLOCKLIST_FILE"); blocklistFile != "" {
+		data, err := os.ReadFile(blocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		blocked = strings.Split(string(data), "\n")
+	}
+
+	return utils.NewPasswordPolicy(
+		viper.GetInt("PASSWORD_MIN_LENGTH"),
+		viper.GetInt("PASSWORD_MAX_LENGTH"),
+		viper.GetStringSlice("PASSWORD_REQUIRED_CLASSES"),
+		blocked...,
+	)
+}
@@ -0,0 +1,55 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestInitPasswordPolicy_Default(t *testing.T) {
+	resetViper(t)
+
+	policy, err := InitPasswordPolicy()
+
+	require.NoError(t, err)
+	assert.Equal(t, 8, policy.MinLength)
+	assert.Equal(t, 128, policy.MaxLength)
+	assert.Empty(t, policy.RequiredClasses)
+}
+
+func TestInitPasswordPolicy_Config(t *testing.T) {
+	resetViper(t)
+
+	blocklistFile := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklistFile, []byte("Todolist2025!\nAcmeCorp2025!\n"), 0o600))
+
+	viper.Set("PASSWORD_MIN_LENGTH", 10)
+	viper.Set("PASSWORD_MAX_LENGTH", 64)
+	viper.Set("PASSWORD_REQUIRED_CLASSES", "upper digit")
+	viper.Set("PASSWORD_BLOCKLIST_FILE", blocklistFile)
+
+	policy, err := InitPasswordPolicy()
+
+	require.NoError(t, err)
+	assert.Equal(t, 10, policy.MinLength)
+	assert.Equal(t, 64, policy.MaxLength)
+	assert.Equal(t, []string{"upper", "digit"}, policy.RequiredClasses)
+	assert.ErrorIs(t, policy.Check("acmecorp2025!"), utils.ErrPasswordMissingClass)
+	assert.ErrorIs(t, policy.Check("ACMECORP2025!"), utils.ErrPasswordCommon)
+	assert.NoError(t, policy.Check("Correct horse 42"))
+}
+
+func TestInitPasswordPolicy_UnknownClass(t *testing.T) {
+	resetViper(t)
+	viper.Set("PASSWORD_REQUIRED_CLASSES", []string{"emoji"})
+
+	_, err := InitPasswordPolicy()
+
+	assert.ErrorIs(t, err, utils.ErrUnknownCharClass)
+}
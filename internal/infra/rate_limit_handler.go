@@ -0,0 +1,19 @@
+package infra
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+)
+
+// InitRateLimitInterceptor creates the rate limit interceptor of RATE_LIMITS , a list of rules with method , rate ,
+// burst and max_in_flight , and RATE_LIMIT_MAX_IN_FLIGHT , the calls in flight of the whole server.
+// The callers of the methods other than publicMethods are limited by their ip and the user of their bearer token.
+func InitRateLimitInterceptor(publicMethods []string) (*interceptor.RateLimitInterceptor, error) {
+	var rules []interceptor.RateLimitRule
+	if err := viper.UnmarshalKey("RATE_LIMITS", &rules); err != nil {
+		return nil, err
+	}
+
+	return interceptor.NewRateLimitInterceptor(rules, viper.GetInt("RATE_LIMIT_MAX_IN_FLIGHT"), publicMethods)
+}
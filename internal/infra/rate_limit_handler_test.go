@@ -0,0 +1,46 @@
+package infra
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitRateLimitInterceptor(t *testing.T) {
+	resetViper(t)
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+RATE_LIMIT_MAX_IN_FLIGHT: 100
+RATE_LIMITS:
+  - method: "*"
+    rate: 20
+    burst: 40
+  - method: /user.UserService/Login
+    rate: 0.5
+    burst: 5
+    max_in_flight: 10
+`)))
+
+	rateLimitInterceptor, err := InitRateLimitInterceptor(nil)
+
+	require.NoError(t, err)
+	assert.NotNil(t, rateLimitInterceptor)
+}
+
+func TestInitRateLimitInterceptor_Invalid(t *testing.T) {
+	resetViper(t)
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+RATE_LIMITS:
+  - method: Login
+    rate: 1
+    burst: 1
+`)))
+
+	_, err := InitRateLimitInterceptor(nil)
+
+	assert.Error(t, err)
+}
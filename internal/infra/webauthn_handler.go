@@ -0,0 +1,47 @@
+package infra
+
+import (
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/spf13/viper"
+)
+
+// InitWebAuthn creates the passkey relying party of WEBAUTHN_RP_ID , WEBAUTHN_RP_DISPLAY_NAME and WEBAUTHN_RP_ORIGINS.
+// Without WEBAUTHN_RP_ID passkeys are off and nil is returned.
+// Passkeys replace the password , so the authenticator must always verify the user , e.g. with a fingerprint or PIN.
+func InitWebAuthn() (*webauthn.WebAuthn, error) {
+	rpID := viper.GetString("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		return nil, nil
+	}
+
+	displayName := viper.GetString("WEBAUTHN_RP_DISPLAY_NAME")
+	if displayName == "" {
+		displayName = viper.GetString("SERVER_NAME")
+	}
+
+	timeout := viper.GetDuration("WEBAUTHN_TIMEOUT")
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: displayName,
+		RPOrigins:     viper.GetStringSlice("WEBAUTHN_RP_ORIGINS"),
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login: webauthn.TimeoutConfig{
+				Enforce:    true,
+				Timeout:    timeout,
+				TimeoutUVD: timeout,
+			},
+			Registration: webauthn.TimeoutConfig{
+				Enforce:    true,
+				Timeout:    timeout,
+				TimeoutUVD: timeout,
+			},
+		},
+	})
+}
@@ -0,0 +1,47 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitWebAuthn_Disabled(t *testing.T) {
+	resetViper(t)
+
+	w, err := InitWebAuthn()
+
+	require.NoError(t, err)
+	assert.Nil(t, w)
+}
+
+func TestInitWebAuthn(t *testing.T) {
+	resetViper(t)
+	viper.Set("SERVER_NAME", "todolist-user")
+	viper.Set("WEBAUTHN_RP_ID", "todolist.example.com")
+	viper.Set("WEBAUTHN_RP_ORIGINS", "https://todolist.example.com https://app.todolist.example.com")
+	viper.Set("WEBAUTHN_TIMEOUT", "2m")
+
+	w, err := InitWebAuthn()
+
+	require.NoError(t, err)
+	assert.Equal(t, "todolist.example.com", w.Config.RPID)
+	assert.Equal(t, "todolist-user", w.Config.RPDisplayName)
+	assert.Equal(t, []string{"https://todolist.example.com", "https://app.todolist.example.com"}, w.Config.RPOrigins)
+	assert.Equal(t, protocol.VerificationRequired, w.Config.AuthenticatorSelection.UserVerification)
+	assert.Equal(t, 2*time.Minute, w.Config.Timeouts.Login.Timeout)
+	assert.True(t, w.Config.Timeouts.Registration.Enforce)
+}
+
+func TestInitWebAuthn_MissingOrigins(t *testing.T) {
+	resetViper(t)
+	viper.Set("WEBAUTHN_RP_ID", "todolist.example.com")
+
+	_, err := InitWebAuthn()
+
+	assert.Error(t, err)
+}
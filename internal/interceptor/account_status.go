@@ -0,0 +1,25 @@
+package interceptor
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+)
+
+// AccountStatusError returns the error of an account that can't be used in its status , nil for active and
+// pending_verification accounts , whether those may log in without a verified email is up to the caller.
+// Each status has its own code , so a client can tell the user what happened.
+func AccountStatusError(accountStatus entity.UserStatus) error {
+	switch accountStatus {
+	case entity.UserStatusDisabled:
+		return status.Error(codes.PermissionDenied, mErr.ErrAccountDisabled)
+	case entity.UserStatusLocked:
+		return status.Error(codes.Unauthenticated, mErr.ErrAccountLocked)
+	case entity.UserStatusDeleted:
+		return status.Error(codes.NotFound, mErr.ErrAccountDeleted)
+	}
+
+	return nil
+}
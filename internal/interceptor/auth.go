@@ -0,0 +1,189 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+// AuthInterceptor validates the bearer token of every rpc except the public methods ,
+// a revoked token and the token of a disabled , locked or deleted account are rejected
+type AuthInterceptor struct {
+	keys             utils.KeyStore
+	issuer           string
+	revokedTokenRepo repository.RevokedTokensRepository
+	userRepo         repository.UsersRepository
+	publicMethods    map[string]struct{}
+}
+
+// NewAuthInterceptor creates an AuthInterceptor.
+// publicMethods are full method names , e.g. "/user.UserService/Login".
+// The account status is not checked when userRepo is nil.
+func NewAuthInterceptor(
+	keys utils.KeyStore,
+	issuer string,
+	revokedTokenRepo repository.RevokedTokensRepository,
+	userRepo repository.UsersRepository,
+	publicMethods []string,
+) *AuthInterceptor {
+	methods := make(map[string]struct{}, len(publicMethods))
+	for _, method := range publicMethods {
+		methods[method] = struct{}{}
+	}
+
+	return &AuthInterceptor{
+		keys:             keys,
+		issuer:           issuer,
+		revokedTokenRepo: revokedTokenRepo,
+		userRepo:         userRepo,
+		publicMethods:    methods,
+	}
+}
+
+func (a *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (a *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *AuthInterceptor) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	if _, ok := a.publicMethods[fullMethod]; ok {
+		return ctx, nil
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := utils.ParseToken(token, a.keys, a.issuer)
+	if err != nil {
+		return nil, unauthenticatedError(err)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidToken)
+	}
+
+	if err = a.checkRevoked(ctx, claims, userID); err != nil {
+		return nil, err
+	}
+
+	if err = a.checkAccountStatus(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return ContextWithClaims(ctx, claims), nil
+}
+
+func (a *AuthInterceptor) checkRevoked(ctx context.Context, claims *utils.Claims, userID uuid.UUID) error {
+	revoked, err := a.revokedTokenRepo.IsRevoked(ctx, claims.ID, userID, claims.IssuedAt.Time)
+	if err != nil {
+		log.Error().Err(err).Msg("IsRevoked error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if revoked {
+		return status.Error(codes.Unauthenticated, mErr.Err401TokenRevoked.Msg)
+	}
+
+	return nil
+}
+
+// checkAccountStatus rejects the token when the account was disabled , locked or deleted after it was issued.
+// Those changes revoke the tokens too , the status check keeps the token of a concurrent login out.
+func (a *AuthInterceptor) checkAccountStatus(ctx context.Context, userID uuid.UUID) error {
+	if a.userRepo == nil {
+		return nil
+	}
+
+	accountStatus, err := a.userRepo.GetStatus(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return AccountStatusError(entity.UserStatusDeleted)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("GetStatus error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return AccountStatusError(accountStatus)
+}
+
+// bearerToken reads the token from the "authorization: Bearer <token>" metadata
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	value := values[0]
+	if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return "", status.Error(codes.Unauthenticated, mErr.ErrInvalidToken)
+	}
+
+	token := strings.TrimSpace(value[len(bearerPrefix):])
+	if token == "" {
+		return "", status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	return token, nil
+}
+
+func unauthenticatedError(err error) error {
+	var unauthorizedErr *mErr.UnauthorizedError
+	if errors.As(err, &unauthorizedErr) {
+		return status.Error(codes.Unauthenticated, unauthorizedErr.Msg)
+	}
+
+	return status.Error(codes.Unauthenticated, mErr.ErrInvalidToken)
+}
+
+// wrappedServerStream replaces the context of a grpc.ServerStream
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedServerStream) Context() context.Context {
+	return w.ctx
+}
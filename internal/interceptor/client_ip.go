@@ -0,0 +1,118 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const forwardedForHeader = "x-forwarded-for"
+
+// ClientIPInterceptor puts the ip of the client in the context of every rpc , see ClientIPFromContext.
+// The ip is the address of the peer , x-forwarded-for is only used when the peer is a trusted proxy ,
+// otherwise any client could pick its own ip.
+type ClientIPInterceptor struct {
+	trustedProxies []netip.Prefix
+}
+
+// NewClientIPInterceptor creates a ClientIPInterceptor.
+// trustedProxies are ips or CIDRs of the load balancers in front of the server , e.g. "10.0.0.0/8"
+func NewClientIPInterceptor(trustedProxies []string) (*ClientIPInterceptor, error) {
+	prefixes := make([]netip.Prefix, 0, len(trustedProxies))
+
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return &ClientIPInterceptor{
+		trustedProxies: prefixes,
+	}, nil
+}
+
+func (c *ClientIPInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if ip := c.clientIP(ctx); ip != "" {
+			ctx = ContextWithClientIP(ctx, ip)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (c *ClientIPInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ip := c.clientIP(ss.Context())
+		if ip == "" {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ContextWithClientIP(ss.Context(), ip)})
+	}
+}
+
+// clientIP returns the ip of the peer , or the rightmost x-forwarded-for entry that is not a trusted proxy
+// when the peer is one. It is empty when the peer has no ip , e.g. a unix socket.
+func (c *ClientIPInterceptor) clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	addrPort, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+
+	ip := addrPort.Addr().Unmap()
+	if !c.isTrusted(ip) {
+		return ip.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := strings.Split(strings.Join(md.Get(forwardedForHeader), ","), ",")
+
+	// each proxy appends the address it received the request from , so the entries on the right are the most reliable
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+
+		ip = addr.Unmap()
+		if !c.isTrusted(ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
+
+func (c *ClientIPInterceptor) isTrusted(ip netip.Addr) bool {
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
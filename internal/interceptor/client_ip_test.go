@@ -0,0 +1,137 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func contextWithPeer(addr net.Addr, forwardedFor ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	if len(forwardedFor) > 0 {
+		md := metadata.MD{}
+		md.Append(forwardedForHeader, forwardedFor...)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	return ctx
+}
+
+func tcpAddr(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 51234}
+}
+
+func TestClientIPInterceptor_Unary(t *testing.T) {
+	c, err := NewClientIPInterceptor([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{
+			name: "direct client",
+			ctx:  contextWithPeer(tcpAddr("203.0.113.7")),
+			want: "203.0.113.7",
+		},
+		{
+			name: "spoofed x-forwarded-for of an untrusted peer",
+			ctx:  contextWithPeer(tcpAddr("203.0.113.7"), "198.51.100.1"),
+			want: "203.0.113.7",
+		},
+		{
+			name: "trusted proxy",
+			ctx:  contextWithPeer(tcpAddr("10.1.2.3"), "198.51.100.1"),
+			want: "198.51.100.1",
+		},
+		{
+			name: "chain of trusted proxies , the client prepended a fake entry",
+			ctx:  contextWithPeer(tcpAddr("10.1.2.3"), "1.1.1.1, 198.51.100.1, 192.168.1.1"),
+			want: "198.51.100.1",
+		},
+		{
+			name: "several x-forwarded-for headers",
+			ctx:  contextWithPeer(tcpAddr("10.1.2.3"), "1.1.1.1", "198.51.100.1"),
+			want: "198.51.100.1",
+		},
+		{
+			name: "trusted proxy without x-forwarded-for",
+			ctx:  contextWithPeer(tcpAddr("10.1.2.3")),
+			want: "10.1.2.3",
+		},
+		{
+			name: "invalid entry stops the walk",
+			ctx:  contextWithPeer(tcpAddr("10.1.2.3"), "198.51.100.1, unknown, 10.0.0.2"),
+			want: "10.0.0.2",
+		},
+		{
+			name: "ipv4 mapped ipv6 peer",
+			ctx:  contextWithPeer(tcpAddr("::ffff:203.0.113.7")),
+			want: "203.0.113.7",
+		},
+		{
+			name: "ipv6 peer",
+			ctx:  contextWithPeer(tcpAddr("2001:db8::1")),
+			want: "2001:db8::1",
+		},
+		{
+			name: "unix socket",
+			ctx:  contextWithPeer(&net.UnixAddr{Name: "/tmp/grpc.sock", Net: "unix"}),
+			want: "",
+		},
+		{
+			name: "no peer",
+			ctx:  context.Background(),
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := func(ctx context.Context, req any) (any, error) {
+				got, _ = ClientIPFromContext(ctx)
+				return "ok", nil
+			}
+
+			resp, err := c.Unary()(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: testMethod}, handler)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "ok", resp)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClientIPInterceptor_Stream(t *testing.T) {
+	c, err := NewClientIPInterceptor(nil)
+	require.NoError(t, err)
+
+	called := false
+	handler := func(srv any, stream grpc.ServerStream) error {
+		called = true
+		ip, ok := ClientIPFromContext(stream.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "203.0.113.7", ip)
+		return nil
+	}
+
+	stream := &mockServerStream{ctx: contextWithPeer(tcpAddr("203.0.113.7"))}
+	err = c.Stream()(nil, stream, &grpc.StreamServerInfo{FullMethod: testMethod}, handler)
+
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestNewClientIPInterceptor_Invalid(t *testing.T) {
+	for _, proxy := range []string{"10.0.0.0/33", "proxy.local", ""} {
+		_, err := NewClientIPInterceptor([]string{proxy})
+		assert.Error(t, err, proxy)
+	}
+}
@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"context"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+type (
+	claimsKey   struct{}
+	clientIPKey struct{}
+)
+
+// ContextWithClaims returns a copy of ctx that carries the claims of the validated token
+func ContextWithClaims(ctx context.Context, claims *utils.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ContextWithUserID returns a copy of ctx that carries the authenticated user id
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return ContextWithClaims(ctx, &utils.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID}})
+}
+
+// ClaimsFromContext returns the claims of the token validated by the auth interceptor
+func ClaimsFromContext(ctx context.Context) (claims *utils.Claims, ok bool) {
+	claims, ok = ctx.Value(claimsKey{}).(*utils.Claims)
+	return claims, ok && claims != nil
+}
+
+// UserIDFromContext returns the authenticated user id put in ctx by the auth interceptor
+func UserIDFromContext(ctx context.Context) (userID string, ok bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.Subject == "" {
+		return "", false
+	}
+
+	return claims.Subject, true
+}
+
+// ContextWithClientIP returns a copy of ctx that carries the ip of the client
+func ContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the client ip put in ctx by the client ip interceptor
+func ClientIPFromContext(ctx context.Context) (ip string, ok bool) {
+	ip, ok = ctx.Value(clientIPKey{}).(string)
+	return ip, ok && ip != ""
+}
@@ -0,0 +1,78 @@
+package interceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+)
+
+// PermissionInterceptor rejects the calls of a method when the token lacks the permission the method requires ,
+// the methods without a permission are only authenticated. The admin users have every permission ,
+// like in the checks of the service , also with a token issued before they became admins.
+// It must come after the auth interceptor , the permissions are read from the token.
+type PermissionInterceptor struct {
+	methodPermissions map[string]string
+	adminUserIDs      map[string]struct{}
+}
+
+// NewPermissionInterceptor creates a PermissionInterceptor.
+// methodPermissions maps full method names , e.g. "/user.UserService/ListUsers" , to their permission.
+// adminUserIDs are the users of AUTH_ADMIN_USER_IDS.
+func NewPermissionInterceptor(methodPermissions map[string]string, adminUserIDs []string) *PermissionInterceptor {
+	admins := make(map[string]struct{}, len(adminUserIDs))
+	for _, id := range adminUserIDs {
+		admins[id] = struct{}{}
+	}
+
+	return &PermissionInterceptor{
+		methodPermissions: methodPermissions,
+		adminUserIDs:      admins,
+	}
+}
+
+func (p *PermissionInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := p.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (p *PermissionInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func (p *PermissionInterceptor) authorize(ctx context.Context, fullMethod string) error {
+	permission, ok := p.methodPermissions[fullMethod]
+	if !ok {
+		return nil
+	}
+
+	// a public method with a permission is a configuration error , it is refused too
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	if _, ok := p.adminUserIDs[claims.Subject]; ok {
+		return nil
+	}
+
+	if !claims.HasPermission(permission) {
+		return status.Error(codes.PermissionDenied, mErr.ErrPermissionDenied)
+	}
+
+	return nil
+}
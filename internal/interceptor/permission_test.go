@@ -0,0 +1,109 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const (
+	testAdminMethod     = "/user.UserService/ListUsers"
+	testAdminPermission = "users:read"
+	testAdminUserID     = "4c8a1a8e-3d1f-4a8e-9d3b-6b1f3e7a2c10"
+)
+
+func newTestPermissionInterceptor() *PermissionInterceptor {
+	return NewPermissionInterceptor(map[string]string{testAdminMethod: testAdminPermission}, []string{testAdminUserID})
+}
+
+func contextWithPermissions(permissions ...string) context.Context {
+	return contextWithUserPermissions(testUserID.String(), permissions...)
+}
+
+func contextWithUserPermissions(userID string, permissions ...string) context.Context {
+	return ContextWithClaims(context.Background(), &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID},
+		Permissions:      permissions,
+	})
+}
+
+func TestPermissionInterceptor_Unary(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		wantCode codes.Code
+	}{
+		{
+			name:     "method without permission",
+			ctx:      contextWithPermissions(),
+			method:   testMethod,
+			wantCode: codes.OK,
+		},
+		{
+			name:     "no claims",
+			ctx:      context.Background(),
+			method:   testAdminMethod,
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "missing permission",
+			ctx:      contextWithPermissions("roles:read"),
+			method:   testAdminMethod,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "granted",
+			ctx:      contextWithPermissions("roles:read", testAdminPermission),
+			method:   testAdminMethod,
+			wantCode: codes.OK,
+		},
+		{
+			name:     "admin user without the permission in the token",
+			ctx:      contextWithUserPermissions(testAdminUserID),
+			method:   testAdminMethod,
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := func(ctx context.Context, req any) (any, error) {
+				called = true
+				return "ok", nil
+			}
+
+			_, err := newTestPermissionInterceptor().Unary()(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCode == codes.OK, called)
+		})
+	}
+}
+
+func TestPermissionInterceptor_Stream(t *testing.T) {
+	called := false
+	handler := func(srv any, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+
+	interceptor := newTestPermissionInterceptor().Stream()
+	info := &grpc.StreamServerInfo{FullMethod: testAdminMethod}
+
+	err := interceptor(nil, &mockServerStream{ctx: contextWithPermissions()}, info, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.False(t, called)
+
+	err = interceptor(nil, &mockServerStream{ctx: contextWithPermissions(testAdminPermission)}, info, handler)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
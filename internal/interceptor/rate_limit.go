@@ -0,0 +1,205 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+)
+
+const (
+	// DefaultRateLimitMethod is the method of the rule for the methods without their own rule
+	DefaultRateLimitMethod = "*"
+
+	// busyRetryDelay is the retry hint when there are too many calls in flight , they usually end within it
+	busyRetryDelay = time.Second
+)
+
+// RateLimitRule limits a method , or every method without its own rule when Method is "*"
+type RateLimitRule struct {
+	// Method is the full method name , e.g. "/user.UserService/Login"
+	Method string `mapstructure:"method"`
+	// Rate is the number of calls per second of each caller , 0 is unlimited
+	Rate float64 `mapstructure:"rate"`
+	// Burst is the number of calls a caller can make at once , at least 1 when Rate is set
+	Burst int `mapstructure:"burst"`
+	// MaxInFlight is the number of calls of the method running at the same time over all callers , 0 is unlimited
+	MaxInFlight int `mapstructure:"max_in_flight"`
+}
+
+// RateLimitInterceptor rejects the calls over their limits with ResourceExhausted and RetryInfo:
+//   - a token bucket per method and caller , the caller is the client ip and , except for the public methods ,
+//     the user of the bearer token. The methods without their own rule share the buckets of the "*" rule.
+//   - a max number of calls in flight per method and for the whole server.
+//
+// It must come after the client ip interceptor and before the auth interceptor ,
+// so the calls over the limits are rejected before the revocation and account status are looked up.
+type RateLimitInterceptor struct {
+	methods       map[string]*methodLimits
+	defaultLimits *methodLimits
+	inFlight      *limiter.ConcurrencyLimiter
+	publicMethods map[string]struct{}
+}
+
+type methodLimits struct {
+	rate     *limiter.RateLimiter
+	inFlight *limiter.ConcurrencyLimiter
+}
+
+// NewRateLimitInterceptor creates a RateLimitInterceptor , maxInFlight limits the calls of the whole server , 0 is unlimited.
+// publicMethods are the full method names the auth interceptor lets through without a token , their callers are keyed by the client ip only.
+func NewRateLimitInterceptor(rules []RateLimitRule, maxInFlight int, publicMethods []string) (*RateLimitInterceptor, error) {
+	r := &RateLimitInterceptor{
+		methods:       make(map[string]*methodLimits, len(rules)),
+		publicMethods: make(map[string]struct{}, len(publicMethods)),
+	}
+	for _, method := range publicMethods {
+		r.publicMethods[method] = struct{}{}
+	}
+
+	if maxInFlight < 0 {
+		return nil, fmt.Errorf("rate limit: negative max in flight %d", maxInFlight)
+	}
+	if maxInFlight > 0 {
+		r.inFlight = limiter.NewConcurrencyLimiter(maxInFlight)
+	}
+
+	for _, rule := range rules {
+		if rule.Method != DefaultRateLimitMethod && !strings.HasPrefix(rule.Method, "/") {
+			return nil, fmt.Errorf("rate limit: method %q is not a full method name", rule.Method)
+		}
+		if rule.Rate < 0 || rule.MaxInFlight < 0 {
+			return nil, fmt.Errorf("rate limit: negative limit for %q", rule.Method)
+		}
+		if rule.Rate > 0 && rule.Burst < 1 {
+			return nil, fmt.Errorf("rate limit: burst of %q must be at least 1", rule.Method)
+		}
+
+		limits := &methodLimits{}
+		if rule.Rate > 0 {
+			limits.rate = limiter.NewRateLimiter(rule.Rate, rule.Burst)
+		}
+		if rule.MaxInFlight > 0 {
+			limits.inFlight = limiter.NewConcurrencyLimiter(rule.MaxInFlight)
+		}
+
+		if rule.Method == DefaultRateLimitMethod {
+			if r.defaultLimits != nil {
+				return nil, fmt.Errorf("rate limit: duplicate rule for %q", rule.Method)
+			}
+			r.defaultLimits = limits
+			continue
+		}
+
+		if _, ok := r.methods[rule.Method]; ok {
+			return nil, fmt.Errorf("rate limit: duplicate rule for %q", rule.Method)
+		}
+		r.methods[rule.Method] = limits
+	}
+
+	return r, nil
+}
+
+func (r *RateLimitInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		release, err := r.admit(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return handler(ctx, req)
+	}
+}
+
+// Stream limits the start of a stream , the stream holds its in flight slots until it ends
+func (r *RateLimitInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := r.admit(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return handler(srv, ss)
+	}
+}
+
+// admit checks the limits of the call , release must be called when the call ends
+func (r *RateLimitInterceptor) admit(ctx context.Context, fullMethod string) (release func(), err error) {
+	limits, ok := r.methods[fullMethod]
+	if !ok {
+		limits = r.defaultLimits
+	}
+
+	caller := r.callerKey(ctx, fullMethod)
+
+	if limits != nil && limits.rate != nil {
+		if retryAfter, ok := limits.rate.Allow(caller); !ok {
+			log.Warn().Str("method", fullMethod).Str("caller", caller).Msg("rate limited")
+			return nil, limiter.ResourceExhausted(mErr.ErrRateLimited, retryAfter)
+		}
+	}
+
+	var acquired []*limiter.ConcurrencyLimiter
+	release = func() {
+		for _, inFlight := range acquired {
+			inFlight.Release()
+		}
+	}
+
+	for _, inFlight := range []*limiter.ConcurrencyLimiter{r.inFlight, limits.concurrency()} {
+		if inFlight == nil {
+			continue
+		}
+		if !inFlight.Acquire() {
+			release()
+			log.Warn().Str("method", fullMethod).Msg("too many calls in flight")
+			return nil, limiter.ResourceExhausted(mErr.ErrServerBusy, busyRetryDelay)
+		}
+		acquired = append(acquired, inFlight)
+	}
+
+	return release, nil
+}
+
+func (m *methodLimits) concurrency() *limiter.ConcurrencyLimiter {
+	if m == nil {
+		return nil
+	}
+	return m.inFlight
+}
+
+// callerKey is the client ip , with the user of the bearer token for the methods that need a token.
+// The token is not verified here , the auth interceptor verifies it once after the limits.
+// A forged user only gets a bucket of its own ip , and the public methods ignore the token ,
+// so a new user in every call does not get around the limits of the methods that need no token.
+func (r *RateLimitInterceptor) callerKey(ctx context.Context, fullMethod string) string {
+	key := "unknown"
+	if ip, ok := ClientIPFromContext(ctx); ok {
+		key = "ip:" + ip
+	}
+
+	if _, ok := r.publicMethods[fullMethod]; ok {
+		return key
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return key
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(token, claims); err != nil || claims.Subject == "" {
+		return key
+	}
+
+	return key + ",user:" + claims.Subject
+}
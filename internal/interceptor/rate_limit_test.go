@@ -0,0 +1,193 @@
+package interceptor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const (
+	testLoginMethod = "/user.UserService/Login"
+	testOtherMethod = "/user.UserService/GetMe"
+)
+
+func callUnary(r *RateLimitInterceptor, ctx context.Context, method string) error {
+	_, err := r.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	})
+	return err
+}
+
+// contextWithToken is a call from ip with a bearer token of the user signed by key
+func contextWithToken(t *testing.T, userID string, key *utils.SigningKey, ip string) context.Context {
+	token, err := utils.GenerateToken(userID, nil, nil, time.Now(), key, testIssuer, 1)
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	return ContextWithClientIP(ctx, ip)
+}
+
+func TestRateLimitInterceptor_Rate(t *testing.T) {
+	r, err := NewRateLimitInterceptor([]RateLimitRule{
+		{Method: DefaultRateLimitMethod, Rate: 1, Burst: 2},
+		{Method: testLoginMethod, Rate: 0.5, Burst: 1},
+	}, 0, []string{testLoginMethod})
+	require.NoError(t, err)
+
+	alice := contextWithToken(t, "alice", testKey, "203.0.113.7")
+	bob := contextWithToken(t, "bob", testKey, "203.0.113.7")
+	ip := ContextWithClientIP(context.Background(), "203.0.113.7")
+
+	// Login has its own bucket
+	assert.NoError(t, callUnary(r, ip, testLoginMethod))
+	err = callUnary(r, ip, testLoginMethod)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	retryDelay, ok := limiter.RetryDelay(err)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, retryDelay)
+
+	// the other methods share the "*" bucket of the caller
+	assert.NoError(t, callUnary(r, alice, testOtherMethod))
+	assert.NoError(t, callUnary(r, alice, testMethod))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(callUnary(r, alice, testOtherMethod)))
+
+	// other callers are not affected
+	assert.NoError(t, callUnary(r, bob, testOtherMethod))
+	assert.NoError(t, callUnary(r, ip, testOtherMethod))
+}
+
+func TestRateLimitInterceptor_PublicMethod(t *testing.T) {
+	r, err := NewRateLimitInterceptor([]RateLimitRule{{Method: DefaultRateLimitMethod, Rate: 1, Burst: 1}}, 0, []string{testLoginMethod})
+	require.NoError(t, err)
+
+	// a forged token for every call of a public method doesn't get a new bucket , the calls count for the ip
+	forged := utils.NewHMACKey("", "other_secret")
+	assert.NoError(t, callUnary(r, contextWithToken(t, "alice", forged, "203.0.113.7"), testLoginMethod))
+	err = callUnary(r, contextWithToken(t, "bob", forged, "203.0.113.7"), testLoginMethod)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the user of the token has its own bucket only with its ip
+	assert.NoError(t, callUnary(r, contextWithToken(t, "alice", testKey, "203.0.113.7"), testMethod))
+	assert.NoError(t, callUnary(r, contextWithToken(t, "alice", testKey, "198.51.100.1"), testMethod))
+	err = callUnary(r, contextWithToken(t, "alice", testKey, "203.0.113.7"), testMethod)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestRateLimitInterceptor_NoRules(t *testing.T) {
+	r, err := NewRateLimitInterceptor(nil, 0, []string{testLoginMethod})
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, callUnary(r, context.Background(), testMethod))
+	}
+}
+
+func TestRateLimitInterceptor_InFlight(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       []RateLimitRule
+		maxInFlight int
+		otherMethod bool
+	}{
+		{
+			name:        "server",
+			maxInFlight: 2,
+			otherMethod: false,
+		},
+		{
+			name:        "method",
+			rules:       []RateLimitRule{{Method: testLoginMethod, MaxInFlight: 2}},
+			otherMethod: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRateLimitInterceptor(tt.rules, tt.maxInFlight, []string{testLoginMethod})
+			require.NoError(t, err)
+
+			started := make(chan struct{})
+			unblock := make(chan struct{})
+			blocking := func(ctx context.Context, req any) (any, error) {
+				started <- struct{}{}
+				<-unblock
+				return "ok", nil
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := r.Unary()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: testLoginMethod}, blocking)
+					assert.NoError(t, err)
+				}()
+				<-started
+			}
+
+			// both slots are taken
+			err = callUnary(r, context.Background(), testLoginMethod)
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+			_, ok := limiter.RetryDelay(err)
+			assert.True(t, ok)
+
+			// a method limit doesn't block the other methods
+			err = callUnary(r, context.Background(), testOtherMethod)
+			assert.Equal(t, tt.otherMethod, err == nil)
+
+			close(unblock)
+			wg.Wait()
+
+			// the slots are released
+			assert.NoError(t, callUnary(r, context.Background(), testLoginMethod))
+		})
+	}
+}
+
+func TestRateLimitInterceptor_Stream(t *testing.T) {
+	r, err := NewRateLimitInterceptor([]RateLimitRule{{Method: testMethod, Rate: 1, Burst: 1}}, 0, []string{testLoginMethod})
+	require.NoError(t, err)
+
+	stream := &mockServerStream{ctx: contextWithToken(t, "alice", testKey, "203.0.113.7")}
+	handler := func(srv any, stream grpc.ServerStream) error {
+		return nil
+	}
+	info := &grpc.StreamServerInfo{FullMethod: testMethod}
+
+	assert.NoError(t, r.Stream()(nil, stream, info, handler))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(r.Stream()(nil, stream, info, handler)))
+}
+
+func TestNewRateLimitInterceptor_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       []RateLimitRule
+		maxInFlight int
+	}{
+		{"short method name", []RateLimitRule{{Method: "Login", Rate: 1, Burst: 1}}, 0},
+		{"no burst", []RateLimitRule{{Method: testLoginMethod, Rate: 1}}, 0},
+		{"negative rate", []RateLimitRule{{Method: testLoginMethod, Rate: -1, Burst: 1}}, 0},
+		{"negative in flight", []RateLimitRule{{Method: testLoginMethod, MaxInFlight: -1}}, 0},
+		{"duplicate method", []RateLimitRule{{Method: testLoginMethod}, {Method: testLoginMethod}}, 0},
+		{"duplicate default", []RateLimitRule{{Method: "*"}, {Method: "*"}}, 0},
+		{"negative server in flight", nil, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRateLimitInterceptor(tt.rules, tt.maxInFlight, []string{testLoginMethod})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import "sync/atomic"
+
+// ConcurrencyLimiter limits how many calls run at the same time , it never waits
+type ConcurrencyLimiter struct {
+	max      int64
+	inFlight atomic.Int64
+}
+
+// NewConcurrencyLimiter allows max calls at the same time
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		max: int64(max),
+	}
+}
+
+// Acquire takes a slot , it returns false when all slots are taken. Every successful Acquire needs a Release.
+func (c *ConcurrencyLimiter) Acquire() bool {
+	if c.inFlight.Add(1) > c.max {
+		c.inFlight.Add(-1)
+		return false
+	}
+
+	return true
+}
+
+func (c *ConcurrencyLimiter) Release() {
+	c.inFlight.Add(-1)
+}
+
+// InFlight returns the number of calls holding a slot
+func (c *ConcurrencyLimiter) InFlight() int {
+	return int(c.inFlight.Load())
+}
@@ -0,0 +1,149 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/repository"
+)
+
+// LoginLimitConfig is the brute force protection of Login
+type LoginLimitConfig struct {
+	// Window is how long a failure is counted
+	Window time.Duration
+	// Lockout is how long an email or ip stays locked
+	Lockout time.Duration
+	// MaxAccountFailures locks the email after that many failures in the window , 0 never locks
+	MaxAccountFailures int
+	// MaxIPFailures locks the client ip after that many failures in the window , 0 never locks.
+	// It should be higher than MaxAccountFailures , many users can share an ip behind a NAT.
+	MaxIPFailures int
+	// Delay is the wait after a failure of an email , doubled on every further failure up to MaxDelay , 0 disables it
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// LoginLimiter throttles the logins per email and per client ip.
+// Each failure of an email makes the next attempt wait longer , MaxAccountFailures failures lock it for Lockout.
+// The failures of unknown emails are counted too , so the limiter doesn't tell which email is registered.
+type LoginLimiter struct {
+	store  repository.LoginAttemptsRepository
+	config LoginLimitConfig
+	now    func() time.Time
+}
+
+func NewLoginLimiter(store repository.LoginAttemptsRepository, config LoginLimitConfig) *LoginLimiter {
+	return &LoginLimiter{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Check returns how long the client has to wait before it may try the email again , 0 when it may try now.
+// ip is empty when the client ip is unknown.
+func (l *LoginLimiter) Check(ctx context.Context, email string, ip string) (time.Duration, error) {
+	now := l.now()
+
+	account, err := l.store.Get(ctx, accountKey(email))
+	if err != nil {
+		return 0, err
+	}
+
+	var retryAt time.Time
+	switch {
+	case account.IsLocked(now):
+		retryAt = *account.LockedUntil
+	case account.Failures > 0 && !account.FirstFailureAt.Before(now.Add(-l.config.Window)):
+		retryAt = account.LastFailureAt.Add(l.delay(account.Failures))
+	}
+
+	if ip != "" {
+		client, err := l.store.Get(ctx, ipKey(ip))
+		if err != nil {
+			return 0, err
+		}
+		if client.IsLocked(now) && client.LockedUntil.After(retryAt) {
+			retryAt = *client.LockedUntil
+		}
+	}
+
+	if !retryAt.After(now) {
+		return 0, nil
+	}
+
+	return retryAt.Sub(now), nil
+}
+
+// Fail records a failed login of the email from the ip and locks the ones over their limit
+func (l *LoginLimiter) Fail(ctx context.Context, email string, ip string) error {
+	if err := l.fail(ctx, accountKey(email), l.config.MaxAccountFailures); err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	return l.fail(ctx, ipKey(ip), l.config.MaxIPFailures)
+}
+
+// Succeed forgets the failures of the email , the failures of the ip are kept
+func (l *LoginLimiter) Succeed(ctx context.Context, email string) error {
+	return l.store.Reset(ctx, accountKey(email))
+}
+
+// AccountAttempts returns the failed logins of the email , an empty LoginAttempt when there are none
+func (l *LoginLimiter) AccountAttempts(ctx context.Context, email string) (*entity.LoginAttempt, error) {
+	return l.store.Get(ctx, accountKey(email))
+}
+
+// Clean deletes the attempts that don't count anymore
+func (l *LoginLimiter) Clean(ctx context.Context) error {
+	return l.store.DeleteExpired(ctx, l.now().Add(-l.config.Window))
+}
+
+func (l *LoginLimiter) fail(ctx context.Context, key string, maxFailures int) error {
+	now := l.now()
+
+	failures, err := l.store.AddFailure(ctx, key, now, now.Add(-l.config.Window))
+	if err != nil {
+		return err
+	}
+
+	if maxFailures > 0 && failures >= maxFailures {
+		return l.store.Lock(ctx, key, now.Add(l.config.Lockout))
+	}
+
+	return nil
+}
+
+// delay returns the wait after the failures , Delay doubled for every failure after the first up to MaxDelay
+func (l *LoginLimiter) delay(failures int) time.Duration {
+	if l.config.Delay <= 0 || failures <= 0 {
+		return 0
+	}
+
+	delay := l.config.Delay
+	for i := 1; i < failures; i++ {
+		if l.config.MaxDelay > 0 && delay >= l.config.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+
+	if l.config.MaxDelay > 0 && delay > l.config.MaxDelay {
+		delay = l.config.MaxDelay
+	}
+
+	return delay
+}
+
+func accountKey(email string) string {
+	return "email:" + email
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,221 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itmrchow/todolist-user/internal/repository"
+)
+
+const (
+	testEmail = "bob@example.com"
+	testIP    = "203.0.113.7"
+)
+
+// newTestLoginLimiter returns a limiter with a clock the test moves forward
+func newTestLoginLimiter(config LoginLimitConfig) (*LoginLimiter, *time.Time) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	l := NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), config)
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
+
+func TestLoginLimiter_ProgressiveDelay(t *testing.T) {
+	ctx := context.Background()
+	l, now := newTestLoginLimiter(LoginLimitConfig{
+		Window:   15 * time.Minute,
+		Delay:    time.Second,
+		MaxDelay: 4 * time.Second,
+	})
+
+	wait, err := l.Check(ctx, testEmail, testIP)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// 1s , 2s , 4s , then capped at 4s
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		require.NoError(t, l.Fail(ctx, testEmail, testIP))
+
+		wait, err = l.Check(ctx, testEmail, testIP)
+		require.NoError(t, err)
+		assert.Equal(t, want, wait)
+
+		*now = now.Add(want)
+
+		wait, err = l.Check(ctx, testEmail, testIP)
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+
+	// another email is not delayed
+	wait, err = l.Check(ctx, "alice@example.com", testIP)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestLoginLimiter_AccountLockout(t *testing.T) {
+	ctx := context.Background()
+	l, now := newTestLoginLimiter(LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            10 * time.Minute,
+		MaxAccountFailures: 3,
+	})
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, l.Fail(ctx, testEmail, testIP))
+	}
+
+	wait, err := l.Check(ctx, testEmail, testIP)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+
+	// locked from every ip
+	wait, err = l.Check(ctx, testEmail, "198.51.100.1")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, wait)
+
+	*now = now.Add(10 * time.Minute)
+
+	wait, err = l.Check(ctx, testEmail, testIP)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// the count restarts after the lock
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+	wait, err = l.Check(ctx, testEmail, testIP)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestLoginLimiter_IPLockout(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLoginLimiter(LoginLimitConfig{
+		Window:        15 * time.Minute,
+		Lockout:       5 * time.Minute,
+		MaxIPFailures: 3,
+	})
+
+	// one failure for each of many emails
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		require.NoError(t, l.Fail(ctx, email, testIP))
+	}
+
+	wait, err := l.Check(ctx, "d@example.com", testIP)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, wait)
+
+	// the same email from another ip
+	wait, err = l.Check(ctx, "d@example.com", "198.51.100.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// an unknown ip is neither counted nor locked
+	require.NoError(t, l.Fail(ctx, "e@example.com", ""))
+	wait, err = l.Check(ctx, "d@example.com", "")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestLoginLimiter_Window(t *testing.T) {
+	ctx := context.Background()
+	l, now := newTestLoginLimiter(LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            10 * time.Minute,
+		MaxAccountFailures: 3,
+	})
+
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+
+	// the old failures don't count anymore
+	*now = now.Add(16 * time.Minute)
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+
+	wait, err := l.Check(ctx, testEmail, testIP)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestLoginLimiter_Succeed(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLoginLimiter(LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            10 * time.Minute,
+		MaxAccountFailures: 3,
+		MaxIPFailures:      3,
+	})
+
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+	require.NoError(t, l.Succeed(ctx, testEmail))
+
+	// the email starts over , the ip keeps its failures
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+
+	wait, err := l.Check(ctx, testEmail, "198.51.100.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, err = l.Check(ctx, "alice@example.com", testIP)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, wait)
+}
+
+func TestLoginLimiter_AccountAttempts(t *testing.T) {
+	ctx := context.Background()
+	l, now := newTestLoginLimiter(LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            10 * time.Minute,
+		MaxAccountFailures: 2,
+	})
+
+	attempts, err := l.AccountAttempts(ctx, testEmail)
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+	require.NoError(t, l.Fail(ctx, testEmail, testIP))
+
+	// locked by the second failure
+	attempts, err = l.AccountAttempts(ctx, testEmail)
+	require.NoError(t, err)
+	assert.True(t, attempts.IsLocked(*now))
+	assert.Equal(t, now.Add(10*time.Minute), *attempts.LockedUntil)
+}
+
+func TestLoginLimiter_Clean(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewLoginAttemptsMemoryRepository()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	l := NewLoginLimiter(store, LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            time.Hour,
+		MaxAccountFailures: 2,
+	})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Fail(ctx, "old@example.com", ""))
+	require.NoError(t, l.Fail(ctx, testEmail, ""))
+	require.NoError(t, l.Fail(ctx, testEmail, ""))
+
+	now = now.Add(20 * time.Minute)
+	require.NoError(t, l.Clean(ctx))
+
+	old, err := store.Get(ctx, accountKey("old@example.com"))
+	require.NoError(t, err)
+	assert.Zero(t, old.LastFailureAt)
+
+	// still locked , so it is kept
+	locked, err := store.Get(ctx, accountKey(testEmail))
+	require.NoError(t, err)
+	assert.True(t, locked.IsLocked(now))
+}
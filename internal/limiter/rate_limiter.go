@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often RateLimiter looks for idle buckets to forget
+const sweepInterval = time.Minute
+
+// RateLimiter is a token bucket per key , e.g. per caller.
+// A bucket that is full again is forgotten , so the memory only grows with the active keys.
+type RateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter allows perSecond requests per key on average and burst at once
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token of the key , it returns how long to wait for the next token when there is none
+func (r *RateLimiter) Allow(key string) (retryAfter time.Duration, ok bool) {
+	now := r.now()
+
+	r.mu.Lock()
+	r.sweep(now)
+
+	b, found := r.buckets[key]
+	if !found {
+		b = &bucket{limiter: rate.NewLimiter(r.limit, r.burst)}
+		r.buckets[key] = b
+	}
+	b.lastSeen = now
+	r.mu.Unlock()
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return 0, false
+	}
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, false
+	}
+
+	return 0, true
+}
+
+// sweep forgets the buckets that refilled since they were last used , r.mu must be held
+func (r *RateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+	r.lastSweep = now
+
+	refill := time.Duration(float64(r.burst) / float64(r.limit) * float64(time.Second))
+	for key, b := range r.buckets {
+		if now.Sub(b.lastSeen) > refill {
+			delete(r.buckets, key)
+		}
+	}
+}
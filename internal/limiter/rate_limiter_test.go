@@ -0,0 +1,72 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRateLimiter(2, 3)
+	r.now = func() time.Time { return now }
+
+	// the burst
+	for i := 0; i < 3; i++ {
+		_, ok := r.Allow("a")
+		assert.True(t, ok)
+	}
+
+	// a token every 500ms
+	retryAfter, ok := r.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// a rejected call doesn't take a token
+	retryAfter, ok = r.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// another key has its own bucket
+	_, ok = r.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	_, ok = r.Allow("a")
+	assert.True(t, ok)
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRateLimiter(0.1, 10)
+	r.now = func() time.Time { return now }
+
+	// a bucket refills in 100s
+	r.Allow("idle")
+	now = now.Add(50 * time.Second)
+	r.Allow("active")
+
+	// idle refilled its tokens , active didn't
+	now = now.Add(60 * time.Second)
+	r.Allow("other")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	assert.NotContains(t, r.buckets, "idle")
+	assert.Contains(t, r.buckets, "active")
+	assert.Contains(t, r.buckets, "other")
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	c := NewConcurrencyLimiter(2)
+
+	assert.True(t, c.Acquire())
+	assert.True(t, c.Acquire())
+	assert.False(t, c.Acquire())
+	assert.Equal(t, 2, c.InFlight())
+
+	c.Release()
+	assert.True(t, c.Acquire())
+	assert.Equal(t, 2, c.InFlight())
+}
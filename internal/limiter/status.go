@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ResourceExhausted returns a ResourceExhausted status with google.rpc.RetryInfo details ,
+// retryAfter is rounded up to the second so a client that waits that long is not rejected again
+func ResourceExhausted(message string, retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, message)
+
+	if rounded := retryAfter.Truncate(time.Second); rounded < retryAfter {
+		retryAfter = rounded + time.Second
+	}
+
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// RetryDelay returns the retry delay in the RetryInfo details of err
+func RetryDelay(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			return retryInfo.GetRetryDelay().AsDuration(), true
+		}
+	}
+
+	return 0, false
+}
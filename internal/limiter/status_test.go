@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResourceExhausted(t *testing.T) {
+	err := ResourceExhausted("slow down", 1500*time.Millisecond)
+
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "slow down", status.Convert(err).Message())
+
+	// rounded up to the second
+	delay, ok := RetryDelay(err)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, delay)
+
+	delay, ok = RetryDelay(ResourceExhausted("slow down", time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+}
+
+func TestRetryDelay_NoDetails(t *testing.T) {
+	_, ok := RetryDelay(status.Error(codes.ResourceExhausted, "slow down"))
+	assert.False(t, ok)
+
+	_, ok = RetryDelay(errors.New("other"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+var _ Mailer = &logMailer{}
+
+// logMailer writes the emails to w instead of sending them , for local development and tests
+type logMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewLogMailer(w io.Writer, from string) Mailer {
+	return &logMailer{
+		w:    w,
+		from: from,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.build(m.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err = m.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(m.w, "\r\n\r\n")
+
+	return err
+}
@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMailer_Send(t *testing.T) {
+
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf, "Todolist <noreply@example.com>")
+
+	err := m.Send(context.Background(), &Message{
+		To:      "test@example.com",
+		Subject: "重設密碼",
+		Body:    "line 1\nline 2",
+	})
+
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "From: Todolist <noreply@example.com>\r\n")
+	assert.Contains(t, out, "To: test@example.com\r\n")
+	assert.Contains(t, out, "Subject: =?utf-8?q?")
+	assert.Contains(t, out, "Content-Type: text/plain; charset=utf-8\r\n")
+	assert.True(t, strings.Contains(out, "\r\n\r\nline 1\r\nline 2"))
+}
+
+func TestMessage_Build_InvalidHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		msg  *Message
+	}{
+		{
+			name: "invalid from",
+			from: "not an address",
+			msg:  &Message{To: "test@example.com"},
+		},
+		{
+			name: "invalid to",
+			from: "noreply@example.com",
+			msg:  &Message{To: "test@example.com\r\nBcc: other@example.com"},
+		},
+		{
+			name: "subject injection",
+			from: "noreply@example.com",
+			msg:  &Message{To: "test@example.com", Subject: "hi\r\nBcc: other@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewLogMailer(&buf, tt.from).Send(context.Background(), tt.msg)
+
+			assert.ErrorIs(t, err, ErrInvalidHeader)
+			assert.Zero(t, buf.Len())
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ LoginAttemptsRepository = &loginAttemptDatabase{}
+
+type loginAttemptDatabase struct {
+	conn *gorm.DB
+}
+
+func NewLoginAttemptsRepository(conn *gorm.DB) LoginAttemptsRepository {
+	return &loginAttemptDatabase{
+		conn: conn,
+	}
+}
+
+func (d *loginAttemptDatabase) Get(ctx context.Context, key string) (*entity.LoginAttempt, error) {
+	attempt := &entity.LoginAttempt{}
+
+	err := d.conn.WithContext(ctx).Where("`key` = ?", key).First(attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &entity.LoginAttempt{Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+func (d *loginAttemptDatabase) AddFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (int, error) {
+	var failures int
+
+	err := d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the assignments run in order , failures reads the first_failure_at before it is updated
+		err := tx.Clauses(clause.OnConflict{
+			DoUpdates: []clause.Assignment{
+				{Column: clause.Column{Name: "failures"}, Value: gorm.Expr("IF(first_failure_at < ?, 1, failures + 1)", windowStart)},
+				{Column: clause.Column{Name: "first_failure_at"}, Value: gorm.Expr("IF(first_failure_at < ?, ?, first_failure_at)", windowStart, now)},
+				{Column: clause.Column{Name: "last_failure_at"}, Value: now},
+			},
+		}).Create(&entity.LoginAttempt{
+			Key:            key,
+			Failures:       1,
+			FirstFailureAt: now,
+			LastFailureAt:  now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&entity.LoginAttempt{}).Select("failures").Where("`key` = ?", key).Scan(&failures).Error
+	})
+
+	return failures, err
+}
+
+func (d *loginAttemptDatabase) Lock(ctx context.Context, key string, until time.Time) error {
+	return d.conn.WithContext(ctx).Model(&entity.LoginAttempt{}).Where("`key` = ?", key).Updates(map[string]any{
+		"failures":     0,
+		"locked_until": until,
+	}).Error
+}
+
+func (d *loginAttemptDatabase) Reset(ctx context.Context, key string) error {
+	return d.conn.WithContext(ctx).Where("`key` = ?", key).Delete(&entity.LoginAttempt{}).Error
+}
+
+func (d *loginAttemptDatabase) DeleteExpired(ctx context.Context, before time.Time) error {
+	return d.conn.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&entity.LoginAttempt{}).Error
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+// LoginAttemptsRepository counts the failed logins per key , shared by all instances when it is backed by the database
+type LoginAttemptsRepository interface {
+	// Get returns the attempts of the key , a key without failures returns an empty LoginAttempt and no error
+	Get(ctx context.Context, key string) (*entity.LoginAttempt, error)
+	// AddFailure increments the failures of the key atomically and returns the new count.
+	// The count restarts from 1 when the first failure is before windowStart.
+	AddFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (int, error)
+	// Lock blocks the key until the time and restarts its failure count
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets the failures and the lock of the key
+	Reset(ctx context.Context, key string) error
+	// DeleteExpired deletes the keys that failed last before the time and are not locked anymore
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
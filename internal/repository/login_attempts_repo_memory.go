@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ LoginAttemptsRepository = &loginAttemptMemory{}
+
+// loginAttemptMemory keeps the login attempts in memory , for tests and a single instance
+type loginAttemptMemory struct {
+	mu       sync.Mutex
+	attempts map[string]entity.LoginAttempt
+}
+
+func NewLoginAttemptsMemoryRepository() LoginAttemptsRepository {
+	return &loginAttemptMemory{
+		attempts: map[string]entity.LoginAttempt{},
+	}
+}
+
+func (m *loginAttemptMemory) Get(ctx context.Context, key string) (*entity.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return &entity.LoginAttempt{Key: key}, nil
+	}
+
+	return &attempt, nil
+}
+
+func (m *loginAttemptMemory) AddFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		attempt = entity.LoginAttempt{Key: key}
+	}
+
+	if !ok || attempt.FirstFailureAt.Before(windowStart) {
+		attempt.Failures = 1
+		attempt.FirstFailureAt = now
+	} else {
+		attempt.Failures++
+	}
+	attempt.LastFailureAt = now
+
+	m.attempts[key] = attempt
+
+	return attempt.Failures, nil
+}
+
+func (m *loginAttemptMemory) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return nil
+	}
+
+	attempt.Failures = 0
+	attempt.LockedUntil = &until
+	m.attempts[key] = attempt
+
+	return nil
+}
+
+func (m *loginAttemptMemory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
+
+func (m *loginAttemptMemory) DeleteExpired(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, attempt := range m.attempts {
+		if attempt.LastFailureAt.Before(before) && !attempt.IsLocked(before) {
+			delete(m.attempts, key)
+		}
+	}
+
+	return nil
+}
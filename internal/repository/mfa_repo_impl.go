@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ MFARepository = &mfaDatabase{}
+
+type mfaDatabase struct {
+	conn *gorm.DB
+}
+
+func NewMFARepository(conn *gorm.DB) MFARepository {
+	return &mfaDatabase{
+		conn: conn,
+	}
+}
+
+func (d *mfaDatabase) GetTOTP(ctx context.Context, userID uuid.UUID) (totp *entity.UserTOTP, err error) {
+	if err := d.conn.WithContext(ctx).Where("user_id = ?", userID).First(&totp).Error; err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (d *mfaDatabase) ListRecoveryCodes(ctx context.Context, userID uuid.UUID) (codes []*entity.RecoveryCode, err error) {
+	err = d.conn.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&codes).Error
+	return
+}
+
+func (d *mfaDatabase) CreateTOTP(ctx context.Context, totp *entity.UserTOTP) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND confirmed_at IS NULL", totp.UserID).Delete(&entity.UserTOTP{}).Error
+		if err != nil {
+			return err
+		}
+
+		// the primary key refuses the new row when a confirmed one is left
+		err = tx.Create(totp).Error
+		if isDuplicateKey(err) {
+			return ErrTOTPAlreadyEnabled
+		}
+		return err
+	})
+}
+
+func (d *mfaDatabase) ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, confirmedAt time.Time, codes []*entity.RecoveryCode) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the condition makes concurrent confirmations fail except one
+		result := tx.Model(&entity.UserTOTP{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]any{
+				"confirmed_at":   confirmedAt,
+				"last_used_step": step,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTOTPAlreadyEnabled
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(codes).Error
+	})
+}
+
+func (d *mfaDatabase) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	// the condition makes a code valid only once , also across concurrent requests
+	result := d.conn.WithContext(ctx).Model(&entity.UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTOTPCodeUsed
+	}
+
+	return nil
+}
+
+func (d *mfaDatabase) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time) error {
+	result := d.conn.WithContext(ctx).Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Limit(1).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+
+	return nil
+}
+
+func (d *mfaDatabase) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&entity.UserTOTP{}).Error
+	})
+}
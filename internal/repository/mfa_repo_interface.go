@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var (
+	// ErrTOTPAlreadyEnabled is returned when the user already has a confirmed TOTP
+	ErrTOTPAlreadyEnabled = errors.New("totp already enabled")
+	// ErrTOTPCodeUsed is returned by UseTOTPStep when a code of the step or a later one was already accepted
+	ErrTOTPCodeUsed = errors.New("totp code already used")
+	// ErrRecoveryCodeInvalid is returned by UseRecoveryCode when the code doesn't exist or was already used
+	ErrRecoveryCodeInvalid = errors.New("recovery code invalid or used")
+)
+
+type MFARepository interface {
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*entity.UserTOTP, error)
+	// ListRecoveryCodes returns the recovery codes of the user , used ones included
+	ListRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]*entity.RecoveryCode, error)
+	// CreateTOTP replaces the enrollment of the user that is not confirmed yet ,
+	// it returns ErrTOTPAlreadyEnabled when a confirmed one exists
+	CreateTOTP(ctx context.Context, totp *entity.UserTOTP) error
+	// ConfirmTOTP enables the TOTP of the user , accepts the code of step and replaces the recovery codes.
+	// It returns ErrTOTPAlreadyEnabled when the TOTP is missing or already confirmed.
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, confirmedAt time.Time, codes []*entity.RecoveryCode) error
+	// UseTOTPStep accepts a code of step , only when no code of step or a later one was accepted before
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error
+	// UseRecoveryCode marks the code as used , only when it is not used yet
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time) error
+	// DeleteTOTP disables the TOTP of the user and deletes the recovery codes
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+}
@@ -0,0 +1,401 @@
+// Code generated by mockery v2.53.2. DO NOT EDIT.
+
+package repository
+
+import (
+	context "context"
+	time "time"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+
+	entity "github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ MFARepository = &MockMFARepository{}
+
+// MockMFARepository is an autogenerated mock type for the MFARepository type
+type MockMFARepository struct {
+	mock.Mock
+}
+
+type MockMFARepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockMFARepository) EXPECT() *MockMFARepository_Expecter {
+	return &MockMFARepository_Expecter{mock: &_m.Mock}
+}
+
+// ConfirmTOTP provides a mock function with given fields: ctx, userID, step, confirmedAt, codes
+func (_m *MockMFARepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, step int64, confirmedAt time.Time, codes []*entity.RecoveryCode) error {
+	ret := _m.Called(ctx, userID, step, confirmedAt, codes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmTOTP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int64, time.Time, []*entity.RecoveryCode) error); ok {
+		r0 = rf(ctx, userID, step, confirmedAt, codes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMFARepository_ConfirmTOTP_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConfirmTOTP'
+type MockMFARepository_ConfirmTOTP_Call struct {
+	*mock.Call
+}
+
+// ConfirmTOTP is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - step int64
+//   - confirmedAt time.Time
+//   - codes []*entity.RecoveryCode
+func (_e *MockMFARepository_Expecter) ConfirmTOTP(ctx interface{}, userID interface{}, step interface{}, confirmedAt interface{}, codes interface{}) *MockMFARepository_ConfirmTOTP_Call {
+	return &MockMFARepository_ConfirmTOTP_Call{Call: _e.mock.On("ConfirmTOTP", ctx, userID, step, confirmedAt, codes)}
+}
+
+func (_c *MockMFARepository_ConfirmTOTP_Call) Run(run func(ctx context.Context, userID uuid.UUID, step int64, confirmedAt time.Time, codes []*entity.RecoveryCode)) *MockMFARepository_ConfirmTOTP_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(int64), args[3].(time.Time), args[4].([]*entity.RecoveryCode))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_ConfirmTOTP_Call) Return(_a0 error) *MockMFARepository_ConfirmTOTP_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMFARepository_ConfirmTOTP_Call) RunAndReturn(run func(context.Context, uuid.UUID, int64, time.Time, []*entity.RecoveryCode) error) *MockMFARepository_ConfirmTOTP_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateTOTP provides a mock function with given fields: ctx, totp
+func (_m *MockMFARepository) CreateTOTP(ctx context.Context, totp *entity.UserTOTP) error {
+	ret := _m.Called(ctx, totp)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateTOTP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.UserTOTP) error); ok {
+		r0 = rf(ctx, totp)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMFARepository_CreateTOTP_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateTOTP'
+type MockMFARepository_CreateTOTP_Call struct {
+	*mock.Call
+}
+
+// CreateTOTP is a helper method to define mock.On call
+//   - ctx context.Context
+//   - totp *entity.UserTOTP
+func (_e *MockMFARepository_Expecter) CreateTOTP(ctx interface{}, totp interface{}) *MockMFARepository_CreateTOTP_Call {
+	return &MockMFARepository_CreateTOTP_Call{Call: _e.mock.On("CreateTOTP", ctx, totp)}
+}
+
+func (_c *MockMFARepository_CreateTOTP_Call) Run(run func(ctx context.Context, totp *entity.UserTOTP)) *MockMFARepository_CreateTOTP_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.UserTOTP))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_CreateTOTP_Call) Return(_a0 error) *MockMFARepository_CreateTOTP_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMFARepository_CreateTOTP_Call) RunAndReturn(run func(context.Context, *entity.UserTOTP) error) *MockMFARepository_CreateTOTP_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteTOTP provides a mock function with given fields: ctx, userID
+func (_m *MockMFARepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteTOTP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMFARepository_DeleteTOTP_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteTOTP'
+type MockMFARepository_DeleteTOTP_Call struct {
+	*mock.Call
+}
+
+// DeleteTOTP is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+func (_e *MockMFARepository_Expecter) DeleteTOTP(ctx interface{}, userID interface{}) *MockMFARepository_DeleteTOTP_Call {
+	return &MockMFARepository_DeleteTOTP_Call{Call: _e.mock.On("DeleteTOTP", ctx, userID)}
+}
+
+func (_c *MockMFARepository_DeleteTOTP_Call) Run(run func(ctx context.Context, userID uuid.UUID)) *MockMFARepository_DeleteTOTP_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_DeleteTOTP_Call) Return(_a0 error) *MockMFARepository_DeleteTOTP_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMFARepository_DeleteTOTP_Call) RunAndReturn(run func(context.Context, uuid.UUID) error) *MockMFARepository_DeleteTOTP_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetTOTP provides a mock function with given fields: ctx, userID
+func (_m *MockMFARepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*entity.UserTOTP, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTOTP")
+	}
+
+	var r0 *entity.UserTOTP
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*entity.UserTOTP, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *entity.UserTOTP); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.UserTOTP)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockMFARepository_GetTOTP_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetTOTP'
+type MockMFARepository_GetTOTP_Call struct {
+	*mock.Call
+}
+
+// GetTOTP is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+func (_e *MockMFARepository_Expecter) GetTOTP(ctx interface{}, userID interface{}) *MockMFARepository_GetTOTP_Call {
+	return &MockMFARepository_GetTOTP_Call{Call: _e.mock.On("GetTOTP", ctx, userID)}
+}
+
+func (_c *MockMFARepository_GetTOTP_Call) Run(run func(ctx context.Context, userID uuid.UUID)) *MockMFARepository_GetTOTP_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_GetTOTP_Call) Return(_a0 *entity.UserTOTP, _a1 error) *MockMFARepository_GetTOTP_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockMFARepository_GetTOTP_Call) RunAndReturn(run func(context.Context, uuid.UUID) (*entity.UserTOTP, error)) *MockMFARepository_GetTOTP_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRecoveryCodes provides a mock function with given fields: ctx, userID
+func (_m *MockMFARepository) ListRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]*entity.RecoveryCode, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRecoveryCodes")
+	}
+
+	var r0 []*entity.RecoveryCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]*entity.RecoveryCode, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*entity.RecoveryCode); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.RecoveryCode)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockMFARepository_ListRecoveryCodes_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRecoveryCodes'
+type MockMFARepository_ListRecoveryCodes_Call struct {
+	*mock.Call
+}
+
+// ListRecoveryCodes is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+func (_e *MockMFARepository_Expecter) ListRecoveryCodes(ctx interface{}, userID interface{}) *MockMFARepository_ListRecoveryCodes_Call {
+	return &MockMFARepository_ListRecoveryCodes_Call{Call: _e.mock.On("ListRecoveryCodes", ctx, userID)}
+}
+
+func (_c *MockMFARepository_ListRecoveryCodes_Call) Run(run func(ctx context.Context, userID uuid.UUID)) *MockMFARepository_ListRecoveryCodes_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_ListRecoveryCodes_Call) Return(_a0 []*entity.RecoveryCode, _a1 error) *MockMFARepository_ListRecoveryCodes_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockMFARepository_ListRecoveryCodes_Call) RunAndReturn(run func(context.Context, uuid.UUID) ([]*entity.RecoveryCode, error)) *MockMFARepository_ListRecoveryCodes_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UseRecoveryCode provides a mock function with given fields: ctx, userID, codeHash, usedAt
+func (_m *MockMFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time) error {
+	ret := _m.Called(ctx, userID, codeHash, usedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseRecoveryCode")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, time.Time) error); ok {
+		r0 = rf(ctx, userID, codeHash, usedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMFARepository_UseRecoveryCode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UseRecoveryCode'
+type MockMFARepository_UseRecoveryCode_Call struct {
+	*mock.Call
+}
+
+// UseRecoveryCode is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - codeHash string
+//   - usedAt time.Time
+func (_e *MockMFARepository_Expecter) UseRecoveryCode(ctx interface{}, userID interface{}, codeHash interface{}, usedAt interface{}) *MockMFARepository_UseRecoveryCode_Call {
+	return &MockMFARepository_UseRecoveryCode_Call{Call: _e.mock.On("UseRecoveryCode", ctx, userID, codeHash, usedAt)}
+}
+
+func (_c *MockMFARepository_UseRecoveryCode_Call) Run(run func(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time)) *MockMFARepository_UseRecoveryCode_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(string), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_UseRecoveryCode_Call) Return(_a0 error) *MockMFARepository_UseRecoveryCode_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMFARepository_UseRecoveryCode_Call) RunAndReturn(run func(context.Context, uuid.UUID, string, time.Time) error) *MockMFARepository_UseRecoveryCode_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UseTOTPStep provides a mock function with given fields: ctx, userID, step
+func (_m *MockMFARepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	ret := _m.Called(ctx, userID, step)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseTOTPStep")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int64) error); ok {
+		r0 = rf(ctx, userID, step)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockMFARepository_UseTOTPStep_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UseTOTPStep'
+type MockMFARepository_UseTOTPStep_Call struct {
+	*mock.Call
+}
+
+// UseTOTPStep is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - step int64
+func (_e *MockMFARepository_Expecter) UseTOTPStep(ctx interface{}, userID interface{}, step interface{}) *MockMFARepository_UseTOTPStep_Call {
+	return &MockMFARepository_UseTOTPStep_Call{Call: _e.mock.On("UseTOTPStep", ctx, userID, step)}
+}
+
+func (_c *MockMFARepository_UseTOTPStep_Call) Run(run func(ctx context.Context, userID uuid.UUID, step int64)) *MockMFARepository_UseTOTPStep_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(int64))
+	})
+	return _c
+}
+
+func (_c *MockMFARepository_UseTOTPStep_Call) Return(_a0 error) *MockMFARepository_UseTOTPStep_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockMFARepository_UseTOTPStep_Call) RunAndReturn(run func(context.Context, uuid.UUID, int64) error) *MockMFARepository_UseTOTPStep_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockMFARepository creates a new instance of MockMFARepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockMFARepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockMFARepository {
+	mock := &MockMFARepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,175 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const emailCanonicalBatchSize = 500
+
+// emailCanonicalRuleSetting keeps the rule of the stored canonical emails
+const emailCanonicalRuleSetting = "email_canonical_rule"
+
+var ErrDuplicateEmailCanonical = errors.New("users share a canonical email")
+
+// emailCanonicalRule describes how utils.NormalizeEmail makes the canonical emails ,
+// the version has to change with NormalizeEmail so the stored values are made again
+func emailCanonicalRule(lowercaseLocalPart bool) string {
+	return fmt.Sprintf("v1 lowercase_local_part=%t", lowercaseLocalPart)
+}
+
+// EmailCanonical prepares the users table for the email_canonical column , it must run before AutoMigrate.
+// AutoMigrate can't add a not null unique column to a table with rows , so this adds the column as nullable ,
+// backfills it from email and drops the old unique index on email.
+// AutoMigrate then makes the column not null and creates the unique index.
+//
+// The rule of the stored values is recorded , when it differs , e.g. EMAIL_LOWERCASE_LOCAL_PART was changed ,
+// every user gets its canonical email made again , the deleted users their deleted_email_canonical.
+//
+// It fails when several users have the same canonical email , e.g. Bob@x.com and bob@x.com registered before ,
+// nothing is changed then and those accounts have to be merged or deleted by hand. It is safe to run again after that.
+func EmailCanonical(db *gorm.DB, lowercaseLocalPart bool) error {
+	migrator := db.Migrator()
+
+	if !migrator.HasTable(&entity.User{}) {
+		return nil
+	}
+
+	if !migrator.HasColumn(&entity.User{}, "EmailCanonical") {
+		if err := db.Exec("ALTER TABLE users ADD COLUMN email_canonical varchar(255) NULL").Error; err != nil {
+			return fmt.Errorf("add email_canonical: %w", err)
+		}
+	}
+
+	if migrator.HasIndex(&entity.User{}, "idx_users_email") {
+		if err := migrator.DropIndex(&entity.User{}, "idx_users_email"); err != nil {
+			return fmt.Errorf("drop idx_users_email: %w", err)
+		}
+	}
+
+	if err := db.AutoMigrate(&entity.MigrationSetting{}); err != nil {
+		return fmt.Errorf("migrate migration_settings: %w", err)
+	}
+
+	rule := emailCanonicalRule(lowercaseLocalPart)
+	var stored entity.MigrationSetting
+	err := db.Where("name = ?", emailCanonicalRuleSetting).Limit(1).Find(&stored).Error
+	if err != nil {
+		return fmt.Errorf("get %s: %w", emailCanonicalRuleSetting, err)
+	}
+
+	// the values of an unknown rule are made again too , they may predate the recorded rules
+	all := stored.Value != rule
+	withDeleted := migrator.HasColumn(&entity.User{}, "DeletedEmailCanonical")
+
+	// in one transaction , so a collision leaves the stored values and the recorded rule as they were
+	return db.Transaction(func(tx *gorm.DB) error {
+		count, err := backfillEmailCanonical(tx, lowercaseLocalPart, all, withDeleted)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: %w", ErrDuplicateEmailCanonical, err)
+		}
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Info().Int("users", count).Str("rule", rule).Msg("email_canonical backfilled")
+		}
+
+		if err = checkDuplicateEmailCanonical(tx); err != nil {
+			return err
+		}
+
+		return tx.Save(&entity.MigrationSetting{Name: emailCanonicalRuleSetting, Value: rule}).Error
+	})
+}
+
+// checkDuplicateEmailCanonical fails when users share a canonical email , the unique index doesn't exist
+// on the first run. Soft deleted users are included , the unique index includes them too.
+func checkDuplicateEmailCanonical(db *gorm.DB) error {
+	var duplicates []string
+	err := db.Table("users").Select("email_canonical").
+		Group("email_canonical").Having("COUNT(*) > 1").
+		Pluck("email_canonical", &duplicates).Error
+	if err != nil {
+		return fmt.Errorf("find duplicate email_canonical: %w", err)
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	for _, email := range duplicates {
+		log.Error().Str("email_canonical", email).Msg("canonical email used by more than one user")
+	}
+
+	return fmt.Errorf("%w: %d canonical emails are used by more than one user", ErrDuplicateEmailCanonical, len(duplicates))
+}
+
+// backfillEmailCanonical makes email_canonical from email , of every user when all is set ,
+// otherwise only of the users that don't have it yet. The email column is not changed.
+// A deleted user keeps its placeholder , its deleted_email_canonical is made again instead.
+func backfillEmailCanonical(db *gorm.DB, lowercaseLocalPart bool, all bool, withDeleted bool) (int, error) {
+	type userEmail struct {
+		ID                    uuid.UUID
+		Email                 string
+		EmailCanonical        *string
+		DeletedEmailCanonical *string
+	}
+
+	columns := []string{"id", "email", "email_canonical"}
+	if withDeleted {
+		columns = append(columns, "deleted_email_canonical")
+	}
+
+	total := 0
+	var lastID *uuid.UUID
+	for {
+		query := db.Table("users").Select(columns).Order("id").Limit(emailCanonicalBatchSize)
+		if !all {
+			query = query.Where("email_canonical IS NULL")
+		}
+		if lastID != nil {
+			query = query.Where("id > ?", *lastID)
+		}
+
+		var rows []userEmail
+		if err := query.Find(&rows).Error; err != nil {
+			return total, fmt.Errorf("find users for email_canonical: %w", err)
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		for _, row := range rows {
+			canonical, err := utils.NormalizeEmail(row.Email, lowercaseLocalPart)
+			if err != nil {
+				// saved before the validation , such a user can't log in anyway , the value only has to be unique
+				log.Warn().Err(err).Str("user_id", row.ID.String()).Msg("email can't be normalized")
+				canonical = strings.TrimSpace(row.Email)
+			}
+
+			column, current := "email_canonical", row.EmailCanonical
+			if row.DeletedEmailCanonical != nil {
+				column, current = "deleted_email_canonical", row.DeletedEmailCanonical
+			}
+			if current != nil && *current == canonical {
+				continue
+			}
+
+			err = db.Table("users").Where("id = ?", row.ID).Update(column, canonical).Error
+			if err != nil {
+				return total, fmt.Errorf("update %s of %s: %w", column, row.ID, err)
+			}
+			total++
+		}
+
+		lastID = &rows[len(rows)-1].ID
+	}
+}
@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+// UserStatus prepares the users table for the status column , it must run before AutoMigrate.
+// AutoMigrate would make every existing user active , so this adds the column as nullable and backfills it ,
+// the deleted users become deleted and the users that didn't verify the email become pending_verification.
+// AutoMigrate then makes the column not null. It is safe to run again.
+func UserStatus(db *gorm.DB) error {
+	migrator := db.Migrator()
+
+	if !migrator.HasTable(&entity.User{}) {
+		return nil
+	}
+
+	if !migrator.HasColumn(&entity.User{}, "Status") {
+		if err := db.Exec("ALTER TABLE users ADD COLUMN status varchar(32) NULL").Error; err != nil {
+			return fmt.Errorf("add status: %w", err)
+		}
+	}
+
+	result := db.Exec("UPDATE users SET status = CASE"+
+		" WHEN deleted_at IS NOT NULL THEN ?"+
+		" WHEN email_verified_at IS NULL THEN ?"+
+		" ELSE ? END"+
+		" WHERE status IS NULL",
+		entity.UserStatusDeleted, entity.UserStatusPendingVerification, entity.UserStatusActive)
+	if result.Error != nil {
+		return fmt.Errorf("backfill status: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Info().Int64("users", result.RowsAffected).Msg("status backfilled")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ OneTimeTokensRepository = &oneTimeTokenDatabase{}
+
+type oneTimeTokenDatabase struct {
+	conn *gorm.DB
+}
+
+func NewOneTimeTokensRepository(conn *gorm.DB) OneTimeTokensRepository {
+	return &oneTimeTokenDatabase{
+		conn: conn,
+	}
+}
+
+func (d *oneTimeTokenDatabase) Create(ctx context.Context, token *entity.OneTimeToken) error {
+	return d.conn.WithContext(ctx).Create(token).Error
+}
+
+func (d *oneTimeTokenDatabase) GetByHash(ctx context.Context, purpose entity.OneTimeTokenPurpose, tokenHash string) (token *entity.OneTimeToken, err error) {
+	if err := d.conn.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (d *oneTimeTokenDatabase) ResetPassword(ctx context.Context, token *entity.OneTimeToken, passwordHash string, usedAt time.Time) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the condition makes concurrent uses of the same token fail except one
+		result := tx.Model(&entity.OneTimeToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", usedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOneTimeTokenUsed
+		}
+
+		if err := tx.Model(&entity.User{}).Where("id = ?", token.UserID).Update("password", passwordHash).Error; err != nil {
+			return err
+		}
+
+		// the other reset links sent before are useless now
+		return tx.Model(&entity.OneTimeToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", usedAt).Error
+	})
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+// ErrOneTimeTokenUsed is returned when the token was already used
+var ErrOneTimeTokenUsed = errors.New("one time token already used")
+
+type OneTimeTokensRepository interface {
+	Create(ctx context.Context, token *entity.OneTimeToken) error
+	GetByHash(ctx context.Context, purpose entity.OneTimeTokenPurpose, tokenHash string) (*entity.OneTimeToken, error)
+	// ResetPassword marks the reset token as used and sets the password of its user in one transaction ,
+	// so a failed update doesn't burn the token. The other unused reset tokens of the user are marked as used too.
+	// It fails with ErrOneTimeTokenUsed when the token was already used.
+	ResetPassword(ctx context.Context, token *entity.OneTimeToken, passwordHash string, usedAt time.Time) error
+}
@@ -0,0 +1,197 @@
+// Code generated by mockery v2.53.2. DO NOT EDIT.
+
+package repository
+
+import (
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+
+	entity "github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ OneTimeTokensRepository = &MockOneTimeTokensRepository{}
+
+// MockOneTimeTokensRepository is an autogenerated mock type for the OneTimeTokensRepository type
+type MockOneTimeTokensRepository struct {
+	mock.Mock
+}
+
+type MockOneTimeTokensRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockOneTimeTokensRepository) EXPECT() *MockOneTimeTokensRepository_Expecter {
+	return &MockOneTimeTokensRepository_Expecter{mock: &_m.Mock}
+}
+
+// Create provides a mock function with given fields: ctx, token
+func (_m *MockOneTimeTokensRepository) Create(ctx context.Context, token *entity.OneTimeToken) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.OneTimeToken) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockOneTimeTokensRepository_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockOneTimeTokensRepository_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - ctx context.Context
+//   - token *entity.OneTimeToken
+func (_e *MockOneTimeTokensRepository_Expecter) Create(ctx interface{}, token interface{}) *MockOneTimeTokensRepository_Create_Call {
+	return &MockOneTimeTokensRepository_Create_Call{Call: _e.mock.On("Create", ctx, token)}
+}
+
+func (_c *MockOneTimeTokensRepository_Create_Call) Run(run func(ctx context.Context, token *entity.OneTimeToken)) *MockOneTimeTokensRepository_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.OneTimeToken))
+	})
+	return _c
+}
+
+func (_c *MockOneTimeTokensRepository_Create_Call) Return(_a0 error) *MockOneTimeTokensRepository_Create_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockOneTimeTokensRepository_Create_Call) RunAndReturn(run func(context.Context, *entity.OneTimeToken) error) *MockOneTimeTokensRepository_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByHash provides a mock function with given fields: ctx, purpose, tokenHash
+func (_m *MockOneTimeTokensRepository) GetByHash(ctx context.Context, purpose entity.OneTimeTokenPurpose, tokenHash string) (*entity.OneTimeToken, error) {
+	ret := _m.Called(ctx, purpose, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByHash")
+	}
+
+	var r0 *entity.OneTimeToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.OneTimeTokenPurpose, string) (*entity.OneTimeToken, error)); ok {
+		return rf(ctx, purpose, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.OneTimeTokenPurpose, string) *entity.OneTimeToken); ok {
+		r0 = rf(ctx, purpose, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.OneTimeToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.OneTimeTokenPurpose, string) error); ok {
+		r1 = rf(ctx, purpose, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockOneTimeTokensRepository_GetByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByHash'
+type MockOneTimeTokensRepository_GetByHash_Call struct {
+	*mock.Call
+}
+
+// GetByHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - purpose entity.OneTimeTokenPurpose
+//   - tokenHash string
+func (_e *MockOneTimeTokensRepository_Expecter) GetByHash(ctx interface{}, purpose interface{}, tokenHash interface{}) *MockOneTimeTokensRepository_GetByHash_Call {
+	return &MockOneTimeTokensRepository_GetByHash_Call{Call: _e.mock.On("GetByHash", ctx, purpose, tokenHash)}
+}
+
+func (_c *MockOneTimeTokensRepository_GetByHash_Call) Run(run func(ctx context.Context, purpose entity.OneTimeTokenPurpose, tokenHash string)) *MockOneTimeTokensRepository_GetByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(entity.OneTimeTokenPurpose), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockOneTimeTokensRepository_GetByHash_Call) Return(_a0 *entity.OneTimeToken, _a1 error) *MockOneTimeTokensRepository_GetByHash_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockOneTimeTokensRepository_GetByHash_Call) RunAndReturn(run func(context.Context, entity.OneTimeTokenPurpose, string) (*entity.OneTimeToken, error)) *MockOneTimeTokensRepository_GetByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ResetPassword provides a mock function with given fields: ctx, token, passwordHash, usedAt
+func (_m *MockOneTimeTokensRepository) ResetPassword(ctx context.Context, token *entity.OneTimeToken, passwordHash string, usedAt time.Time) error {
+	ret := _m.Called(ctx, token, passwordHash, usedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.OneTimeToken, string, time.Time) error); ok {
+		r0 = rf(ctx, token, passwordHash, usedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockOneTimeTokensRepository_ResetPassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ResetPassword'
+type MockOneTimeTokensRepository_ResetPassword_Call struct {
+	*mock.Call
+}
+
+// ResetPassword is a helper method to define mock.On call
+//   - ctx context.Context
+//   - token *entity.OneTimeToken
+//   - passwordHash string
+//   - usedAt time.Time
+func (_e *MockOneTimeTokensRepository_Expecter) ResetPassword(ctx interface{}, token interface{}, passwordHash interface{}, usedAt interface{}) *MockOneTimeTokensRepository_ResetPassword_Call {
+	return &MockOneTimeTokensRepository_ResetPassword_Call{Call: _e.mock.On("ResetPassword", ctx, token, passwordHash, usedAt)}
+}
+
+func (_c *MockOneTimeTokensRepository_ResetPassword_Call) Run(run func(ctx context.Context, token *entity.OneTimeToken, passwordHash string, usedAt time.Time)) *MockOneTimeTokensRepository_ResetPassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.OneTimeToken), args[2].(string), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockOneTimeTokensRepository_ResetPassword_Call) Return(_a0 error) *MockOneTimeTokensRepository_ResetPassword_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockOneTimeTokensRepository_ResetPassword_Call) RunAndReturn(run func(context.Context, *entity.OneTimeToken, string, time.Time) error) *MockOneTimeTokensRepository_ResetPassword_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockOneTimeTokensRepository creates a new instance of MockOneTimeTokensRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockOneTimeTokensRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockOneTimeTokensRepository {
+	mock := &MockOneTimeTokensRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ RefreshTokensRepository = &refreshTokenDatabase{}
+
+type refreshTokenDatabase struct {
+	conn *gorm.DB
+}
+
+func NewRefreshTokensRepository(conn *gorm.DB) RefreshTokensRepository {
+	return &refreshTokenDatabase{
+		conn: conn,
+	}
+}
+
+func (d *refreshTokenDatabase) Create(ctx context.Context, token *entity.RefreshToken) error {
+	return d.conn.WithContext(ctx).Create(token).Error
+}
+
+func (d *refreshTokenDatabase) GetByHash(ctx context.Context, tokenHash string) (token *entity.RefreshToken, err error) {
+	if err := d.conn.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (d *refreshTokenDatabase) ListByUser(ctx context.Context, userID uuid.UUID, after *entity.RefreshToken, limit int) (tokens []*entity.RefreshToken, err error) {
+	query := d.conn.WithContext(ctx).Where("user_id = ?", userID)
+
+	if after != nil {
+		query = query.Where(
+			"(family_id > ? OR (family_id = ? AND (created_at > ? OR (created_at = ? AND id > ?))))",
+			after.FamilyID, after.FamilyID, after.CreatedAt, after.CreatedAt, after.ID,
+		)
+	}
+
+	err = query.Order("family_id, created_at, id").Limit(limit).Find(&tokens).Error
+	return
+}
+
+func (d *refreshTokenDatabase) Rotate(ctx context.Context, current *entity.RefreshToken, next *entity.RefreshToken) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the condition makes concurrent rotations of the same token fail except one
+		result := tx.Model(&entity.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("used_at", next.CreatedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenUsed
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+func (d *refreshTokenDatabase) RevokeFamily(ctx context.Context, familyID uuid.UUID, revokedAt time.Time) error {
+	return d.conn.WithContext(ctx).Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", revokedAt).Error
+}
+
+func (d *refreshTokenDatabase) RevokeByUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	return d.conn.WithContext(ctx).Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+}
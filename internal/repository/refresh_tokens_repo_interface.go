@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+// ErrRefreshTokenUsed is returned by Rotate when the token was already used or revoked
+var ErrRefreshTokenUsed = errors.New("refresh token already used")
+
+type RefreshTokensRepository interface {
+	Create(ctx context.Context, token *entity.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	// ListByUser returns at most limit refresh tokens of the user , used and revoked ones included , starting after the token
+	// when it is not nil. They are ordered by family and then oldest first , so the tokens of a family are next to each other.
+	ListByUser(ctx context.Context, userID uuid.UUID, after *entity.RefreshToken, limit int) ([]*entity.RefreshToken, error)
+	// Rotate marks current as used and creates next , only when current is neither used nor revoked
+	Rotate(ctx context.Context, current *entity.RefreshToken, next *entity.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, revokedAt time.Time) error
+	RevokeByUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error
+}
@@ -0,0 +1,353 @@
+// Code generated by mockery v2.53.2. DO NOT EDIT.
+
+package repository
+
+import (
+	context "context"
+	time "time"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+
+	entity "github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ RefreshTokensRepository = &MockRefreshTokensRepository{}
+
+// MockRefreshTokensRepository is an autogenerated mock type for the RefreshTokensRepository type
+type MockRefreshTokensRepository struct {
+	mock.Mock
+}
+
+type MockRefreshTokensRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockRefreshTokensRepository) EXPECT() *MockRefreshTokensRepository_Expecter {
+	return &MockRefreshTokensRepository_Expecter{mock: &_m.Mock}
+}
+
+// Create provides a mock function with given fields: ctx, token
+func (_m *MockRefreshTokensRepository) Create(ctx context.Context, token *entity.RefreshToken) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.RefreshToken) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRefreshTokensRepository_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockRefreshTokensRepository_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - ctx context.Context
+//   - token *entity.RefreshToken
+func (_e *MockRefreshTokensRepository_Expecter) Create(ctx interface{}, token interface{}) *MockRefreshTokensRepository_Create_Call {
+	return &MockRefreshTokensRepository_Create_Call{Call: _e.mock.On("Create", ctx, token)}
+}
+
+func (_c *MockRefreshTokensRepository_Create_Call) Run(run func(ctx context.Context, token *entity.RefreshToken)) *MockRefreshTokensRepository_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.RefreshToken))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_Create_Call) Return(_a0 error) *MockRefreshTokensRepository_Create_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_Create_Call) RunAndReturn(run func(context.Context, *entity.RefreshToken) error) *MockRefreshTokensRepository_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByHash provides a mock function with given fields: ctx, tokenHash
+func (_m *MockRefreshTokensRepository) GetByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	ret := _m.Called(ctx, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByHash")
+	}
+
+	var r0 *entity.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.RefreshToken, error)); ok {
+		return rf(ctx, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.RefreshToken); ok {
+		r0 = rf(ctx, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.RefreshToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_GetByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByHash'
+type MockRefreshTokensRepository_GetByHash_Call struct {
+	*mock.Call
+}
+
+// GetByHash is a helper method to define mock.On call
+//   - ctx context.Context
+//   - tokenHash string
+func (_e *MockRefreshTokensRepository_Expecter) GetByHash(ctx interface{}, tokenHash interface{}) *MockRefreshTokensRepository_GetByHash_Call {
+	return &MockRefreshTokensRepository_GetByHash_Call{Call: _e.mock.On("GetByHash", ctx, tokenHash)}
+}
+
+func (_c *MockRefreshTokensRepository_GetByHash_Call) Run(run func(ctx context.Context, tokenHash string)) *MockRefreshTokensRepository_GetByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_GetByHash_Call) Return(_a0 *entity.RefreshToken, _a1 error) *MockRefreshTokensRepository_GetByHash_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_GetByHash_Call) RunAndReturn(run func(context.Context, string) (*entity.RefreshToken, error)) *MockRefreshTokensRepository_GetByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListByUser provides a mock function with given fields: ctx, userID, after, limit
+func (_m *MockRefreshTokensRepository) ListByUser(ctx context.Context, userID uuid.UUID, after *entity.RefreshToken, limit int) ([]*entity.RefreshToken, error) {
+	ret := _m.Called(ctx, userID, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByUser")
+	}
+
+	var r0 []*entity.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, *entity.RefreshToken, int) ([]*entity.RefreshToken, error)); ok {
+		return rf(ctx, userID, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, *entity.RefreshToken, int) []*entity.RefreshToken); ok {
+		r0 = rf(ctx, userID, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.RefreshToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, *entity.RefreshToken, int) error); ok {
+		r1 = rf(ctx, userID, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRefreshTokensRepository_ListByUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListByUser'
+type MockRefreshTokensRepository_ListByUser_Call struct {
+	*mock.Call
+}
+
+// ListByUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - after *entity.RefreshToken
+//   - limit int
+func (_e *MockRefreshTokensRepository_Expecter) ListByUser(ctx interface{}, userID interface{}, after interface{}, limit interface{}) *MockRefreshTokensRepository_ListByUser_Call {
+	return &MockRefreshTokensRepository_ListByUser_Call{Call: _e.mock.On("ListByUser", ctx, userID, after, limit)}
+}
+
+func (_c *MockRefreshTokensRepository_ListByUser_Call) Run(run func(ctx context.Context, userID uuid.UUID, after *entity.RefreshToken, limit int)) *MockRefreshTokensRepository_ListByUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(*entity.RefreshToken), args[3].(int))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_ListByUser_Call) Return(_a0 []*entity.RefreshToken, _a1 error) *MockRefreshTokensRepository_ListByUser_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_ListByUser_Call) RunAndReturn(run func(context.Context, uuid.UUID, *entity.RefreshToken, int) ([]*entity.RefreshToken, error)) *MockRefreshTokensRepository_ListByUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeByUser provides a mock function with given fields: ctx, userID, revokedAt
+func (_m *MockRefreshTokensRepository) RevokeByUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	ret := _m.Called(ctx, userID, revokedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeByUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, userID, revokedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRefreshTokensRepository_RevokeByUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeByUser'
+type MockRefreshTokensRepository_RevokeByUser_Call struct {
+	*mock.Call
+}
+
+// RevokeByUser is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - revokedAt time.Time
+func (_e *MockRefreshTokensRepository_Expecter) RevokeByUser(ctx interface{}, userID interface{}, revokedAt interface{}) *MockRefreshTokensRepository_RevokeByUser_Call {
+	return &MockRefreshTokensRepository_RevokeByUser_Call{Call: _e.mock.On("RevokeByUser", ctx, userID, revokedAt)}
+}
+
+func (_c *MockRefreshTokensRepository_RevokeByUser_Call) Run(run func(ctx context.Context, userID uuid.UUID, revokedAt time.Time)) *MockRefreshTokensRepository_RevokeByUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_RevokeByUser_Call) Return(_a0 error) *MockRefreshTokensRepository_RevokeByUser_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_RevokeByUser_Call) RunAndReturn(run func(context.Context, uuid.UUID, time.Time) error) *MockRefreshTokensRepository_RevokeByUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeFamily provides a mock function with given fields: ctx, familyID, revokedAt
+func (_m *MockRefreshTokensRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, revokedAt time.Time) error {
+	ret := _m.Called(ctx, familyID, revokedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeFamily")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, familyID, revokedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRefreshTokensRepository_RevokeFamily_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeFamily'
+type MockRefreshTokensRepository_RevokeFamily_Call struct {
+	*mock.Call
+}
+
+// RevokeFamily is a helper method to define mock.On call
+//   - ctx context.Context
+//   - familyID uuid.UUID
+//   - revokedAt time.Time
+func (_e *MockRefreshTokensRepository_Expecter) RevokeFamily(ctx interface{}, familyID interface{}, revokedAt interface{}) *MockRefreshTokensRepository_RevokeFamily_Call {
+	return &MockRefreshTokensRepository_RevokeFamily_Call{Call: _e.mock.On("RevokeFamily", ctx, familyID, revokedAt)}
+}
+
+func (_c *MockRefreshTokensRepository_RevokeFamily_Call) Run(run func(ctx context.Context, familyID uuid.UUID, revokedAt time.Time)) *MockRefreshTokensRepository_RevokeFamily_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_RevokeFamily_Call) Return(_a0 error) *MockRefreshTokensRepository_RevokeFamily_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_RevokeFamily_Call) RunAndReturn(run func(context.Context, uuid.UUID, time.Time) error) *MockRefreshTokensRepository_RevokeFamily_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Rotate provides a mock function with given fields: ctx, current, next
+func (_m *MockRefreshTokensRepository) Rotate(ctx context.Context, current *entity.RefreshToken, next *entity.RefreshToken) error {
+	ret := _m.Called(ctx, current, next)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Rotate")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.RefreshToken, *entity.RefreshToken) error); ok {
+		r0 = rf(ctx, current, next)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRefreshTokensRepository_Rotate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Rotate'
+type MockRefreshTokensRepository_Rotate_Call struct {
+	*mock.Call
+}
+
+// Rotate is a helper method to define mock.On call
+//   - ctx context.Context
+//   - current *entity.RefreshToken
+//   - next *entity.RefreshToken
+func (_e *MockRefreshTokensRepository_Expecter) Rotate(ctx interface{}, current interface{}, next interface{}) *MockRefreshTokensRepository_Rotate_Call {
+	return &MockRefreshTokensRepository_Rotate_Call{Call: _e.mock.On("Rotate", ctx, current, next)}
+}
+
+func (_c *MockRefreshTokensRepository_Rotate_Call) Run(run func(ctx context.Context, current *entity.RefreshToken, next *entity.RefreshToken)) *MockRefreshTokensRepository_Rotate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.RefreshToken), args[2].(*entity.RefreshToken))
+	})
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_Rotate_Call) Return(_a0 error) *MockRefreshTokensRepository_Rotate_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRefreshTokensRepository_Rotate_Call) RunAndReturn(run func(context.Context, *entity.RefreshToken, *entity.RefreshToken) error) *MockRefreshTokensRepository_Rotate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockRefreshTokensRepository creates a new instance of MockRefreshTokensRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockRefreshTokensRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockRefreshTokensRepository {
+	mock := &MockRefreshTokensRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
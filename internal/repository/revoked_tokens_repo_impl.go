@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ RevokedTokensRepository = &revokedTokenDatabase{}
+
+type revokedTokenDatabase struct {
+	conn *gorm.DB
+}
+
+func NewRevokedTokensRepository(conn *gorm.DB) RevokedTokensRepository {
+	return &revokedTokenDatabase{
+		conn: conn,
+	}
+}
+
+func (d *revokedTokenDatabase) RevokeToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	return d.conn.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&entity.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (d *revokedTokenDatabase) RevokeUserTokens(ctx context.Context, userID uuid.UUID, revokedBefore time.Time) error {
+	return d.conn.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "updated_at"}),
+	}).Create(&entity.UserTokenRevocation{
+		UserID:        userID,
+		RevokedBefore: revokedBefore.Truncate(time.Second),
+	}).Error
+}
+
+func (d *revokedTokenDatabase) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	var count int64
+	if err := d.conn.WithContext(ctx).Model(&entity.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := d.conn.WithContext(ctx).Model(&entity.UserTokenRevocation{}).
+		Where("user_id = ? AND revoked_before > ?", userID, issuedAt).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (d *revokedTokenDatabase) DeleteExpired(ctx context.Context, now time.Time) error {
+	return d.conn.WithContext(ctx).Where("expires_at < ?", now).Delete(&entity.RevokedToken{}).Error
+}
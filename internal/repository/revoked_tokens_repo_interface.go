@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokedTokensRepository stores the access tokens that are invalid before they expire ,
+// either one token by its jti or all tokens of a user issued before a time
+type RevokedTokensRepository interface {
+	RevokeToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error
+	// RevokeUserTokens invalidates the tokens of the user issued before revokedBefore , truncated to the second like the iat claim.
+	// The tokens issued earlier in the same second stay valid , so the new tokens issued right after are not revoked ,
+	// a token known to the caller is revoked by its jti as well.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, revokedBefore time.Time) error
+	IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var _ RevokedTokensRepository = &revokedTokenMemory{}
+
+// revokedTokenMemory keeps the revoked tokens in memory , for tests and a single instance
+type revokedTokenMemory struct {
+	mu            sync.RWMutex
+	tokens        map[string]time.Time
+	revokedBefore map[uuid.UUID]time.Time
+}
+
+func NewRevokedTokensMemoryRepository() RevokedTokensRepository {
+	return &revokedTokenMemory{
+		tokens:        map[string]time.Time{},
+		revokedBefore: map[uuid.UUID]time.Time{},
+	}
+}
+
+func (m *revokedTokenMemory) RevokeToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[jti] = expiresAt
+
+	return nil
+}
+
+func (m *revokedTokenMemory) RevokeUserTokens(ctx context.Context, userID uuid.UUID, revokedBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokedBefore[userID] = revokedBefore.Truncate(time.Second)
+
+	return nil
+}
+
+func (m *revokedTokenMemory) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.tokens[jti]; ok {
+		return true, nil
+	}
+
+	revokedBefore, ok := m.revokedBefore[userID]
+
+	return ok && issuedAt.Before(revokedBefore), nil
+}
+
+func (m *revokedTokenMemory) DeleteExpired(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for jti, expiresAt := range m.tokens {
+		if expiresAt.Before(now) {
+			delete(m.tokens, jti)
+		}
+	}
+
+	return nil
+}
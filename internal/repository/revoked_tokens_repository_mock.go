@@ -0,0 +1,243 @@
+// Code generated by mockery v2.53.2. DO NOT EDIT.
+
+package repository
+
+import (
+	context "context"
+	time "time"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+)
+
+var _ RevokedTokensRepository = &MockRevokedTokensRepository{}
+
+// MockRevokedTokensRepository is an autogenerated mock type for the RevokedTokensRepository type
+type MockRevokedTokensRepository struct {
+	mock.Mock
+}
+
+type MockRevokedTokensRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockRevokedTokensRepository) EXPECT() *MockRevokedTokensRepository_Expecter {
+	return &MockRevokedTokensRepository_Expecter{mock: &_m.Mock}
+}
+
+// DeleteExpired provides a mock function with given fields: ctx, now
+func (_m *MockRevokedTokensRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	ret := _m.Called(ctx, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpired")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRevokedTokensRepository_DeleteExpired_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteExpired'
+type MockRevokedTokensRepository_DeleteExpired_Call struct {
+	*mock.Call
+}
+
+// DeleteExpired is a helper method to define mock.On call
+//   - ctx context.Context
+//   - now time.Time
+func (_e *MockRevokedTokensRepository_Expecter) DeleteExpired(ctx interface{}, now interface{}) *MockRevokedTokensRepository_DeleteExpired_Call {
+	return &MockRevokedTokensRepository_DeleteExpired_Call{Call: _e.mock.On("DeleteExpired", ctx, now)}
+}
+
+func (_c *MockRevokedTokensRepository_DeleteExpired_Call) Run(run func(ctx context.Context, now time.Time)) *MockRevokedTokensRepository_DeleteExpired_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_DeleteExpired_Call) Return(_a0 error) *MockRevokedTokensRepository_DeleteExpired_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_DeleteExpired_Call) RunAndReturn(run func(context.Context, time.Time) error) *MockRevokedTokensRepository_DeleteExpired_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// IsRevoked provides a mock function with given fields: ctx, jti, userID, issuedAt
+func (_m *MockRevokedTokensRepository) IsRevoked(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	ret := _m.Called(ctx, jti, userID, issuedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsRevoked")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uuid.UUID, time.Time) (bool, error)); ok {
+		return rf(ctx, jti, userID, issuedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, uuid.UUID, time.Time) bool); ok {
+		r0 = rf(ctx, jti, userID, issuedAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, uuid.UUID, time.Time) error); ok {
+		r1 = rf(ctx, jti, userID, issuedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRevokedTokensRepository_IsRevoked_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'IsRevoked'
+type MockRevokedTokensRepository_IsRevoked_Call struct {
+	*mock.Call
+}
+
+// IsRevoked is a helper method to define mock.On call
+//   - ctx context.Context
+//   - jti string
+//   - userID uuid.UUID
+//   - issuedAt time.Time
+func (_e *MockRevokedTokensRepository_Expecter) IsRevoked(ctx interface{}, jti interface{}, userID interface{}, issuedAt interface{}) *MockRevokedTokensRepository_IsRevoked_Call {
+	return &MockRevokedTokensRepository_IsRevoked_Call{Call: _e.mock.On("IsRevoked", ctx, jti, userID, issuedAt)}
+}
+
+func (_c *MockRevokedTokensRepository_IsRevoked_Call) Run(run func(ctx context.Context, jti string, userID uuid.UUID, issuedAt time.Time)) *MockRevokedTokensRepository_IsRevoked_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(uuid.UUID), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_IsRevoked_Call) Return(_a0 bool, _a1 error) *MockRevokedTokensRepository_IsRevoked_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_IsRevoked_Call) RunAndReturn(run func(context.Context, string, uuid.UUID, time.Time) (bool, error)) *MockRevokedTokensRepository_IsRevoked_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeToken provides a mock function with given fields: ctx, jti, userID, expiresAt
+func (_m *MockRevokedTokensRepository) RevokeToken(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time) error {
+	ret := _m.Called(ctx, jti, userID, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, jti, userID, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRevokedTokensRepository_RevokeToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeToken'
+type MockRevokedTokensRepository_RevokeToken_Call struct {
+	*mock.Call
+}
+
+// RevokeToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - jti string
+//   - userID uuid.UUID
+//   - expiresAt time.Time
+func (_e *MockRevokedTokensRepository_Expecter) RevokeToken(ctx interface{}, jti interface{}, userID interface{}, expiresAt interface{}) *MockRevokedTokensRepository_RevokeToken_Call {
+	return &MockRevokedTokensRepository_RevokeToken_Call{Call: _e.mock.On("RevokeToken", ctx, jti, userID, expiresAt)}
+}
+
+func (_c *MockRevokedTokensRepository_RevokeToken_Call) Run(run func(ctx context.Context, jti string, userID uuid.UUID, expiresAt time.Time)) *MockRevokedTokensRepository_RevokeToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(uuid.UUID), args[3].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_RevokeToken_Call) Return(_a0 error) *MockRevokedTokensRepository_RevokeToken_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_RevokeToken_Call) RunAndReturn(run func(context.Context, string, uuid.UUID, time.Time) error) *MockRevokedTokensRepository_RevokeToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeUserTokens provides a mock function with given fields: ctx, userID, revokedBefore
+func (_m *MockRevokedTokensRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, revokedBefore time.Time) error {
+	ret := _m.Called(ctx, userID, revokedBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeUserTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, userID, revokedBefore)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRevokedTokensRepository_RevokeUserTokens_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeUserTokens'
+type MockRevokedTokensRepository_RevokeUserTokens_Call struct {
+	*mock.Call
+}
+
+// RevokeUserTokens is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - revokedBefore time.Time
+func (_e *MockRevokedTokensRepository_Expecter) RevokeUserTokens(ctx interface{}, userID interface{}, revokedBefore interface{}) *MockRevokedTokensRepository_RevokeUserTokens_Call {
+	return &MockRevokedTokensRepository_RevokeUserTokens_Call{Call: _e.mock.On("RevokeUserTokens", ctx, userID, revokedBefore)}
+}
+
+func (_c *MockRevokedTokensRepository_RevokeUserTokens_Call) Run(run func(ctx context.Context, userID uuid.UUID, revokedBefore time.Time)) *MockRevokedTokensRepository_RevokeUserTokens_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_RevokeUserTokens_Call) Return(_a0 error) *MockRevokedTokensRepository_RevokeUserTokens_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRevokedTokensRepository_RevokeUserTokens_Call) RunAndReturn(run func(context.Context, uuid.UUID, time.Time) error) *MockRevokedTokensRepository_RevokeUserTokens_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockRevokedTokensRepository creates a new instance of MockRevokedTokensRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockRevokedTokensRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockRevokedTokensRepository {
+	mock := &MockRevokedTokensRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
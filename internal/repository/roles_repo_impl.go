@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ RolesRepository = &rolesDatabase{}
+
+type rolesDatabase struct {
+	conn *gorm.DB
+}
+
+func NewRolesRepository(conn *gorm.DB) RolesRepository {
+	return &rolesDatabase{
+		conn: conn,
+	}
+}
+
+func (d *rolesDatabase) SyncPermissions(ctx context.Context, permissions []*entity.Permission) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		names := make([]string, 0, len(permissions))
+		for _, permission := range permissions {
+			names = append(names, permission.Name)
+		}
+
+		// an empty NOT IN list matches no row in MySQL , so every permission would stay
+		removed := tx.Where("1 = 1")
+		if len(names) > 0 {
+			removed = tx.Where("permission NOT IN ?", names)
+		}
+		if err := removed.Delete(&entity.RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		removed = tx.Where("1 = 1")
+		if len(names) > 0 {
+			removed = tx.Where("name NOT IN ?", names)
+		}
+		if err := removed.Delete(&entity.Permission{}).Error; err != nil {
+			return err
+		}
+
+		if len(permissions) == 0 {
+			return nil
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(permissions).Error
+	})
+}
+
+func (d *rolesDatabase) ListPermissions(ctx context.Context) (permissions []*entity.Permission, err error) {
+	err = d.conn.WithContext(ctx).Order("name").Find(&permissions).Error
+	return
+}
+
+func (d *rolesDatabase) GetRole(ctx context.Context, name string) (role *entity.Role, err error) {
+	if err := d.conn.WithContext(ctx).Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (d *rolesDatabase) ListRoles(ctx context.Context) (roles []*entity.Role, err error) {
+	err = d.conn.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error
+	return
+}
+
+func (d *rolesDatabase) CreateRole(ctx context.Context, role *entity.Role) error {
+	// the permissions are created with the role , they are an association
+	err := d.conn.WithContext(ctx).Create(role).Error
+	if isDuplicateKey(err) {
+		// the id is a random uuid , so the violated index is the name
+		return ErrRoleAlreadyExists
+	}
+	return err
+}
+
+func (d *rolesDatabase) UpdateRole(ctx context.Context, role *entity.Role) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.Role{}).Where("id = ?", role.ID).Updates(map[string]any{
+			"description": role.Description,
+			"updated_at":  role.UpdatedAt,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("role_id = ?", role.ID).Delete(&entity.RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		if len(role.Permissions) == 0 {
+			return nil
+		}
+
+		return tx.Create(&role.Permissions).Error
+	})
+}
+
+func (d *rolesDatabase) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&entity.UserRole{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("role_id = ?", roleID).Delete(&entity.RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", roleID).Delete(&entity.Role{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+func (d *rolesDatabase) AssignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	return d.conn.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&entity.UserRole{UserID: userID, RoleID: roleID}).Error
+}
+
+func (d *rolesDatabase) UnassignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	return d.conn.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&entity.UserRole{}).Error
+}
+
+func (d *rolesDatabase) ListRoleUsers(ctx context.Context, roleID uuid.UUID) (userIDs []uuid.UUID, err error) {
+	err = d.conn.WithContext(ctx).Model(&entity.UserRole{}).Where("role_id = ?", roleID).Pluck("user_id", &userIDs).Error
+	return
+}
+
+func (d *rolesDatabase) ListUserRoles(ctx context.Context, userID uuid.UUID) (roles []*entity.Role, err error) {
+	err = d.conn.WithContext(ctx).Preload("Permissions").
+		Where("id IN (?)", d.conn.Model(&entity.UserRole{}).Select("role_id").Where("user_id = ?", userID)).
+		Order("name").
+		Find(&roles).Error
+	return
+}
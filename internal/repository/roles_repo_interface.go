@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var (
+	// ErrRoleAlreadyExists is returned by CreateRole when the name is taken
+	ErrRoleAlreadyExists = errors.New("role already exists")
+)
+
+type RolesRepository interface {
+	// SyncPermissions makes the permissions table hold exactly permissions , the permissions that are gone
+	// are removed from the roles too
+	SyncPermissions(ctx context.Context, permissions []*entity.Permission) error
+	ListPermissions(ctx context.Context) ([]*entity.Permission, error)
+	// GetRole returns the role with its permissions , gorm.ErrRecordNotFound when it doesn't exist
+	GetRole(ctx context.Context, name string) (*entity.Role, error)
+	// ListRoles returns all the roles with their permissions , sorted by name
+	ListRoles(ctx context.Context) ([]*entity.Role, error)
+	// CreateRole inserts the role with its permissions , it returns ErrRoleAlreadyExists when the name is taken
+	CreateRole(ctx context.Context, role *entity.Role) error
+	// UpdateRole saves the description and replaces the permissions of the role
+	UpdateRole(ctx context.Context, role *entity.Role) error
+	// DeleteRole deletes the role and unassigns it from its users , gorm.ErrRecordNotFound when it doesn't exist
+	DeleteRole(ctx context.Context, roleID uuid.UUID) error
+	// AssignRole gives the role to the user , assigning a role twice is not an error
+	AssignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error
+	// UnassignRole takes the role from the user , unassigning a role the user doesn't have is not an error
+	UnassignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error
+	// ListRoleUsers returns the ids of the users that have the role
+	ListRoleUsers(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error)
+	// ListUserRoles returns the roles of the user with their permissions , sorted by name
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]*entity.Role, error)
+}
@@ -0,0 +1,618 @@
+// Code generated by mockery v2.53.2. DO NOT EDIT.
+
+package repository
+
+import (
+	context "context"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+
+	entity "github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ RolesRepository = &MockRolesRepository{}
+
+// MockRolesRepository is an autogenerated mock type for the RolesRepository type
+type MockRolesRepository struct {
+	mock.Mock
+}
+
+type MockRolesRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockRolesRepository) EXPECT() *MockRolesRepository_Expecter {
+	return &MockRolesRepository_Expecter{mock: &_m.Mock}
+}
+
+// AssignRole provides a mock function with given fields: ctx, userID, roleID
+func (_m *MockRolesRepository) AssignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	ret := _m.Called(ctx, userID, roleID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AssignRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID, roleID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRolesRepository_AssignRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AssignRole'
+type MockRolesRepository_AssignRole_Call struct {
+	*mock.Call
+}
+
+// AssignRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - roleID uuid.UUID
+func (_e *MockRolesRepository_Expecter) AssignRole(ctx interface{}, userID interface{}, roleID interface{}) *MockRolesRepository_AssignRole_Call {
+	return &MockRolesRepository_AssignRole_Call{Call: _e.mock.On("AssignRole", ctx, userID, roleID)}
+}
+
+func (_c *MockRolesRepository_AssignRole_Call) Run(run func(ctx context.Context, userID uuid.UUID, roleID uuid.UUID)) *MockRolesRepository_AssignRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_AssignRole_Call) Return(_a0 error) *MockRolesRepository_AssignRole_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRolesRepository_AssignRole_Call) RunAndReturn(run func(context.Context, uuid.UUID, uuid.UUID) error) *MockRolesRepository_AssignRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateRole provides a mock function with given fields: ctx, role
+func (_m *MockRolesRepository) CreateRole(ctx context.Context, role *entity.Role) error {
+	ret := _m.Called(ctx, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Role) error); ok {
+		r0 = rf(ctx, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRolesRepository_CreateRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateRole'
+type MockRolesRepository_CreateRole_Call struct {
+	*mock.Call
+}
+
+// CreateRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - role *entity.Role
+func (_e *MockRolesRepository_Expecter) CreateRole(ctx interface{}, role interface{}) *MockRolesRepository_CreateRole_Call {
+	return &MockRolesRepository_CreateRole_Call{Call: _e.mock.On("CreateRole", ctx, role)}
+}
+
+func (_c *MockRolesRepository_CreateRole_Call) Run(run func(ctx context.Context, role *entity.Role)) *MockRolesRepository_CreateRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.Role))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_CreateRole_Call) Return(_a0 error) *MockRolesRepository_CreateRole_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRolesRepository_CreateRole_Call) RunAndReturn(run func(context.Context, *entity.Role) error) *MockRolesRepository_CreateRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteRole provides a mock function with given fields: ctx, roleID
+func (_m *MockRolesRepository) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	ret := _m.Called(ctx, roleID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, roleID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRolesRepository_DeleteRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteRole'
+type MockRolesRepository_DeleteRole_Call struct {
+	*mock.Call
+}
+
+// DeleteRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - roleID uuid.UUID
+func (_e *MockRolesRepository_Expecter) DeleteRole(ctx interface{}, roleID interface{}) *MockRolesRepository_DeleteRole_Call {
+	return &MockRolesRepository_DeleteRole_Call{Call: _e.mock.On("DeleteRole", ctx, roleID)}
+}
+
+func (_c *MockRolesRepository_DeleteRole_Call) Run(run func(ctx context.Context, roleID uuid.UUID)) *MockRolesRepository_DeleteRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_DeleteRole_Call) Return(_a0 error) *MockRolesRepository_DeleteRole_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRolesRepository_DeleteRole_Call) RunAndReturn(run func(context.Context, uuid.UUID) error) *MockRolesRepository_DeleteRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRole provides a mock function with given fields: ctx, name
+func (_m *MockRolesRepository) GetRole(ctx context.Context, name string) (*entity.Role, error) {
+	ret := _m.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRole")
+	}
+
+	var r0 *entity.Role
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.Role, error)); ok {
+		return rf(ctx, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.Role); ok {
+		r0 = rf(ctx, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.Role)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRolesRepository_GetRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRole'
+type MockRolesRepository_GetRole_Call struct {
+	*mock.Call
+}
+
+// GetRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - name string
+func (_e *MockRolesRepository_Expecter) GetRole(ctx interface{}, name interface{}) *MockRolesRepository_GetRole_Call {
+	return &MockRolesRepository_GetRole_Call{Call: _e.mock.On("GetRole", ctx, name)}
+}
+
+func (_c *MockRolesRepository_GetRole_Call) Run(run func(ctx context.Context, name string)) *MockRolesRepository_GetRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_GetRole_Call) Return(_a0 *entity.Role, _a1 error) *MockRolesRepository_GetRole_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRolesRepository_GetRole_Call) RunAndReturn(run func(context.Context, string) (*entity.Role, error)) *MockRolesRepository_GetRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListPermissions provides a mock function with given fields: ctx
+func (_m *MockRolesRepository) ListPermissions(ctx context.Context) ([]*entity.Permission, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListPermissions")
+	}
+
+	var r0 []*entity.Permission
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*entity.Permission, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*entity.Permission); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.Permission)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRolesRepository_ListPermissions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListPermissions'
+type MockRolesRepository_ListPermissions_Call struct {
+	*mock.Call
+}
+
+// ListPermissions is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockRolesRepository_Expecter) ListPermissions(ctx interface{}) *MockRolesRepository_ListPermissions_Call {
+	return &MockRolesRepository_ListPermissions_Call{Call: _e.mock.On("ListPermissions", ctx)}
+}
+
+func (_c *MockRolesRepository_ListPermissions_Call) Run(run func(ctx context.Context)) *MockRolesRepository_ListPermissions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_ListPermissions_Call) Return(_a0 []*entity.Permission, _a1 error) *MockRolesRepository_ListPermissions_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRolesRepository_ListPermissions_Call) RunAndReturn(run func(context.Context) ([]*entity.Permission, error)) *MockRolesRepository_ListPermissions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRoleUsers provides a mock function with given fields: ctx, roleID
+func (_m *MockRolesRepository) ListRoleUsers(ctx context.Context, roleID uuid.UUID) ([]uuid.UUID, error) {
+	ret := _m.Called(ctx, roleID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRoleUsers")
+	}
+
+	var r0 []uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]uuid.UUID, error)); ok {
+		return rf(ctx, roleID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []uuid.UUID); ok {
+		r0 = rf(ctx, roleID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, roleID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRolesRepository_ListRoleUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRoleUsers'
+type MockRolesRepository_ListRoleUsers_Call struct {
+	*mock.Call
+}
+
+// ListRoleUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - roleID uuid.UUID
+func (_e *MockRolesRepository_Expecter) ListRoleUsers(ctx interface{}, roleID interface{}) *MockRolesRepository_ListRoleUsers_Call {
+	return &MockRolesRepository_ListRoleUsers_Call{Call: _e.mock.On("ListRoleUsers", ctx, roleID)}
+}
+
+func (_c *MockRolesRepository_ListRoleUsers_Call) Run(run func(ctx context.Context, roleID uuid.UUID)) *MockRolesRepository_ListRoleUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_ListRoleUsers_Call) Return(_a0 []uuid.UUID, _a1 error) *MockRolesRepository_ListRoleUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRolesRepository_ListRoleUsers_Call) RunAndReturn(run func(context.Context, uuid.UUID) ([]uuid.UUID, error)) *MockRolesRepository_ListRoleUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRoles provides a mock function with given fields: ctx
+func (_m *MockRolesRepository) ListRoles(ctx context.Context) ([]*entity.Role, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRoles")
+	}
+
+	var r0 []*entity.Role
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*entity.Role, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*entity.Role); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.Role)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRolesRepository_ListRoles_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRoles'
+type MockRolesRepository_ListRoles_Call struct {
+	*mock.Call
+}
+
+// ListRoles is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockRolesRepository_Expecter) ListRoles(ctx interface{}) *MockRolesRepository_ListRoles_Call {
+	return &MockRolesRepository_ListRoles_Call{Call: _e.mock.On("ListRoles", ctx)}
+}
+
+func (_c *MockRolesRepository_ListRoles_Call) Run(run func(ctx context.Context)) *MockRolesRepository_ListRoles_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_ListRoles_Call) Return(_a0 []*entity.Role, _a1 error) *MockRolesRepository_ListRoles_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRolesRepository_ListRoles_Call) RunAndReturn(run func(context.Context) ([]*entity.Role, error)) *MockRolesRepository_ListRoles_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListUserRoles provides a mock function with given fields: ctx, userID
+func (_m *MockRolesRepository) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]*entity.Role, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUserRoles")
+	}
+
+	var r0 []*entity.Role
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]*entity.Role, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*entity.Role); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.Role)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRolesRepository_ListUserRoles_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListUserRoles'
+type MockRolesRepository_ListUserRoles_Call struct {
+	*mock.Call
+}
+
+// ListUserRoles is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+func (_e *MockRolesRepository_Expecter) ListUserRoles(ctx interface{}, userID interface{}) *MockRolesRepository_ListUserRoles_Call {
+	return &MockRolesRepository_ListUserRoles_Call{Call: _e.mock.On("ListUserRoles", ctx, userID)}
+}
+
+func (_c *MockRolesRepository_ListUserRoles_Call) Run(run func(ctx context.Context, userID uuid.UUID)) *MockRolesRepository_ListUserRoles_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_ListUserRoles_Call) Return(_a0 []*entity.Role, _a1 error) *MockRolesRepository_ListUserRoles_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRolesRepository_ListUserRoles_Call) RunAndReturn(run func(context.Context, uuid.UUID) ([]*entity.Role, error)) *MockRolesRepository_ListUserRoles_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SyncPermissions provides a mock function with given fields: ctx, permissions
+func (_m *MockRolesRepository) SyncPermissions(ctx context.Context, permissions []*entity.Permission) error {
+	ret := _m.Called(ctx, permissions)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SyncPermissions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*entity.Permission) error); ok {
+		r0 = rf(ctx, permissions)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRolesRepository_SyncPermissions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SyncPermissions'
+type MockRolesRepository_SyncPermissions_Call struct {
+	*mock.Call
+}
+
+// SyncPermissions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - permissions []*entity.Permission
+func (_e *MockRolesRepository_Expecter) SyncPermissions(ctx interface{}, permissions interface{}) *MockRolesRepository_SyncPermissions_Call {
+	return &MockRolesRepository_SyncPermissions_Call{Call: _e.mock.On("SyncPermissions", ctx, permissions)}
+}
+
+func (_c *MockRolesRepository_SyncPermissions_Call) Run(run func(ctx context.Context, permissions []*entity.Permission)) *MockRolesRepository_SyncPermissions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]*entity.Permission))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_SyncPermissions_Call) Return(_a0 error) *MockRolesRepository_SyncPermissions_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRolesRepository_SyncPermissions_Call) RunAndReturn(run func(context.Context, []*entity.Permission) error) *MockRolesRepository_SyncPermissions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UnassignRole provides a mock function with given fields: ctx, userID, roleID
+func (_m *MockRolesRepository) UnassignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	ret := _m.Called(ctx, userID, roleID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnassignRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID, roleID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRolesRepository_UnassignRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UnassignRole'
+type MockRolesRepository_UnassignRole_Call struct {
+	*mock.Call
+}
+
+// UnassignRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+//   - roleID uuid.UUID
+func (_e *MockRolesRepository_Expecter) UnassignRole(ctx interface{}, userID interface{}, roleID interface{}) *MockRolesRepository_UnassignRole_Call {
+	return &MockRolesRepository_UnassignRole_Call{Call: _e.mock.On("UnassignRole", ctx, userID, roleID)}
+}
+
+func (_c *MockRolesRepository_UnassignRole_Call) Run(run func(ctx context.Context, userID uuid.UUID, roleID uuid.UUID)) *MockRolesRepository_UnassignRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_UnassignRole_Call) Return(_a0 error) *MockRolesRepository_UnassignRole_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRolesRepository_UnassignRole_Call) RunAndReturn(run func(context.Context, uuid.UUID, uuid.UUID) error) *MockRolesRepository_UnassignRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateRole provides a mock function with given fields: ctx, role
+func (_m *MockRolesRepository) UpdateRole(ctx context.Context, role *entity.Role) error {
+	ret := _m.Called(ctx, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.Role) error); ok {
+		r0 = rf(ctx, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRolesRepository_UpdateRole_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateRole'
+type MockRolesRepository_UpdateRole_Call struct {
+	*mock.Call
+}
+
+// UpdateRole is a helper method to define mock.On call
+//   - ctx context.Context
+//   - role *entity.Role
+func (_e *MockRolesRepository_Expecter) UpdateRole(ctx interface{}, role interface{}) *MockRolesRepository_UpdateRole_Call {
+	return &MockRolesRepository_UpdateRole_Call{Call: _e.mock.On("UpdateRole", ctx, role)}
+}
+
+func (_c *MockRolesRepository_UpdateRole_Call) Run(run func(ctx context.Context, role *entity.Role)) *MockRolesRepository_UpdateRole_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.Role))
+	})
+	return _c
+}
+
+func (_c *MockRolesRepository_UpdateRole_Call) Return(_a0 error) *MockRolesRepository_UpdateRole_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRolesRepository_UpdateRole_Call) RunAndReturn(run func(context.Context, *entity.Role) error) *MockRolesRepository_UpdateRole_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockRolesRepository creates a new instance of MockRolesRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockRolesRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockRolesRepository {
+	mock := &MockRolesRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -2,7 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
@@ -11,6 +16,9 @@ import (
 
 var _ UsersRepository = &database{}
 
+// ER_DUP_ENTRY
+const mysqlErrDuplicateEntry = 1062
+
 type database struct {
 	conn *gorm.DB
 }
@@ -22,7 +30,12 @@ func NewUsersRepository(conn *gorm.DB) UsersRepository {
 }
 
 func (d *database) Create(ctx context.Context, user *entity.User) error {
-	return d.conn.WithContext(ctx).Create(user).Error
+	err := d.conn.WithContext(ctx).Create(user).Error
+	if isDuplicateKey(err) {
+		// the id is a random uuid , so the violated index is email_canonical
+		return ErrEmailAlreadyExists
+	}
+	return err
 }
 
 func (d *database) Get(ctx context.Context, id uuid.UUID) (user *entity.User, err error) {
@@ -32,32 +45,246 @@ func (d *database) Get(ctx context.Context, id uuid.UUID) (user *entity.User, er
 	return
 }
 
-func (d *database) GetByEmail(ctx context.Context, email string) (user *entity.User, err error) {
-	if err := d.conn.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+func (d *database) GetByEmail(ctx context.Context, emailCanonical string) (user *entity.User, err error) {
+	if err := d.conn.WithContext(ctx).Where("email_canonical = ?", emailCanonical).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return
 }
 
-func (d *database) Update(ctx context.Context, user *entity.User) error {
-	return d.conn.WithContext(ctx).Model(&entity.User{}).Where("id = ?", user.ID).Updates(user).Error
+func (d *database) Update(ctx context.Context, user *entity.User, columns ...string) error {
+	if len(columns) == 0 {
+		return ErrNoColumns
+	}
+
+	// Select makes gorm update zero values too , Updates alone skips them
+	return d.conn.WithContext(ctx).Model(&entity.User{}).Where("id = ?", user.ID).Select(columns).Updates(user).Error
 }
 
-func (d *database) Delete(ctx context.Context, id uuid.UUID) error {
-	return d.conn.WithContext(ctx).Where("id = ?", id).Delete(&entity.User{}).Error
+func (d *database) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	return d.conn.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Update("password", passwordHash).Error
 }
 
-func (d *database) ExistsByEmail(ctx context.Context, email string) (bool, error) {
-	var count int64
-	if err := d.conn.WithContext(ctx).Model(&entity.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
-		return false, err
+func (d *database) GetStatus(ctx context.Context, id uuid.UUID) (entity.UserStatus, error) {
+	var user entity.User
+	if err := d.conn.WithContext(ctx).Select("status").Where("id = ?", id).First(&user).Error; err != nil {
+		return "", err
 	}
-	return count > 0, nil
+	return user.Status, nil
+}
+
+func (d *database) ChangeStatus(ctx context.Context, change *entity.UserStatusChange) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.User{}).
+			Where("id = ? AND status = ?", change.UserID, change.FromStatus).
+			Update("status", change.ToStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&entity.User{}).Where("id = ?", change.UserID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return ErrStatusChanged
+		}
+
+		return tx.Create(change).Error
+	})
+}
+
+func (d *database) ListStatusChanges(ctx context.Context, userID uuid.UUID) (changes []*entity.UserStatusChange, err error) {
+	err = d.conn.WithContext(ctx).Where("user_id = ?", userID).Order("created_at, id").Find(&changes).Error
+	return
+}
+
+func (d *database) Delete(ctx context.Context, change *entity.UserStatusChange) error {
+	return d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user entity.User
+		if err := tx.Where("id = ?", change.UserID).First(&user).Error; err != nil {
+			return err
+		}
+
+		// the soft delete scope adds deleted_at IS NULL , a concurrent Delete updates nothing
+		result := tx.Model(&entity.User{}).Where("id = ? AND status = ?", change.UserID, change.FromStatus).Updates(map[string]any{
+			"deleted_email_canonical": user.EmailCanonical,
+			"email_canonical":         deletedEmailCanonical(change.UserID),
+			"status":                  change.ToStatus,
+			"deleted_at":              time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if user.Status != change.FromStatus {
+				return ErrStatusChanged
+			}
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Create(change).Error
+	})
 }
 
-func (d *database) GetByEmailAndPassword(ctx context.Context, email, password string) (user *entity.User, err error) {
-	if err := d.conn.WithContext(ctx).Where("email = ? AND password = ?", email, password).First(&user).Error; err != nil {
+func (d *database) GetDeletedByEmail(ctx context.Context, emailCanonical string) (user *entity.User, err error) {
+	err = d.conn.WithContext(ctx).Unscoped().
+		Where("deleted_email_canonical = ? AND deleted_at IS NOT NULL", emailCanonical).
+		Order("deleted_at DESC").
+		First(&user).Error
+	if err != nil {
 		return nil, err
 	}
 	return
 }
+
+func (d *database) Restore(ctx context.Context, user *entity.User, change *entity.UserStatusChange) error {
+	if user.DeletedEmailCanonical == nil {
+		return gorm.ErrRecordNotFound
+	}
+
+	err := d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&entity.User{}).
+			Where("id = ? AND deleted_at IS NOT NULL", user.ID).
+			Updates(map[string]any{
+				"email_canonical":         *user.DeletedEmailCanonical,
+				"deleted_email_canonical": nil,
+				"status":                  change.ToStatus,
+				"deleted_at":              nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Create(change).Error
+	})
+	if isDuplicateKey(err) {
+		return ErrEmailAlreadyExists
+	}
+
+	return err
+}
+
+func (d *database) ListUsers(ctx context.Context, filter UserFilter, order UserOrder, after *UserCursor, limit int) (users []*entity.User, err error) {
+	query := d.conn.WithContext(ctx)
+
+	if filter.IncludeDeleted {
+		query = query.Unscoped()
+	}
+
+	if filter.EmailPrefix != "" {
+		pattern := escapeLike(filter.EmailPrefix) + "%"
+		if filter.IncludeDeleted {
+			query = query.Where("(email_canonical LIKE ? OR deleted_email_canonical LIKE ?)", pattern, pattern)
+		} else {
+			query = query.Where("email_canonical LIKE ?", pattern)
+		}
+	}
+	if filter.NamePrefix != "" {
+		query = query.Where("name LIKE ?", escapeLike(filter.NamePrefix)+"%")
+	}
+	if filter.Role != "" {
+		query = query.Where("id IN (?)", d.conn.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ?", filter.Role))
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedUntil.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedUntil)
+	}
+
+	column := string(order.Field)
+	if column == "" {
+		column = string(UserSortCreatedAt)
+	}
+
+	direction, compare := "ASC", ">"
+	if order.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	if after != nil {
+		query = query.Where(
+			fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, compare),
+			after.Value, after.Value, after.ID,
+		)
+	}
+
+	err = query.
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(limit).
+		Find(&users).Error
+	return
+}
+
+func (d *database) PurgeDeleted(ctx context.Context, deletedBefore time.Time, limit int) (purged int64, err error) {
+	err = d.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		err := tx.Unscoped().Model(&entity.User{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		// the revocations stay , the access tokens issued before the deletion must not become valid again
+		dependents := []any{
+			&entity.RefreshToken{},
+			&entity.OneTimeToken{},
+			&entity.UserTOTP{},
+			&entity.RecoveryCode{},
+			&entity.WebAuthnCredential{},
+			&entity.WebAuthnSession{},
+			&entity.UserRole{},
+			&entity.UserStatusChange{},
+		}
+		for _, dependent := range dependents {
+			if err = tx.Where("user_id IN ?", ids).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&entity.User{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+
+	return purged, err
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern , backslash is the default escape character of MySQL
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// deletedEmailCanonical replaces the canonical email of a deleted user , it is unique and never a valid email
+func deletedEmailCanonical(id uuid.UUID) string {
+	return "deleted:" + id.String()
+}
+
+// isDuplicateKey reports whether err is a unique index violation.
+// gorm translates the errors of every driver to gorm.ErrDuplicatedKey when TranslateError is on ,
+// the MySQL error 1062 is checked too for connections opened without it.
+func isDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry
+}
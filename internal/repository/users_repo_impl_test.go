@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestIsDuplicateKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"translated", gorm.ErrDuplicatedKey, true},
+		{"mysql 1062", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'bob@example.com' for key 'users.idx_users_email_canonical'"}, true},
+		{"wrapped mysql 1062", fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062}), true},
+		{"other mysql error", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, false},
+		{"other error", errors.New("db error"), false},
+		{"not found", gorm.ErrRecordNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isDuplicateKey(tt.err))
+		})
+	}
+}
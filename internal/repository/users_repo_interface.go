@@ -2,18 +2,107 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/itmrchow/todolist-user/internal/entity"
 )
 
+var (
+	// ErrNoColumns is returned by Update when no column is given
+	ErrNoColumns = errors.New("no columns to update")
+	// ErrEmailAlreadyExists is returned by Create when another user has the canonical email
+	ErrEmailAlreadyExists = errors.New("email already exists")
+	// ErrStatusChanged is returned when the status of the user is not the FromStatus of the change anymore
+	ErrStatusChanged = errors.New("user status changed")
+)
+
+// UserSortField is a column ListUsers can sort by , the id breaks the ties
+type UserSortField string
+
+const (
+	UserSortCreatedAt UserSortField = "created_at"
+	UserSortEmail     UserSortField = "email_canonical"
+	UserSortName      UserSortField = "name"
+)
+
+// UserFilter selects the users of ListUsers , the zero value of a field doesn't filter
+type UserFilter struct {
+	// EmailPrefix matches the start of the canonical email
+	EmailPrefix string
+	NamePrefix  string
+	// Role lists the users that have the role
+	Role string
+	// Status lists the users in the status , the deleted users are only found with IncludeDeleted
+	Status entity.UserStatus
+	// CreatedFrom is inclusive , CreatedUntil is exclusive
+	CreatedFrom  time.Time
+	CreatedUntil time.Time
+	// IncludeDeleted lists the soft deleted users too , the email prefix matches their deleted email
+	IncludeDeleted bool
+}
+
+// UserOrder is the sort order of ListUsers
+type UserOrder struct {
+	Field UserSortField
+	Desc  bool
+}
+
+// UserCursor is the position of a user in a ListUsers order , the page after it starts with the next user
+type UserCursor struct {
+	ID uuid.UUID
+	// Value is the sort column of the user , a time.Time for created_at and a string otherwise
+	Value any
+}
+
+// NewUserCursor returns the position of the user in order
+func NewUserCursor(user *entity.User, order UserOrder) *UserCursor {
+	cursor := &UserCursor{ID: user.ID}
+
+	switch order.Field {
+	case UserSortEmail:
+		cursor.Value = user.EmailCanonical
+	case UserSortName:
+		cursor.Value = user.Name
+	default:
+		cursor.Value = user.CreatedAt
+	}
+
+	return cursor
+}
+
 type UsersRepository interface {
+	// Create inserts the user , the unique index on email_canonical makes it fail with ErrEmailAlreadyExists
+	// when the email is taken , also by a concurrent Create
 	Create(ctx context.Context, user *entity.User) error
 	Get(ctx context.Context, id uuid.UUID) (*entity.User, error)
-	GetByEmail(ctx context.Context, email string) (*entity.User, error)
-	Update(ctx context.Context, user *entity.User) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	ExistsByEmail(ctx context.Context, email string) (bool, error)
-	GetByEmailAndPassword(ctx context.Context, email, password string) (*entity.User, error)
+	// GetByEmail finds the user by the canonical email , see utils.NormalizeEmail
+	GetByEmail(ctx context.Context, emailCanonical string) (*entity.User, error)
+	// Update updates only the given columns , zero values included
+	Update(ctx context.Context, user *entity.User, columns ...string) error
+	// UpdatePassword updates only the password column with an already hashed password
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	// GetStatus returns only the status of the user , it fails with gorm.ErrRecordNotFound for a deleted user
+	GetStatus(ctx context.Context, id uuid.UUID) (entity.UserStatus, error)
+	// ChangeStatus changes the status of change.UserID from change.FromStatus to change.ToStatus and records the change ,
+	// it fails with ErrStatusChanged when a concurrent change came first
+	ChangeStatus(ctx context.Context, change *entity.UserStatusChange) error
+	// ListStatusChanges returns the status changes of the user , the oldest first
+	ListStatusChanges(ctx context.Context, userID uuid.UUID) ([]*entity.UserStatusChange, error)
+	// Delete soft deletes change.UserID , frees the canonical email and records the change to the deleted status ,
+	// it fails with gorm.ErrRecordNotFound when the user doesn't exist or is already deleted
+	Delete(ctx context.Context, change *entity.UserStatusChange) error
+	// GetDeletedByEmail finds the last deleted user of the canonical email
+	GetDeletedByEmail(ctx context.Context, emailCanonical string) (*entity.User, error)
+	// Restore undoes Delete and records the change out of the deleted status ,
+	// it fails with ErrEmailAlreadyExists when the email was registered again meanwhile
+	Restore(ctx context.Context, user *entity.User, change *entity.UserStatusChange) error
+	// ListUsers returns at most limit users matching filter in order , starting after the cursor when it is not nil.
+	// Paging by the position of the last user keeps the pages stable when users are created meanwhile.
+	ListUsers(ctx context.Context, filter UserFilter, order UserOrder, after *UserCursor, limit int) ([]*entity.User, error)
+	// PurgeDeleted hard deletes at most limit users deleted before deletedBefore with their tokens , MFA , passkeys , roles
+	// and status changes , it returns how many users were purged
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
 }
@@ -4,9 +4,10 @@ package repository
 
 import (
 	context "context"
+	time "time"
 
-	mock "github.com/stretchr/testify/mock"
 	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
 
 	entity "github.com/itmrchow/todolist-user/internal/entity"
 )
@@ -26,6 +27,53 @@ func (_m *MockUsersRepository) EXPECT() *MockUsersRepository_Expecter {
 	return &MockUsersRepository_Expecter{mock: &_m.Mock}
 }
 
+// ChangeStatus provides a mock function with given fields: ctx, change
+func (_m *MockUsersRepository) ChangeStatus(ctx context.Context, change *entity.UserStatusChange) error {
+	ret := _m.Called(ctx, change)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangeStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.UserStatusChange) error); ok {
+		r0 = rf(ctx, change)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockUsersRepository_ChangeStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ChangeStatus'
+type MockUsersRepository_ChangeStatus_Call struct {
+	*mock.Call
+}
+
+// ChangeStatus is a helper method to define mock.On call
+//   - ctx context.Context
+//   - change *entity.UserStatusChange
+func (_e *MockUsersRepository_Expecter) ChangeStatus(ctx interface{}, change interface{}) *MockUsersRepository_ChangeStatus_Call {
+	return &MockUsersRepository_ChangeStatus_Call{Call: _e.mock.On("ChangeStatus", ctx, change)}
+}
+
+func (_c *MockUsersRepository_ChangeStatus_Call) Run(run func(ctx context.Context, change *entity.UserStatusChange)) *MockUsersRepository_ChangeStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.UserStatusChange))
+	})
+	return _c
+}
+
+func (_c *MockUsersRepository_ChangeStatus_Call) Return(_a0 error) *MockUsersRepository_ChangeStatus_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockUsersRepository_ChangeStatus_Call) RunAndReturn(run func(context.Context, *entity.UserStatusChange) error) *MockUsersRepository_ChangeStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Create provides a mock function with given fields: ctx, user
 func (_m *MockUsersRepository) Create(ctx context.Context, user *entity.User) error {
 	ret := _m.Called(ctx, user)
@@ -73,17 +121,17 @@ func (_c *MockUsersRepository_Create_Call) RunAndReturn(run func(context.Context
 	return _c
 }
 
-// Delete provides a mock function with given fields: ctx, id
-func (_m *MockUsersRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	ret := _m.Called(ctx, id)
+// Delete provides a mock function with given fields: ctx, change
+func (_m *MockUsersRepository) Delete(ctx context.Context, change *entity.UserStatusChange) error {
+	ret := _m.Called(ctx, change)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Delete")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.UserStatusChange) error); ok {
+		r0 = rf(ctx, change)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -98,14 +146,14 @@ type MockUsersRepository_Delete_Call struct {
 
 // Delete is a helper method to define mock.On call
 //   - ctx context.Context
-//   - id uuid.UUID
-func (_e *MockUsersRepository_Expecter) Delete(ctx interface{}, id interface{}) *MockUsersRepository_Delete_Call {
-	return &MockUsersRepository_Delete_Call{Call: _e.mock.On("Delete", ctx, id)}
+//   - change *entity.UserStatusChange
+func (_e *MockUsersRepository_Expecter) Delete(ctx interface{}, change interface{}) *MockUsersRepository_Delete_Call {
+	return &MockUsersRepository_Delete_Call{Call: _e.mock.On("Delete", ctx, change)}
 }
 
-func (_c *MockUsersRepository_Delete_Call) Run(run func(ctx context.Context, id uuid.UUID)) *MockUsersRepository_Delete_Call {
+func (_c *MockUsersRepository_Delete_Call) Run(run func(ctx context.Context, change *entity.UserStatusChange)) *MockUsersRepository_Delete_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(uuid.UUID))
+		run(args[0].(context.Context), args[1].(*entity.UserStatusChange))
 	})
 	return _c
 }
@@ -115,32 +163,34 @@ func (_c *MockUsersRepository_Delete_Call) Return(_a0 error) *MockUsersRepositor
 	return _c
 }
 
-func (_c *MockUsersRepository_Delete_Call) RunAndReturn(run func(context.Context, uuid.UUID) error) *MockUsersRepository_Delete_Call {
+func (_c *MockUsersRepository_Delete_Call) RunAndReturn(run func(context.Context, *entity.UserStatusChange) error) *MockUsersRepository_Delete_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// ExistsByEmail provides a mock function with given fields: ctx, email
-func (_m *MockUsersRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
-	ret := _m.Called(ctx, email)
+// Get provides a mock function with given fields: ctx, id
+func (_m *MockUsersRepository) Get(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ExistsByEmail")
+		panic("no return value specified for Get")
 	}
 
-	var r0 bool
+	var r0 *entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
-		return rf(ctx, email)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*entity.User, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
-		r0 = rf(ctx, email)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *entity.User); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r0 = ret.Get(0).(bool)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.User)
+		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, email)
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -148,58 +198,58 @@ func (_m *MockUsersRepository) ExistsByEmail(ctx context.Context, email string)
 	return r0, r1
 }
 
-// MockUsersRepository_ExistsByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExistsByEmail'
-type MockUsersRepository_ExistsByEmail_Call struct {
+// MockUsersRepository_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type MockUsersRepository_Get_Call struct {
 	*mock.Call
 }
 
-// ExistsByEmail is a helper method to define mock.On call
+// Get is a helper method to define mock.On call
 //   - ctx context.Context
-//   - email string
-func (_e *MockUsersRepository_Expecter) ExistsByEmail(ctx interface{}, email interface{}) *MockUsersRepository_ExistsByEmail_Call {
-	return &MockUsersRepository_ExistsByEmail_Call{Call: _e.mock.On("ExistsByEmail", ctx, email)}
+//   - id uuid.UUID
+func (_e *MockUsersRepository_Expecter) Get(ctx interface{}, id interface{}) *MockUsersRepository_Get_Call {
+	return &MockUsersRepository_Get_Call{Call: _e.mock.On("Get", ctx, id)}
 }
 
-func (_c *MockUsersRepository_ExistsByEmail_Call) Run(run func(ctx context.Context, email string)) *MockUsersRepository_ExistsByEmail_Call {
+func (_c *MockUsersRepository_Get_Call) Run(run func(ctx context.Context, id uuid.UUID)) *MockUsersRepository_Get_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		run(args[0].(context.Context), args[1].(uuid.UUID))
 	})
 	return _c
 }
 
-func (_c *MockUsersRepository_ExistsByEmail_Call) Return(_a0 bool, _a1 error) *MockUsersRepository_ExistsByEmail_Call {
+func (_c *MockUsersRepository_Get_Call) Return(_a0 *entity.User, _a1 error) *MockUsersRepository_Get_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockUsersRepository_ExistsByEmail_Call) RunAndReturn(run func(context.Context, string) (bool, error)) *MockUsersRepository_ExistsByEmail_Call {
+func (_c *MockUsersRepository_Get_Call) RunAndReturn(run func(context.Context, uuid.UUID) (*entity.User, error)) *MockUsersRepository_Get_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Get provides a mock function with given fields: ctx, id
-func (_m *MockUsersRepository) Get(ctx context.Context, id uuid.UUID) (*entity.User, error) {
-	ret := _m.Called(ctx, id)
+// GetByEmail provides a mock function with given fields: ctx, emailCanonical
+func (_m *MockUsersRepository) GetByEmail(ctx context.Context, emailCanonical string) (*entity.User, error) {
+	ret := _m.Called(ctx, emailCanonical)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Get")
+		panic("no return value specified for GetByEmail")
 	}
 
 	var r0 *entity.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (*entity.User, error)); ok {
-		return rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.User, error)); ok {
+		return rf(ctx, emailCanonical)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) *entity.User); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.User); ok {
+		r0 = rf(ctx, emailCanonical)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*entity.User)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, emailCanonical)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -207,50 +257,50 @@ func (_m *MockUsersRepository) Get(ctx context.Context, id uuid.UUID) (*entity.U
 	return r0, r1
 }
 
-// MockUsersRepository_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
-type MockUsersRepository_Get_Call struct {
+// MockUsersRepository_GetByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByEmail'
+type MockUsersRepository_GetByEmail_Call struct {
 	*mock.Call
 }
 
-// Get is a helper method to define mock.On call
+// GetByEmail is a helper method to define mock.On call
 //   - ctx context.Context
-//   - id uuid.UUID
-func (_e *MockUsersRepository_Expecter) Get(ctx interface{}, id interface{}) *MockUsersRepository_Get_Call {
-	return &MockUsersRepository_Get_Call{Call: _e.mock.On("Get", ctx, id)}
+//   - emailCanonical string
+func (_e *MockUsersRepository_Expecter) GetByEmail(ctx interface{}, emailCanonical interface{}) *MockUsersRepository_GetByEmail_Call {
+	return &MockUsersRepository_GetByEmail_Call{Call: _e.mock.On("GetByEmail", ctx, emailCanonical)}
 }
 
-func (_c *MockUsersRepository_Get_Call) Run(run func(ctx context.Context, id uuid.UUID)) *MockUsersRepository_Get_Call {
+func (_c *MockUsersRepository_GetByEmail_Call) Run(run func(ctx context.Context, emailCanonical string)) *MockUsersRepository_GetByEmail_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(uuid.UUID))
+		run(args[0].(context.Context), args[1].(string))
 	})
 	return _c
 }
 
-func (_c *MockUsersRepository_Get_Call) Return(_a0 *entity.User, _a1 error) *MockUsersRepository_Get_Call {
+func (_c *MockUsersRepository_GetByEmail_Call) Return(_a0 *entity.User, _a1 error) *MockUsersRepository_GetByEmail_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockUsersRepository_Get_Call) RunAndReturn(run func(context.Context, uuid.UUID) (*entity.User, error)) *MockUsersRepository_Get_Call {
+func (_c *MockUsersRepository_GetByEmail_Call) RunAndReturn(run func(context.Context, string) (*entity.User, error)) *MockUsersRepository_GetByEmail_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetByEmail provides a mock function with given fields: ctx, email
-func (_m *MockUsersRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
-	ret := _m.Called(ctx, email)
+// GetDeletedByEmail provides a mock function with given fields: ctx, emailCanonical
+func (_m *MockUsersRepository) GetDeletedByEmail(ctx context.Context, emailCanonical string) (*entity.User, error) {
+	ret := _m.Called(ctx, emailCanonical)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetByEmail")
+		panic("no return value specified for GetDeletedByEmail")
 	}
 
 	var r0 *entity.User
 	var r1 error
 	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.User, error)); ok {
-		return rf(ctx, email)
+		return rf(ctx, emailCanonical)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.User); ok {
-		r0 = rf(ctx, email)
+		r0 = rf(ctx, emailCanonical)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*entity.User)
@@ -258,7 +308,7 @@ func (_m *MockUsersRepository) GetByEmail(ctx context.Context, email string) (*e
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, email)
+		r1 = rf(ctx, emailCanonical)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -266,58 +316,115 @@ func (_m *MockUsersRepository) GetByEmail(ctx context.Context, email string) (*e
 	return r0, r1
 }
 
-// MockUsersRepository_GetByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByEmail'
-type MockUsersRepository_GetByEmail_Call struct {
+// MockUsersRepository_GetDeletedByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetDeletedByEmail'
+type MockUsersRepository_GetDeletedByEmail_Call struct {
 	*mock.Call
 }
 
-// GetByEmail is a helper method to define mock.On call
+// GetDeletedByEmail is a helper method to define mock.On call
 //   - ctx context.Context
-//   - email string
-func (_e *MockUsersRepository_Expecter) GetByEmail(ctx interface{}, email interface{}) *MockUsersRepository_GetByEmail_Call {
-	return &MockUsersRepository_GetByEmail_Call{Call: _e.mock.On("GetByEmail", ctx, email)}
+//   - emailCanonical string
+func (_e *MockUsersRepository_Expecter) GetDeletedByEmail(ctx interface{}, emailCanonical interface{}) *MockUsersRepository_GetDeletedByEmail_Call {
+	return &MockUsersRepository_GetDeletedByEmail_Call{Call: _e.mock.On("GetDeletedByEmail", ctx, emailCanonical)}
 }
 
-func (_c *MockUsersRepository_GetByEmail_Call) Run(run func(ctx context.Context, email string)) *MockUsersRepository_GetByEmail_Call {
+func (_c *MockUsersRepository_GetDeletedByEmail_Call) Run(run func(ctx context.Context, emailCanonical string)) *MockUsersRepository_GetDeletedByEmail_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		run(args[0].(context.Context), args[1].(string))
 	})
 	return _c
 }
 
-func (_c *MockUsersRepository_GetByEmail_Call) Return(_a0 *entity.User, _a1 error) *MockUsersRepository_GetByEmail_Call {
+func (_c *MockUsersRepository_GetDeletedByEmail_Call) Return(_a0 *entity.User, _a1 error) *MockUsersRepository_GetDeletedByEmail_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockUsersRepository_GetByEmail_Call) RunAndReturn(run func(context.Context, string) (*entity.User, error)) *MockUsersRepository_GetByEmail_Call {
+func (_c *MockUsersRepository_GetDeletedByEmail_Call) RunAndReturn(run func(context.Context, string) (*entity.User, error)) *MockUsersRepository_GetDeletedByEmail_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetByEmailAndPassword provides a mock function with given fields: ctx, email, password
-func (_m *MockUsersRepository) GetByEmailAndPassword(ctx context.Context, email string, password string) (*entity.User, error) {
-	ret := _m.Called(ctx, email, password)
+// GetStatus provides a mock function with given fields: ctx, id
+func (_m *MockUsersRepository) GetStatus(ctx context.Context, id uuid.UUID) (entity.UserStatus, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetByEmailAndPassword")
+		panic("no return value specified for GetStatus")
 	}
 
-	var r0 *entity.User
+	var r0 entity.UserStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (entity.UserStatus, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) entity.UserStatus); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(entity.UserStatus)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockUsersRepository_GetStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetStatus'
+type MockUsersRepository_GetStatus_Call struct {
+	*mock.Call
+}
+
+// GetStatus is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+func (_e *MockUsersRepository_Expecter) GetStatus(ctx interface{}, id interface{}) *MockUsersRepository_GetStatus_Call {
+	return &MockUsersRepository_GetStatus_Call{Call: _e.mock.On("GetStatus", ctx, id)}
+}
+
+func (_c *MockUsersRepository_GetStatus_Call) Run(run func(ctx context.Context, id uuid.UUID)) *MockUsersRepository_GetStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockUsersRepository_GetStatus_Call) Return(_a0 entity.UserStatus, _a1 error) *MockUsersRepository_GetStatus_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockUsersRepository_GetStatus_Call) RunAndReturn(run func(context.Context, uuid.UUID) (entity.UserStatus, error)) *MockUsersRepository_GetStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListStatusChanges provides a mock function with given fields: ctx, userID
+func (_m *MockUsersRepository) ListStatusChanges(ctx context.Context, userID uuid.UUID) ([]*entity.UserStatusChange, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListStatusChanges")
+	}
+
+	var r0 []*entity.UserStatusChange
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*entity.User, error)); ok {
-		return rf(ctx, email, password)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]*entity.UserStatusChange, error)); ok {
+		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) *entity.User); ok {
-		r0 = rf(ctx, email, password)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*entity.UserStatusChange); ok {
+		r0 = rf(ctx, userID)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*entity.User)
+			r0 = ret.Get(0).([]*entity.UserStatusChange)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, email, password)
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -325,47 +432,221 @@ func (_m *MockUsersRepository) GetByEmailAndPassword(ctx context.Context, email
 	return r0, r1
 }
 
-// MockUsersRepository_GetByEmailAndPassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByEmailAndPassword'
-type MockUsersRepository_GetByEmailAndPassword_Call struct {
+// MockUsersRepository_ListStatusChanges_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListStatusChanges'
+type MockUsersRepository_ListStatusChanges_Call struct {
 	*mock.Call
 }
 
-// GetByEmailAndPassword is a helper method to define mock.On call
+// ListStatusChanges is a helper method to define mock.On call
 //   - ctx context.Context
-//   - email string
-//   - password string
-func (_e *MockUsersRepository_Expecter) GetByEmailAndPassword(ctx interface{}, email interface{}, password interface{}) *MockUsersRepository_GetByEmailAndPassword_Call {
-	return &MockUsersRepository_GetByEmailAndPassword_Call{Call: _e.mock.On("GetByEmailAndPassword", ctx, email, password)}
+//   - userID uuid.UUID
+func (_e *MockUsersRepository_Expecter) ListStatusChanges(ctx interface{}, userID interface{}) *MockUsersRepository_ListStatusChanges_Call {
+	return &MockUsersRepository_ListStatusChanges_Call{Call: _e.mock.On("ListStatusChanges", ctx, userID)}
 }
 
-func (_c *MockUsersRepository_GetByEmailAndPassword_Call) Run(run func(ctx context.Context, email string, password string)) *MockUsersRepository_GetByEmailAndPassword_Call {
+func (_c *MockUsersRepository_ListStatusChanges_Call) Run(run func(ctx context.Context, userID uuid.UUID)) *MockUsersRepository_ListStatusChanges_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string), args[2].(string))
+		run(args[0].(context.Context), args[1].(uuid.UUID))
 	})
 	return _c
 }
 
-func (_c *MockUsersRepository_GetByEmailAndPassword_Call) Return(_a0 *entity.User, _a1 error) *MockUsersRepository_GetByEmailAndPassword_Call {
+func (_c *MockUsersRepository_ListStatusChanges_Call) Return(_a0 []*entity.UserStatusChange, _a1 error) *MockUsersRepository_ListStatusChanges_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockUsersRepository_GetByEmailAndPassword_Call) RunAndReturn(run func(context.Context, string, string) (*entity.User, error)) *MockUsersRepository_GetByEmailAndPassword_Call {
+func (_c *MockUsersRepository_ListStatusChanges_Call) RunAndReturn(run func(context.Context, uuid.UUID) ([]*entity.UserStatusChange, error)) *MockUsersRepository_ListStatusChanges_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Update provides a mock function with given fields: ctx, user
-func (_m *MockUsersRepository) Update(ctx context.Context, user *entity.User) error {
-	ret := _m.Called(ctx, user)
+// ListUsers provides a mock function with given fields: ctx, filter, order, after, limit
+func (_m *MockUsersRepository) ListUsers(ctx context.Context, filter UserFilter, order UserOrder, after *UserCursor, limit int) ([]*entity.User, error) {
+	ret := _m.Called(ctx, filter, order, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUsers")
+	}
+
+	var r0 []*entity.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, UserFilter, UserOrder, *UserCursor, int) ([]*entity.User, error)); ok {
+		return rf(ctx, filter, order, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, UserFilter, UserOrder, *UserCursor, int) []*entity.User); ok {
+		r0 = rf(ctx, filter, order, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, UserFilter, UserOrder, *UserCursor, int) error); ok {
+		r1 = rf(ctx, filter, order, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockUsersRepository_ListUsers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListUsers'
+type MockUsersRepository_ListUsers_Call struct {
+	*mock.Call
+}
+
+// ListUsers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - filter UserFilter
+//   - order UserOrder
+//   - after *UserCursor
+//   - limit int
+func (_e *MockUsersRepository_Expecter) ListUsers(ctx interface{}, filter interface{}, order interface{}, after interface{}, limit interface{}) *MockUsersRepository_ListUsers_Call {
+	return &MockUsersRepository_ListUsers_Call{Call: _e.mock.On("ListUsers", ctx, filter, order, after, limit)}
+}
+
+func (_c *MockUsersRepository_ListUsers_Call) Run(run func(ctx context.Context, filter UserFilter, order UserOrder, after *UserCursor, limit int)) *MockUsersRepository_ListUsers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(UserFilter), args[2].(UserOrder), args[3].(*UserCursor), args[4].(int))
+	})
+	return _c
+}
+
+func (_c *MockUsersRepository_ListUsers_Call) Return(_a0 []*entity.User, _a1 error) *MockUsersRepository_ListUsers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockUsersRepository_ListUsers_Call) RunAndReturn(run func(context.Context, UserFilter, UserOrder, *UserCursor, int) ([]*entity.User, error)) *MockUsersRepository_ListUsers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeDeleted provides a mock function with given fields: ctx, deletedBefore, limit
+func (_m *MockUsersRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	ret := _m.Called(ctx, deletedBefore, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeleted")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) (int64, error)); ok {
+		return rf(ctx, deletedBefore, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) int64); ok {
+		r0 = rf(ctx, deletedBefore, limit)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, deletedBefore, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockUsersRepository_PurgeDeleted_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeDeleted'
+type MockUsersRepository_PurgeDeleted_Call struct {
+	*mock.Call
+}
+
+// PurgeDeleted is a helper method to define mock.On call
+//   - ctx context.Context
+//   - deletedBefore time.Time
+//   - limit int
+func (_e *MockUsersRepository_Expecter) PurgeDeleted(ctx interface{}, deletedBefore interface{}, limit interface{}) *MockUsersRepository_PurgeDeleted_Call {
+	return &MockUsersRepository_PurgeDeleted_Call{Call: _e.mock.On("PurgeDeleted", ctx, deletedBefore, limit)}
+}
+
+func (_c *MockUsersRepository_PurgeDeleted_Call) Run(run func(ctx context.Context, deletedBefore time.Time, limit int)) *MockUsersRepository_PurgeDeleted_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *MockUsersRepository_PurgeDeleted_Call) Return(_a0 int64, _a1 error) *MockUsersRepository_PurgeDeleted_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockUsersRepository_PurgeDeleted_Call) RunAndReturn(run func(context.Context, time.Time, int) (int64, error)) *MockUsersRepository_PurgeDeleted_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function with given fields: ctx, user, change
+func (_m *MockUsersRepository) Restore(ctx context.Context, user *entity.User, change *entity.UserStatusChange) error {
+	ret := _m.Called(ctx, user, change)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.User, *entity.UserStatusChange) error); ok {
+		r0 = rf(ctx, user, change)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockUsersRepository_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type MockUsersRepository_Restore_Call struct {
+	*mock.Call
+}
+
+// Restore is a helper method to define mock.On call
+//   - ctx context.Context
+//   - user *entity.User
+//   - change *entity.UserStatusChange
+func (_e *MockUsersRepository_Expecter) Restore(ctx interface{}, user interface{}, change interface{}) *MockUsersRepository_Restore_Call {
+	return &MockUsersRepository_Restore_Call{Call: _e.mock.On("Restore", ctx, user, change)}
+}
+
+func (_c *MockUsersRepository_Restore_Call) Run(run func(ctx context.Context, user *entity.User, change *entity.UserStatusChange)) *MockUsersRepository_Restore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.User), args[2].(*entity.UserStatusChange))
+	})
+	return _c
+}
+
+func (_c *MockUsersRepository_Restore_Call) Return(_a0 error) *MockUsersRepository_Restore_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockUsersRepository_Restore_Call) RunAndReturn(run func(context.Context, *entity.User, *entity.UserStatusChange) error) *MockUsersRepository_Restore_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, user, columns
+func (_m *MockUsersRepository) Update(ctx context.Context, user *entity.User, columns ...string) error {
+	_va := make([]interface{}, len(columns))
+	for _i := range columns {
+		_va[_i] = columns[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, user)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Update")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *entity.User) error); ok {
-		r0 = rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.User, ...string) error); ok {
+		r0 = rf(ctx, user, columns...)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -381,13 +662,21 @@ type MockUsersRepository_Update_Call struct {
 // Update is a helper method to define mock.On call
 //   - ctx context.Context
 //   - user *entity.User
-func (_e *MockUsersRepository_Expecter) Update(ctx interface{}, user interface{}) *MockUsersRepository_Update_Call {
-	return &MockUsersRepository_Update_Call{Call: _e.mock.On("Update", ctx, user)}
+//   - columns ...string
+func (_e *MockUsersRepository_Expecter) Update(ctx interface{}, user interface{}, columns ...interface{}) *MockUsersRepository_Update_Call {
+	return &MockUsersRepository_Update_Call{Call: _e.mock.On("Update",
+		append([]interface{}{ctx, user}, columns...)...)}
 }
 
-func (_c *MockUsersRepository_Update_Call) Run(run func(ctx context.Context, user *entity.User)) *MockUsersRepository_Update_Call {
+func (_c *MockUsersRepository_Update_Call) Run(run func(ctx context.Context, user *entity.User, columns ...string)) *MockUsersRepository_Update_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(*entity.User))
+		variadicArgs := make([]string, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(string)
+			}
+		}
+		run(args[0].(context.Context), args[1].(*entity.User), variadicArgs...)
 	})
 	return _c
 }
@@ -397,7 +686,55 @@ func (_c *MockUsersRepository_Update_Call) Return(_a0 error) *MockUsersRepositor
 	return _c
 }
 
-func (_c *MockUsersRepository_Update_Call) RunAndReturn(run func(context.Context, *entity.User) error) *MockUsersRepository_Update_Call {
+func (_c *MockUsersRepository_Update_Call) RunAndReturn(run func(context.Context, *entity.User, ...string) error) *MockUsersRepository_Update_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdatePassword provides a mock function with given fields: ctx, id, passwordHash
+func (_m *MockUsersRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	ret := _m.Called(ctx, id, passwordHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdatePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) error); ok {
+		r0 = rf(ctx, id, passwordHash)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockUsersRepository_UpdatePassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdatePassword'
+type MockUsersRepository_UpdatePassword_Call struct {
+	*mock.Call
+}
+
+// UpdatePassword is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+//   - passwordHash string
+func (_e *MockUsersRepository_Expecter) UpdatePassword(ctx interface{}, id interface{}, passwordHash interface{}) *MockUsersRepository_UpdatePassword_Call {
+	return &MockUsersRepository_UpdatePassword_Call{Call: _e.mock.On("UpdatePassword", ctx, id, passwordHash)}
+}
+
+func (_c *MockUsersRepository_UpdatePassword_Call) Run(run func(ctx context.Context, id uuid.UUID, passwordHash string)) *MockUsersRepository_UpdatePassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockUsersRepository_UpdatePassword_Call) Return(_a0 error) *MockUsersRepository_UpdatePassword_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockUsersRepository_UpdatePassword_Call) RunAndReturn(run func(context.Context, uuid.UUID, string) error) *MockUsersRepository_UpdatePassword_Call {
 	_c.Call.Return(run)
 	return _c
 }
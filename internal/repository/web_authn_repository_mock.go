@@ -0,0 +1,352 @@
+// Code generated by mockery v2.53.2. DO NOT EDIT.
+
+package repository
+
+import (
+	context "context"
+	time "time"
+
+	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
+
+	entity "github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ WebAuthnRepository = &MockWebAuthnRepository{}
+
+// MockWebAuthnRepository is an autogenerated mock type for the WebAuthnRepository type
+type MockWebAuthnRepository struct {
+	mock.Mock
+}
+
+type MockWebAuthnRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockWebAuthnRepository) EXPECT() *MockWebAuthnRepository_Expecter {
+	return &MockWebAuthnRepository_Expecter{mock: &_m.Mock}
+}
+
+// CreateCredential provides a mock function with given fields: ctx, credential
+func (_m *MockWebAuthnRepository) CreateCredential(ctx context.Context, credential *entity.WebAuthnCredential) error {
+	ret := _m.Called(ctx, credential)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateCredential")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.WebAuthnCredential) error); ok {
+		r0 = rf(ctx, credential)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWebAuthnRepository_CreateCredential_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateCredential'
+type MockWebAuthnRepository_CreateCredential_Call struct {
+	*mock.Call
+}
+
+// CreateCredential is a helper method to define mock.On call
+//   - ctx context.Context
+//   - credential *entity.WebAuthnCredential
+func (_e *MockWebAuthnRepository_Expecter) CreateCredential(ctx interface{}, credential interface{}) *MockWebAuthnRepository_CreateCredential_Call {
+	return &MockWebAuthnRepository_CreateCredential_Call{Call: _e.mock.On("CreateCredential", ctx, credential)}
+}
+
+func (_c *MockWebAuthnRepository_CreateCredential_Call) Run(run func(ctx context.Context, credential *entity.WebAuthnCredential)) *MockWebAuthnRepository_CreateCredential_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.WebAuthnCredential))
+	})
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_CreateCredential_Call) Return(_a0 error) *MockWebAuthnRepository_CreateCredential_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_CreateCredential_Call) RunAndReturn(run func(context.Context, *entity.WebAuthnCredential) error) *MockWebAuthnRepository_CreateCredential_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateSession provides a mock function with given fields: ctx, session
+func (_m *MockWebAuthnRepository) CreateSession(ctx context.Context, session *entity.WebAuthnSession) error {
+	ret := _m.Called(ctx, session)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSession")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entity.WebAuthnSession) error); ok {
+		r0 = rf(ctx, session)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWebAuthnRepository_CreateSession_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateSession'
+type MockWebAuthnRepository_CreateSession_Call struct {
+	*mock.Call
+}
+
+// CreateSession is a helper method to define mock.On call
+//   - ctx context.Context
+//   - session *entity.WebAuthnSession
+func (_e *MockWebAuthnRepository_Expecter) CreateSession(ctx interface{}, session interface{}) *MockWebAuthnRepository_CreateSession_Call {
+	return &MockWebAuthnRepository_CreateSession_Call{Call: _e.mock.On("CreateSession", ctx, session)}
+}
+
+func (_c *MockWebAuthnRepository_CreateSession_Call) Run(run func(ctx context.Context, session *entity.WebAuthnSession)) *MockWebAuthnRepository_CreateSession_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*entity.WebAuthnSession))
+	})
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_CreateSession_Call) Return(_a0 error) *MockWebAuthnRepository_CreateSession_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_CreateSession_Call) RunAndReturn(run func(context.Context, *entity.WebAuthnSession) error) *MockWebAuthnRepository_CreateSession_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteExpiredSessions provides a mock function with given fields: ctx, before
+func (_m *MockWebAuthnRepository) DeleteExpiredSessions(ctx context.Context, before time.Time) error {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiredSessions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWebAuthnRepository_DeleteExpiredSessions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteExpiredSessions'
+type MockWebAuthnRepository_DeleteExpiredSessions_Call struct {
+	*mock.Call
+}
+
+// DeleteExpiredSessions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - before time.Time
+func (_e *MockWebAuthnRepository_Expecter) DeleteExpiredSessions(ctx interface{}, before interface{}) *MockWebAuthnRepository_DeleteExpiredSessions_Call {
+	return &MockWebAuthnRepository_DeleteExpiredSessions_Call{Call: _e.mock.On("DeleteExpiredSessions", ctx, before)}
+}
+
+func (_c *MockWebAuthnRepository_DeleteExpiredSessions_Call) Run(run func(ctx context.Context, before time.Time)) *MockWebAuthnRepository_DeleteExpiredSessions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_DeleteExpiredSessions_Call) Return(_a0 error) *MockWebAuthnRepository_DeleteExpiredSessions_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_DeleteExpiredSessions_Call) RunAndReturn(run func(context.Context, time.Time) error) *MockWebAuthnRepository_DeleteExpiredSessions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListCredentials provides a mock function with given fields: ctx, userID
+func (_m *MockWebAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*entity.WebAuthnCredential, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListCredentials")
+	}
+
+	var r0 []*entity.WebAuthnCredential
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]*entity.WebAuthnCredential, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []*entity.WebAuthnCredential); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entity.WebAuthnCredential)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockWebAuthnRepository_ListCredentials_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListCredentials'
+type MockWebAuthnRepository_ListCredentials_Call struct {
+	*mock.Call
+}
+
+// ListCredentials is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID uuid.UUID
+func (_e *MockWebAuthnRepository_Expecter) ListCredentials(ctx interface{}, userID interface{}) *MockWebAuthnRepository_ListCredentials_Call {
+	return &MockWebAuthnRepository_ListCredentials_Call{Call: _e.mock.On("ListCredentials", ctx, userID)}
+}
+
+func (_c *MockWebAuthnRepository_ListCredentials_Call) Run(run func(ctx context.Context, userID uuid.UUID)) *MockWebAuthnRepository_ListCredentials_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_ListCredentials_Call) Return(_a0 []*entity.WebAuthnCredential, _a1 error) *MockWebAuthnRepository_ListCredentials_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_ListCredentials_Call) RunAndReturn(run func(context.Context, uuid.UUID) ([]*entity.WebAuthnCredential, error)) *MockWebAuthnRepository_ListCredentials_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// TakeSession provides a mock function with given fields: ctx, purpose, tokenHash
+func (_m *MockWebAuthnRepository) TakeSession(ctx context.Context, purpose entity.WebAuthnSessionPurpose, tokenHash string) (*entity.WebAuthnSession, error) {
+	ret := _m.Called(ctx, purpose, tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakeSession")
+	}
+
+	var r0 *entity.WebAuthnSession
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebAuthnSessionPurpose, string) (*entity.WebAuthnSession, error)); ok {
+		return rf(ctx, purpose, tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WebAuthnSessionPurpose, string) *entity.WebAuthnSession); ok {
+		r0 = rf(ctx, purpose, tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.WebAuthnSession)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, entity.WebAuthnSessionPurpose, string) error); ok {
+		r1 = rf(ctx, purpose, tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockWebAuthnRepository_TakeSession_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TakeSession'
+type MockWebAuthnRepository_TakeSession_Call struct {
+	*mock.Call
+}
+
+// TakeSession is a helper method to define mock.On call
+//   - ctx context.Context
+//   - purpose entity.WebAuthnSessionPurpose
+//   - tokenHash string
+func (_e *MockWebAuthnRepository_Expecter) TakeSession(ctx interface{}, purpose interface{}, tokenHash interface{}) *MockWebAuthnRepository_TakeSession_Call {
+	return &MockWebAuthnRepository_TakeSession_Call{Call: _e.mock.On("TakeSession", ctx, purpose, tokenHash)}
+}
+
+func (_c *MockWebAuthnRepository_TakeSession_Call) Run(run func(ctx context.Context, purpose entity.WebAuthnSessionPurpose, tokenHash string)) *MockWebAuthnRepository_TakeSession_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(entity.WebAuthnSessionPurpose), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_TakeSession_Call) Return(_a0 *entity.WebAuthnSession, _a1 error) *MockWebAuthnRepository_TakeSession_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_TakeSession_Call) RunAndReturn(run func(context.Context, entity.WebAuthnSessionPurpose, string) (*entity.WebAuthnSession, error)) *MockWebAuthnRepository_TakeSession_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateCredentialUsage provides a mock function with given fields: ctx, id, signCount, backupState, usedAt
+func (_m *MockWebAuthnRepository) UpdateCredentialUsage(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time) error {
+	ret := _m.Called(ctx, id, signCount, backupState, usedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateCredentialUsage")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uint32, bool, time.Time) error); ok {
+		r0 = rf(ctx, id, signCount, backupState, usedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockWebAuthnRepository_UpdateCredentialUsage_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateCredentialUsage'
+type MockWebAuthnRepository_UpdateCredentialUsage_Call struct {
+	*mock.Call
+}
+
+// UpdateCredentialUsage is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id uuid.UUID
+//   - signCount uint32
+//   - backupState bool
+//   - usedAt time.Time
+func (_e *MockWebAuthnRepository_Expecter) UpdateCredentialUsage(ctx interface{}, id interface{}, signCount interface{}, backupState interface{}, usedAt interface{}) *MockWebAuthnRepository_UpdateCredentialUsage_Call {
+	return &MockWebAuthnRepository_UpdateCredentialUsage_Call{Call: _e.mock.On("UpdateCredentialUsage", ctx, id, signCount, backupState, usedAt)}
+}
+
+func (_c *MockWebAuthnRepository_UpdateCredentialUsage_Call) Run(run func(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time)) *MockWebAuthnRepository_UpdateCredentialUsage_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(uint32), args[3].(bool), args[4].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_UpdateCredentialUsage_Call) Return(_a0 error) *MockWebAuthnRepository_UpdateCredentialUsage_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockWebAuthnRepository_UpdateCredentialUsage_Call) RunAndReturn(run func(context.Context, uuid.UUID, uint32, bool, time.Time) error) *MockWebAuthnRepository_UpdateCredentialUsage_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockWebAuthnRepository creates a new instance of MockWebAuthnRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockWebAuthnRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockWebAuthnRepository {
+	mock := &MockWebAuthnRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var _ WebAuthnRepository = &webAuthnDatabase{}
+
+type webAuthnDatabase struct {
+	conn *gorm.DB
+}
+
+func NewWebAuthnRepository(conn *gorm.DB) WebAuthnRepository {
+	return &webAuthnDatabase{
+		conn: conn,
+	}
+}
+
+func (d *webAuthnDatabase) ListCredentials(ctx context.Context, userID uuid.UUID) (credentials []*entity.WebAuthnCredential, err error) {
+	err = d.conn.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	return
+}
+
+func (d *webAuthnDatabase) CreateCredential(ctx context.Context, credential *entity.WebAuthnCredential) error {
+	err := d.conn.WithContext(ctx).Create(credential).Error
+	if isDuplicateKey(err) {
+		return ErrCredentialAlreadyExists
+	}
+	return err
+}
+
+func (d *webAuthnDatabase) UpdateCredentialUsage(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time) error {
+	return d.conn.WithContext(ctx).Model(&entity.WebAuthnCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"sign_count":   signCount,
+			"backup_state": backupState,
+			"last_used_at": usedAt,
+		}).Error
+}
+
+func (d *webAuthnDatabase) CreateSession(ctx context.Context, session *entity.WebAuthnSession) error {
+	return d.conn.WithContext(ctx).Create(session).Error
+}
+
+func (d *webAuthnDatabase) TakeSession(ctx context.Context, purpose entity.WebAuthnSessionPurpose, tokenHash string) (session *entity.WebAuthnSession, err error) {
+	if err := d.conn.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&session).Error; err != nil {
+		return nil, err
+	}
+
+	// only the request that deletes the row may finish the session
+	result := d.conn.WithContext(ctx).Where("id = ?", session.ID).Delete(&entity.WebAuthnSession{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWebAuthnSessionUsed
+	}
+
+	return
+}
+
+func (d *webAuthnDatabase) DeleteExpiredSessions(ctx context.Context, before time.Time) error {
+	return d.conn.WithContext(ctx).Where("expires_at < ?", before).Delete(&entity.WebAuthnSession{}).Error
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+var (
+	// ErrCredentialAlreadyExists is returned by CreateCredential when the credential id is registered already
+	ErrCredentialAlreadyExists = errors.New("webauthn credential already exists")
+	// ErrWebAuthnSessionUsed is returned by TakeSession when the session was taken by a concurrent request
+	ErrWebAuthnSessionUsed = errors.New("webauthn session already used")
+)
+
+type WebAuthnRepository interface {
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]*entity.WebAuthnCredential, error)
+	CreateCredential(ctx context.Context, credential *entity.WebAuthnCredential) error
+	// UpdateCredentialUsage stores the sign counter and the backup state of the last login with the credential
+	UpdateCredentialUsage(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, usedAt time.Time) error
+
+	CreateSession(ctx context.Context, session *entity.WebAuthnSession) error
+	// TakeSession returns the session and deletes it , a session can only be finished once
+	TakeSession(ctx context.Context, purpose entity.WebAuthnSessionPurpose, tokenHash string) (*entity.WebAuthnSession, error)
+	// DeleteExpiredSessions deletes the sessions that expired before the time
+	DeleteExpiredSessions(ctx context.Context, before time.Time) error
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"github.com/itmrchow/todolist-user/internal/entity"
+)
+
+// the permissions of the rpcs , the roles are made of them
+const (
+	PermissionUsersRead         = "users:read"
+	PermissionUsersRevokeTokens = "users:revoke_tokens"
+	PermissionRolesRead         = "roles:read"
+	PermissionRolesWrite        = "roles:write"
+	PermissionRolesAssign       = "roles:assign"
+	PermissionUsersManage       = "users:manage"
+	PermissionTokensIntrospect  = "tokens:introspect"
+)
+
+// Permissions are all the permissions , they are synced into the permissions table at startup
+var Permissions = []*entity.Permission{
+	{Name: PermissionUsersRead, Description: "list the users and their roles"},
+	{Name: PermissionUsersRevokeTokens, Description: "sign a user out of every session"},
+	{Name: PermissionRolesRead, Description: "list the roles and the permissions"},
+	{Name: PermissionRolesWrite, Description: "create , update and delete roles"},
+	{Name: PermissionRolesAssign, Description: "assign roles to users and unassign them"},
+	{Name: PermissionUsersManage, Description: "disable , enable and unlock accounts"},
+	{Name: PermissionTokensIntrospect, Description: "check the access tokens of the users , for other services"},
+}
+
+// MethodPermissions maps the rpcs to the permission they require , the permission interceptor enforces it.
+// The rpcs that are not listed only require a valid token , or none when they are public.
+var MethodPermissions = map[string]string{
+	"/user.UserService/ListUsers":                PermissionUsersRead,
+	"/user.UserService/ListUserRoles":            PermissionUsersRead,
+	"/user.UserService/ListAccountStatusChanges": PermissionUsersRead,
+	"/user.UserService/RevokeUserTokens":         PermissionUsersRevokeTokens,
+	"/user.UserService/ListPermissions":          PermissionRolesRead,
+	"/user.UserService/ListRoles":                PermissionRolesRead,
+	"/user.UserService/CreateRole":               PermissionRolesWrite,
+	"/user.UserService/UpdateRole":               PermissionRolesWrite,
+	"/user.UserService/DeleteRole":               PermissionRolesWrite,
+	"/user.UserService/AssignRole":               PermissionRolesAssign,
+	"/user.UserService/UnassignRole":             PermissionRolesAssign,
+	"/user.UserService/DisableAccount":           PermissionUsersManage,
+	"/user.UserService/EnableAccount":            PermissionUsersManage,
+	"/user.UserService/UnlockAccount":            PermissionUsersManage,
+	"/user.UserService/VerifyToken":              PermissionTokensIntrospect,
+}
+
+// permissionNames returns the names of all the permissions
+func permissionNames() []string {
+	names := make([]string, 0, len(Permissions))
+	for _, permission := range Permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// DeleteAccount deletes the authenticated user and logs out every session.
+// It needs the password , or an access token of a login within ACCOUNT_DELETION_FRESH_TOKEN_AGE ,
+// a refreshed token keeps the time of the login.
+// The account can be restored with RestoreAccount until purge_at , then it is purged with all its data.
+func (u *userServiceImpl) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (resp *pb.DeleteAccountResponse, err error) {
+	claims, userID, err := authenticatedClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if err = u.checkReauthentication(ctx, claims, user, req.Password, now); err != nil {
+		return nil, err
+	}
+
+	if !user.Status.CanTransitionTo(entity.UserStatusDeleted) {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrInvalidStatusTransition)
+	}
+
+	err = u.userRepo.Delete(ctx, entity.NewUserStatusChange(user, entity.UserStatusDeleted, statusReasonAccountDeleted, &user.ID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, status.Error(codes.NotFound, mErr.ErrUserNotFound)
+	}
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return nil, status.Error(codes.Aborted, mErr.ErrAccountStatusChanged)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Delete user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.revokeAllTokens(ctx, user.ID, now); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("account deleted")
+
+	return &pb.DeleteAccountResponse{
+		PurgeAt: timestamppb.New(now.Add(u.accountConfig.DeletionGracePeriod)),
+	}, nil
+}
+
+// checkReauthentication checks the password , or without a password that the access token comes from a login
+// within ACCOUNT_DELETION_FRESH_TOKEN_AGE , before a change a stolen access token must not make
+func (u *userServiceImpl) checkReauthentication(ctx context.Context, claims *utils.Claims, user *entity.User, password string, now time.Time) error {
+	if password == "" {
+		if claims.AuthTime == nil || now.Sub(claims.AuthTime.Time) > u.accountConfig.FreshTokenAge {
+			return status.Error(codes.PermissionDenied, mErr.ErrReauthenticationRequired)
+		}
+		return nil
+	}
+
+	if err := u.checkUserPassword(ctx, user, password); err != nil {
+		return err
+	}
+	u.loginSucceeded(ctx, user.EmailCanonical)
+
+	return nil
+}
+
+// RestoreAccount undoes DeleteAccount within the grace period , the user logs in with Login afterwards.
+// It checks the password like Login , so the failures are throttled the same way.
+func (u *userServiceImpl) RestoreAccount(ctx context.Context, req *pb.RestoreAccountRequest) (resp *protobuf.EmptyResponse, err error) {
+	v := validation.New()
+	email := v.CanonicalEmail("email", req.Email, entity.UserEmailMaxLength, u.lowercaseEmailLocalPart)
+	v.Required("password", req.Password)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	clientIP, _ := interceptor.ClientIPFromContext(ctx)
+	if err = u.checkLoginLimit(ctx, email, clientIP); err != nil {
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetDeletedByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error().Err(err).Msg("GetDeletedByEmail error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	// an account past the grace period waits for the purge , it can't be restored anymore
+	if user == nil || !u.isRestorable(user, time.Now()) {
+		dummyUser().CheckPassword(req.Password)
+		u.loginFailed(ctx, email, clientIP)
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidLoginInfo)
+	}
+
+	if match, _ := user.CheckPassword(req.Password); !match {
+		u.loginFailed(ctx, email, clientIP)
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidLoginInfo)
+	}
+
+	u.loginSucceeded(ctx, email)
+
+	// back to active , or pending_verification when the email was never verified
+	err = u.userRepo.Restore(ctx, user, entity.NewUserStatusChange(user, user.UsableStatus(), statusReasonAccountRestored, &user.ID))
+	if errors.Is(err, repository.ErrEmailAlreadyExists) {
+		// the email was registered again after the deletion
+		return nil, status.Error(codes.AlreadyExists, mErr.ErrEmailAlreadyExists)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// purged or restored meanwhile
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidLoginInfo)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Restore user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("account restored")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// isRestorable reports whether the deleted user is still within the grace period
+func (u *userServiceImpl) isRestorable(user *entity.User, now time.Time) bool {
+	return user.DeletedAt.Valid && now.Before(user.DeletedAt.Time.Add(u.accountConfig.DeletionGracePeriod))
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestAccountTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountTestSuite))
+}
+
+type AccountTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	user                 *entity.User
+}
+
+func (s *AccountTestSuite) SetupTest() {
+	viper.Set("ACCOUNT_DELETION_GRACE_PERIOD", "720h")
+	viper.Set("ACCOUNT_DELETION_FRESH_TOKEN_AGE", "5m")
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.userService = s.newUserService(nil)
+
+	s.user = &entity.User{
+		ID:             uuid.New(),
+		Name:           "test",
+		Email:          "test@example.com",
+		EmailCanonical: "test@example.com",
+		Password:       "password",
+		Status:         entity.UserStatusActive,
+	}
+	s.Require().NoError(s.user.HashPassword())
+}
+
+func (s *AccountTestSuite) newUserService(loginLimiter *limiter.LoginLimiter) pb.UserServiceServer {
+	return newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+		LoginLimiter:     loginLimiter,
+	})
+}
+
+func (s *AccountTestSuite) assertCode(code codes.Code, message string, err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(code, rpcErr.Code())
+	s.Assert().Equal(message, rpcErr.Message())
+}
+
+// tokenContext is the context of a request with an access token issued at issuedAt for a login at authTime
+func (s *AccountTestSuite) tokenContext(issuedAt time.Time, authTime time.Time) context.Context {
+	return interceptor.ContextWithClaims(context.Background(), &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   s.user.ID.String(),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+		},
+		AuthTime: jwt.NewNumericDate(authTime),
+	})
+}
+
+// deletedUser is the user deleted at deletedAt as GetDeletedByEmail returns it
+func (s *AccountTestSuite) deletedUser(deletedAt time.Time) *entity.User {
+	user := *s.user
+	user.DeletedAt = gorm.DeletedAt{Time: deletedAt, Valid: true}
+	user.DeletedEmailCanonical = &s.user.EmailCanonical
+	user.EmailCanonical = "deleted:" + s.user.ID.String()
+	user.Status = entity.UserStatusDeleted
+	return &user
+}
+
+// statusChange matches the status change of the user made by the user
+func (s *AccountTestSuite) statusChange(from entity.UserStatus, to entity.UserStatus) any {
+	return mock.MatchedBy(func(change *entity.UserStatusChange) bool {
+		return change.UserID == s.user.ID && change.FromStatus == from && change.ToStatus == to &&
+			change.ActorID != nil && *change.ActorID == s.user.ID
+	})
+}
+
+func (s *AccountTestSuite) expectDeleted(ctx context.Context) {
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Delete(ctx, s.statusChange(entity.UserStatusActive, entity.UserStatusDeleted)).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(ctx, s.user.ID, mock.Anything).Return(nil)
+
+	// the token of the call is revoked by its jti too
+	claims, _ := interceptor.ClaimsFromContext(ctx)
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(ctx, claims.ID, s.user.ID, claims.ExpiresAt.Time).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(ctx, s.user.ID, mock.Anything).Return(nil)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_WithPassword() {
+	// input , the token is too old to delete without the password
+	loginAt := time.Now().Add(-time.Hour)
+	ctx := s.tokenContext(loginAt, loginAt)
+
+	// mock
+	s.expectDeleted(ctx)
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "password"})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().WithinDuration(time.Now().Add(720*time.Hour), resp.PurgeAt.AsTime(), time.Minute)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_WithFreshToken() {
+	// input
+	loginAt := time.Now().Add(-time.Minute)
+	ctx := s.tokenContext(loginAt, loginAt)
+
+	// mock
+	s.expectDeleted(ctx)
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp.PurgeAt)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_StaleToken() {
+	// input
+	loginAt := time.Now().Add(-10 * time.Minute)
+	ctx := s.tokenContext(loginAt, loginAt)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, "password or a recent login required", err)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_RefreshedToken() {
+	// input , the token was just refreshed but the login is old
+	ctx := s.tokenContext(time.Now(), time.Now().Add(-time.Hour))
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, "password or a recent login required", err)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_IncorrectPassword() {
+	// input , a fresh token doesn't make a wrong password right
+	ctx := s.tokenContext(time.Now(), time.Now())
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "wrong_password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, "incorrect password", err)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_Lockout() {
+	s.userService = s.newUserService(limiter.NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), limiter.LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+		MaxAccountFailures: 2,
+	}))
+
+	// input
+	loginAt := time.Now().Add(-time.Hour)
+	ctx := s.tokenContext(loginAt, loginAt)
+	req := &pb.DeleteAccountRequest{Password: "wrong_password"}
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil).Times(3)
+
+	// execute
+	for i := 0; i < 2; i++ {
+		_, err := s.userService.DeleteAccount(ctx, req)
+		s.assertCode(codes.PermissionDenied, "incorrect password", err)
+	}
+
+	// the right password is refused too while locked
+	req.Password = "password"
+	resp, err := s.userService.DeleteAccount(ctx, req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_AlreadyDeleted() {
+	// input
+	ctx := s.tokenContext(time.Now(), time.Now())
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Delete(ctx, s.statusChange(entity.UserStatusActive, entity.UserStatusDeleted)).Return(gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.NotFound, "user not found", err)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_DBError() {
+	// input
+	ctx := s.tokenContext(time.Now(), time.Now())
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Delete(ctx, s.statusChange(entity.UserStatusActive, entity.UserStatusDeleted)).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.DeleteAccount(ctx, &pb.DeleteAccountRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Internal, "internal server error", err)
+}
+
+func (s *AccountTestSuite) Test_DeleteAccount_Unauthenticated() {
+	// execute
+	resp, err := s.userService.DeleteAccount(context.Background(), &pb.DeleteAccountRequest{Password: "password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_Success() {
+	// input
+	ctx := context.Background()
+	deleted := s.deletedUser(time.Now().Add(-24 * time.Hour))
+
+	// mock
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(ctx, "test@example.com").Return(deleted, nil)
+	s.mockUserRepo.EXPECT().Restore(ctx, deleted, s.statusChange(entity.UserStatusDeleted, entity.UserStatusPendingVerification)).Return(nil)
+
+	// execute
+	resp, err := s.userService.RestoreAccount(ctx, &pb.RestoreAccountRequest{
+		Email:    " test@Example.com ",
+		Password: "password",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_GracePeriodOver() {
+	// input
+	ctx := context.Background()
+
+	// mock
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(ctx, "test@example.com").Return(s.deletedUser(time.Now().Add(-721*time.Hour)), nil)
+
+	// execute
+	resp, err := s.userService.RestoreAccount(ctx, &pb.RestoreAccountRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid login info", err)
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_NotDeleted() {
+	// input
+	ctx := context.Background()
+
+	// mock
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(ctx, "test@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.RestoreAccount(ctx, &pb.RestoreAccountRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid login info", err)
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_IncorrectPassword() {
+	// input
+	ctx := context.Background()
+
+	// mock
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(ctx, "test@example.com").Return(s.deletedUser(time.Now()), nil)
+
+	// execute
+	resp, err := s.userService.RestoreAccount(ctx, &pb.RestoreAccountRequest{
+		Email:    "test@example.com",
+		Password: "wrong_password",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid login info", err)
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_EmailTaken() {
+	// input
+	ctx := context.Background()
+	deleted := s.deletedUser(time.Now())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(ctx, "test@example.com").Return(deleted, nil)
+	s.mockUserRepo.EXPECT().Restore(ctx, deleted, s.statusChange(entity.UserStatusDeleted, entity.UserStatusPendingVerification)).Return(repository.ErrEmailAlreadyExists)
+
+	// execute
+	resp, err := s.userService.RestoreAccount(ctx, &pb.RestoreAccountRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.AlreadyExists, "email already exists", err)
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_Lockout() {
+	s.userService = s.newUserService(limiter.NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), limiter.LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+		MaxAccountFailures: 2,
+	}))
+
+	// input
+	ctx := interceptor.ContextWithClientIP(context.Background(), "203.0.113.7")
+	req := &pb.RestoreAccountRequest{
+		Email:    "test@example.com",
+		Password: "wrong_password",
+	}
+
+	// mock , the user is only looked up before the lock
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(ctx, "test@example.com").Return(s.deletedUser(time.Now()), nil).Times(2)
+
+	// execute
+	for i := 0; i < 2; i++ {
+		_, err := s.userService.RestoreAccount(ctx, req)
+		s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+	}
+
+	req.Password = "password"
+	resp, err := s.userService.RestoreAccount(ctx, req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *AccountTestSuite) Test_RestoreAccount_InvalidRequest() {
+	// execute
+	resp, err := s.userService.RestoreAccount(context.Background(), &pb.RestoreAccountRequest{Email: "not an email"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+}
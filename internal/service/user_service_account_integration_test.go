@@ -0,0 +1,147 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/repository"
+)
+
+func TestDeleteAccount_EmailReuse(t *testing.T) {
+	db := newIntegrationDB(t, true)
+	require.NoError(t, db.AutoMigrate(
+		&entity.RefreshToken{},
+		&entity.OneTimeToken{},
+		&entity.UserTOTP{},
+		&entity.RecoveryCode{},
+		&entity.WebAuthnCredential{},
+		&entity.WebAuthnSession{},
+		&entity.UserStatusChange{},
+	))
+
+	const email = "deleted@example.com"
+	deleteUsers := func() {
+		require.NoError(t, db.Unscoped().
+			Where("email_canonical = ? OR deleted_email_canonical = ?", email, email).
+			Delete(&entity.User{}).Error)
+	}
+	deleteUsers()
+	t.Cleanup(deleteUsers)
+
+	ctx := context.Background()
+	userRepo := repository.NewUsersRepository(db)
+
+	newUser := func() *entity.User {
+		return &entity.User{ID: uuid.New(), Name: "test", Email: email, EmailCanonical: email, Password: "x", Status: entity.UserStatusActive}
+	}
+	deleteChange := func(user *entity.User) *entity.UserStatusChange {
+		return entity.NewUserStatusChange(user, entity.UserStatusDeleted, statusReasonAccountDeleted, nil)
+	}
+	restoreChange := func(user *entity.User) *entity.UserStatusChange {
+		return entity.NewUserStatusChange(user, entity.UserStatusActive, statusReasonAccountRestored, nil)
+	}
+
+	// the deleted user can't log in , and its email is free
+	deleted := newUser()
+	require.NoError(t, userRepo.Create(ctx, deleted))
+	require.NoError(t, db.Create(&entity.RefreshToken{
+		ID: uuid.New(), UserID: deleted.ID, FamilyID: uuid.New(), TokenHash: uuid.NewString(), ExpiresAt: time.Now().Add(time.Hour),
+	}).Error)
+
+	require.NoError(t, userRepo.Delete(ctx, deleteChange(deleted)))
+	assert.ErrorIs(t, userRepo.Delete(ctx, deleteChange(deleted)), gorm.ErrRecordNotFound)
+
+	_, err := userRepo.GetByEmail(ctx, email)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	found, err := userRepo.GetDeletedByEmail(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, deleted.ID, found.ID)
+	assert.Equal(t, entity.UserStatusDeleted, found.Status)
+
+	// restored while the email is still free
+	require.NoError(t, userRepo.Restore(ctx, found, restoreChange(found)))
+	restored, err := userRepo.GetByEmail(ctx, email)
+	require.NoError(t, err)
+	assert.Equal(t, deleted.ID, restored.ID)
+	assert.Equal(t, entity.UserStatusActive, restored.Status)
+
+	changes, err := userRepo.ListStatusChanges(ctx, deleted.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, entity.UserStatusDeleted, changes[0].ToStatus)
+	assert.Equal(t, entity.UserStatusActive, changes[1].ToStatus)
+
+	// deleted again and the email registered by someone else , so it can't be restored anymore
+	require.NoError(t, userRepo.Delete(ctx, deleteChange(restored)))
+	require.NoError(t, userRepo.Create(ctx, newUser()))
+
+	found, err = userRepo.GetDeletedByEmail(ctx, email)
+	require.NoError(t, err)
+	assert.ErrorIs(t, userRepo.Restore(ctx, found, restoreChange(found)), repository.ErrEmailAlreadyExists)
+
+	// the purge removes the user and its tokens , not the new account
+	purged, err := userRepo.PurgeDeleted(ctx, time.Now().Add(time.Minute), 100)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+
+	_, err = userRepo.GetDeletedByEmail(ctx, email)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	var tokens int64
+	require.NoError(t, db.Model(&entity.RefreshToken{}).Where("user_id = ?", deleted.ID).Count(&tokens).Error)
+	assert.Zero(t, tokens)
+	var statusChanges int64
+	require.NoError(t, db.Model(&entity.UserStatusChange{}).Where("user_id = ?", deleted.ID).Count(&statusChanges).Error)
+	assert.Zero(t, statusChanges)
+
+	_, err = userRepo.GetByEmail(ctx, email)
+	assert.NoError(t, err)
+}
+
+func TestChangeStatus_Concurrent(t *testing.T) {
+	db := newIntegrationDB(t, true)
+	require.NoError(t, db.AutoMigrate(&entity.UserStatusChange{}))
+
+	ctx := context.Background()
+	userRepo := repository.NewUsersRepository(db)
+
+	const email = "status@example.com"
+	user := &entity.User{ID: uuid.New(), Name: "test", Email: email, EmailCanonical: email, Password: "x", Status: entity.UserStatusActive}
+	require.NoError(t, db.Unscoped().Where("email_canonical = ?", email).Delete(&entity.User{}).Error)
+	require.NoError(t, userRepo.Create(ctx, user))
+	t.Cleanup(func() {
+		db.Where("user_id = ?", user.ID).Delete(&entity.UserStatusChange{})
+		db.Unscoped().Where("id = ?", user.ID).Delete(&entity.User{})
+	})
+
+	// both changes read the user while it was active , only the first one applies
+	disable := entity.NewUserStatusChange(user, entity.UserStatusDisabled, "spam", nil)
+	lock := entity.NewUserStatusChange(user, entity.UserStatusLocked, statusReasonRefreshTokenUsed, nil)
+
+	require.NoError(t, userRepo.ChangeStatus(ctx, disable))
+	assert.ErrorIs(t, userRepo.ChangeStatus(ctx, lock), repository.ErrStatusChanged)
+
+	got, err := userRepo.GetStatus(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserStatusDisabled, got)
+
+	changes, err := userRepo.ListStatusChanges(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, entity.UserStatusActive, changes[0].FromStatus)
+	assert.Equal(t, entity.UserStatusDisabled, changes[0].ToStatus)
+
+	// an unknown user is not a concurrent change
+	missing := entity.NewUserStatusChange(&entity.User{ID: uuid.New(), Status: entity.UserStatusActive}, entity.UserStatusDisabled, "spam", nil)
+	assert.ErrorIs(t, userRepo.ChangeStatus(ctx, missing), gorm.ErrRecordNotFound)
+}
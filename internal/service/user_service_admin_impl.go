@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+)
+
+const (
+	defaultListUsersPageSize = 50
+	maxListUsersPageSize     = 200
+)
+
+// listUsersOrders are the accepted order_by values , the default is the newest users first
+var listUsersOrders = map[string]repository.UserOrder{
+	"":                {Field: repository.UserSortCreatedAt, Desc: true},
+	"created_at":      {Field: repository.UserSortCreatedAt},
+	"created_at asc":  {Field: repository.UserSortCreatedAt},
+	"created_at desc": {Field: repository.UserSortCreatedAt, Desc: true},
+	"email":           {Field: repository.UserSortEmail},
+	"email asc":       {Field: repository.UserSortEmail},
+	"email desc":      {Field: repository.UserSortEmail, Desc: true},
+	"name":            {Field: repository.UserSortName},
+	"name asc":        {Field: repository.UserSortName},
+	"name desc":       {Field: repository.UserSortName, Desc: true},
+}
+
+// ListUsers lists the users , a page at a time.
+// next_page_token continues the same query , it is refused with other filters or another order.
+func (u *userServiceImpl) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (resp *pb.ListUsersResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionUsersRead); err != nil {
+		return nil, err
+	}
+
+	filter := repository.UserFilter{
+		EmailPrefix:    canonicalEmailPrefix(req.EmailPrefix, u.lowercaseEmailLocalPart),
+		NamePrefix:     strings.TrimSpace(req.NamePrefix),
+		Role:           strings.TrimSpace(req.Role),
+		Status:         entity.UserStatus(strings.ToLower(strings.TrimSpace(req.Status))),
+		IncludeDeleted: req.IncludeDeleted,
+	}
+	// the deleted users are listed only with include_deleted
+	if filter.Status == entity.UserStatusDeleted {
+		filter.IncludeDeleted = true
+	}
+	if req.CreatedAfter != nil {
+		filter.CreatedFrom = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		filter.CreatedUntil = req.CreatedBefore.AsTime()
+	}
+
+	order, orderOK := listUsersOrders[strings.ToLower(strings.Join(strings.Fields(req.OrderBy), " "))]
+
+	v := validation.New()
+	v.Check(req.PageSize >= 0, "page_size", "must not be negative")
+	v.Check(orderOK, "order_by", "must be created_at , email or name , optionally followed by asc or desc")
+	v.Check(filter.Status == "" || filter.Status.IsValid(), "status",
+		"must be active , pending_verification , disabled , locked or deleted")
+	v.Check(req.CreatedAfter == nil || req.CreatedAfter.IsValid(), "created_after", "must be a valid timestamp")
+	v.Check(req.CreatedBefore == nil || req.CreatedBefore.IsValid(), "created_before", "must be a valid timestamp")
+	v.Check(filter.CreatedFrom.IsZero() || filter.CreatedUntil.IsZero() || filter.CreatedFrom.Before(filter.CreatedUntil),
+		"created_before", "must be after created_after")
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultListUsersPageSize
+	}
+	pageSize = min(pageSize, maxListUsersPageSize)
+
+	query := listUsersQuery(filter, order)
+
+	var after *repository.UserCursor
+	if req.PageToken != "" {
+		if after, err = decodeUsersPageToken(req.PageToken, query, order); err != nil {
+			return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidPageToken)
+		}
+	}
+
+	// one more user tells whether there is a next page
+	users, err := u.userRepo.ListUsers(ctx, filter, order, after, pageSize+1)
+	if err != nil {
+		log.Error().Err(err).Msg("ListUsers error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	resp = &pb.ListUsersResponse{
+		Users: make([]*pb.UserSummary, 0, min(len(users), pageSize)),
+	}
+
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		resp.NextPageToken, err = encodeUsersPageToken(query, repository.NewUserCursor(users[len(users)-1], order))
+		if err != nil {
+			log.Error().Err(err).Msg("encode page token error")
+			return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+		}
+	}
+
+	for _, user := range users {
+		resp.Users = append(resp.Users, toUserSummary(user))
+	}
+
+	return resp, nil
+}
+
+func toUserSummary(user *entity.User) *pb.UserSummary {
+	summary := &pb.UserSummary{
+		Id:            user.ID.String(),
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		Status:        string(user.Status),
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+	}
+
+	if user.DeletedAt.Valid {
+		summary.DeletedAt = timestamppb.New(user.DeletedAt.Time)
+	}
+
+	return summary
+}
+
+// canonicalEmailPrefix lowercases the prefix like utils.NormalizeEmail does with a whole email
+func canonicalEmailPrefix(prefix string, lowercaseLocalPart bool) string {
+	prefix = strings.TrimSpace(prefix)
+
+	at := strings.LastIndex(prefix, "@")
+	if at < 0 {
+		if lowercaseLocalPart {
+			return strings.ToLower(prefix)
+		}
+		return prefix
+	}
+
+	local, domain := prefix[:at], prefix[at:]
+	if lowercaseLocalPart {
+		local = strings.ToLower(local)
+	}
+
+	return local + strings.ToLower(domain)
+}
+
+// usersPageToken is the position of the last user of a page , base64 encoded json in next_page_token
+type usersPageToken struct {
+	// Query identifies the filters and the order the token was made for
+	Query string    `json:"q"`
+	ID    uuid.UUID `json:"id"`
+	Time  time.Time `json:"t,omitempty"`
+	Value string    `json:"v,omitempty"`
+}
+
+// listUsersQuery is a short hash of the filters and the order
+func listUsersQuery(filter repository.UserFilter, order repository.UserOrder) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%q|%q|%q|%q|%d|%d|%t|%s|%t",
+		filter.EmailPrefix,
+		filter.NamePrefix,
+		filter.Role,
+		filter.Status,
+		filter.CreatedFrom.UnixNano(),
+		filter.CreatedUntil.UnixNano(),
+		filter.IncludeDeleted,
+		order.Field,
+		order.Desc,
+	))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// encodeUsersPageToken fails when the cursor time can't be marshaled , e.g. a year after 9999
+func encodeUsersPageToken(query string, cursor *repository.UserCursor) (string, error) {
+	token := usersPageToken{Query: query, ID: cursor.ID}
+
+	switch value := cursor.Value.(type) {
+	case time.Time:
+		token.Time = value
+	case string:
+		token.Value = value
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeUsersPageToken(pageToken string, query string, order repository.UserOrder) (*repository.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var token usersPageToken
+	if err = json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+
+	if token.Query != query {
+		return nil, fmt.Errorf("page token of another query")
+	}
+
+	cursor := &repository.UserCursor{ID: token.ID}
+	if order.Field == repository.UserSortCreatedAt {
+		cursor.Value = token.Time
+	} else {
+		cursor.Value = token.Value
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestListUsersTestSuite(t *testing.T) {
+	suite.Run(t, new(ListUsersTestSuite))
+}
+
+type ListUsersTestSuite struct {
+	suite.Suite
+	userService  pb.UserServiceServer
+	mockUserRepo *repository.MockUsersRepository
+	ctx          context.Context
+}
+
+func (s *ListUsersTestSuite) SetupTest() {
+	adminID := uuid.NewString()
+	viper.Set("AUTH_ADMIN_USER_IDS", []string{adminID})
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo: s.mockUserRepo,
+		KeyRing:  newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+	s.ctx = interceptor.ContextWithUserID(context.Background(), adminID)
+}
+
+func newListedUsers(count int) []*entity.User {
+	users := make([]*entity.User, count)
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range users {
+		users[i] = &entity.User{
+			ID:             uuid.New(),
+			Name:           "test",
+			Email:          "test@example.com",
+			EmailCanonical: "test@example.com",
+			Status:         entity.UserStatusActive,
+			CreatedAt:      createdAt.Add(-time.Duration(i) * time.Minute),
+			UpdatedAt:      createdAt,
+		}
+	}
+	return users
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_NotAdmin() {
+	ctx := interceptor.ContextWithUserID(context.Background(), uuid.NewString())
+
+	// execute
+	resp, err := s.userService.ListUsers(ctx, &pb.ListUsersRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_InvalidArgument() {
+	tests := map[string]*pb.ListUsersRequest{
+		"negative page size": {PageSize: -1},
+		"unknown order":      {OrderBy: "password"},
+		"unknown direction":  {OrderBy: "name up"},
+		"unknown status":     {Status: "banned"},
+		"empty created range": {
+			CreatedAfter:  timestamppb.New(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)),
+			CreatedBefore: timestamppb.New(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	}
+
+	for name, req := range tests {
+		s.Run(name, func() {
+			// execute
+			resp, err := s.userService.ListUsers(s.ctx, req)
+
+			// assert
+			s.Assert().Nil(resp)
+			s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_Defaults() {
+	users := newListedUsers(2)
+	users[1].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	// mock
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx,
+		repository.UserFilter{},
+		repository.UserOrder{Field: repository.UserSortCreatedAt, Desc: true},
+		(*repository.UserCursor)(nil),
+		defaultListUsersPageSize+1,
+	).Return(users, nil)
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Empty(resp.NextPageToken)
+	s.Require().Len(resp.Users, 2)
+	s.Assert().Equal(users[0].ID.String(), resp.Users[0].Id)
+	s.Assert().Equal("active", resp.Users[0].Status)
+	s.Assert().Nil(resp.Users[0].DeletedAt)
+	s.Assert().NotNil(resp.Users[1].DeletedAt)
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_Filters() {
+	createdAfter := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// mock
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx,
+		repository.UserFilter{
+			EmailPrefix:    "Test@example",
+			NamePrefix:     "te",
+			Role:           "support",
+			CreatedFrom:    createdAfter,
+			IncludeDeleted: true,
+		},
+		repository.UserOrder{Field: repository.UserSortEmail},
+		(*repository.UserCursor)(nil),
+		maxListUsersPageSize+1,
+	).Return([]*entity.User{}, nil)
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{
+		PageSize:       1000,
+		EmailPrefix:    " Test@EXAMPLE ",
+		NamePrefix:     "te ",
+		Role:           " support",
+		CreatedAfter:   timestamppb.New(createdAfter),
+		IncludeDeleted: true,
+		OrderBy:        " Email  ASC",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Empty(resp.Users)
+	s.Assert().Empty(resp.NextPageToken)
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_DeletedStatus() {
+	// mock , the deleted users are included for the deleted status
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx,
+		repository.UserFilter{Status: entity.UserStatusDeleted, IncludeDeleted: true},
+		mock.Anything, (*repository.UserCursor)(nil), mock.Anything,
+	).Return([]*entity.User{}, nil)
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{Status: " Deleted"})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Empty(resp.Users)
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_NextPage() {
+	users := newListedUsers(3)
+	order := repository.UserOrder{Field: repository.UserSortCreatedAt, Desc: true}
+
+	// mock
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx, repository.UserFilter{}, order, (*repository.UserCursor)(nil), 3).
+		Return(users, nil).Once()
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{PageSize: 2})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Len(resp.Users, 2)
+	s.Require().NotEmpty(resp.NextPageToken)
+
+	// the token continues after the last user of the page
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx, repository.UserFilter{}, order,
+		mock.MatchedBy(func(after *repository.UserCursor) bool {
+			return after.ID == users[1].ID && after.Value.(time.Time).Equal(users[1].CreatedAt)
+		}), 3).
+		Return(users[2:], nil).Once()
+
+	resp, err = s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{PageSize: 2, PageToken: resp.NextPageToken})
+
+	s.Require().NoError(err)
+	s.Assert().Len(resp.Users, 1)
+	s.Assert().Empty(resp.NextPageToken)
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_InvalidPageToken() {
+	users := newListedUsers(2)
+
+	// mock
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx, mock.Anything, mock.Anything, mock.Anything, 2).Return(users, nil)
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{PageSize: 1})
+	s.Require().NoError(err)
+
+	tests := map[string]*pb.ListUsersRequest{
+		"not base64":    {PageToken: "%%%"},
+		"not json":      {PageToken: "bm90IGpzb24"},
+		"other filters": {PageSize: 1, PageToken: resp.NextPageToken, NamePrefix: "other"},
+		"other order":   {PageSize: 1, PageToken: resp.NextPageToken, OrderBy: "name"},
+		"other role":    {PageSize: 1, PageToken: resp.NextPageToken, Role: "support"},
+		"other status":  {PageSize: 1, PageToken: resp.NextPageToken, Status: "disabled"},
+	}
+
+	for name, req := range tests {
+		s.Run(name, func() {
+			// execute
+			resp, err := s.userService.ListUsers(s.ctx, req)
+
+			// assert
+			s.Assert().Nil(resp)
+			s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+			s.Assert().Equal(mErr.ErrInvalidPageToken, status.Convert(err).Message())
+		})
+	}
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_PageTokenError() {
+	// input , json can't marshal a year after 9999
+	users := newListedUsers(2)
+	users[0].CreatedAt = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// mock
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx, mock.Anything, mock.Anything, mock.Anything, 2).Return(users, nil)
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{PageSize: 1})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *ListUsersTestSuite) Test_ListUsers_RepoError() {
+	// mock
+	s.mockUserRepo.EXPECT().ListUsers(s.ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.ListUsers(s.ctx, &pb.ListUsersRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *ListUsersTestSuite) Test_canonicalEmailPrefix() {
+	s.Assert().Equal("Test", canonicalEmailPrefix(" Test ", false))
+	s.Assert().Equal("test", canonicalEmailPrefix("Test", true))
+	s.Assert().Equal("Test@example.c", canonicalEmailPrefix("Test@Example.C", false))
+	s.Assert().Equal("test@example.c", canonicalEmailPrefix("Test@Example.C", true))
+}
@@ -0,0 +1,81 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/repository"
+)
+
+func TestListUsers_KeysetUnderInserts(t *testing.T) {
+	db := newIntegrationDB(t, true)
+
+	const prefix = "keyset-"
+	deleteUsers := func() {
+		require.NoError(t, db.Unscoped().Where("email_canonical LIKE ?", prefix+"%").Delete(&entity.User{}).Error)
+	}
+	deleteUsers()
+	t.Cleanup(deleteUsers)
+
+	ctx := context.Background()
+	userRepo := repository.NewUsersRepository(db)
+
+	// half of the users share a created_at , the id breaks the tie
+	createdAt := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	newUser := func(i int, createdAt time.Time) *entity.User {
+		email := fmt.Sprintf("%s%03d@example.com", prefix, i)
+		return &entity.User{ID: uuid.New(), Name: "keyset", Email: email, EmailCanonical: email, Password: "x", CreatedAt: createdAt}
+	}
+
+	inserted := 100
+	existing := map[uuid.UUID]bool{}
+	for i := range 20 {
+		user := newUser(i, createdAt.Add(time.Duration(i%10)*time.Minute))
+		require.NoError(t, userRepo.Create(ctx, user))
+		existing[user.ID] = true
+	}
+
+	for _, order := range []repository.UserOrder{
+		{Field: repository.UserSortCreatedAt, Desc: true},
+		{Field: repository.UserSortCreatedAt},
+		{Field: repository.UserSortEmail},
+		{Field: repository.UserSortName, Desc: true},
+	} {
+		t.Run(fmt.Sprintf("%s desc=%t", order.Field, order.Desc), func(t *testing.T) {
+			filter := repository.UserFilter{EmailPrefix: prefix}
+			seen := map[uuid.UUID]bool{}
+
+			var after *repository.UserCursor
+			for {
+				users, err := userRepo.ListUsers(ctx, filter, order, after, 3)
+				require.NoError(t, err)
+				if len(users) == 0 {
+					break
+				}
+
+				for _, user := range users {
+					assert.False(t, seen[user.ID], "user listed twice")
+					seen[user.ID] = true
+				}
+				after = repository.NewUserCursor(users[len(users)-1], order)
+
+				// users registered between the pages must not shift the listing
+				inserted++
+				require.NoError(t, userRepo.Create(ctx, newUser(inserted, time.Now().UTC())))
+			}
+
+			for id := range existing {
+				assert.True(t, seen[id], "user skipped")
+			}
+		})
+	}
+}
@@ -0,0 +1,521 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+)
+
+const (
+	// exportChunkSize keeps every message far below the default 4 MB limit of grpc
+	exportChunkSize     = 64 << 10
+	exportContentType   = "application/json"
+	exportFormatVersion = 1
+	// exportSessionPageSize is how many refresh tokens are loaded at a time for the sessions
+	exportSessionPageSize = 500
+)
+
+// ExportMyData streams everything the service stores about the authenticated user as one JSON document.
+// The first message has the filename and the content type , the document is the concatenated data of all messages.
+// Each section is loaded and sent on its own , the sessions a page of refresh tokens at a time ,
+// so the whole document is never held in memory. not_stored lists the personal data the service doesn't keep.
+// Secrets are left out: password hash , TOTP secret , recovery code hashes , passkey public keys and token hashes.
+// The failed logins of the client ips are left out too , they are not only the user's.
+func (u *userServiceImpl) ExportMyData(req *pb.ExportMyDataRequest, stream pb.UserService_ExportMyDataServer) error {
+	ctx := stream.Context()
+
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	w := &exportWriter{stream: stream, filename: "todolist-user-" + userID.String() + ".json"}
+	if err = u.writeExport(ctx, newExportDocument(w), user); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	log.Info().Str("user_id", userID.String()).Int("bytes", w.written).Msg("personal data exported")
+
+	return nil
+}
+
+// exportWriter sends the written data in messages of exportChunkSize , the first one has the filename
+type exportWriter struct {
+	stream   pb.UserService_ExportMyDataServer
+	filename string
+	buf      bytes.Buffer
+	sent     int
+	written  int
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	w.written += len(p)
+
+	for w.buf.Len() >= exportChunkSize {
+		if err := w.send(exportChunkSize); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close sends the rest , an empty document is still one message
+func (w *exportWriter) Close() error {
+	if w.buf.Len() == 0 && w.sent > 0 {
+		return nil
+	}
+
+	return w.send(w.buf.Len())
+}
+
+func (w *exportWriter) send(n int) error {
+	// copied , the buffer reuses its memory
+	chunk := &pb.ExportMyDataResponse{
+		Data: bytes.Clone(w.buf.Next(n)),
+	}
+	if w.sent == 0 {
+		chunk.Filename = w.filename
+		chunk.ContentType = exportContentType
+	}
+
+	// the error is the client going away
+	if err := w.stream.Send(chunk); err != nil {
+		return err
+	}
+	w.sent++
+
+	return nil
+}
+
+// exportDocument writes the JSON object of the export one field at a time , the field names are part of the format
+type exportDocument struct {
+	w      io.Writer
+	fields int
+}
+
+func newExportDocument(w io.Writer) *exportDocument {
+	return &exportDocument{w: w}
+}
+
+// field writes the field , indented like json.MarshalIndent
+func (d *exportDocument) field(name string, value any) error {
+	data, err := marshalExport(name, value, "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = d.key(name); err != nil {
+		return err
+	}
+
+	_, err = d.w.Write(data)
+	return err
+}
+
+// arrayField writes the field as an array , each element is written when write passes it ,
+// so the elements are never held in memory together
+func (d *exportDocument) arrayField(name string, write func(element func(value any) error) error) error {
+	if err := d.key(name); err != nil {
+		return err
+	}
+
+	elements := 0
+	element := func(value any) error {
+		data, err := marshalExport(name, value, "    ")
+		if err != nil {
+			return err
+		}
+
+		separator := ",\n    "
+		if elements == 0 {
+			separator = "[\n    "
+		}
+		elements++
+
+		_, err = fmt.Fprintf(d.w, "%s%s", separator, data)
+		return err
+	}
+
+	if err := write(element); err != nil {
+		return err
+	}
+
+	end := "\n  ]"
+	if elements == 0 {
+		end = "[]"
+	}
+
+	_, err := d.w.Write([]byte(end))
+	return err
+}
+
+// key writes the name of the next field
+func (d *exportDocument) key(name string) error {
+	separator := ",\n  "
+	if d.fields == 0 {
+		separator = "{\n  "
+	}
+	d.fields++
+
+	_, err := fmt.Fprintf(d.w, "%s%q: ", separator, name)
+	return err
+}
+
+// close ends the object
+func (d *exportDocument) close() error {
+	end := "\n}\n"
+	if d.fields == 0 {
+		end = "{}\n"
+	}
+
+	_, err := d.w.Write([]byte(end))
+	return err
+}
+
+// marshalExport marshals a value of the field , indented to be written at prefix
+func marshalExport(name string, value any, prefix string) ([]byte, error) {
+	data, err := json.MarshalIndent(value, prefix, "  ")
+	if err != nil {
+		log.Error().Err(err).Str("field", name).Msg("marshal export error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	return data, nil
+}
+
+type exportProfile struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	AvatarURL       string     `json:"avatar_url"`
+	Bio             string     `json:"bio"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// exportSession is a login , the refresh tokens rotated from it are one session
+type exportSession struct {
+	ID              string     `json:"id"`
+	DeviceID        string     `json:"device_id"`
+	SignedInAt      time.Time  `json:"signed_in_at"`
+	LastRefreshedAt *time.Time `json:"last_refreshed_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+}
+
+type exportMFA struct {
+	TOTPEnrolledAt      time.Time   `json:"totp_enrolled_at"`
+	TOTPConfirmedAt     *time.Time  `json:"totp_confirmed_at"`
+	RecoveryCodes       int         `json:"recovery_codes"`
+	RecoveryCodesUsedAt []time.Time `json:"recovery_codes_used_at"`
+}
+
+type exportPasskey struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	AAGUID         string     `json:"aaguid"`
+	BackupEligible bool       `json:"backup_eligible"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+}
+
+// exportLoginAttempts are the failed logins of the email , Login throttles them
+type exportLoginAttempts struct {
+	Failures       int        `json:"failures"`
+	FirstFailureAt time.Time  `json:"first_failure_at"`
+	LastFailureAt  time.Time  `json:"last_failure_at"`
+	LockedUntil    *time.Time `json:"locked_until"`
+}
+
+// exportNotStored is a kind of personal data the service doesn't keep , so the export can't have it
+type exportNotStored struct {
+	Section string `json:"section"`
+	Reason  string `json:"reason"`
+}
+
+// exportNotStoredSections tells the reader what is missing from the export on purpose
+var exportNotStoredSections = []*exportNotStored{
+	{"login_history", "the successful logins are the sessions , the failed ones are only counted in login_attempts until their window ends"},
+	{"consents", "the service records no consents"},
+	{"audit_events", "the account status changes are the only events recorded , see status_changes"},
+}
+
+type exportStatusChange struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// writeExport writes the document , each field is loaded right before it is written ,
+// the fields with elements are written a part at a time
+func (u *userServiceImpl) writeExport(ctx context.Context, doc *exportDocument, user *entity.User) error {
+	fields := []struct {
+		name     string
+		load     func() (any, error)
+		elements func(element func(value any) error) error
+	}{
+		{name: "format_version", load: func() (any, error) { return exportFormatVersion, nil }},
+		{name: "exported_at", load: func() (any, error) { return time.Now().UTC(), nil }},
+		{name: "profile", load: func() (any, error) { return newExportProfile(user), nil }},
+		{name: "sessions", elements: func(element func(value any) error) error { return u.exportSessions(ctx, user.ID, element) }},
+		{name: "mfa", load: func() (any, error) { return u.exportMFA(ctx, user.ID) }},
+		{name: "passkeys", load: func() (any, error) { return u.exportPasskeys(ctx, user.ID) }},
+		{name: "roles", load: func() (any, error) { return u.exportRoles(ctx, user.ID) }},
+		{name: "status_changes", load: func() (any, error) { return u.exportStatusChanges(ctx, user.ID) }},
+		{name: "login_attempts", load: func() (any, error) { return u.exportLoginAttempts(ctx, user) }},
+		{name: "not_stored", load: func() (any, error) { return exportNotStoredSections, nil }},
+	}
+
+	for _, field := range fields {
+		if field.elements != nil {
+			if err := doc.arrayField(field.name, field.elements); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := field.load()
+		if err != nil {
+			return err
+		}
+
+		if err = doc.field(field.name, value); err != nil {
+			return err
+		}
+	}
+
+	return doc.close()
+}
+
+func newExportProfile(user *entity.User) *exportProfile {
+	return &exportProfile{
+		ID:              user.ID.String(),
+		Name:            user.Name,
+		Email:           user.Email,
+		AvatarURL:       user.AvatarURL,
+		Bio:             user.Bio,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Status:          string(user.Status),
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+// exportSessions loads the refresh tokens a page at a time , a session is passed to element once all its tokens are read
+func (u *userServiceImpl) exportSessions(ctx context.Context, userID uuid.UUID, element func(value any) error) error {
+	var (
+		after   *entity.RefreshToken
+		session *exportSession
+	)
+
+	for {
+		tokens, err := u.refreshTokenRepo.ListByUser(ctx, userID, after, exportSessionPageSize)
+		if err != nil {
+			log.Error().Err(err).Msg("ListByUser error")
+			return status.Error(codes.Internal, mErr.ErrInternalServerError)
+		}
+
+		for _, token := range tokens {
+			if session != nil && session.ID != token.FamilyID.String() {
+				if err = element(session); err != nil {
+					return err
+				}
+				session = nil
+			}
+			session = addSessionToken(session, token)
+		}
+
+		if len(tokens) < exportSessionPageSize {
+			break
+		}
+		after = tokens[len(tokens)-1]
+	}
+
+	if session == nil {
+		return nil
+	}
+	return element(session)
+}
+
+func (u *userServiceImpl) exportPasskeys(ctx context.Context, userID uuid.UUID) ([]*exportPasskey, error) {
+	passkeys := []*exportPasskey{}
+	if u.webAuthnRepo == nil {
+		return passkeys, nil
+	}
+
+	credentials, err := u.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListCredentials error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	for _, credential := range credentials {
+		passkeys = append(passkeys, &exportPasskey{
+			ID:             credential.ID.String(),
+			Name:           credential.Name,
+			Transports:     credential.TransportList(),
+			AAGUID:         formatAAGUID(credential.AAGUID),
+			BackupEligible: credential.BackupEligible,
+			CreatedAt:      credential.CreatedAt,
+			LastUsedAt:     credential.LastUsedAt,
+		})
+	}
+
+	return passkeys, nil
+}
+
+func (u *userServiceImpl) exportRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	names := []string{}
+	if u.roleRepo == nil {
+		return names, nil
+	}
+
+	roles, err := u.roleRepo.ListUserRoles(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListUserRoles error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+
+	return names, nil
+}
+
+// exportStatusChanges leaves out who made the change , it may be another user
+func (u *userServiceImpl) exportStatusChanges(ctx context.Context, userID uuid.UUID) ([]*exportStatusChange, error) {
+	changes, err := u.userRepo.ListStatusChanges(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListStatusChanges error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	statusChanges := []*exportStatusChange{}
+	for _, change := range changes {
+		statusChanges = append(statusChanges, &exportStatusChange{
+			FromStatus: string(change.FromStatus),
+			ToStatus:   string(change.ToStatus),
+			Reason:     change.Reason,
+			ChangedAt:  change.CreatedAt,
+		})
+	}
+
+	return statusChanges, nil
+}
+
+// exportLoginAttempts returns the failed logins of the email , nil when there are none
+func (u *userServiceImpl) exportLoginAttempts(ctx context.Context, user *entity.User) (*exportLoginAttempts, error) {
+	if u.loginLimiter == nil {
+		return nil, nil
+	}
+
+	attempts, err := u.loginLimiter.AccountAttempts(ctx, user.EmailCanonical)
+	if err != nil {
+		log.Error().Err(err).Msg("AccountAttempts error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if attempts.Failures == 0 && attempts.LockedUntil == nil {
+		return nil, nil
+	}
+
+	return &exportLoginAttempts{
+		Failures:       attempts.Failures,
+		FirstFailureAt: attempts.FirstFailureAt,
+		LastFailureAt:  attempts.LastFailureAt,
+		LockedUntil:    attempts.LockedUntil,
+	}, nil
+}
+
+// addSessionToken adds the next token , oldest first , of a family to its session , a new session when session is nil
+func addSessionToken(session *exportSession, token *entity.RefreshToken) *exportSession {
+	if session == nil {
+		session = &exportSession{
+			ID:         token.FamilyID.String(),
+			DeviceID:   token.DeviceID,
+			SignedInAt: token.CreatedAt,
+		}
+	} else {
+		refreshedAt := token.CreatedAt
+		session.LastRefreshedAt = &refreshedAt
+	}
+
+	session.ExpiresAt = token.ExpiresAt
+	if token.RevokedAt != nil && session.RevokedAt == nil {
+		session.RevokedAt = token.RevokedAt
+	}
+
+	return session
+}
+
+// exportMFA returns nil when the user never enrolled TOTP
+func (u *userServiceImpl) exportMFA(ctx context.Context, userID uuid.UUID) (*exportMFA, error) {
+	if u.mfaRepo == nil {
+		return nil, nil
+	}
+
+	totp, err := u.mfaRepo.GetTOTP(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("GetTOTP error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	recoveryCodes, err := u.mfaRepo.ListRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListRecoveryCodes error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	mfa := &exportMFA{
+		TOTPEnrolledAt:      totp.CreatedAt,
+		TOTPConfirmedAt:     totp.ConfirmedAt,
+		RecoveryCodes:       len(recoveryCodes),
+		RecoveryCodesUsedAt: []time.Time{},
+	}
+	for _, code := range recoveryCodes {
+		if code.UsedAt != nil {
+			mfa.RecoveryCodesUsedAt = append(mfa.RecoveryCodesUsedAt, *code.UsedAt)
+		}
+	}
+
+	return mfa, nil
+}
+
+// formatAAGUID formats the model id of the authenticator like a uuid , it is all zeros for most passkeys
+func formatAAGUID(aaguid []byte) string {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
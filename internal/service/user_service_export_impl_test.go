@@ -0,0 +1,451 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestExportTestSuite(t *testing.T) {
+	suite.Run(t, new(ExportTestSuite))
+}
+
+type ExportTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockMFARepo          *repository.MockMFARepository
+	mockWebAuthnRepo     *repository.MockWebAuthnRepository
+	user                 *entity.User
+	ctx                  context.Context
+}
+
+func (s *ExportTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockMFARepo = repository.NewMockMFARepository(s.T())
+	s.mockWebAuthnRepo = repository.NewMockWebAuthnRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+		MFARepo:          s.mockMFARepo,
+		WebAuthnRepo:     s.mockWebAuthnRepo,
+	})
+
+	s.user = &entity.User{
+		ID:             uuid.New(),
+		Name:           "test",
+		Email:          "Test@example.com",
+		EmailCanonical: "test@example.com",
+		Password:       "password",
+		Bio:            "hello",
+		Status:         entity.UserStatusActive,
+	}
+	s.Require().NoError(s.user.HashPassword())
+
+	s.ctx = interceptor.ContextWithUserID(context.Background(), s.user.ID.String())
+}
+
+// exportStream collects the messages of ExportMyData
+type exportStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages []*pb.ExportMyDataResponse
+	err      error
+}
+
+func (e *exportStream) Context() context.Context {
+	return e.ctx
+}
+
+func (e *exportStream) Send(resp *pb.ExportMyDataResponse) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.messages = append(e.messages, resp)
+	return nil
+}
+
+func (e *exportStream) document() []byte {
+	var data bytes.Buffer
+	for _, message := range e.messages {
+		data.Write(message.Data)
+	}
+	return data.Bytes()
+}
+
+func (s *ExportTestSuite) expectEmptyExport() {
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, (*entity.RefreshToken)(nil), exportSessionPageSize).Return([]*entity.RefreshToken{}, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+	s.mockUserRepo.EXPECT().ListStatusChanges(s.ctx, s.user.ID).Return([]*entity.UserStatusChange{}, nil)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_Success() {
+	// input
+	now := time.Now().Truncate(time.Second)
+	signedIn := uuid.New()
+	revokedAt := now.Add(-time.Minute)
+	usedAt := now.Add(-time.Hour)
+	confirmedAt := now.Add(-48 * time.Hour)
+	passkeyUsedAt := now.Add(-2 * time.Hour)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, (*entity.RefreshToken)(nil), exportSessionPageSize).Return([]*entity.RefreshToken{
+		{FamilyID: signedIn, DeviceID: "phone", TokenHash: "hash-1", CreatedAt: now.Add(-3 * time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{FamilyID: signedIn, DeviceID: "phone", TokenHash: "hash-3", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(2 * time.Hour)},
+		{FamilyID: uuid.New(), DeviceID: "laptop", TokenHash: "hash-2", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(time.Hour), RevokedAt: &revokedAt},
+	}, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(&entity.UserTOTP{
+		UserID:          s.user.ID,
+		SecretEncrypted: "totp-secret",
+		ConfirmedAt:     &confirmedAt,
+		CreatedAt:       confirmedAt.Add(-time.Minute),
+	}, nil)
+	s.mockMFARepo.EXPECT().ListRecoveryCodes(s.ctx, s.user.ID).Return([]*entity.RecoveryCode{
+		{CodeHash: "code-hash-1", UsedAt: &usedAt},
+		{CodeHash: "code-hash-2"},
+	}, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{
+		{ID: uuid.New(), Name: "MacBook", Transports: "internal,hybrid", AAGUID: make([]byte, 16), PublicKey: []byte("public-key"), CreatedAt: now, LastUsedAt: &passkeyUsedAt},
+	}, nil)
+	adminID := uuid.New()
+	s.mockUserRepo.EXPECT().ListStatusChanges(s.ctx, s.user.ID).Return([]*entity.UserStatusChange{
+		{UserID: s.user.ID, FromStatus: entity.UserStatusPendingVerification, ToStatus: entity.UserStatusActive, Reason: "email verified", ActorID: &s.user.ID, CreatedAt: now.Add(-72 * time.Hour)},
+		{UserID: s.user.ID, FromStatus: entity.UserStatusActive, ToStatus: entity.UserStatusDisabled, Reason: "spam", ActorID: &adminID, CreatedAt: now.Add(-48 * time.Hour)},
+		{UserID: s.user.ID, FromStatus: entity.UserStatusDisabled, ToStatus: entity.UserStatusActive, Reason: "appeal accepted", ActorID: &adminID, CreatedAt: now.Add(-24 * time.Hour)},
+	}, nil)
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(stream.messages, 1)
+	s.Assert().Equal("todolist-user-"+s.user.ID.String()+".json", stream.messages[0].Filename)
+	s.Assert().Equal("application/json", stream.messages[0].ContentType)
+
+	var export struct {
+		FormatVersion int `json:"format_version"`
+		Profile       struct {
+			ID     string `json:"id"`
+			Email  string `json:"email"`
+			Bio    string `json:"bio"`
+			Status string `json:"status"`
+		} `json:"profile"`
+		Sessions []struct {
+			ID              string     `json:"id"`
+			DeviceID        string     `json:"device_id"`
+			SignedInAt      time.Time  `json:"signed_in_at"`
+			LastRefreshedAt *time.Time `json:"last_refreshed_at"`
+			RevokedAt       *time.Time `json:"revoked_at"`
+		} `json:"sessions"`
+		MFA *struct {
+			TOTPConfirmedAt     *time.Time  `json:"totp_confirmed_at"`
+			RecoveryCodes       int         `json:"recovery_codes"`
+			RecoveryCodesUsedAt []time.Time `json:"recovery_codes_used_at"`
+		} `json:"mfa"`
+		Passkeys []struct {
+			Name       string   `json:"name"`
+			Transports []string `json:"transports"`
+			AAGUID     string   `json:"aaguid"`
+		} `json:"passkeys"`
+		StatusChanges []struct {
+			FromStatus string    `json:"from_status"`
+			ToStatus   string    `json:"to_status"`
+			Reason     string    `json:"reason"`
+			ChangedAt  time.Time `json:"changed_at"`
+		} `json:"status_changes"`
+	}
+	document := stream.document()
+	s.Require().NoError(json.Unmarshal(document, &export))
+
+	s.Assert().Equal(1, export.FormatVersion)
+	s.Assert().Equal(s.user.ID.String(), export.Profile.ID)
+	s.Assert().Equal("Test@example.com", export.Profile.Email)
+	s.Assert().Equal("hello", export.Profile.Bio)
+	s.Assert().Equal("active", export.Profile.Status)
+
+	// the rotated tokens are one session
+	s.Require().Len(export.Sessions, 2)
+	s.Assert().Equal(signedIn.String(), export.Sessions[0].ID)
+	s.Assert().Equal("phone", export.Sessions[0].DeviceID)
+	s.Assert().True(now.Add(-3 * time.Hour).Equal(export.Sessions[0].SignedInAt))
+	s.Require().NotNil(export.Sessions[0].LastRefreshedAt)
+	s.Assert().True(now.Add(-time.Hour).Equal(*export.Sessions[0].LastRefreshedAt))
+	s.Assert().Nil(export.Sessions[0].RevokedAt)
+	s.Assert().Equal("laptop", export.Sessions[1].DeviceID)
+	s.Assert().Nil(export.Sessions[1].LastRefreshedAt)
+	s.Assert().NotNil(export.Sessions[1].RevokedAt)
+
+	s.Require().NotNil(export.MFA)
+	s.Assert().True(confirmedAt.Equal(*export.MFA.TOTPConfirmedAt))
+	s.Assert().Equal(2, export.MFA.RecoveryCodes)
+	s.Assert().Len(export.MFA.RecoveryCodesUsedAt, 1)
+
+	s.Require().Len(export.Passkeys, 1)
+	s.Assert().Equal("MacBook", export.Passkeys[0].Name)
+	s.Assert().Equal([]string{"internal", "hybrid"}, export.Passkeys[0].Transports)
+	s.Assert().Equal("00000000-0000-0000-0000-000000000000", export.Passkeys[0].AAGUID)
+
+	s.Require().Len(export.StatusChanges, 3)
+	s.Assert().Equal("active", export.StatusChanges[1].FromStatus)
+	s.Assert().Equal("disabled", export.StatusChanges[1].ToStatus)
+	s.Assert().Equal("spam", export.StatusChanges[1].Reason)
+	s.Assert().True(now.Add(-48 * time.Hour).Equal(export.StatusChanges[1].ChangedAt))
+
+	// no secrets , and not who the admins are
+	for _, secret := range []string{s.user.Password, "hash-1", "totp-secret", "code-hash-1", "cHVibGljLWtleQ", adminID.String()} {
+		s.Assert().NotContains(string(document), secret)
+	}
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_Empty() {
+	// mock
+	s.expectEmptyExport()
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert
+	s.Require().NoError(err)
+
+	var export map[string]any
+	s.Require().NoError(json.Unmarshal(stream.document(), &export))
+	s.Assert().Equal([]any{}, export["sessions"])
+	s.Assert().Nil(export["mfa"])
+	s.Assert().Equal([]any{}, export["passkeys"])
+	s.Assert().Equal([]any{}, export["status_changes"])
+	s.Assert().Contains(export, "login_attempts")
+	s.Assert().Nil(export["login_attempts"])
+
+	// the data that isn't kept is named
+	var notStored []string
+	for _, section := range export["not_stored"].([]any) {
+		notStored = append(notStored, section.(map[string]any)["section"].(string))
+	}
+	s.Assert().Equal([]string{"login_history", "consents", "audit_events"}, notStored)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_SessionPages() {
+	// input , a full page of one session , its last rotation and another session on the next page
+	now := time.Now().Truncate(time.Second)
+	rotated, other := uuid.New(), uuid.New()
+
+	firstPage := make([]*entity.RefreshToken, exportSessionPageSize)
+	for i := range firstPage {
+		firstPage[i] = &entity.RefreshToken{ID: uuid.New(), FamilyID: rotated, DeviceID: "phone", CreatedAt: now.Add(time.Duration(i-2*exportSessionPageSize) * time.Minute)}
+	}
+	last := firstPage[len(firstPage)-1]
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, (*entity.RefreshToken)(nil), exportSessionPageSize).Return(firstPage, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, last, exportSessionPageSize).Return([]*entity.RefreshToken{
+		{ID: uuid.New(), FamilyID: rotated, DeviceID: "phone", CreatedAt: now},
+		{ID: uuid.New(), FamilyID: other, DeviceID: "laptop", CreatedAt: now.Add(-time.Hour)},
+	}, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+	s.mockUserRepo.EXPECT().ListStatusChanges(s.ctx, s.user.ID).Return([]*entity.UserStatusChange{}, nil)
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert , the session across the pages is one session
+	s.Require().NoError(err)
+
+	var export struct {
+		Sessions []struct {
+			ID              string     `json:"id"`
+			SignedInAt      time.Time  `json:"signed_in_at"`
+			LastRefreshedAt *time.Time `json:"last_refreshed_at"`
+		} `json:"sessions"`
+	}
+	s.Require().NoError(json.Unmarshal(stream.document(), &export))
+	s.Require().Len(export.Sessions, 2)
+	s.Assert().Equal(rotated.String(), export.Sessions[0].ID)
+	s.Assert().True(firstPage[0].CreatedAt.Equal(export.Sessions[0].SignedInAt))
+	s.Require().NotNil(export.Sessions[0].LastRefreshedAt)
+	s.Assert().True(now.Equal(*export.Sessions[0].LastRefreshedAt))
+	s.Assert().Equal(other.String(), export.Sessions[1].ID)
+	s.Assert().Nil(export.Sessions[1].LastRefreshedAt)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_Chunked() {
+	// input , a bio that doesn't fit in one message
+	s.user.Bio = strings.Repeat("a", 2*exportChunkSize)
+
+	// mock
+	s.expectEmptyExport()
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(stream.messages, 3)
+	for i, message := range stream.messages {
+		s.Assert().LessOrEqual(len(message.Data), exportChunkSize)
+		s.Assert().Equal(i == 0, message.Filename != "")
+	}
+
+	var export struct {
+		Profile struct {
+			Bio string `json:"bio"`
+		} `json:"profile"`
+	}
+	s.Require().NoError(json.Unmarshal(stream.document(), &export))
+	s.Assert().Equal(s.user.Bio, export.Profile.Bio)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_Streamed() {
+	// input , the profile fills a message before the sessions are loaded
+	s.user.Bio = strings.Repeat("a", exportChunkSize)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, (*entity.RefreshToken)(nil), exportSessionPageSize).Return(nil, errors.New("db error"))
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert , the client gets an error after the sent part
+	s.Assert().Equal(codes.Internal, status.Code(err))
+	s.Require().Len(stream.messages, 1)
+	s.Assert().Len(stream.messages[0].Data, exportChunkSize)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_LoginAttempts() {
+	// input
+	loginLimiter := limiter.NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), limiter.LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+		MaxAccountFailures: 5,
+	})
+	s.Require().NoError(loginLimiter.Fail(context.Background(), s.user.EmailCanonical, "203.0.113.7"))
+
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+		MFARepo:          s.mockMFARepo,
+		WebAuthnRepo:     s.mockWebAuthnRepo,
+		LoginLimiter:     loginLimiter,
+	})
+
+	// mock
+	s.expectEmptyExport()
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert , the failures of the email only , the ip is not the user's
+	s.Require().NoError(err)
+
+	var export struct {
+		LoginAttempts *struct {
+			Failures    int        `json:"failures"`
+			LockedUntil *time.Time `json:"locked_until"`
+		} `json:"login_attempts"`
+	}
+	document := stream.document()
+	s.Require().NoError(json.Unmarshal(document, &export))
+	s.Require().NotNil(export.LoginAttempts)
+	s.Assert().Equal(1, export.LoginAttempts.Failures)
+	s.Assert().Nil(export.LoginAttempts.LockedUntil)
+	s.Assert().NotContains(string(document), "203.0.113.7")
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_WithoutMFAAndPasskeys() {
+	// input
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, (*entity.RefreshToken)(nil), exportSessionPageSize).Return([]*entity.RefreshToken{}, nil)
+	s.mockUserRepo.EXPECT().ListStatusChanges(s.ctx, s.user.ID).Return([]*entity.UserStatusChange{}, nil)
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Len(stream.messages, 1)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_DBError() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockRefreshTokenRepo.EXPECT().ListByUser(s.ctx, s.user.ID, (*entity.RefreshToken)(nil), exportSessionPageSize).Return(nil, errors.New("db error"))
+
+	stream := &exportStream{ctx: s.ctx}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert
+	s.Assert().Equal(codes.Internal, status.Code(err))
+	s.Assert().Empty(stream.messages)
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_SendError() {
+	// mock
+	s.expectEmptyExport()
+
+	stream := &exportStream{ctx: s.ctx, err: errors.New("client gone")}
+
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, stream)
+
+	// assert
+	s.Assert().EqualError(err, "client gone")
+}
+
+func (s *ExportTestSuite) Test_ExportMyData_Unauthenticated() {
+	// execute
+	err := s.userService.ExportMyData(&pb.ExportMyDataRequest{}, &exportStream{ctx: context.Background()})
+
+	// assert
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
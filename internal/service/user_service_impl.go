@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 	"github.com/itmrchow/todolist-proto/protobuf"
 	pb "github.com/itmrchow/todolist-proto/protobuf/user"
@@ -17,97 +21,463 @@ import (
 
 	"github.com/itmrchow/todolist-user/internal/entity"
 	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/mailer"
 	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/internal/worker"
 	"github.com/itmrchow/todolist-user/utils"
 )
 
+// dummyUser has a password hash made with the current parameters,
+// it is checked when the login email doesn't exist
+var dummyUser = sync.OnceValue(func() *entity.User {
+	user := &entity.User{Password: uuid.NewString()}
+	if err := user.HashPassword(); err != nil {
+		log.Error().Err(err).Msg("hash dummy password error")
+	}
+	return user
+})
+
+// checkDummyPassword spends the time of a real password check on dummyUser
+var checkDummyPassword = func(password string) {
+	dummyUser().CheckPassword(password)
+}
+
 type userServiceImpl struct {
 	pb.UnimplementedUserServiceServer
-	userRepo  repository.UsersRepository
-	jwtConfig *JwtConfig
+	userRepo         repository.UsersRepository
+	refreshTokenRepo repository.RefreshTokensRepository
+	revokedTokenRepo repository.RevokedTokensRepository
+	oneTimeTokenRepo repository.OneTimeTokensRepository
+	mailer           mailer.Mailer
+	mailWorkers      *worker.Pool
+	jwtConfig        *JwtConfig
+	resetConfig      *PasswordResetConfig
+	verifyConfig     *EmailVerificationConfig
+	passwordPolicy   *utils.PasswordPolicy
+	loginLimiter     *limiter.LoginLimiter
+	mfaRepo          repository.MFARepository
+	secretBox        *utils.SecretBox
+	mfaConfig        *MFAConfig
+	accountConfig    *AccountConfig
+	webAuthnRepo     repository.WebAuthnRepository
+	webAuthn         *webauthn.WebAuthn
+	roleRepo         repository.RolesRepository
+	adminUserIDs     map[string]struct{}
+	// lowercaseEmailLocalPart makes Bob@example.com and bob@example.com the same account
+	lowercaseEmailLocalPart bool
 }
 
 type JwtConfig struct {
-	SecretKey string
-	ExpireAt  int
-	Issuer    string
+	KeyRing         *utils.KeyRing
+	ExpireAt        int
+	RefreshExpireAt int
+	Issuer          string
+}
+
+type PasswordResetConfig struct {
+	ExpireAt time.Duration
+	URL      string
+}
+
+type EmailVerificationConfig struct {
+	ExpireAt       time.Duration
+	URL            string
+	ResendInterval time.Duration
+	// Required makes Login refuse the users that didn't verify the email
+	Required bool
 }
 
-func NewUserService(userRepo repository.UsersRepository) pb.UserServiceServer {
+type MFAConfig struct {
+	// Issuer is the account issuer shown by the authenticator app
+	Issuer string
+	// TokenExpireAt is how long a login waits for the second factor
+	TokenExpireAt time.Duration
+}
+
+type AccountConfig struct {
+	// DeletionGracePeriod is how long a deleted account can be restored before it is purged
+	DeletionGracePeriod time.Duration
+	// FreshTokenAge is how old an access token may be to delete the account without the password
+	FreshTokenAge time.Duration
+	// LockOnRefreshTokenReuse locks the account when a used refresh token is presented again ,
+	// off by default as anyone with an old refresh token could lock the account
+	LockOnRefreshTokenReuse bool
+}
+
+// UserServiceDeps are the components of the user service.
+// The repositories of the users and tokens , the Mailer , MailWorkers and the KeyRing are required , NewUserService fails without them.
+// A nil LoginLimiter doesn't throttle logins , MFA needs MFARepo and SecretBox , passkeys need WebAuthnRepo and WebAuthn ,
+// roles need RoleRepo , their rpcs fail with FailedPrecondition otherwise. A nil PasswordPolicy is the default policy.
+type UserServiceDeps struct {
+	UserRepo         repository.UsersRepository
+	RefreshTokenRepo repository.RefreshTokensRepository
+	RevokedTokenRepo repository.RevokedTokensRepository
+	OneTimeTokenRepo repository.OneTimeTokensRepository
+	Mailer           mailer.Mailer
+	MailWorkers      *worker.Pool
+	KeyRing          *utils.KeyRing
+	PasswordPolicy   *utils.PasswordPolicy
+	LoginLimiter     *limiter.LoginLimiter
+	MFARepo          repository.MFARepository
+	SecretBox        *utils.SecretBox
+	WebAuthnRepo     repository.WebAuthnRepository
+	WebAuthn         *webauthn.WebAuthn
+	RoleRepo         repository.RolesRepository
+}
+
+func NewUserService(deps UserServiceDeps) (pb.UserServiceServer, error) {
+	if err := deps.check(); err != nil {
+		return nil, err
+	}
+
+	passwordPolicy := deps.PasswordPolicy
+	if passwordPolicy == nil {
+		passwordPolicy = utils.DefaultPasswordPolicy()
+	}
+
+	adminUserIDs := map[string]struct{}{}
+	for _, id := range viper.GetStringSlice("AUTH_ADMIN_USER_IDS") {
+		adminUserIDs[id] = struct{}{}
+	}
+
+	totpIssuer := viper.GetString("MFA_TOTP_ISSUER")
+	if totpIssuer == "" {
+		totpIssuer = viper.GetString("SERVER_NAME")
+	}
+
 	return &userServiceImpl{
-		userRepo: userRepo,
+		userRepo:         deps.UserRepo,
+		refreshTokenRepo: deps.RefreshTokenRepo,
+		revokedTokenRepo: deps.RevokedTokenRepo,
+		oneTimeTokenRepo: deps.OneTimeTokenRepo,
+		mailer:           deps.Mailer,
+		mailWorkers:      deps.MailWorkers,
+		passwordPolicy:   passwordPolicy,
+		loginLimiter:     deps.LoginLimiter,
+		mfaRepo:          deps.MFARepo,
+		secretBox:        deps.SecretBox,
+		webAuthnRepo:     deps.WebAuthnRepo,
+		webAuthn:         deps.WebAuthn,
+		roleRepo:         deps.RoleRepo,
+		adminUserIDs:     adminUserIDs,
+
+		lowercaseEmailLocalPart: viper.GetBool("EMAIL_LOWERCASE_LOCAL_PART"),
 		jwtConfig: &JwtConfig{
-			SecretKey: viper.GetString("JWT_SECRET_KEY"),
-			ExpireAt:  viper.GetInt("JWT_EXPIRE_AT"),
-			Issuer:    viper.GetString("SERVER_NAME"),
+			KeyRing:         deps.KeyRing,
+			ExpireAt:        viper.GetInt("JWT_EXPIRE_AT"),
+			RefreshExpireAt: viper.GetInt("REFRESH_TOKEN_EXPIRE_AT"),
+			Issuer:          viper.GetString("SERVER_NAME"),
+		},
+		resetConfig: &PasswordResetConfig{
+			ExpireAt: viper.GetDuration("PASSWORD_RESET_EXPIRE_AT"),
+			URL:      viper.GetString("PASSWORD_RESET_URL"),
 		},
+		verifyConfig: &EmailVerificationConfig{
+			ExpireAt:       viper.GetDuration("EMAIL_VERIFICATION_EXPIRE_AT"),
+			URL:            viper.GetString("EMAIL_VERIFICATION_URL"),
+			ResendInterval: viper.GetDuration("EMAIL_VERIFICATION_RESEND_INTERVAL"),
+			Required:       viper.GetBool("AUTH_REQUIRE_EMAIL_VERIFIED"),
+		},
+		mfaConfig: &MFAConfig{
+			Issuer:        totpIssuer,
+			TokenExpireAt: viper.GetDuration("MFA_TOKEN_EXPIRE_AT"),
+		},
+		accountConfig: &AccountConfig{
+			DeletionGracePeriod: viper.GetDuration("ACCOUNT_DELETION_GRACE_PERIOD"),
+			FreshTokenAge:       viper.GetDuration("ACCOUNT_DELETION_FRESH_TOKEN_AGE"),
+
+			LockOnRefreshTokenReuse: viper.GetBool("ACCOUNT_LOCK_ON_REFRESH_TOKEN_REUSE"),
+		},
+	}, nil
+}
+
+// check fails when a required component is missing
+func (d UserServiceDeps) check() error {
+	required := []struct {
+		name    string
+		missing bool
+	}{
+		{"UserRepo", d.UserRepo == nil},
+		{"RefreshTokenRepo", d.RefreshTokenRepo == nil},
+		{"RevokedTokenRepo", d.RevokedTokenRepo == nil},
+		{"OneTimeTokenRepo", d.OneTimeTokenRepo == nil},
+		{"Mailer", d.Mailer == nil},
+		{"MailWorkers", d.MailWorkers == nil},
+		{"KeyRing", d.KeyRing == nil},
+	}
+
+	for _, dep := range required {
+		if dep.missing {
+			return fmt.Errorf("user service: %s is required", dep.name)
+		}
+	}
+
+	return nil
+}
+
+// sendInBackground queues an email the rpc doesn't wait for , it is dropped and logged when the queue is full
+func (u *userServiceImpl) sendInBackground(email string, send func(ctx context.Context)) {
+	if err := u.mailWorkers.Submit(send); err != nil {
+		log.Error().Err(err).Str("email", email).Msg("email dropped")
 	}
 }
 
 func (u *userServiceImpl) Login(ctx context.Context, req *pb.LoginRequest) (resp *pb.LoginResponse, err error) {
-	// get user info by email
-	user := &entity.User{
-		Email:    req.Email,
-		Password: req.Password,
+	v := validation.New()
+	email := v.CanonicalEmail("email", req.Email, entity.UserEmailMaxLength, u.lowercaseEmailLocalPart)
+	v.Required("password", req.Password)
+	if err = v.Err(); err != nil {
+		return nil, err
 	}
-	user.HashPassword()
 
-	user, err = u.userRepo.GetByEmailAndPassword(ctx, user.Email, user.Password)
+	clientIP, _ := interceptor.ClientIPFromContext(ctx)
+	if err = u.checkLoginLimit(ctx, email, clientIP); err != nil {
+		return nil, err
+	}
+
+	// get user info by email
+	user, err := u.userRepo.GetByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidLoginInfo)
+			return nil, u.loginNotFound(ctx, email, clientIP, req.Password)
 		}
-		log.Error().Err(err).Msg("GetByEmailAndPassword error")
+		log.Error().Err(err).Msg("GetByEmail error")
 		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
 	}
 
-	// generate token
-	token, err := utils.GenerateToken(user.ID.String(), u.jwtConfig.SecretKey, u.jwtConfig.Issuer, u.jwtConfig.ExpireAt)
+	// check password
+	match, needsRehash := user.CheckPassword(req.Password)
+	if !match {
+		u.loginFailed(ctx, email, clientIP)
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidLoginInfo)
+	}
+
+	// checked after the password , so it doesn't tell the email is registered ,
+	// the failures are kept , a disabled or locked account doesn't reset them
+	if err = u.checkLoginStatus(user); err != nil {
+		return nil, err
+	}
+
+	mfaEnabled, err := u.isMFAEnabled(ctx, user.ID)
 	if err != nil {
-		log.Error().Err(err).Msg("Generate token error")
-		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+		return nil, err
 	}
 
-	resp = &pb.LoginResponse{
-		Id:        user.ID.String(),
-		Name:      user.Name,
-		Email:     user.Email,
-		Token:     token,
-		ExpiresIn: timestamppb.New(time.Now().Add(time.Duration(u.jwtConfig.ExpireAt) * time.Hour)),
+	// upgrade legacy or outdated hash
+	if needsRehash {
+		u.rehashPassword(ctx, user, req.Password)
 	}
 
-	return
+	// with two factor the failures count until VerifyMFA verifies the second factor too
+	if mfaEnabled {
+		return u.mfaChallenge(user)
+	}
+
+	resp, err = u.issueLoginTokens(ctx, user, req.DeviceId)
+	if err != nil {
+		return nil, err
+	}
+	u.loginSucceeded(ctx, email)
 
+	return resp, nil
 }
 
 func (u *userServiceImpl) Register(ctx context.Context, req *pb.RegisterRequest) (resp *protobuf.EmptyResponse, err error) {
-	// check email is exist?
-	isExist, err := u.userRepo.ExistsByEmail(ctx, req.Email)
-	if err != nil {
-		log.Error().Err(err).Msg("internal server error")
-		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
-	}
+	name := strings.TrimSpace(req.Name)
 
-	if isExist {
-		return nil, status.Error(codes.AlreadyExists, mErr.ErrEmailAlreadyExists)
+	v := validation.New()
+	email := v.CanonicalEmail("email", req.Email, entity.UserEmailMaxLength, u.lowercaseEmailLocalPart)
+	v.Length("name", name, 1, entity.UserNameMaxLength)
+	v.Password("password", req.Password, u.passwordPolicy)
+	if err = v.Err(); err != nil {
+		return nil, err
 	}
 
 	// insert db
+	now := time.Now()
 	user := &entity.User{
-		ID:       uuid.New(),
-		Email:    req.Email,
-		Password: req.Password,
-		Name:     req.Name,
+		ID:                      uuid.New(),
+		Email:                   strings.TrimSpace(req.Email),
+		EmailCanonical:          email,
+		Password:                req.Password,
+		Name:                    name,
+		Status:                  entity.UserStatusPendingVerification,
+		EmailVerificationSentAt: &now,
 	}
 
-	user.HashPassword()
+	err = user.HashPassword()
+	if err != nil {
+		log.Error().Err(err).Msg("hash password error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
 
+	// the unique index decides , a check before the insert races with concurrent registrations
 	err = u.userRepo.Create(ctx, user)
-
+	if errors.Is(err, repository.ErrEmailAlreadyExists) {
+		return nil, status.Error(codes.AlreadyExists, mErr.ErrEmailAlreadyExists)
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("user , insert db error")
 		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
 	}
 
+	u.sendInBackground("email verification", func(ctx context.Context) {
+		u.sendEmailVerification(ctx, user)
+	})
+
+	return
+}
+
+// GetJwks returns the public keys that verify the issued tokens , shared secrets are never returned
+func (u *userServiceImpl) GetJwks(ctx context.Context, req *pb.GetJwksRequest) (resp *pb.GetJwksResponse, err error) {
+	jwks := utils.NewJWKS(u.jwtConfig.KeyRing.VerificationKeys()...)
+
+	resp = &pb.GetJwksResponse{
+		Keys: make([]*pb.Jwk, 0, len(jwks.Keys)),
+	}
+
+	for _, key := range jwks.Keys {
+		resp.Keys = append(resp.Keys, &pb.Jwk{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Use: key.Use,
+			Alg: key.Alg,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+
 	return
 }
+
+// issueLoginTokens completes a login , it starts a new refresh token family authenticated now
+func (u *userServiceImpl) issueLoginTokens(ctx context.Context, user *entity.User, deviceID string) (*pb.LoginResponse, error) {
+	tokens, refreshToken, err := u.newTokenPair(ctx, user.ID, uuid.New(), deviceID, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Generate token error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	err = u.refreshTokenRepo.Create(ctx, refreshToken)
+	if err != nil {
+		log.Error().Err(err).Msg("refresh token , insert db error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return &pb.LoginResponse{
+		Id:               user.ID.String(),
+		Name:             user.Name,
+		Email:            user.Email,
+		Token:            tokens.accessToken,
+		ExpiresIn:        timestamppb.New(tokens.accessExpiresAt),
+		RefreshToken:     tokens.refreshToken,
+		RefreshExpiresIn: timestamppb.New(tokens.refreshExpiresAt),
+	}, nil
+}
+
+// loginNotFound fails the login of an email that is not registered.
+// A deleted account that can still be restored gets its own error , but only with the right password.
+func (u *userServiceImpl) loginNotFound(ctx context.Context, email string, clientIP string, password string) error {
+	deleted, err := u.userRepo.GetDeletedByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error().Err(err).Msg("GetDeletedByEmail error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if deleted != nil && u.isRestorable(deleted, time.Now()) {
+		if match, _ := deleted.CheckPassword(password); match {
+			return interceptor.AccountStatusError(entity.UserStatusDeleted)
+		}
+	} else {
+		// spend the same time as a real password check , so the response time doesn't reveal the email is not registered
+		checkDummyPassword(password)
+	}
+
+	u.loginFailed(ctx, email, clientIP)
+	return status.Error(codes.Unauthenticated, mErr.ErrInvalidLoginInfo)
+}
+
+// checkLoginLimit rejects the login with ResourceExhausted and RetryInfo while the email or the ip is throttled.
+// The login is not blocked when the attempt store fails.
+func (u *userServiceImpl) checkLoginLimit(ctx context.Context, email string, clientIP string) error {
+	if u.loginLimiter == nil {
+		return nil
+	}
+
+	retryAfter, err := u.loginLimiter.Check(ctx, email, clientIP)
+	if err != nil {
+		log.Error().Err(err).Msg("login limiter , check error")
+		return nil
+	}
+
+	if retryAfter > 0 {
+		log.Warn().Str("client_ip", clientIP).Dur("retry_after", retryAfter).Msg("login throttled")
+		return limiter.ResourceExhausted(mErr.ErrTooManyLoginAttempts, retryAfter)
+	}
+
+	return nil
+}
+
+// checkUserPassword checks the password of an authenticated user before a sensitive change.
+// The failures count as failed logins of the email , so a stolen access token can't guess the password.
+// The caller resets the failures with loginSucceeded once the user is fully verified.
+func (u *userServiceImpl) checkUserPassword(ctx context.Context, user *entity.User, password string) error {
+	clientIP, _ := interceptor.ClientIPFromContext(ctx)
+	if err := u.checkLoginLimit(ctx, user.EmailCanonical, clientIP); err != nil {
+		return err
+	}
+
+	if match, _ := user.CheckPassword(password); !match {
+		u.loginFailed(ctx, user.EmailCanonical, clientIP)
+		return status.Error(codes.PermissionDenied, mErr.ErrIncorrectPassword)
+	}
+
+	return nil
+}
+
+func (u *userServiceImpl) loginFailed(ctx context.Context, email string, clientIP string) {
+	if u.loginLimiter == nil {
+		return
+	}
+
+	if err := u.loginLimiter.Fail(ctx, email, clientIP); err != nil {
+		log.Error().Err(err).Msg("login limiter , fail error")
+	}
+}
+
+func (u *userServiceImpl) loginSucceeded(ctx context.Context, email string) {
+	if u.loginLimiter == nil {
+		return
+	}
+
+	if err := u.loginLimiter.Succeed(ctx, email); err != nil {
+		log.Error().Err(err).Msg("login limiter , succeed error")
+	}
+}
+
+// rehashPassword re-hashes the password with the current algorithm after a successful login,
+// a failure is only logged so the login itself is not affected
+func (u *userServiceImpl) rehashPassword(ctx context.Context, user *entity.User, password string) {
+	rehashed := &entity.User{
+		ID:       user.ID,
+		Password: password,
+	}
+
+	if err := rehashed.HashPassword(); err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("rehash password error")
+		return
+	}
+
+	if err := u.userRepo.UpdatePassword(ctx, user.ID, rehashed.Password); err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID.String()).Msg("update rehashed password error")
+		return
+	}
+
+	user.Password = rehashed.Password
+}
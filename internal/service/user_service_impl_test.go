@@ -2,11 +2,20 @@ package service
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc/codes"
@@ -14,63 +23,129 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/mailer"
 	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/internal/worker"
+	"github.com/itmrchow/todolist-user/utils"
 )
 
+func newTestKeyRing(t *testing.T, current *utils.SigningKey, verifyKeys ...*utils.SigningKey) *utils.KeyRing {
+	keyRing, err := utils.NewKeyRing(current, verifyKeys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyRing
+}
+
+// newTestUserService creates the user service , the required components left out are mocks expecting no calls
+func newTestUserService(t *testing.T, deps UserServiceDeps) pb.UserServiceServer {
+	if deps.UserRepo == nil {
+		deps.UserRepo = repository.NewMockUsersRepository(t)
+	}
+	if deps.RefreshTokenRepo == nil {
+		deps.RefreshTokenRepo = repository.NewMockRefreshTokensRepository(t)
+	}
+	if deps.RevokedTokenRepo == nil {
+		deps.RevokedTokenRepo = repository.NewMockRevokedTokensRepository(t)
+	}
+	if deps.OneTimeTokenRepo == nil {
+		deps.OneTimeTokenRepo = repository.NewMockOneTimeTokensRepository(t)
+	}
+	if deps.Mailer == nil {
+		deps.Mailer = mailer.NewMockMailer(t)
+	}
+	if deps.MailWorkers == nil {
+		deps.MailWorkers = newTestMailWorkers(t)
+	}
+	if deps.KeyRing == nil {
+		deps.KeyRing = newTestKeyRing(t, utils.NewHMACKey("", "secret"))
+	}
+
+	userService, err := NewUserService(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userService
+}
+
+// newTestMailWorkers creates a mail worker pool , the emails queued by a test are sent before it ends
+func newTestMailWorkers(t *testing.T) *worker.Pool {
+	pool, err := worker.NewPool(1, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := pool.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return pool
+}
+
+func TestNewUserService_MissingDeps(t *testing.T) {
+	deps := UserServiceDeps{
+		UserRepo:         repository.NewMockUsersRepository(t),
+		RefreshTokenRepo: repository.NewMockRefreshTokensRepository(t),
+		RevokedTokenRepo: repository.NewMockRevokedTokensRepository(t),
+		OneTimeTokenRepo: repository.NewMockOneTimeTokensRepository(t),
+		KeyRing:          newTestKeyRing(t, utils.NewHMACKey("", "secret")),
+	}
+
+	// the verification and reset emails can't be sent without a mailer
+	userService, err := NewUserService(deps)
+
+	assert.Nil(t, userService)
+	assert.EqualError(t, err, "user service: Mailer is required")
+}
+
 func TestRegisterTestSuite(t *testing.T) {
 	suite.Run(t, new(RegisterTestSuite))
 }
 
 type RegisterTestSuite struct {
 	suite.Suite
-	userService  pb.UserServiceServer
-	mockUserRepo *repository.MockUsersRepository
-	input        struct {
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	mockMailer           *mailer.MockMailer
+	input                struct {
 		ctx context.Context
 		req *pb.RegisterRequest
 	}
 }
 
 func (s *RegisterTestSuite) SetupTest() {
-	userRepo := repository.NewMockUsersRepository(s.T())
-	s.mockUserRepo = userRepo
-	s.userService = NewUserService(userRepo)
-}
-
-func (s *RegisterTestSuite) Test_Register_ExistsByEmail_DbError() {
-	// input
-	s.input.ctx = context.Background()
-	s.input.req = &pb.RegisterRequest{
-		Email:    "db_error@example.com",
-		Password: "password",
-		Name:     "test",
-	}
-
-	// mock
-	s.mockUserRepo.EXPECT().ExistsByEmail(context.Background(), mock.Anything).Return(false, errors.New("db error"))
-
-	// execute
-	resp, err := s.userService.Register(s.input.ctx, s.input.req)
-
-	// assert
-	s.Assert().Nil(resp)
-	rpcErr, ok := status.FromError(err)
-	s.Assert().True(ok)
-	s.Assert().Equal(codes.Internal, rpcErr.Code())
+	viper.Set("EMAIL_VERIFICATION_EXPIRE_AT", "24h")
+	s.T().Cleanup(viper.Reset)
 
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.mockMailer = mailer.NewMockMailer(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		Mailer:           s.mockMailer,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
 }
 
-func (s *RegisterTestSuite) Test_Register_ExistsByEmail_EmailAlreadyExists() {
+func (s *RegisterTestSuite) Test_Register_EmailAlreadyExists() {
 	// input
 	s.input.ctx = context.Background()
 	s.input.req = &pb.RegisterRequest{
 		Email:    "exist@example.com",
-		Password: "password",
+		Password: "correct horse battery",
 		Name:     "test",
 	}
 
-	// mock
-	s.mockUserRepo.EXPECT().ExistsByEmail(context.Background(), mock.Anything).Return(true, nil)
+	// mock , the unique index rejects the insert
+	s.mockUserRepo.EXPECT().Create(context.Background(), mock.Anything).Return(repository.ErrEmailAlreadyExists)
 
 	// execute
 	resp, err := s.userService.Register(s.input.ctx, s.input.req)
@@ -89,12 +164,11 @@ func (s *RegisterTestSuite) Test_Register_Create_DbError() {
 	s.input.ctx = context.Background()
 	s.input.req = &pb.RegisterRequest{
 		Email:    "test@example.com",
-		Password: "password",
+		Password: "correct horse battery",
 		Name:     "test",
 	}
 
 	// mock
-	s.mockUserRepo.EXPECT().ExistsByEmail(context.Background(), s.input.req.Email).Return(false, nil)
 	s.mockUserRepo.EXPECT().Create(context.Background(), mock.MatchedBy(func(user *entity.User) bool {
 		return user.Email == s.input.req.Email &&
 			user.Password != s.input.req.Password &&
@@ -118,18 +192,26 @@ func (s *RegisterTestSuite) Test_Register_Success() {
 	s.input.ctx = context.Background()
 	s.input.req = &pb.RegisterRequest{
 		Email:    "test@example.com",
-		Password: "password",
+		Password: "correct horse battery",
 		Name:     "test",
 	}
 
 	// mock
-	s.mockUserRepo.EXPECT().ExistsByEmail(context.Background(), "test@example.com").Return(false, nil)
+	sent := make(chan *mailer.Message, 1)
+
+	// mock
 	s.mockUserRepo.EXPECT().Create(context.Background(), mock.MatchedBy(func(user *entity.User) bool {
 		return user.Email == s.input.req.Email &&
 			user.Password != s.input.req.Password &&
 			user.Name == s.input.req.Name &&
-			user.ID != uuid.Nil
+			user.ID != uuid.Nil &&
+			!user.IsEmailVerified() &&
+			user.Status == entity.UserStatusPendingVerification &&
+			user.EmailVerificationSentAt != nil
 	})).Return(nil)
+	s.mockMailer.EXPECT().Send(mock.Anything, mock.Anything).Run(func(ctx context.Context, msg *mailer.Message) {
+		sent <- msg
+	}).Return(nil)
 
 	// execute
 	resp, err := s.userService.Register(s.input.ctx, s.input.req)
@@ -137,6 +219,124 @@ func (s *RegisterTestSuite) Test_Register_Success() {
 	// assert
 	s.Assert().Nil(resp)
 	s.Assert().Nil(err)
+
+	select {
+	case msg := <-sent:
+		s.Assert().Equal("test@example.com", msg.To)
+	case <-time.After(5 * time.Second):
+		s.Fail("verification email not sent")
+	}
+}
+
+func (s *RegisterTestSuite) Test_Register_CanonicalEmail() {
+	viper.Set("EMAIL_LOWERCASE_LOCAL_PART", true)
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		Mailer:           s.mockMailer,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.RegisterRequest{
+		Email:    "  Bob@Bücher.DE ",
+		Password: "correct horse battery",
+		Name:     "bob",
+	}
+
+	// mock , bob@xn--bcher-kva.de is taken
+	s.mockUserRepo.EXPECT().Create(context.Background(), mock.MatchedBy(func(user *entity.User) bool {
+		return user.EmailCanonical == "bob@xn--bcher-kva.de"
+	})).Return(repository.ErrEmailAlreadyExists)
+
+	// execute
+	resp, err := s.userService.Register(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.AlreadyExists, status.Code(err))
+}
+
+func (s *RegisterTestSuite) Test_Register_CanonicalEmail_Create() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.RegisterRequest{
+		Email:    " Bob@Example.COM",
+		Password: "correct horse battery",
+		Name:     "bob",
+	}
+
+	// mock , the local part keeps its case by default
+	s.mockUserRepo.EXPECT().Create(context.Background(), mock.MatchedBy(func(user *entity.User) bool {
+		return user.Email == "Bob@Example.COM" &&
+			user.EmailCanonical == "Bob@example.com"
+	})).Return(nil)
+	sent := make(chan *mailer.Message, 1)
+	s.mockMailer.EXPECT().Send(mock.Anything, mock.Anything).Run(func(ctx context.Context, msg *mailer.Message) {
+		sent <- msg
+	}).Return(nil)
+
+	// execute
+	resp, err := s.userService.Register(s.input.ctx, s.input.req)
+
+	// assert , the email is sent to the address the user typed
+	s.Assert().Nil(resp)
+	s.Assert().Nil(err)
+
+	select {
+	case msg := <-sent:
+		s.Assert().Equal("Bob@Example.COM", msg.To)
+	case <-time.After(5 * time.Second):
+		s.Fail("verification email not sent")
+	}
+}
+
+func (s *RegisterTestSuite) Test_Register_InvalidArgument() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.RegisterRequest{
+		Email:    "not an email",
+		Password: "password",
+		Name:     strings.Repeat("名", 21),
+	}
+
+	// execute
+	resp, err := s.userService.Register(s.input.ctx, s.input.req)
+
+	// assert, no db call and every field is reported
+	s.Assert().Nil(resp)
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.InvalidArgument, rpcErr.Code())
+
+	fields := map[string]string{}
+	for _, violation := range validation.FieldViolations(err) {
+		fields[violation.GetField()] = violation.GetDescription()
+	}
+	s.Assert().Equal("must be a valid email address", fields["email"])
+	s.Assert().Equal("must be at most 20 characters", fields["name"])
+	s.Assert().Equal("password is too common", fields["password"])
+}
+
+func (s *RegisterTestSuite) Test_Register_BlankName() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.RegisterRequest{
+		Email:    "test@example.com",
+		Password: "correct horse battery",
+		Name:     "   ",
+	}
+
+	// execute
+	resp, err := s.userService.Register(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	violations := validation.FieldViolations(err)
+	s.Require().Len(violations, 1)
+	s.Assert().Equal("name", violations[0].GetField())
 }
 
 func TestLoginTestSuite(t *testing.T) {
@@ -145,21 +345,29 @@ func TestLoginTestSuite(t *testing.T) {
 
 type LoginTestSuite struct {
 	suite.Suite
-	userService  pb.UserServiceServer
-	mockUserRepo *repository.MockUsersRepository
-	input        struct {
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	input                struct {
 		ctx context.Context
 		req *pb.LoginRequest
 	}
 }
 
 func (s *LoginTestSuite) SetupTest() {
-	userRepo := repository.NewMockUsersRepository(s.T())
-	s.mockUserRepo = userRepo
-	s.userService = NewUserService(userRepo)
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
 }
 
-func (s *LoginTestSuite) Test_Login_GetByEmailAndPassword_DBError() {
+func (s *LoginTestSuite) Test_Login_GetByEmail_DBError() {
 	// input
 	s.input.ctx = context.Background()
 	s.input.req = &pb.LoginRequest{
@@ -168,9 +376,7 @@ func (s *LoginTestSuite) Test_Login_GetByEmailAndPassword_DBError() {
 	}
 
 	// mock
-	s.mockUserRepo.EXPECT().GetByEmailAndPassword(context.Background(), s.input.req.Email, mock.MatchedBy(func(password string) bool {
-		return password != s.input.req.Password
-	})).Return(nil, errors.New("db error"))
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(nil, errors.New("db error"))
 
 	// execute
 	resp, err := s.userService.Login(s.input.ctx, s.input.req)
@@ -182,6 +388,23 @@ func (s *LoginTestSuite) Test_Login_GetByEmailAndPassword_DBError() {
 	s.Assert().Equal(codes.Internal, rpcErr.Code())
 }
 
+func (s *LoginTestSuite) Test_Login_InvalidArgument() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@",
+		Password: "",
+	}
+
+	// execute
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+	s.Assert().Len(validation.FieldViolations(err), 2)
+}
+
 func (s *LoginTestSuite) Test_Login_UserNotFound() {
 	// input
 	s.input.ctx = context.Background()
@@ -191,9 +414,77 @@ func (s *LoginTestSuite) Test_Login_UserNotFound() {
 	}
 
 	// mock
-	s.mockUserRepo.EXPECT().GetByEmailAndPassword(context.Background(), s.input.req.Email, mock.MatchedBy(func(password string) bool {
-		return password != s.input.req.Password
-	})).Return(nil, gorm.ErrRecordNotFound)
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(nil, gorm.ErrRecordNotFound)
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(context.Background(), s.input.req.Email).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.Unauthenticated, rpcErr.Code())
+}
+
+func (s *LoginTestSuite) Test_Login_UnknownEmail_DummyPasswordCheck() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "unknown@example.com",
+		Password: "password",
+	}
+
+	user := &entity.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Name:     "test",
+		Password: "other_password",
+	}
+	s.Require().NoError(user.HashPassword())
+
+	var checked []string
+	original := checkDummyPassword
+	checkDummyPassword = func(password string) {
+		checked = append(checked, password)
+		original(password)
+	}
+	s.T().Cleanup(func() { checkDummyPassword = original })
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), "unknown@example.com").Return(nil, gorm.ErrRecordNotFound)
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(context.Background(), "unknown@example.com").Return(nil, gorm.ErrRecordNotFound)
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), "test@example.com").Return(user, nil)
+
+	// execute
+	_, unknownErr := s.userService.Login(s.input.ctx, s.input.req)
+	_, wrongPasswordErr := s.userService.Login(s.input.ctx, &pb.LoginRequest{Email: "test@example.com", Password: "password"})
+
+	// assert , the unknown email spends a password check and can't be told from a wrong password
+	s.Assert().Equal([]string{"password"}, checked)
+	s.Require().Error(unknownErr)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(unknownErr))
+	s.Assert().Equal(wrongPasswordErr.Error(), unknownErr.Error())
+}
+
+func (s *LoginTestSuite) Test_Login_WrongPassword() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "wrong_password",
+	}
+
+	user := &entity.User{
+		ID:       uuid.New(),
+		Email:    s.input.req.Email,
+		Name:     "test",
+		Password: "password",
+	}
+	s.Require().NoError(user.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
 
 	// execute
 	resp, err := s.userService.Login(s.input.ctx, s.input.req)
@@ -203,6 +494,7 @@ func (s *LoginTestSuite) Test_Login_UserNotFound() {
 	rpcErr, ok := status.FromError(err)
 	s.Assert().True(ok)
 	s.Assert().Equal(codes.Unauthenticated, rpcErr.Code())
+	s.Assert().Equal("invalid login info", rpcErr.Message())
 }
 
 func (s *LoginTestSuite) Test_Login_Success() {
@@ -211,6 +503,7 @@ func (s *LoginTestSuite) Test_Login_Success() {
 	s.input.req = &pb.LoginRequest{
 		Email:    "test@example.com",
 		Password: "password",
+		DeviceId: "device",
 	}
 
 	userID := uuid.New()
@@ -218,13 +511,24 @@ func (s *LoginTestSuite) Test_Login_Success() {
 		ID:       userID,
 		Email:    s.input.req.Email,
 		Name:     "test",
-		Password: "hashed_password",
+		Password: s.input.req.Password,
 	}
+	s.Require().NoError(user.HashPassword())
+
+	var savedRefreshToken *entity.RefreshToken
 
 	// mock
-	s.mockUserRepo.EXPECT().GetByEmailAndPassword(context.Background(), s.input.req.Email, mock.MatchedBy(func(password string) bool {
-		return password != s.input.req.Password
-	})).Return(user, nil)
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(context.Background(), mock.MatchedBy(func(token *entity.RefreshToken) bool {
+		return token.UserID == userID &&
+			token.DeviceID == "device" &&
+			token.FamilyID != uuid.Nil &&
+			token.UsedAt == nil &&
+			token.RevokedAt == nil
+	})).RunAndReturn(func(ctx context.Context, token *entity.RefreshToken) error {
+		savedRefreshToken = token
+		return nil
+	})
 
 	// execute
 	resp, err := s.userService.Login(s.input.ctx, s.input.req)
@@ -237,85 +541,520 @@ func (s *LoginTestSuite) Test_Login_Success() {
 	s.Assert().Equal(user.Email, resp.Email)
 	s.Assert().NotEmpty(resp.Token)
 	s.Assert().NotNil(resp.ExpiresIn)
+	s.Assert().NotEmpty(resp.RefreshToken)
+	s.Assert().NotNil(resp.RefreshExpiresIn)
+
+	// only the hash is stored
+	s.Require().NotNil(savedRefreshToken)
+	s.Assert().NotEqual(resp.RefreshToken, savedRefreshToken.TokenHash)
+	s.Assert().Equal(utils.HashOpaqueToken(resp.RefreshToken), savedRefreshToken.TokenHash)
+}
+
+func (s *LoginTestSuite) Test_Login_CreateRefreshToken_DbError() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+
+	user := &entity.User{
+		ID:       uuid.New(),
+		Email:    s.input.req.Email,
+		Name:     "test",
+		Password: s.input.req.Password,
+	}
+	s.Require().NoError(user.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(context.Background(), mock.Anything).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.Internal, rpcErr.Code())
+}
+
+func (s *LoginTestSuite) Test_Login_LegacyHash_Rehash() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+
+	userID := uuid.New()
+	legacyHash := sha512.Sum512([]byte(s.input.req.Password + s.input.req.Email))
+	user := &entity.User{
+		ID:       userID,
+		Email:    s.input.req.Email,
+		Name:     "test",
+		Password: fmt.Sprintf("%x", legacyHash),
+	}
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
+	s.mockUserRepo.EXPECT().UpdatePassword(context.Background(), userID, mock.MatchedBy(func(hash string) bool {
+		match, needsRehash := (&entity.User{Password: hash}).CheckPassword(s.input.req.Password)
+		return match && !needsRehash
+	})).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(context.Background(), mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+	s.Assert().Equal(userID.String(), resp.Id)
+	s.Assert().NotEmpty(resp.Token)
+}
+
+// withLoginLimiter makes the service throttle the logins with an in memory limiter
+func (s *LoginTestSuite) withLoginLimiter(config limiter.LoginLimitConfig) {
+	loginLimiter := limiter.NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), config)
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+		LoginLimiter:     loginLimiter,
+	})
+}
+
+func (s *LoginTestSuite) Test_Login_Lockout() {
+	s.withLoginLimiter(limiter.LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+		MaxAccountFailures: 2,
+	})
+
+	// input
+	s.input.ctx = interceptor.ContextWithClientIP(context.Background(), "203.0.113.7")
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "wrong_password",
+	}
+
+	user := &entity.User{ID: uuid.New(), Email: s.input.req.Email, Password: "password"}
+	s.Require().NoError(user.HashPassword())
+
+	// mock , the user is only looked up before the lock
+	s.mockUserRepo.EXPECT().GetByEmail(s.input.ctx, s.input.req.Email).Return(user, nil).Times(2)
+
+	// execute
+	for i := 0; i < 2; i++ {
+		_, err := s.userService.Login(s.input.ctx, s.input.req)
+		s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+	}
+
+	// the right password is refused too while locked
+	s.input.req.Password = "password"
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+	retryDelay, ok := limiter.RetryDelay(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(15*time.Minute, retryDelay)
+}
+
+func (s *LoginTestSuite) Test_Login_UnknownEmailIsThrottled() {
+	s.withLoginLimiter(limiter.LoginLimitConfig{
+		Window: 15 * time.Minute,
+		Delay:  time.Minute,
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "nobody@example.com",
+		Password: "password",
+	}
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(s.input.ctx, s.input.req.Email).Return(nil, gorm.ErrRecordNotFound).Once()
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(s.input.ctx, s.input.req.Email).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	// execute , the second attempt comes before the delay
+	_, err := s.userService.Login(s.input.ctx, s.input.req)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert , the same as for a registered email
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+	retryDelay, ok := limiter.RetryDelay(err)
+	s.Assert().True(ok)
+	s.Assert().LessOrEqual(retryDelay, time.Minute)
 }
 
-// func (s *UserServiceImplTestSuite) Test_userServiceImpl_Login() {
-// 	type args struct {
-// 		ctx context.Context
-// 		req *pb.LoginRequest
-// 	}
-// 	tests := []struct {
-// 		name       string
-// 		args       args
-// 		mockFunc   func(m *repository.MockUsersRepository)
-// 		assertFunc func(resp *pb.LoginResponse, err error)
-// 	}{
-// 		{
-// 			name: "db error",
-// 			args: args{
-// 				ctx: context.Background(),
-// 				req: &pb.LoginRequest{
-// 					Email:    "db_error@example.com",
-// 					Password: "password",
-// 				},
-// 			},
-// 			mockFunc: func(m *repository.MockUsersRepository) {
-// 				m.EXPECT().GetByEmailAndPassword(context.Background(), "db_error@example.com", mock.AnythingOfType("string")).Return(nil, errors.New("db error")).Times(1)
-// 			},
-// 			assertFunc: func(resp *pb.LoginResponse, err error) {
-// 				s.Assert().ErrorIs(err, &mErr.Err500InternalServer)
-// 			},
-// 		},
-// 		{
-// 			name: "user not found",
-// 			args: args{
-// 				ctx: context.Background(),
-// 				req: &pb.LoginRequest{
-// 					Email:    "not_found@example.com",
-// 					Password: "password",
-// 				},
-// 			},
-// 			mockFunc: func(m *repository.MockUsersRepository) {
-// 				m.EXPECT().GetByEmailAndPassword(context.Background(), "not_found@example.com", mock.AnythingOfType("string")).Return(nil, gorm.ErrRecordNotFound).Times(1)
-// 			},
-// 			assertFunc: func(resp *pb.LoginResponse, err error) {
-// 				s.Assert().ErrorIs(err, &mErr.Err400InvalidLoginInfo)
-// 			},
-// 		},
-// 		{
-// 			name: "success",
-// 			args: args{
-// 				ctx: context.Background(),
-// 				req: &pb.LoginRequest{
-// 					Email:    "success@example.com",
-// 					Password: "password",
-// 				},
-// 			},
-// 			mockFunc: func(m *repository.MockUsersRepository) {
-// 				m.EXPECT().GetByEmailAndPassword(context.Background(), "success@example.com", mock.AnythingOfType("string")).Return(&entity.User{
-// 					ID:       uuid.New(),
-// 					Email:    "success@example.com",
-// 					Password: "password",
-// 					Name:     "test",
-// 				}, nil).Times(1)
-// 			},
-// 			assertFunc: func(resp *pb.LoginResponse, err error) {
-// 				s.Assert().NoError(err)
-// 				s.Assert().Equal("success@example.com", resp.Email)
-// 				s.Assert().Equal("test", resp.Name)
-// 				s.Assert().NotEmpty(resp.Token)
-// 				s.Assert().NotEmpty(resp.ExpiresIn)
-// 			},
-// 		},
-// 	}
-
-// 	for _, tt := range tests {
-
-// 		s.Run(tt.name, func() {
-
-// 			tt.mockFunc(s.mockUserRepo)
-// 			resp, err := s.userService.Login(tt.args.ctx, tt.args.req)
-// 			tt.assertFunc(resp, err)
-// 		})
-// 	}
-// }
+func (s *LoginTestSuite) Test_Login_SuccessResetsFailures() {
+	s.withLoginLimiter(limiter.LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+		MaxAccountFailures: 2,
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email: "test@example.com",
+	}
+
+	user := &entity.User{ID: uuid.New(), Email: s.input.req.Email, Password: "password"}
+	s.Require().NoError(user.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(s.input.ctx, s.input.req.Email).Return(user, nil).Times(3)
+	s.mockRefreshTokenRepo.EXPECT().Create(s.input.ctx, mock.Anything).Return(nil).Once()
+
+	// execute , a failure , a success and a failure don't lock the email
+	for _, password := range []string{"wrong_password", "password", "wrong_password"} {
+		s.input.req.Password = password
+		_, _ = s.userService.Login(s.input.ctx, s.input.req)
+	}
+
+	s.input.req.Password = "password"
+	s.mockUserRepo.EXPECT().GetByEmail(s.input.ctx, s.input.req.Email).Return(user, nil).Once()
+	s.mockRefreshTokenRepo.EXPECT().Create(s.input.ctx, mock.Anything).Return(nil).Once()
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *LoginTestSuite) Test_Login_DisabledKeepsFailures() {
+	s.withLoginLimiter(limiter.LoginLimitConfig{
+		Window:             15 * time.Minute,
+		Lockout:            15 * time.Minute,
+		MaxAccountFailures: 2,
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email: "test@example.com",
+	}
+
+	user := &entity.User{ID: uuid.New(), Email: s.input.req.Email, Password: "password", Status: entity.UserStatusDisabled}
+	s.Require().NoError(user.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(s.input.ctx, s.input.req.Email).Return(user, nil).Times(3)
+
+	// execute , the right password of a disabled account doesn't reset the failures
+	for _, password := range []string{"wrong_password", "password", "wrong_password"} {
+		s.input.req.Password = password
+		_, _ = s.userService.Login(s.input.ctx, s.input.req)
+	}
+
+	s.input.req.Password = "password"
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *LoginTestSuite) Test_Login_LegacyHash_EmailCase() {
+	viper.Set("EMAIL_LOWERCASE_LOCAL_PART", true)
+	s.T().Cleanup(viper.Reset)
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// input , another case than on Register
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    " bob@EXAMPLE.com ",
+		Password: "password",
+	}
+
+	// the legacy hash includes the email as it was registered
+	userID := uuid.New()
+	legacyHash := sha512.Sum512([]byte(s.input.req.Password + "Bob@Example.com"))
+	user := &entity.User{
+		ID:             userID,
+		Email:          "Bob@Example.com",
+		EmailCanonical: "bob@example.com",
+		Name:           "bob",
+		Password:       fmt.Sprintf("%x", legacyHash),
+	}
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), "bob@example.com").Return(user, nil)
+	s.mockUserRepo.EXPECT().UpdatePassword(context.Background(), userID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(context.Background(), mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+	s.Assert().Equal("Bob@Example.com", resp.Email)
+}
+
+func (s *LoginTestSuite) Test_Login_LegacyHash_RehashDbError() {
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+
+	legacyHash := sha512.Sum512([]byte(s.input.req.Password + s.input.req.Email))
+	user := &entity.User{
+		ID:       uuid.New(),
+		Email:    s.input.req.Email,
+		Name:     "test",
+		Password: fmt.Sprintf("%x", legacyHash),
+	}
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
+	s.mockUserRepo.EXPECT().UpdatePassword(context.Background(), mock.Anything, mock.Anything).Return(errors.New("db error"))
+	s.mockRefreshTokenRepo.EXPECT().Create(context.Background(), mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+	// assert, login still succeeds
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+	s.Assert().NotEmpty(resp.Token)
+}
+
+func (s *LoginTestSuite) Test_Login_EmailNotVerified_Required() {
+	viper.Set("AUTH_REQUIRE_EMAIL_VERIFIED", true)
+	s.T().Cleanup(viper.Reset)
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+
+	user := &entity.User{
+		ID:       uuid.New(),
+		Email:    s.input.req.Email,
+		Name:     "test",
+		Password: s.input.req.Password,
+	}
+	s.Require().NoError(user.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
+
+	// execute
+	resp, err := userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.FailedPrecondition, rpcErr.Code())
+	s.Assert().Equal("email not verified", rpcErr.Message())
+}
+
+func (s *LoginTestSuite) Test_Login_EmailNotVerified_Required_WrongPassword() {
+	viper.Set("AUTH_REQUIRE_EMAIL_VERIFIED", true)
+	s.T().Cleanup(viper.Reset)
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "wrong_password",
+	}
+
+	user := &entity.User{
+		ID:       uuid.New(),
+		Email:    s.input.req.Email,
+		Name:     "test",
+		Password: "password",
+	}
+	s.Require().NoError(user.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil)
+
+	// execute
+	resp, err := userService.Login(s.input.ctx, s.input.req)
+
+	// assert, the password is checked first
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *LoginTestSuite) Test_Login_AccountStatus() {
+	tests := []struct {
+		status  entity.UserStatus
+		code    codes.Code
+		message string
+	}{
+		{entity.UserStatusDisabled, codes.PermissionDenied, "account disabled"},
+		{entity.UserStatusLocked, codes.Unauthenticated, "account locked , reset the password to unlock it"},
+	}
+
+	for _, tt := range tests {
+		s.Run(string(tt.status), func() {
+			// input
+			s.input.ctx = context.Background()
+			s.input.req = &pb.LoginRequest{
+				Email:    "test@example.com",
+				Password: "password",
+			}
+
+			user := &entity.User{
+				ID:       uuid.New(),
+				Email:    s.input.req.Email,
+				Name:     "test",
+				Password: s.input.req.Password,
+				Status:   tt.status,
+			}
+			s.Require().NoError(user.HashPassword())
+
+			// mock
+			s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(user, nil).Once()
+
+			// execute
+			resp, err := s.userService.Login(s.input.ctx, s.input.req)
+
+			// assert
+			s.Assert().Nil(resp)
+			rpcErr, ok := status.FromError(err)
+			s.Assert().True(ok)
+			s.Assert().Equal(tt.code, rpcErr.Code())
+			s.Assert().Equal(tt.message, rpcErr.Message())
+		})
+	}
+}
+
+func (s *LoginTestSuite) Test_Login_AccountDeleted() {
+	viper.Set("ACCOUNT_DELETION_GRACE_PERIOD", "720h")
+	s.T().Cleanup(viper.Reset)
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// input
+	s.input.ctx = context.Background()
+	s.input.req = &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password",
+	}
+
+	deleted := &entity.User{
+		ID:        uuid.New(),
+		Email:     s.input.req.Email,
+		Password:  s.input.req.Password,
+		Status:    entity.UserStatusDeleted,
+		DeletedAt: gorm.DeletedAt{Time: time.Now().Add(-time.Hour), Valid: true},
+	}
+	s.Require().NoError(deleted.HashPassword())
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.input.req.Email).Return(nil, gorm.ErrRecordNotFound)
+	s.mockUserRepo.EXPECT().GetDeletedByEmail(context.Background(), s.input.req.Email).Return(deleted, nil)
+
+	// execute
+	resp, err := userService.Login(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.NotFound, status.Code(err))
+
+	// the wrong password doesn't tell the account is deleted
+	s.input.req.Password = "wrong_password"
+	resp, err = userService.Login(s.input.ctx, s.input.req)
+
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func TestGetJwksTestSuite(t *testing.T) {
+	suite.Run(t, new(GetJwksTestSuite))
+}
+
+type GetJwksTestSuite struct {
+	suite.Suite
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+}
+
+func (s *GetJwksTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+}
+
+func (s *GetJwksTestSuite) Test_GetJwks_HMAC() {
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	// execute
+	resp, err := userService.GetJwks(context.Background(), &pb.GetJwksRequest{})
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().Empty(resp.Keys)
+}
+
+func (s *GetJwksTestSuite) Test_GetJwks_ECDSA() {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	signingKey, err := utils.NewPrivateKey("kid", privateKey)
+	s.Require().NoError(err)
+
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), signingKey),
+	})
+
+	// execute
+	resp, err := userService.GetJwks(context.Background(), &pb.GetJwksRequest{})
+
+	// assert
+	s.Assert().Nil(err)
+	s.Require().Len(resp.Keys, 1)
+	s.Assert().Equal("kid", resp.Keys[0].Kid)
+	s.Assert().Equal("EC", resp.Keys[0].Kty)
+	s.Assert().Equal("ES256", resp.Keys[0].Alg)
+	s.Assert().Equal("P-256", resp.Keys[0].Crv)
+}
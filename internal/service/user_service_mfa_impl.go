@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// recoveryCodeCount is the number of recovery codes given when the TOTP is confirmed
+const recoveryCodeCount = 10
+
+// EnrollTOTP creates a TOTP secret for the authenticated user , it is not used at login until ConfirmTOTP.
+// Enrolling again before the confirmation replaces the secret.
+// Like DeleteAccount it needs the password , or an access token of a recent login.
+func (u *userServiceImpl) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (resp *pb.EnrollTOTPResponse, err error) {
+	claims, userID, err := authenticatedClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkMFAConfigured(); err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkReauthentication(ctx, claims, user, req.Password, time.Now()); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		log.Error().Err(err).Msg("generate totp secret error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	sealed, err := u.secretBox.Seal(secret, user.ID.String())
+	if err != nil {
+		log.Error().Err(err).Msg("encrypt totp secret error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	err = u.mfaRepo.CreateTOTP(ctx, &entity.UserTOTP{
+		UserID:          user.ID,
+		SecretEncrypted: sealed,
+	})
+	if errors.Is(err, repository.ErrTOTPAlreadyEnabled) {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrMFAAlreadyEnabled)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("totp , insert db error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return &pb.EnrollTOTPResponse{
+		Secret:     secret,
+		OtpauthUri: utils.TOTPURI(secret, u.mfaConfig.Issuer, user.Email),
+	}, nil
+}
+
+// ConfirmTOTP enables the enrolled TOTP with a code of the authenticator app.
+// The recovery codes are only returned here , the user has to store them.
+// Wrong codes count as failed logins of the email , like VerifyMFA.
+func (u *userServiceImpl) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (resp *pb.ConfirmTOTPResponse, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkMFAConfigured(); err != nil {
+		return nil, err
+	}
+
+	v := validation.New()
+	v.Required("code", req.Code)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientIP, _ := interceptor.ClientIPFromContext(ctx)
+	if err = u.checkLoginLimit(ctx, user.EmailCanonical, clientIP); err != nil {
+		return nil, err
+	}
+
+	totp, err := u.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, mErr.ErrMFANotEnrolled)
+		}
+		log.Error().Err(err).Msg("GetTOTP error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if totp.IsConfirmed() {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := u.secretBox.Open(totp.SecretEncrypted, totp.UserID.String())
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("decrypt totp secret error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	now := time.Now()
+	step, ok := utils.ValidateTOTP(secret, strings.TrimSpace(req.Code), now)
+	if !ok {
+		u.loginFailed(ctx, user.EmailCanonical, clientIP)
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidMFACode)
+	}
+
+	recoveryCodes, hashes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Error().Err(err).Msg("generate recovery codes error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	stored := make([]*entity.RecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		stored = append(stored, &entity.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: now,
+		})
+	}
+
+	err = u.mfaRepo.ConfirmTOTP(ctx, userID, step, now, stored)
+	if errors.Is(err, repository.ErrTOTPAlreadyEnabled) {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrMFAAlreadyEnabled)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("ConfirmTOTP error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("two factor enabled")
+
+	return &pb.ConfirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// DisableTOTP turns the two factor off , it needs the password and a TOTP or recovery code.
+// Wrong passwords and codes count as failed logins of the email , like VerifyMFA.
+func (u *userServiceImpl) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (resp *protobuf.EmptyResponse, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkMFAConfigured(); err != nil {
+		return nil, err
+	}
+
+	v := validation.New()
+	v.Required("password", req.Password)
+	checkSecondFactorFields(v, req.Code, req.RecoveryCode)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkUserPassword(ctx, user, req.Password); err != nil {
+		return nil, err
+	}
+
+	totp, err := u.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error().Err(err).Msg("GetTOTP error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if err != nil || !totp.IsConfirmed() {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrMFANotEnabled)
+	}
+
+	ok, err := u.verifySecondFactor(ctx, totp, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Error().Err(err).Msg("verify second factor error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if !ok {
+		clientIP, _ := interceptor.ClientIPFromContext(ctx)
+		u.loginFailed(ctx, user.EmailCanonical, clientIP)
+		return nil, status.Error(codes.PermissionDenied, mErr.ErrInvalidMFACode)
+	}
+	u.loginSucceeded(ctx, user.EmailCanonical)
+
+	if err = u.mfaRepo.DeleteTOTP(ctx, userID); err != nil {
+		log.Error().Err(err).Msg("DeleteTOTP error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("two factor disabled")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// VerifyMFA completes a login that returned mfa_required with a TOTP or recovery code.
+// The mfa token is single use , wrong codes count as failed logins of the email.
+func (u *userServiceImpl) VerifyMFA(ctx context.Context, req *pb.VerifyMFARequest) (resp *pb.LoginResponse, err error) {
+	if err = u.checkMFAConfigured(); err != nil {
+		return nil, err
+	}
+
+	v := validation.New()
+	v.Required("mfa_token", req.MfaToken)
+	checkSecondFactorFields(v, req.Code, req.RecoveryCode)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	claims, err := utils.ParsePurposeToken(req.MfaToken, u.jwtConfig.KeyRing, u.jwtConfig.Issuer, utils.PurposeMFA)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFAToken)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFAToken)
+	}
+
+	// used before , or issued before the tokens of the user were revoked
+	revoked, err := u.revokedTokenRepo.IsRevoked(ctx, claims.ID, userID, claims.IssuedAt.Time)
+	if err != nil {
+		log.Error().Err(err).Msg("IsRevoked error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if revoked {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFAToken)
+	}
+
+	user, err := u.userRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFAToken)
+		}
+		log.Error().Err(err).Msg("Get user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if claims.Email != user.Email {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFAToken)
+	}
+
+	// disabled or locked since the login
+	if err = u.checkLoginStatus(user); err != nil {
+		return nil, err
+	}
+
+	clientIP, _ := interceptor.ClientIPFromContext(ctx)
+	if err = u.checkLoginLimit(ctx, user.EmailCanonical, clientIP); err != nil {
+		return nil, err
+	}
+
+	// disabled since the login
+	totp, err := u.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error().Err(err).Msg("GetTOTP error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if err != nil || !totp.IsConfirmed() {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFAToken)
+	}
+
+	ok, err := u.verifySecondFactor(ctx, totp, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Error().Err(err).Msg("verify second factor error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if !ok {
+		u.loginFailed(ctx, user.EmailCanonical, clientIP)
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidMFACode)
+	}
+	u.loginSucceeded(ctx, user.EmailCanonical)
+
+	// a failure is only logged , the token can't be used without another code anyway
+	if err = u.revokedTokenRepo.RevokeToken(ctx, claims.ID, userID, claims.ExpiresAt.Time); err != nil {
+		log.Error().Err(err).Msg("revoke mfa token error")
+	}
+
+	return u.issueLoginTokens(ctx, user, req.DeviceId)
+}
+
+// checkMFAConfigured fails when the service runs without the mfa store or the encryption key
+func (u *userServiceImpl) checkMFAConfigured() error {
+	if u.mfaRepo == nil || u.secretBox == nil {
+		return status.Error(codes.FailedPrecondition, mErr.ErrMFANotConfigured)
+	}
+
+	return nil
+}
+
+// isMFAEnabled reports whether the login of the user needs a second factor
+func (u *userServiceImpl) isMFAEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if u.mfaRepo == nil {
+		return false, nil
+	}
+
+	totp, err := u.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		log.Error().Err(err).Msg("GetTOTP error")
+		return false, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return totp.IsConfirmed(), nil
+}
+
+// mfaChallenge is the login response of a user with two factor , only the mfa token to call VerifyMFA with
+func (u *userServiceImpl) mfaChallenge(user *entity.User) (*pb.LoginResponse, error) {
+	expiresAt := time.Now().Add(u.mfaConfig.TokenExpireAt)
+
+	token, err := utils.GeneratePurposeToken(
+		user.ID.String(),
+		utils.PurposeMFA,
+		user.Email,
+		u.jwtConfig.KeyRing.SigningKey(),
+		u.jwtConfig.Issuer,
+		u.mfaConfig.TokenExpireAt,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("generate mfa token error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return &pb.LoginResponse{
+		MfaRequired:  true,
+		MfaToken:     token,
+		MfaExpiresIn: timestamppb.New(expiresAt),
+	}, nil
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code , both are accepted only once
+func (u *userServiceImpl) verifySecondFactor(ctx context.Context, totp *entity.UserTOTP, code string, recoveryCode string) (bool, error) {
+	now := time.Now()
+
+	if strings.TrimSpace(recoveryCode) != "" {
+		err := u.mfaRepo.UseRecoveryCode(ctx, totp.UserID, utils.HashRecoveryCode(recoveryCode), now)
+		if errors.Is(err, repository.ErrRecoveryCodeInvalid) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		log.Info().Str("user_id", totp.UserID.String()).Msg("recovery code used")
+		return true, nil
+	}
+
+	secret, err := u.secretBox.Open(totp.SecretEncrypted, totp.UserID.String())
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, strings.TrimSpace(code), now)
+	if !ok {
+		return false, nil
+	}
+
+	err = u.mfaRepo.UseTOTPStep(ctx, totp.UserID, step)
+	if errors.Is(err, repository.ErrTOTPCodeUsed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkSecondFactorFields checks exactly one of code and recovery_code is set
+func checkSecondFactorFields(v *validation.Validator, code string, recoveryCode string) {
+	code, recoveryCode = strings.TrimSpace(code), strings.TrimSpace(recoveryCode)
+
+	v.Check(code != "" || recoveryCode != "", "code", "code or recovery_code is required")
+	v.Check(code == "" || recoveryCode == "", "recovery_code", "must be empty when code is set")
+}
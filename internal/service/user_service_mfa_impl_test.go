@@ -0,0 +1,633 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestMFATestSuite(t *testing.T) {
+	suite.Run(t, new(MFATestSuite))
+}
+
+type MFATestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	mockMFARepo          *repository.MockMFARepository
+	keyRing              *utils.KeyRing
+	secretBox            *utils.SecretBox
+	user                 *entity.User
+	secret               string
+	totp                 *entity.UserTOTP
+	ctx                  context.Context
+}
+
+func (s *MFATestSuite) SetupTest() {
+	viper.Set("SERVER_NAME", "todolist-user")
+	viper.Set("MFA_TOKEN_EXPIRE_AT", "5m")
+	viper.Set("ACCOUNT_DELETION_FRESH_TOKEN_AGE", "5m")
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.mockMFARepo = repository.NewMockMFARepository(s.T())
+	s.keyRing = newTestKeyRing(s.T(), utils.NewHMACKey("", "secret"))
+
+	secretBox, err := utils.NewSecretBox(bytes.Repeat([]byte{7}, utils.SecretBoxKeySize))
+	s.Require().NoError(err)
+	s.secretBox = secretBox
+
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+		MFARepo:          s.mockMFARepo,
+		SecretBox:        s.secretBox,
+	})
+
+	s.user = &entity.User{
+		ID:             uuid.New(),
+		Name:           "test",
+		Email:          "test@example.com",
+		EmailCanonical: "test@example.com",
+		Password:       "correct horse battery",
+	}
+	s.Require().NoError(s.user.HashPassword())
+
+	s.secret, err = utils.NewTOTPSecret()
+	s.Require().NoError(err)
+
+	sealed, err := s.secretBox.Seal(s.secret, s.user.ID.String())
+	s.Require().NoError(err)
+
+	confirmedAt := time.Now().Add(-time.Hour)
+	s.totp = &entity.UserTOTP{
+		UserID:          s.user.ID,
+		SecretEncrypted: sealed,
+		ConfirmedAt:     &confirmedAt,
+	}
+
+	s.ctx = s.tokenContext(time.Now())
+}
+
+// tokenContext is the context of an access token of the user logged in at authTime
+func (s *MFATestSuite) tokenContext(authTime time.Time) context.Context {
+	return interceptor.ContextWithClaims(context.Background(), &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   s.user.ID.String(),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		AuthTime: jwt.NewNumericDate(authTime),
+	})
+}
+
+// withLoginLimiter recreates the service with a login limiter locking the email after maxFailures
+func (s *MFATestSuite) withLoginLimiter(maxFailures int) {
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+		MFARepo:          s.mockMFARepo,
+		SecretBox:        s.secretBox,
+		LoginLimiter: limiter.NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), limiter.LoginLimitConfig{
+			Window:             15 * time.Minute,
+			Lockout:            15 * time.Minute,
+			MaxAccountFailures: maxFailures,
+		}),
+	})
+}
+
+func (s *MFATestSuite) assertCode(code codes.Code, message string, err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(code, rpcErr.Code())
+	s.Assert().Equal(message, rpcErr.Message())
+}
+
+func (s *MFATestSuite) currentCode() string {
+	code, err := utils.TOTPCode(s.secret, time.Now())
+	s.Require().NoError(err)
+	return code
+}
+
+func (s *MFATestSuite) mfaToken() string {
+	token, err := utils.GeneratePurposeToken(s.user.ID.String(), utils.PurposeMFA, s.user.Email, s.keyRing.SigningKey(), "todolist-user", 5*time.Minute)
+	s.Require().NoError(err)
+	return token
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_Success() {
+	// mock
+	var stored *entity.UserTOTP
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().CreateTOTP(s.ctx, mock.MatchedBy(func(totp *entity.UserTOTP) bool {
+		stored = totp
+		return totp.UserID == s.user.ID && !totp.IsConfirmed()
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.EnrollTOTP(s.ctx, &pb.EnrollTOTPRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Len(resp.Secret, 32)
+	s.Assert().Contains(resp.OtpauthUri, "otpauth://totp/todolist-user:test@example.com?")
+	s.Assert().Contains(resp.OtpauthUri, "secret="+resp.Secret)
+
+	// stored encrypted
+	s.Assert().NotContains(stored.SecretEncrypted, resp.Secret)
+	secret, err := s.secretBox.Open(stored.SecretEncrypted, s.user.ID.String())
+	s.Require().NoError(err)
+	s.Assert().Equal(resp.Secret, secret)
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_StaleLogin_Password() {
+	// input
+	ctx := s.tokenContext(time.Now().Add(-time.Hour))
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().CreateTOTP(ctx, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.EnrollTOTP(ctx, &pb.EnrollTOTPRequest{Password: "correct horse battery"})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(resp.Secret)
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_StaleLogin_ReauthenticationRequired() {
+	// input
+	ctx := s.tokenContext(time.Now().Add(-time.Hour))
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.EnrollTOTP(ctx, &pb.EnrollTOTPRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, "password or a recent login required", err)
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_IncorrectPassword() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.EnrollTOTP(s.ctx, &pb.EnrollTOTPRequest{Password: "wrong password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, "incorrect password", err)
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_AlreadyEnabled() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().CreateTOTP(s.ctx, mock.Anything).Return(repository.ErrTOTPAlreadyEnabled)
+
+	// execute
+	resp, err := s.userService.EnrollTOTP(s.ctx, &pb.EnrollTOTPRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, "two factor authentication already enabled", err)
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_NotConfigured() {
+	// input
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+		MFARepo:          s.mockMFARepo,
+	})
+
+	// execute
+	resp, err := userService.EnrollTOTP(s.ctx, &pb.EnrollTOTPRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, "two factor authentication is not configured", err)
+}
+
+func (s *MFATestSuite) Test_EnrollTOTP_Unauthenticated() {
+	// execute
+	resp, err := s.userService.EnrollTOTP(context.Background(), &pb.EnrollTOTPRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "missing token", err)
+}
+
+func (s *MFATestSuite) Test_ConfirmTOTP_Success() {
+	// input
+	s.totp.ConfirmedAt = nil
+
+	// mock
+	var stored []*entity.RecoveryCode
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil)
+	s.mockMFARepo.EXPECT().ConfirmTOTP(s.ctx, s.user.ID, mock.AnythingOfType("int64"), mock.Anything, mock.MatchedBy(func(codes []*entity.RecoveryCode) bool {
+		stored = codes
+		return len(codes) == 10
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.ConfirmTOTP(s.ctx, &pb.ConfirmTOTPRequest{Code: s.currentCode()})
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(resp.RecoveryCodes, 10)
+	for i, code := range resp.RecoveryCodes {
+		s.Assert().Equal(utils.HashRecoveryCode(code), stored[i].CodeHash)
+		s.Assert().Equal(s.user.ID, stored[i].UserID)
+	}
+}
+
+func (s *MFATestSuite) Test_ConfirmTOTP_InvalidCode() {
+	// input
+	s.totp.ConfirmedAt = nil
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil)
+
+	// execute
+	resp, err := s.userService.ConfirmTOTP(s.ctx, &pb.ConfirmTOTPRequest{Code: "000000x"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid two factor code", err)
+}
+
+func (s *MFATestSuite) Test_ConfirmTOTP_Lockout() {
+	// input
+	s.withLoginLimiter(2)
+	s.totp.ConfirmedAt = nil
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil).Times(3)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil).Twice()
+
+	// execute , the wrong codes count as failed logins
+	for i := 0; i < 2; i++ {
+		_, err := s.userService.ConfirmTOTP(s.ctx, &pb.ConfirmTOTPRequest{Code: "000000x"})
+		s.assertCode(codes.InvalidArgument, "invalid two factor code", err)
+	}
+
+	// the right code is refused too while locked
+	_, err := s.userService.ConfirmTOTP(s.ctx, &pb.ConfirmTOTPRequest{Code: s.currentCode()})
+
+	// assert
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *MFATestSuite) Test_ConfirmTOTP_NotEnrolled() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.ConfirmTOTP(s.ctx, &pb.ConfirmTOTPRequest{Code: "123456"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, "totp not enrolled", err)
+}
+
+func (s *MFATestSuite) Test_ConfirmTOTP_AlreadyEnabled() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil)
+
+	// execute
+	resp, err := s.userService.ConfirmTOTP(s.ctx, &pb.ConfirmTOTPRequest{Code: s.currentCode()})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, "two factor authentication already enabled", err)
+}
+
+func (s *MFATestSuite) Test_DisableTOTP_Success() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil)
+	s.mockMFARepo.EXPECT().UseTOTPStep(s.ctx, s.user.ID, mock.Anything).Return(nil)
+	s.mockMFARepo.EXPECT().DeleteTOTP(s.ctx, s.user.ID).Return(nil)
+
+	// execute
+	_, err := s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password: "correct horse battery",
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *MFATestSuite) Test_DisableTOTP_RecoveryCode() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil)
+	s.mockMFARepo.EXPECT().UseRecoveryCode(s.ctx, s.user.ID, utils.HashRecoveryCode("abcde-fghjk"), mock.Anything).Return(nil)
+	s.mockMFARepo.EXPECT().DeleteTOTP(s.ctx, s.user.ID).Return(nil)
+
+	// execute
+	_, err := s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password:     "correct horse battery",
+		RecoveryCode: "ABCDE-FGHJK",
+	})
+
+	// assert
+	s.Require().NoError(err)
+}
+
+func (s *MFATestSuite) Test_DisableTOTP_IncorrectPassword() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	_, err := s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password: "wrong password",
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.assertCode(codes.PermissionDenied, "incorrect password", err)
+}
+
+func (s *MFATestSuite) Test_DisableTOTP_InvalidCode() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil)
+	s.mockMFARepo.EXPECT().UseRecoveryCode(s.ctx, s.user.ID, mock.Anything, mock.Anything).Return(repository.ErrRecoveryCodeInvalid)
+
+	// execute
+	_, err := s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password:     "correct horse battery",
+		RecoveryCode: "abcde-fghjk",
+	})
+
+	// assert
+	s.assertCode(codes.PermissionDenied, "invalid two factor code", err)
+}
+
+func (s *MFATestSuite) Test_DisableTOTP_Lockout() {
+	// input
+	s.withLoginLimiter(2)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil).Times(3)
+	s.mockMFARepo.EXPECT().GetTOTP(s.ctx, s.user.ID).Return(s.totp, nil).Once()
+	s.mockMFARepo.EXPECT().UseRecoveryCode(s.ctx, s.user.ID, mock.Anything, mock.Anything).Return(repository.ErrRecoveryCodeInvalid).Once()
+
+	// execute , a wrong password and a wrong recovery code both count
+	_, err := s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password:     "wrong password",
+		RecoveryCode: "abcde-fghjk",
+	})
+	s.assertCode(codes.PermissionDenied, "incorrect password", err)
+
+	_, err = s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password:     "correct horse battery",
+		RecoveryCode: "abcde-fghjk",
+	})
+	s.assertCode(codes.PermissionDenied, "invalid two factor code", err)
+
+	// the right password and code are refused too while locked
+	_, err = s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password: "correct horse battery",
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *MFATestSuite) Test_DisableTOTP_MissingSecondFactor() {
+	// execute
+	_, err := s.userService.DisableTOTP(s.ctx, &pb.DisableTOTPRequest{
+		Password: "correct horse battery",
+	})
+
+	// assert
+	s.assertCode(codes.InvalidArgument, "invalid argument", err)
+}
+
+func (s *MFATestSuite) Test_Login_MFARequired() {
+	// mock
+	ctx := context.Background()
+	s.mockUserRepo.EXPECT().GetByEmail(ctx, "test@example.com").Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(ctx, s.user.ID).Return(s.totp, nil)
+
+	// execute
+	resp, err := s.userService.Login(ctx, &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "correct horse battery",
+		DeviceId: "device",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().True(resp.MfaRequired)
+	s.Assert().Empty(resp.Token)
+	s.Assert().Empty(resp.RefreshToken)
+	s.Assert().WithinDuration(time.Now().Add(5*time.Minute), resp.MfaExpiresIn.AsTime(), time.Minute)
+
+	claims, err := utils.ParsePurposeToken(resp.MfaToken, s.keyRing, "todolist-user", utils.PurposeMFA)
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), claims.Subject)
+
+	// not an access token
+	_, err = utils.ParseToken(resp.MfaToken, s.keyRing, "todolist-user")
+	s.Assert().Error(err)
+}
+
+func (s *MFATestSuite) Test_Login_NotConfirmed() {
+	// input
+	s.totp.ConfirmedAt = nil
+
+	// mock
+	ctx := context.Background()
+	s.mockUserRepo.EXPECT().GetByEmail(ctx, "test@example.com").Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(ctx, s.user.ID).Return(s.totp, nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(ctx, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.Login(ctx, &pb.LoginRequest{
+		Email:    "test@example.com",
+		Password: "correct horse battery",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.MfaRequired)
+	s.Assert().NotEmpty(resp.Token)
+}
+
+func (s *MFATestSuite) expectMFAToken(ctx context.Context) {
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(ctx, mock.Anything, s.user.ID, mock.Anything).Return(false, nil)
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(ctx, s.user.ID).Return(s.totp, nil)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_Success() {
+	// mock
+	ctx := context.Background()
+	s.expectMFAToken(ctx)
+	s.mockMFARepo.EXPECT().UseTOTPStep(ctx, s.user.ID, mock.AnythingOfType("int64")).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(ctx, mock.Anything, s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(ctx, mock.MatchedBy(func(t *entity.RefreshToken) bool {
+		return t.UserID == s.user.ID && t.DeviceID == "device"
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(ctx, &pb.VerifyMFARequest{
+		MfaToken: s.mfaToken(),
+		Code:     s.currentCode(),
+		DeviceId: "device",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), resp.Id)
+	s.Assert().NotEmpty(resp.RefreshToken)
+
+	claims, err := utils.ParseToken(resp.Token, s.keyRing, "todolist-user")
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), claims.Subject)
+	s.Require().NotNil(claims.AuthTime)
+	s.Assert().WithinDuration(time.Now(), claims.AuthTime.Time, time.Minute)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_RecoveryCode() {
+	// mock
+	ctx := context.Background()
+	s.expectMFAToken(ctx)
+	s.mockMFARepo.EXPECT().UseRecoveryCode(ctx, s.user.ID, utils.HashRecoveryCode("abcde-fghjk"), mock.Anything).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(ctx, mock.Anything, s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(ctx, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(ctx, &pb.VerifyMFARequest{
+		MfaToken:     s.mfaToken(),
+		RecoveryCode: "abcde-fghjk",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(resp.Token)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_InvalidCode() {
+	// mock
+	ctx := context.Background()
+	s.expectMFAToken(ctx)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(ctx, &pb.VerifyMFARequest{
+		MfaToken: s.mfaToken(),
+		Code:     "12345x",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid two factor code", err)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_CodeReused() {
+	// mock
+	ctx := context.Background()
+	s.expectMFAToken(ctx)
+	s.mockMFARepo.EXPECT().UseTOTPStep(ctx, s.user.ID, mock.Anything).Return(repository.ErrTOTPCodeUsed)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(ctx, &pb.VerifyMFARequest{
+		MfaToken: s.mfaToken(),
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid two factor code", err)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_TokenUsed() {
+	// mock
+	ctx := context.Background()
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(ctx, mock.Anything, s.user.ID, mock.Anything).Return(true, nil)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(ctx, &pb.VerifyMFARequest{
+		MfaToken: s.mfaToken(),
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid or expired mfa token", err)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_AccessToken() {
+	// input
+	accessToken, err := utils.GenerateToken(s.user.ID.String(), nil, nil, time.Now(), s.keyRing.SigningKey(), "todolist-user", 1)
+	s.Require().NoError(err)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(context.Background(), &pb.VerifyMFARequest{
+		MfaToken: accessToken,
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid or expired mfa token", err)
+}
+
+func (s *MFATestSuite) Test_VerifyMFA_Disabled() {
+	// mock
+	ctx := context.Background()
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(ctx, mock.Anything, s.user.ID, mock.Anything).Return(false, nil)
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockMFARepo.EXPECT().GetTOTP(ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.VerifyMFA(ctx, &pb.VerifyMFARequest{
+		MfaToken: s.mfaToken(),
+		Code:     s.currentCode(),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid or expired mfa token", err)
+}
@@ -0,0 +1,432 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const (
+	passkeyNameMaxLength = 64
+	defaultPasskeyName   = "Passkey"
+	// defaultWebAuthnSessionTimeout is used when the relying party doesn't enforce a timeout
+	defaultWebAuthnSessionTimeout = 5 * time.Minute
+)
+
+// BeginPasskeyRegistration starts adding a passkey to the authenticated user.
+// options_json is passed to navigator.credentials.create() , the result is sent to FinishPasskeyRegistration with the session id.
+func (u *userServiceImpl) BeginPasskeyRegistration(ctx context.Context, req *pb.BeginPasskeyRegistrationRequest) (resp *pb.BeginPasskeyRegistrationResponse, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkPasskeyConfigured(); err != nil {
+		return nil, err
+	}
+
+	waUser, err := u.getWebAuthnUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the authenticator refuses to register a second passkey of the same user
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(waUser.credentials))
+	for _, credential := range waUser.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, sessionData, err := u.webAuthn.BeginRegistration(waUser, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		log.Error().Err(err).Msg("begin passkey registration error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	sessionID, options, err := u.createWebAuthnSession(ctx, userID, entity.WebAuthnSessionRegistration, sessionData, creation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.BeginPasskeyRegistrationResponse{
+		SessionId:   sessionID,
+		OptionsJson: options,
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the credential made by the authenticator and stores its public key
+func (u *userServiceImpl) FinishPasskeyRegistration(ctx context.Context, req *pb.FinishPasskeyRegistrationRequest) (resp *pb.Passkey, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkPasskeyConfigured(); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+
+	v := validation.New()
+	v.Required("session_id", req.SessionId)
+	v.Required("credential_json", req.CredentialJson)
+	v.Length("name", name, 0, passkeyNameMaxLength)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	session, sessionData, err := u.takeWebAuthnSession(ctx, entity.WebAuthnSessionRegistration, req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// started by another user
+	if session.UserID != userID {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidPasskeySession)
+	}
+
+	waUser, err := u.getWebAuthnUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(strings.NewReader(req.CredentialJson))
+	if err != nil {
+		log.Info().Err(err).Str("user_id", userID.String()).Msg("parse passkey registration error")
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidPasskey)
+	}
+
+	credential, err := u.webAuthn.CreateCredential(waUser, *sessionData, parsed)
+	if err != nil {
+		log.Info().Err(err).Str("user_id", userID.String()).Msg("verify passkey registration error")
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidPasskey)
+	}
+
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	stored := &entity.WebAuthnCredential{
+		ID:              uuid.New(),
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+		CreatedAt:       time.Now(),
+	}
+
+	err = u.webAuthnRepo.CreateCredential(ctx, stored)
+	if errors.Is(err, repository.ErrCredentialAlreadyExists) {
+		return nil, status.Error(codes.AlreadyExists, mErr.ErrPasskeyAlreadyExists)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("passkey , insert db error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("passkey registered")
+
+	return toPasskey(stored), nil
+}
+
+// BeginPasskeyLogin starts a passwordless login.
+// The passkeys are discoverable , so the user is not asked for the email and the response doesn't tell which emails exist.
+func (u *userServiceImpl) BeginPasskeyLogin(ctx context.Context, req *pb.BeginPasskeyLoginRequest) (resp *pb.BeginPasskeyLoginResponse, err error) {
+	if err = u.checkPasskeyConfigured(); err != nil {
+		return nil, err
+	}
+
+	assertion, sessionData, err := u.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		log.Error().Err(err).Msg("begin passkey login error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	sessionID, options, err := u.createWebAuthnSession(ctx, uuid.Nil, entity.WebAuthnSessionLogin, sessionData, assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.BeginPasskeyLoginResponse{
+		SessionId:   sessionID,
+		OptionsJson: options,
+	}, nil
+}
+
+// FinishPasskeyLogin verifies the assertion of the authenticator and issues the same tokens as Login.
+// A passkey is a second factor by itself , so a user with TOTP is not asked for a code.
+func (u *userServiceImpl) FinishPasskeyLogin(ctx context.Context, req *pb.FinishPasskeyLoginRequest) (resp *pb.LoginResponse, err error) {
+	if err = u.checkPasskeyConfigured(); err != nil {
+		return nil, err
+	}
+
+	v := validation.New()
+	v.Required("session_id", req.SessionId)
+	v.Required("credential_json", req.CredentialJson)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	_, sessionData, err := u.takeWebAuthnSession(ctx, entity.WebAuthnSessionLogin, req.SessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(strings.NewReader(req.CredentialJson))
+	if err != nil {
+		log.Info().Err(err).Msg("parse passkey login error")
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidPasskey)
+	}
+
+	// the user handle the authenticator returns is the user id given at the registration
+	var (
+		waUser    *webAuthnUser
+		lookupErr error
+	)
+	credential, err := u.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		waUser, lookupErr = u.getWebAuthnUser(ctx, userID)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+
+		return waUser, nil
+	}, *sessionData, parsed)
+	if lookupErr != nil && status.Code(lookupErr) == codes.Internal {
+		return nil, lookupErr
+	}
+	if err != nil {
+		log.Info().Err(err).Msg("verify passkey login error")
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidPasskey)
+	}
+
+	// the counter went back , the authenticator may be cloned
+	if credential.Authenticator.CloneWarning {
+		log.Warn().Str("user_id", waUser.user.ID.String()).Msg("passkey sign counter went back , login refused")
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidPasskey)
+	}
+
+	// the library only returns credentials of the user , but a credential removed meanwhile is not stored anymore
+	stored := waUser.credential(credential.ID)
+	if stored == nil {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidPasskey)
+	}
+
+	// a locked or deleted account must not touch the credential
+	if err = u.checkLoginStatus(waUser.user); err != nil {
+		return nil, err
+	}
+
+	err = u.webAuthnRepo.UpdateCredentialUsage(ctx, stored.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("UpdateCredentialUsage error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	log.Info().Str("user_id", waUser.user.ID.String()).Msg("passkey login")
+
+	return u.issueLoginTokens(ctx, waUser.user, req.DeviceId)
+}
+
+// checkPasskeyConfigured fails when the service runs without a relying party
+func (u *userServiceImpl) checkPasskeyConfigured() error {
+	if u.webAuthnRepo == nil || u.webAuthn == nil {
+		return status.Error(codes.FailedPrecondition, mErr.ErrPasskeyNotConfigured)
+	}
+
+	return nil
+}
+
+func (u *userServiceImpl) getWebAuthnUser(ctx context.Context, userID uuid.UUID) (*webAuthnUser, error) {
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := u.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListCredentials error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// createWebAuthnSession stores the session data of a ceremony and returns the session id with the options for the browser
+func (u *userServiceImpl) createWebAuthnSession(
+	ctx context.Context,
+	userID uuid.UUID,
+	purpose entity.WebAuthnSessionPurpose,
+	sessionData *webauthn.SessionData,
+	options any,
+) (sessionID string, optionsJSON string, err error) {
+	now := time.Now()
+	expiresAt := sessionData.Expires
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(defaultWebAuthnSessionTimeout)
+	}
+
+	data, err := json.Marshal(sessionData)
+	if err != nil {
+		log.Error().Err(err).Msg("marshal webauthn session error")
+		return "", "", status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	optionsData, err := json.Marshal(options)
+	if err != nil {
+		log.Error().Err(err).Msg("marshal webauthn options error")
+		return "", "", status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		log.Error().Err(err).Msg("generate webauthn session error")
+		return "", "", status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	err = u.webAuthnRepo.CreateSession(ctx, &entity.WebAuthnSession{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		Data:      string(data),
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("webauthn session , insert db error")
+		return "", "", status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return token, string(optionsData), nil
+}
+
+// takeWebAuthnSession returns the session of the id and deletes it , a session can only be finished once
+func (u *userServiceImpl) takeWebAuthnSession(ctx context.Context, purpose entity.WebAuthnSessionPurpose, sessionID string) (*entity.WebAuthnSession, *webauthn.SessionData, error) {
+	session, err := u.webAuthnRepo.TakeSession(ctx, purpose, utils.HashOpaqueToken(sessionID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, repository.ErrWebAuthnSessionUsed) {
+			return nil, nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidPasskeySession)
+		}
+		log.Error().Err(err).Msg("TakeSession error")
+		return nil, nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if session.IsExpired(time.Now()) {
+		return nil, nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidPasskeySession)
+	}
+
+	var sessionData webauthn.SessionData
+	if err = json.Unmarshal([]byte(session.Data), &sessionData); err != nil {
+		log.Error().Err(err).Msg("unmarshal webauthn session error")
+		return nil, nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return session, &sessionData, nil
+}
+
+func toPasskey(credential *entity.WebAuthnCredential) *pb.Passkey {
+	return &pb.Passkey{
+		Id:         credential.ID.String(),
+		Name:       credential.Name,
+		Transports: credential.TransportList(),
+		CreatedAt:  timestamppb.New(credential.CreatedAt),
+	}
+}
+
+var _ webauthn.User = &webAuthnUser{}
+
+// webAuthnUser is a user and its passkeys as the webauthn library sees them
+type webAuthnUser struct {
+	user        *entity.User
+	credentials []*entity.WebAuthnCredential
+}
+
+// WebAuthnID is the user handle , the 16 bytes of the user id
+func (w *webAuthnUser) WebAuthnID() []byte {
+	return w.user.ID[:]
+}
+
+func (w *webAuthnUser) WebAuthnName() string {
+	return w.user.Email
+}
+
+func (w *webAuthnUser) WebAuthnDisplayName() string {
+	return w.user.Name
+}
+
+func (w *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (w *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(w.credentials))
+
+	for _, c := range w.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0)
+		for _, transport := range c.TransportList() {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
+
+// credential returns the stored passkey of the credential id
+func (w *webAuthnUser) credential(credentialID []byte) *entity.WebAuthnCredential {
+	for _, c := range w.credentials {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			return c
+		}
+	}
+
+	return nil
+}
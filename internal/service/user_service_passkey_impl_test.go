@@ -0,0 +1,705 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+const (
+	testRPID     = "todolist.example.com"
+	testRPOrigin = "https://todolist.example.com"
+)
+
+func TestPasskeyTestSuite(t *testing.T) {
+	suite.Run(t, new(PasskeyTestSuite))
+}
+
+type PasskeyTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockWebAuthnRepo     *repository.MockWebAuthnRepository
+	keyRing              *utils.KeyRing
+	authenticator        *softAuthenticator
+	user                 *entity.User
+	credential           *entity.WebAuthnCredential
+	session              *entity.WebAuthnSession
+	ctx                  context.Context
+}
+
+func (s *PasskeyTestSuite) SetupTest() {
+	viper.Set("SERVER_NAME", "todolist-user")
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockWebAuthnRepo = repository.NewMockWebAuthnRepository(s.T())
+	s.keyRing = newTestKeyRing(s.T(), utils.NewHMACKey("", "secret"))
+
+	s.userService = s.newUserService()
+
+	s.user = &entity.User{
+		ID:             uuid.New(),
+		Name:           "test",
+		Email:          "test@example.com",
+		EmailCanonical: "test@example.com",
+	}
+
+	s.authenticator = newSoftAuthenticator(s.T(), testRPID, testRPOrigin)
+	s.credential = &entity.WebAuthnCredential{
+		ID:              uuid.New(),
+		UserID:          s.user.ID,
+		CredentialID:    s.authenticator.credentialID,
+		PublicKey:       s.authenticator.publicKey(),
+		AttestationType: "none",
+		Transports:      "internal",
+		Name:            "Passkey",
+	}
+	s.session = nil
+
+	s.ctx = interceptor.ContextWithUserID(context.Background(), s.user.ID.String())
+}
+
+func (s *PasskeyTestSuite) newUserService() pb.UserServiceServer {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "todolist",
+		RPOrigins:     []string{testRPOrigin},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: time.Minute},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: time.Minute},
+		},
+	})
+	s.Require().NoError(err)
+
+	return newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		KeyRing:          s.keyRing,
+		WebAuthnRepo:     s.mockWebAuthnRepo,
+		WebAuthn:         webAuthn,
+	})
+}
+
+func (s *PasskeyTestSuite) assertCode(code codes.Code, message string, err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(code, rpcErr.Code())
+	s.Assert().Equal(message, rpcErr.Message())
+}
+
+// expectSession keeps the session the service creates and hands it back when it is taken
+func (s *PasskeyTestSuite) expectSession(ctx context.Context, userID uuid.UUID, purpose entity.WebAuthnSessionPurpose) {
+	s.mockWebAuthnRepo.EXPECT().CreateSession(ctx, mock.MatchedBy(func(session *entity.WebAuthnSession) bool {
+		s.session = session
+		return session.UserID == userID && session.Purpose == purpose && session.ExpiresAt.After(time.Now())
+	})).Return(nil)
+	s.mockWebAuthnRepo.EXPECT().TakeSession(ctx, purpose, mock.Anything).
+		RunAndReturn(func(_ context.Context, _ entity.WebAuthnSessionPurpose, tokenHash string) (*entity.WebAuthnSession, error) {
+			if s.session == nil || s.session.TokenHash != tokenHash {
+				return nil, gorm.ErrRecordNotFound
+			}
+			return s.session, nil
+		}).Maybe()
+}
+
+func (s *PasskeyTestSuite) beginRegistration() *pb.BeginPasskeyRegistrationResponse {
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+	s.expectSession(s.ctx, s.user.ID, entity.WebAuthnSessionRegistration)
+
+	resp, err := s.userService.BeginPasskeyRegistration(s.ctx, &pb.BeginPasskeyRegistrationRequest{})
+	s.Require().NoError(err)
+
+	return resp
+}
+
+func (s *PasskeyTestSuite) beginLogin(ctx context.Context) *pb.BeginPasskeyLoginResponse {
+	s.expectSession(ctx, uuid.Nil, entity.WebAuthnSessionLogin)
+
+	resp, err := s.userService.BeginPasskeyLogin(ctx, &pb.BeginPasskeyLoginRequest{})
+	s.Require().NoError(err)
+
+	return resp
+}
+
+func (s *PasskeyTestSuite) expectLoginUser(ctx context.Context) {
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(ctx, s.user.ID).Return([]*entity.WebAuthnCredential{s.credential}, nil)
+}
+
+func (s *PasskeyTestSuite) Test_BeginPasskeyRegistration_Success() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{s.credential}, nil)
+	s.expectSession(s.ctx, s.user.ID, entity.WebAuthnSessionRegistration)
+
+	// execute
+	resp, err := s.userService.BeginPasskeyRegistration(s.ctx, &pb.BeginPasskeyRegistrationRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(resp.SessionId)
+	s.Assert().Equal(utils.HashOpaqueToken(resp.SessionId), s.session.TokenHash)
+
+	var options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+			User struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"user"`
+			ExcludeCredentials []struct {
+				ID string `json:"id"`
+			} `json:"excludeCredentials"`
+		} `json:"publicKey"`
+	}
+	s.Require().NoError(json.Unmarshal([]byte(resp.OptionsJson), &options))
+	s.Assert().NotEmpty(options.PublicKey.Challenge)
+	s.Assert().Equal(testRPID, options.PublicKey.RP.ID)
+	s.Assert().Equal(base64.RawURLEncoding.EncodeToString(s.user.ID[:]), options.PublicKey.User.ID)
+	s.Assert().Equal("test@example.com", options.PublicKey.User.Name)
+
+	// the registered passkey is excluded
+	s.Require().Len(options.PublicKey.ExcludeCredentials, 1)
+	s.Assert().Equal(base64.RawURLEncoding.EncodeToString(s.authenticator.credentialID), options.PublicKey.ExcludeCredentials[0].ID)
+}
+
+func (s *PasskeyTestSuite) Test_BeginPasskeyRegistration_NotConfigured() {
+	// input
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		KeyRing:          s.keyRing,
+	})
+
+	// execute
+	resp, err := userService.BeginPasskeyRegistration(s.ctx, &pb.BeginPasskeyRegistrationRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, "passkeys are not configured", err)
+}
+
+func (s *PasskeyTestSuite) Test_BeginPasskeyRegistration_Unauthenticated() {
+	// execute
+	resp, err := s.userService.BeginPasskeyRegistration(context.Background(), &pb.BeginPasskeyRegistrationRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_Success() {
+	// input
+	begin := s.beginRegistration()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+
+	var stored *entity.WebAuthnCredential
+	s.mockWebAuthnRepo.EXPECT().CreateCredential(s.ctx, mock.MatchedBy(func(credential *entity.WebAuthnCredential) bool {
+		stored = credential
+		return credential.UserID == s.user.ID
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.register(begin.OptionsJson),
+		Name:           " MacBook ",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal(stored.ID.String(), resp.Id)
+	s.Assert().Equal("MacBook", resp.Name)
+	s.Assert().Equal([]string{"internal"}, resp.Transports)
+
+	s.Assert().Equal(s.authenticator.credentialID, stored.CredentialID)
+	s.Assert().Equal(s.authenticator.publicKey(), stored.PublicKey)
+	s.Assert().Equal("none", stored.AttestationType)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_DefaultName() {
+	// input
+	begin := s.beginRegistration()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+	s.mockWebAuthnRepo.EXPECT().CreateCredential(s.ctx, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.register(begin.OptionsJson),
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("Passkey", resp.Name)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_AlreadyExists() {
+	// input
+	begin := s.beginRegistration()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+	s.mockWebAuthnRepo.EXPECT().CreateCredential(s.ctx, mock.Anything).Return(repository.ErrCredentialAlreadyExists)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.register(begin.OptionsJson),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.AlreadyExists, "passkey already registered", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_WrongOrigin() {
+	// input
+	begin := s.beginRegistration()
+	s.authenticator.origin = "https://evil.example.com"
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.register(begin.OptionsJson),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid passkey", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_InvalidJSON() {
+	// input
+	begin := s.beginRegistration()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(s.ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: "{}",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid passkey", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_OtherUser() {
+	// input
+	begin := s.beginRegistration()
+	otherCtx := interceptor.ContextWithUserID(context.Background(), uuid.NewString())
+
+	// mock
+	s.mockWebAuthnRepo.EXPECT().TakeSession(otherCtx, entity.WebAuthnSessionRegistration, utils.HashOpaqueToken(begin.SessionId)).Return(s.session, nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(otherCtx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.register(begin.OptionsJson),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid or expired passkey session", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_SessionUsed() {
+	// mock
+	s.mockWebAuthnRepo.EXPECT().TakeSession(s.ctx, entity.WebAuthnSessionRegistration, utils.HashOpaqueToken("session")).Return(nil, repository.ErrWebAuthnSessionUsed)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      "session",
+		CredentialJson: "{}",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid or expired passkey session", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_SessionExpired() {
+	// mock
+	s.mockWebAuthnRepo.EXPECT().TakeSession(s.ctx, entity.WebAuthnSessionRegistration, utils.HashOpaqueToken("session")).Return(&entity.WebAuthnSession{
+		UserID:    s.user.ID,
+		Purpose:   entity.WebAuthnSessionRegistration,
+		ExpiresAt: time.Now().Add(-time.Second),
+	}, nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{
+		SessionId:      "session",
+		CredentialJson: "{}",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid or expired passkey session", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyRegistration_InvalidRequest() {
+	// execute
+	resp, err := s.userService.FinishPasskeyRegistration(s.ctx, &pb.FinishPasskeyRegistrationRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_Success() {
+	// input
+	ctx := context.Background()
+	begin := s.beginLogin(ctx)
+	s.authenticator.counter = 3
+	s.credential.SignCount = 2
+
+	// mock
+	s.expectLoginUser(ctx)
+	s.mockWebAuthnRepo.EXPECT().UpdateCredentialUsage(ctx, s.credential.ID, uint32(3), false, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(ctx, mock.MatchedBy(func(t *entity.RefreshToken) bool {
+		return t.UserID == s.user.ID && t.DeviceID == "device"
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.login(begin.OptionsJson, s.user.ID[:]),
+		DeviceId:       "device",
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), resp.Id)
+	s.Assert().NotEmpty(resp.RefreshToken)
+
+	claims, err := utils.ParseToken(resp.Token, s.keyRing, "todolist-user")
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), claims.Subject)
+	s.Require().NotNil(claims.AuthTime)
+	s.Assert().WithinDuration(time.Now(), claims.AuthTime.Time, time.Minute)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_CounterWentBack() {
+	// input
+	ctx := context.Background()
+	begin := s.beginLogin(ctx)
+	s.authenticator.counter = 5
+	s.credential.SignCount = 10
+
+	// mock
+	s.expectLoginUser(ctx)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.login(begin.OptionsJson, s.user.ID[:]),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid passkey", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_WrongKey() {
+	// input
+	ctx := context.Background()
+	begin := s.beginLogin(ctx)
+	s.credential.PublicKey = newSoftAuthenticator(s.T(), testRPID, testRPOrigin).publicKey()
+
+	// mock
+	s.expectLoginUser(ctx)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.login(begin.OptionsJson, s.user.ID[:]),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid passkey", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_UnknownUser() {
+	// input
+	ctx := context.Background()
+	begin := s.beginLogin(ctx)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.login(begin.OptionsJson, s.user.ID[:]),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid passkey", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_UnknownCredential() {
+	// input
+	ctx := context.Background()
+	begin := s.beginLogin(ctx)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+	s.mockWebAuthnRepo.EXPECT().ListCredentials(ctx, s.user.ID).Return([]*entity.WebAuthnCredential{}, nil)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.login(begin.OptionsJson, s.user.ID[:]),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Unauthenticated, "invalid passkey", err)
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_EmailNotVerified() {
+	// input
+	viper.Set("AUTH_REQUIRE_EMAIL_VERIFIED", true)
+	s.userService = s.newUserService()
+	ctx := context.Background()
+	begin := s.beginLogin(ctx)
+
+	// mock
+	s.expectLoginUser(ctx)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: s.authenticator.login(begin.OptionsJson, s.user.ID[:]),
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func (s *PasskeyTestSuite) Test_FinishPasskeyLogin_RegistrationSession() {
+	// input
+	begin := s.beginRegistration()
+	ctx := context.Background()
+
+	// mock
+	s.mockWebAuthnRepo.EXPECT().TakeSession(ctx, entity.WebAuthnSessionLogin, utils.HashOpaqueToken(begin.SessionId)).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.FinishPasskeyLogin(ctx, &pb.FinishPasskeyLoginRequest{
+		SessionId:      begin.SessionId,
+		CredentialJson: "{}",
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid or expired passkey session", err)
+}
+
+func (s *PasskeyTestSuite) Test_BeginPasskeyLogin_NotConfigured() {
+	// input
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		KeyRing:          s.keyRing,
+	})
+
+	// execute
+	resp, err := userService.BeginPasskeyLogin(context.Background(), &pb.BeginPasskeyLoginRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, "passkeys are not configured", err)
+}
+
+// softAuthenticator is a platform authenticator in software , it makes the json a browser sends after
+// navigator.credentials.create() and navigator.credentials.get()
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	rpID         string
+	origin       string
+	counter      uint32
+}
+
+func newSoftAuthenticator(t *testing.T, rpID string, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err = rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{t: t, key: key, credentialID: credentialID, rpID: rpID, origin: origin}
+}
+
+// publicKey is the COSE encoded ES256 public key
+func (a *softAuthenticator) publicKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: x,
+		YCoord: y,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return publicKey
+}
+
+func (a *softAuthenticator) register(optionsJSON string) string {
+	clientData := a.clientData("webauthn.create", optionsJSON)
+
+	// flags: user present , user verified , attested credential data
+	authData := a.authData(0x45)
+	authData = append(authData, make([]byte, 16)...) // aaguid
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, a.publicKey()...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credentialJSON(map[string]any{
+		"clientDataJSON":    encodeBase64URL(clientData),
+		"attestationObject": encodeBase64URL(attestationObject),
+		"transports":        []string{"internal"},
+	})
+}
+
+func (a *softAuthenticator) login(optionsJSON string, userHandle []byte) string {
+	clientData := a.clientData("webauthn.get", optionsJSON)
+
+	// flags: user present , user verified
+	authData := a.authData(0x05)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.credentialJSON(map[string]any{
+		"clientDataJSON":    encodeBase64URL(clientData),
+		"authenticatorData": encodeBase64URL(authData),
+		"signature":         encodeBase64URL(signature),
+		"userHandle":        encodeBase64URL(userHandle),
+	})
+}
+
+func (a *softAuthenticator) clientData(ceremony string, optionsJSON string) []byte {
+	var options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	if err := json.Unmarshal([]byte(optionsJSON), &options); err != nil {
+		a.t.Fatal(err)
+	}
+
+	clientData, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": options.PublicKey.Challenge,
+		"origin":    a.origin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return clientData
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	authData := append([]byte{}, rpIDHash[:]...)
+	authData = append(authData, flags)
+	return binary.BigEndian.AppendUint32(authData, a.counter)
+}
+
+func (a *softAuthenticator) credentialJSON(response map[string]any) string {
+	credentialJSON, err := json.Marshal(map[string]any{
+		"id":       encodeBase64URL(a.credentialID),
+		"rawId":    encodeBase64URL(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return string(credentialJSON)
+}
+
+func encodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
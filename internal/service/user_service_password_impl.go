@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/validation"
+)
+
+// ChangePassword changes the password of the authenticated user and revokes every token issued before.
+// With keep_session the caller gets a new access and refresh token , so only the other sessions are logged out.
+func (u *userServiceImpl) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (resp *pb.ChangePasswordResponse, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validation.New()
+	v.Required("old_password", req.OldPassword)
+	v.Password("new_password", req.NewPassword, u.passwordPolicy)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkUserPassword(ctx, user, req.OldPassword); err != nil {
+		return nil, err
+	}
+	u.loginSucceeded(ctx, user.EmailCanonical)
+
+	if req.NewPassword == req.OldPassword {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrSamePassword)
+	}
+
+	changed := &entity.User{
+		ID:       user.ID,
+		Password: req.NewPassword,
+	}
+
+	if err = changed.HashPassword(); err != nil {
+		log.Error().Err(err).Msg("hash password error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.userRepo.UpdatePassword(ctx, user.ID, changed.Password); err != nil {
+		log.Error().Err(err).Msg("UpdatePassword error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.revokeAllTokens(ctx, user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("password changed")
+
+	resp = &pb.ChangePasswordResponse{}
+	if !req.KeepSession {
+		return resp, nil
+	}
+
+	// the new tokens are issued after the revocation , so they stay valid , the old password was just entered
+	tokens, refreshToken, err := u.newTokenPair(ctx, user.ID, uuid.New(), req.DeviceId, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Generate token error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
+		log.Error().Err(err).Msg("refresh token , insert db error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	resp.Token = tokens.accessToken
+	resp.ExpiresIn = timestamppb.New(tokens.accessExpiresAt)
+	resp.RefreshToken = tokens.refreshToken
+	resp.RefreshExpiresIn = timestamppb.New(tokens.refreshExpiresAt)
+
+	return resp, nil
+}
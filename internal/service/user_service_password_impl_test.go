@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestChangePasswordTestSuite(t *testing.T) {
+	suite.Run(t, new(ChangePasswordTestSuite))
+}
+
+type ChangePasswordTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	keyRing              *utils.KeyRing
+	user                 *entity.User
+	input                struct {
+		ctx context.Context
+		req *pb.ChangePasswordRequest
+	}
+}
+
+func (s *ChangePasswordTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.keyRing = newTestKeyRing(s.T(), utils.NewHMACKey("", "secret"))
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+	})
+
+	s.user = &entity.User{
+		ID:       uuid.New(),
+		Email:    "test@example.com",
+		Name:     "test",
+		Password: "old_password",
+	}
+	s.Require().NoError(s.user.HashPassword())
+
+	s.input.ctx = interceptor.ContextWithUserID(context.Background(), s.user.ID.String())
+	s.input.req = &pb.ChangePasswordRequest{
+		OldPassword: "old_password",
+		NewPassword: "new_password",
+	}
+}
+
+func (s *ChangePasswordTestSuite) assertCode(code codes.Code, message string, err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(code, rpcErr.Code())
+	s.Assert().Equal(message, rpcErr.Message())
+}
+
+func (s *ChangePasswordTestSuite) expectPasswordUpdated() {
+	s.mockUserRepo.EXPECT().UpdatePassword(s.input.ctx, s.user.ID, mock.MatchedBy(func(hash string) bool {
+		match, needsRehash := (&entity.User{Password: hash}).CheckPassword("new_password")
+		return match && !needsRehash
+	})).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(s.input.ctx, s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(s.input.ctx, s.user.ID, mock.Anything).Return(nil)
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_Success() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+	s.expectPasswordUpdated()
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Empty(resp.Token)
+	s.Assert().Empty(resp.RefreshToken)
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_KeepSession() {
+	// input
+	s.input.req.KeepSession = true
+	s.input.req.DeviceId = "device"
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+	s.expectPasswordUpdated()
+	s.mockRefreshTokenRepo.EXPECT().Create(s.input.ctx, mock.MatchedBy(func(t *entity.RefreshToken) bool {
+		return t.UserID == s.user.ID && t.DeviceID == "device"
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(resp.RefreshToken)
+
+	claims, err := utils.ParseToken(resp.Token, s.keyRing, "")
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), claims.Subject)
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_Unauthenticated() {
+	// execute
+	resp, err := s.userService.ChangePassword(context.Background(), s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_PolicyViolation() {
+	// input
+	s.input.req.NewPassword = "short"
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "invalid argument", err)
+	violations := validation.FieldViolations(err)
+	s.Require().Len(violations, 1)
+	s.Assert().Equal("new_password", violations[0].GetField())
+	s.Assert().Equal("password is too short: must be at least 8 characters", violations[0].GetDescription())
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_IncorrectOldPassword() {
+	// input
+	s.input.req.OldPassword = "wrong_password"
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, "incorrect password", err)
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_Lockout() {
+	// input
+	s.user.EmailCanonical = "test@example.com"
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+		LoginLimiter: limiter.NewLoginLimiter(repository.NewLoginAttemptsMemoryRepository(), limiter.LoginLimitConfig{
+			Window:             15 * time.Minute,
+			Lockout:            15 * time.Minute,
+			MaxAccountFailures: 2,
+		}),
+	})
+	s.input.req.OldPassword = "wrong_password"
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil).Times(3)
+
+	// execute
+	for i := 0; i < 2; i++ {
+		_, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+		s.assertCode(codes.PermissionDenied, "incorrect password", err)
+	}
+
+	// the right password is refused too while locked
+	s.input.req.OldPassword = "old_password"
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.ResourceExhausted, status.Code(err))
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_SamePassword() {
+	// input
+	s.input.req.NewPassword = s.input.req.OldPassword
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, "new password must be different", err)
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_UpdatePassword_DbError() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().UpdatePassword(s.input.ctx, s.user.ID, mock.Anything).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *ChangePasswordTestSuite) Test_ChangePassword_Revoke_DbError() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().UpdatePassword(s.input.ctx, s.user.ID, mock.Anything).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(s.input.ctx, s.user.ID, mock.Anything).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+// a token issued before the change is revoked by the watermark , the token issued with keep_session is not
+func (s *ChangePasswordTestSuite) Test_ChangePassword_KeepSession_TokenNotRevoked() {
+	// input
+	s.input.req.KeepSession = true
+	revokedTokenRepo := repository.NewRevokedTokensMemoryRepository()
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: revokedTokenRepo,
+		KeyRing:          s.keyRing,
+	})
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().UpdatePassword(s.input.ctx, s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(s.input.ctx, s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().Create(s.input.ctx, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.ChangePassword(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+
+	claims, err := utils.ParseToken(resp.Token, s.keyRing, "")
+	s.Require().NoError(err)
+	revoked, err := revokedTokenRepo.IsRevoked(context.Background(), claims.ID, s.user.ID, claims.IssuedAt.Time)
+	s.Require().NoError(err)
+	s.Assert().False(revoked)
+
+	// the watermark is truncated to the second , the new token may be issued a second later
+	oldIssuedAt := claims.IssuedAt.Add(-2 * time.Second)
+	revoked, err = revokedTokenRepo.IsRevoked(context.Background(), uuid.NewString(), s.user.ID, oldIssuedAt)
+	s.Require().NoError(err)
+	s.Assert().True(revoked)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/validation"
+)
+
+// profileFields are the UpdateProfile field mask paths and the columns they update
+var profileFields = map[string]string{
+	"name":       "name",
+	"avatar_url": "avatar_url",
+	"bio":        "bio",
+}
+
+// GetMe returns the profile of the authenticated user
+func (u *userServiceImpl) GetMe(ctx context.Context, req *pb.GetMeRequest) (resp *pb.UserProfile, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserProfile(user), nil
+}
+
+// GetUser returns the profile of a user , only the user self and the users with users:read can read it
+func (u *userServiceImpl) GetUser(ctx context.Context, req *pb.GetUserRequest) (resp *pb.UserProfile, err error) {
+	callerID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidUserID)
+	}
+
+	// checked before the lookup , so a caller can not learn which ids exist
+	if callerID != userID {
+		if err = u.checkPermission(ctx, PermissionUsersRead); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserProfile(user), nil
+}
+
+// UpdateProfile updates the profile fields in the update mask , a field in the mask with an empty value is cleared.
+// Without update mask only the fields with a value are updated.
+func (u *userServiceImpl) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (resp *pb.UserProfile, err error) {
+	userID, err := authenticatedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = populatedProfileFields(req)
+	}
+
+	v := validation.New()
+	columns := make([]string, 0, len(paths))
+	seen := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		column, ok := profileFields[path]
+		if !ok {
+			v.AddViolation("update_mask", fmt.Sprintf("unknown field %q", path))
+			continue
+		}
+		if _, ok := seen[column]; ok {
+			continue
+		}
+		seen[column] = struct{}{}
+		columns = append(columns, column)
+
+		switch column {
+		case "name":
+			v.Length("name", strings.TrimSpace(req.Name), 1, entity.UserNameMaxLength)
+		case "avatar_url":
+			v.Check(isValidAvatarURL(strings.TrimSpace(req.AvatarUrl)), "avatar_url", "must be an absolute http or https url")
+		case "bio":
+			v.Length("bio", strings.TrimSpace(req.Bio), 0, entity.UserBioMaxLength)
+		}
+	}
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	user, err := u.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// nothing to update
+	if len(columns) == 0 {
+		return toUserProfile(user), nil
+	}
+
+	for _, column := range columns {
+		switch column {
+		case "name":
+			user.Name = strings.TrimSpace(req.Name)
+		case "avatar_url":
+			user.AvatarURL = strings.TrimSpace(req.AvatarUrl)
+		case "bio":
+			user.Bio = strings.TrimSpace(req.Bio)
+		}
+	}
+
+	err = u.userRepo.Update(ctx, user, columns...)
+	if err != nil {
+		log.Error().Err(err).Msg("Update user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return toUserProfile(user), nil
+}
+
+// authenticatedUserID returns the user id put in ctx by the auth interceptor
+func authenticatedUserID(ctx context.Context) (uuid.UUID, error) {
+	subject, ok := interceptor.UserIDFromContext(ctx)
+	if !ok {
+		return uuid.Nil, status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidToken)
+	}
+
+	return userID, nil
+}
+
+func (u *userServiceImpl) getUser(ctx context.Context, userID uuid.UUID) (*entity.User, error) {
+	user, err := u.userRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, mErr.ErrUserNotFound)
+		}
+		log.Error().Err(err).Msg("Get user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return user, nil
+}
+
+func populatedProfileFields(req *pb.UpdateProfileRequest) (paths []string) {
+	if req.Name != "" {
+		paths = append(paths, "name")
+	}
+	if req.AvatarUrl != "" {
+		paths = append(paths, "avatar_url")
+	}
+	if req.Bio != "" {
+		paths = append(paths, "bio")
+	}
+	return
+}
+
+// isValidAvatarURL accepts an empty url , which clears the avatar , or an absolute http(s) url
+func isValidAvatarURL(avatarURL string) bool {
+	if avatarURL == "" {
+		return true
+	}
+	if len(avatarURL) > entity.UserAvatarURLMaxLength {
+		return false
+	}
+
+	parsed, err := url.Parse(avatarURL)
+	if err != nil {
+		return false
+	}
+
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func toUserProfile(user *entity.User) *pb.UserProfile {
+	return &pb.UserProfile{
+		Id:        user.ID.String(),
+		Email:     user.Email,
+		Name:      user.Name,
+		AvatarUrl: user.AvatarURL,
+		Bio:       user.Bio,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestProfileTestSuite(t *testing.T) {
+	suite.Run(t, new(ProfileTestSuite))
+}
+
+type ProfileTestSuite struct {
+	suite.Suite
+	userService  pb.UserServiceServer
+	mockUserRepo *repository.MockUsersRepository
+	adminID      string
+	user         *entity.User
+	ctx          context.Context
+}
+
+func (s *ProfileTestSuite) SetupTest() {
+	s.adminID = uuid.NewString()
+	viper.Set("AUTH_ADMIN_USER_IDS", []string{s.adminID})
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: repository.NewMockRefreshTokensRepository(s.T()),
+		RevokedTokenRepo: repository.NewMockRevokedTokensRepository(s.T()),
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	s.user = &entity.User{
+		ID:        uuid.New(),
+		Name:      "test",
+		Email:     "test@example.com",
+		AvatarURL: "https://example.com/avatar.png",
+		Bio:       "hello",
+	}
+	s.user.CreatedAt = time.Now().Add(-time.Hour)
+	s.user.UpdatedAt = time.Now().Add(-time.Hour)
+
+	s.ctx = interceptor.ContextWithUserID(context.Background(), s.user.ID.String())
+}
+
+func (s *ProfileTestSuite) Test_GetMe() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.GetMe(s.ctx, &pb.GetMeRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal(s.user.ID.String(), resp.Id)
+	s.Assert().Equal("test", resp.Name)
+	s.Assert().Equal("test@example.com", resp.Email)
+	s.Assert().Equal("https://example.com/avatar.png", resp.AvatarUrl)
+	s.Assert().Equal("hello", resp.Bio)
+}
+
+func (s *ProfileTestSuite) Test_GetMe_Unauthenticated() {
+	// execute
+	resp, err := s.userService.GetMe(context.Background(), &pb.GetMeRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *ProfileTestSuite) Test_GetMe_UserNotFound() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.GetMe(s.ctx, &pb.GetMeRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *ProfileTestSuite) Test_GetMe_DbError() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(nil, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.GetMe(s.ctx, &pb.GetMeRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *ProfileTestSuite) Test_GetUser_OtherUser_PermissionDenied() {
+	// input
+	ctx := interceptor.ContextWithUserID(context.Background(), uuid.NewString())
+
+	// execute
+	resp, err := s.userService.GetUser(ctx, &pb.GetUserRequest{Id: s.user.ID.String()})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *ProfileTestSuite) Test_GetUser_Self() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.GetUser(s.ctx, &pb.GetUserRequest{Id: s.user.ID.String()})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("test@example.com", resp.Email)
+}
+
+func (s *ProfileTestSuite) Test_GetUser_Admin() {
+	// input
+	ctx := interceptor.ContextWithUserID(context.Background(), s.adminID)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.GetUser(ctx, &pb.GetUserRequest{Id: s.user.ID.String()})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("test@example.com", resp.Email)
+}
+
+func (s *ProfileTestSuite) Test_GetUser_InvalidID() {
+	// execute
+	resp, err := s.userService.GetUser(s.ctx, &pb.GetUserRequest{Id: "not_uuid"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *ProfileTestSuite) Test_GetUser_NotFound() {
+	// input
+	ctx := interceptor.ContextWithUserID(context.Background(), s.adminID)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.GetUser(ctx, &pb.GetUserRequest{Id: userID.String()})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *ProfileTestSuite) Test_UpdateProfile_FieldMask_ClearsField() {
+	// input
+	req := &pb.UpdateProfileRequest{
+		Name:       "new name",
+		Bio:        "",
+		AvatarUrl:  "https://example.com/ignored.png",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "bio"}},
+	}
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(s.ctx, mock.MatchedBy(func(u *entity.User) bool {
+		return u.ID == s.user.ID && u.Name == "new name" && u.Bio == ""
+	}), "name", "bio").Return(nil)
+
+	// execute
+	resp, err := s.userService.UpdateProfile(s.ctx, req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("new name", resp.Name)
+	s.Assert().Empty(resp.Bio)
+	s.Assert().Equal("https://example.com/avatar.png", resp.AvatarUrl)
+}
+
+func (s *ProfileTestSuite) Test_UpdateProfile_NoMask_PopulatedFieldsOnly() {
+	// input
+	req := &pb.UpdateProfileRequest{
+		AvatarUrl: "https://example.com/new.png",
+	}
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(s.ctx, mock.Anything, "avatar_url").Return(nil)
+
+	// execute
+	resp, err := s.userService.UpdateProfile(s.ctx, req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("test", resp.Name)
+	s.Assert().Equal("hello", resp.Bio)
+	s.Assert().Equal("https://example.com/new.png", resp.AvatarUrl)
+}
+
+func (s *ProfileTestSuite) Test_UpdateProfile_Nothing() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.UpdateProfile(s.ctx, &pb.UpdateProfileRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("test", resp.Name)
+}
+
+func (s *ProfileTestSuite) Test_UpdateProfile_InvalidMask() {
+	// input
+	req := &pb.UpdateProfileRequest{
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}},
+	}
+
+	// execute
+	resp, err := s.userService.UpdateProfile(s.ctx, req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+	violations := validation.FieldViolations(err)
+	s.Require().Len(violations, 1)
+	s.Assert().Equal("update_mask", violations[0].GetField())
+	s.Assert().Equal(`unknown field "email"`, violations[0].GetDescription())
+}
+
+func (s *ProfileTestSuite) Test_UpdateProfile_InvalidValues() {
+	tests := []struct {
+		name  string
+		req   *pb.UpdateProfileRequest
+		field string
+	}{
+		{
+			name:  "empty name",
+			req:   &pb.UpdateProfileRequest{Name: "  ", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}},
+			field: "name",
+		},
+		{
+			name:  "long name",
+			req:   &pb.UpdateProfileRequest{Name: strings.Repeat("a", 21)},
+			field: "name",
+		},
+		{
+			name:  "relative avatar url",
+			req:   &pb.UpdateProfileRequest{AvatarUrl: "/avatar.png"},
+			field: "avatar_url",
+		},
+		{
+			name:  "avatar url scheme",
+			req:   &pb.UpdateProfileRequest{AvatarUrl: "javascript:alert(1)"},
+			field: "avatar_url",
+		},
+		{
+			name:  "long bio",
+			req:   &pb.UpdateProfileRequest{Bio: strings.Repeat("a", 256)},
+			field: "bio",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			// execute
+			resp, err := s.userService.UpdateProfile(s.ctx, tt.req)
+
+			// assert
+			s.Assert().Nil(resp)
+			s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+			violations := validation.FieldViolations(err)
+			s.Require().Len(violations, 1)
+			s.Assert().Equal(tt.field, violations[0].GetField())
+		})
+	}
+}
+
+func (s *ProfileTestSuite) Test_UpdateProfile_DbError() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(s.ctx, mock.Anything, "name").Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.UpdateProfile(s.ctx, &pb.UpdateProfileRequest{Name: "new name"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
@@ -0,0 +1,162 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/mailer"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/repository/migrate"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// newIntegrationDB connects to the database of TEST_MYSQL_DSN , e.g.
+// root:password@tcp(localhost:3306)/user_test?charset=utf8mb4&parseTime=True&loc=Local
+func newIntegrationDB(t *testing.T, translateError bool) *gorm.DB {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: translateError})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&entity.User{}))
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+func TestRegister_Concurrent(t *testing.T) {
+	// the repository must map the duplicate key error with and without gorm's translation
+	for _, translateError := range []bool{true, false} {
+		t.Run(map[bool]string{true: "translated", false: "driver error"}[translateError], func(t *testing.T) {
+			db := newIntegrationDB(t, translateError)
+
+			const email = "concurrent@example.com"
+			deleteUsers := func() {
+				require.NoError(t, db.Unscoped().Where("email_canonical = ?", email).Delete(&entity.User{}).Error)
+			}
+			deleteUsers()
+			t.Cleanup(deleteUsers)
+
+			userService := newTestUserService(t, UserServiceDeps{
+				UserRepo: repository.NewUsersRepository(db),
+				Mailer:   mailer.NewLogMailer(io.Discard, "noreply@todolist.local"),
+				KeyRing:  newTestKeyRing(t, utils.NewHMACKey("", "secret")),
+			})
+
+			const registrations = 10
+			codesCh := make(chan codes.Code, registrations)
+
+			var (
+				start sync.WaitGroup
+				done  sync.WaitGroup
+			)
+			start.Add(1)
+			for i := 0; i < registrations; i++ {
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					start.Wait()
+
+					_, err := userService.Register(context.Background(), &pb.RegisterRequest{
+						Email:    email,
+						Password: "correct horse battery",
+						Name:     "concurrent",
+					})
+					codesCh <- status.Code(err)
+				}()
+			}
+			start.Done()
+			done.Wait()
+			close(codesCh)
+
+			count := map[codes.Code]int{}
+			for code := range codesCh {
+				count[code]++
+			}
+
+			assert.Equal(t, map[codes.Code]int{
+				codes.OK:            1,
+				codes.AlreadyExists: registrations - 1,
+			}, count)
+
+			var users int64
+			require.NoError(t, db.Model(&entity.User{}).Where("email_canonical = ?", email).Count(&users).Error)
+			assert.Equal(t, int64(1), users)
+		})
+	}
+}
+
+func TestEmailCanonical_RuleChange(t *testing.T) {
+	db := newIntegrationDB(t, true)
+	require.NoError(t, db.AutoMigrate(&entity.UserStatusChange{}))
+
+	ctx := context.Background()
+	userRepo := repository.NewUsersRepository(db)
+
+	const prefix = "rule-change-"
+	deleteUsers := func() {
+		users := db.Unscoped().Model(&entity.User{}).Select("id").Where("email LIKE ?", prefix+"%")
+		require.NoError(t, db.Where("user_id IN (?)", users).Delete(&entity.UserStatusChange{}).Error)
+		require.NoError(t, db.Unscoped().Where("email LIKE ?", prefix+"%").Delete(&entity.User{}).Error)
+	}
+	deleteUsers()
+	t.Cleanup(func() {
+		deleteUsers()
+		require.NoError(t, migrate.EmailCanonical(db, true))
+	})
+
+	canonicals := func(id uuid.UUID) (string, *string) {
+		var user entity.User
+		require.NoError(t, db.Unscoped().Where("id = ?", id).First(&user).Error)
+		return user.EmailCanonical, user.DeletedEmailCanonical
+	}
+
+	require.NoError(t, migrate.EmailCanonical(db, false))
+
+	newUser := func(email string) *entity.User {
+		user := &entity.User{ID: uuid.New(), Name: "test", Email: email, EmailCanonical: email, Password: "x", Status: entity.UserStatusActive}
+		require.NoError(t, userRepo.Create(ctx, user))
+		return user
+	}
+	active := newUser(prefix + "Active@example.com")
+	deleted := newUser(prefix + "Deleted@example.com")
+	require.NoError(t, userRepo.Delete(ctx, entity.NewUserStatusChange(deleted, entity.UserStatusDeleted, statusReasonAccountDeleted, nil)))
+
+	// the rule changes , the stored values are made again , the deleted user keeps its placeholder
+	require.NoError(t, migrate.EmailCanonical(db, true))
+
+	emailCanonical, _ := canonicals(active.ID)
+	assert.Equal(t, prefix+"active@example.com", emailCanonical)
+
+	emailCanonical, deletedEmailCanonical := canonicals(deleted.ID)
+	assert.Equal(t, "deleted:"+deleted.ID.String(), emailCanonical)
+	require.NotNil(t, deletedEmailCanonical)
+	assert.Equal(t, prefix+"deleted@example.com", *deletedEmailCanonical)
+
+	var setting entity.MigrationSetting
+	require.NoError(t, db.Where("name = ?", "email_canonical_rule").First(&setting).Error)
+	assert.Equal(t, "v1 lowercase_local_part=true", setting.Value)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/mailer"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// RequestPasswordReset emails a reset link to the user.
+// The response is the same whether the email is registered or not , the token is created and sent in the background
+// so the response time doesn't tell either.
+func (u *userServiceImpl) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (resp *protobuf.EmptyResponse, err error) {
+	v := validation.New()
+	email := v.CanonicalEmail("email", req.Email, entity.UserEmailMaxLength, u.lowercaseEmailLocalPart)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	resp = &protobuf.EmptyResponse{}
+
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Error().Err(err).Msg("GetByEmail error")
+		}
+		return resp, nil
+	}
+
+	u.sendInBackground("password reset", func(ctx context.Context) {
+		u.sendPasswordReset(ctx, user)
+	})
+
+	return resp, nil
+}
+
+// ConfirmPasswordReset sets a new password with a reset token , the token can be used once.
+// All tokens of the user are revoked , as the old password may be known to someone else , and a locked account is unlocked.
+func (u *userServiceImpl) ConfirmPasswordReset(ctx context.Context, req *pb.ConfirmPasswordResetRequest) (resp *protobuf.EmptyResponse, err error) {
+	now := time.Now()
+
+	v := validation.New()
+	v.Required("token", req.Token)
+	v.Password("new_password", req.NewPassword, u.passwordPolicy)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	token, err := u.oneTimeTokenRepo.GetByHash(ctx, entity.OneTimeTokenPasswordReset, utils.HashOpaqueToken(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidResetToken)
+		}
+		log.Error().Err(err).Msg("GetByHash error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if token.UsedAt != nil || token.IsExpired(now) {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidResetToken)
+	}
+
+	user := &entity.User{
+		ID:       token.UserID,
+		Password: req.NewPassword,
+	}
+
+	if err = user.HashPassword(); err != nil {
+		log.Error().Err(err).Msg("hash password error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	err = u.oneTimeTokenRepo.ResetPassword(ctx, token, user.Password, now)
+	if err != nil {
+		// used by a concurrent request
+		if errors.Is(err, repository.ErrOneTimeTokenUsed) {
+			return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidResetToken)
+		}
+		log.Error().Err(err).Msg("ResetPassword error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.revokeAllTokens(ctx, user.ID, now); err != nil {
+		return nil, err
+	}
+
+	if err = u.unlockAfterPasswordReset(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("password reset")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// sendPasswordReset creates a reset token and emails it , failures are only logged
+func (u *userServiceImpl) sendPasswordReset(ctx context.Context, user *entity.User) {
+	now := time.Now()
+
+	token, tokenHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		log.Error().Err(err).Msg("generate reset token error")
+		return
+	}
+
+	resetToken := &entity.OneTimeToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   entity.OneTimeTokenPasswordReset,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(u.resetConfig.ExpireAt),
+		CreatedAt: now,
+	}
+
+	if err = u.oneTimeTokenRepo.Create(ctx, resetToken); err != nil {
+		log.Error().Err(err).Msg("reset token , insert db error")
+		return
+	}
+
+	msg := &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Use the link below to reset your password , it expires in %s and can be used once.\n\n"+
+			"%s\n\n"+
+			"If you didn't ask to reset your password , you can ignore this email.\n",
+			user.Name, u.resetConfig.ExpireAt, tokenLink(u.resetConfig.URL, token)),
+	}
+
+	if err = u.mailer.Send(ctx, msg); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("send reset email error")
+	}
+}
+
+// tokenLink adds the token to the query of the page url , the token alone when no url is set
+func tokenLink(pageURL string, token string) string {
+	if pageURL == "" {
+		return token
+	}
+
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		log.Error().Err(err).Str("url", pageURL).Msg("invalid token page url")
+		return token
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String()
+}
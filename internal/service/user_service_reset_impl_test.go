@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/mailer"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestPasswordResetTestSuite(t *testing.T) {
+	suite.Run(t, new(PasswordResetTestSuite))
+}
+
+type PasswordResetTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	mockOneTimeTokenRepo *repository.MockOneTimeTokensRepository
+	mockMailer           *mailer.MockMailer
+	user                 *entity.User
+	token                string
+	resetToken           *entity.OneTimeToken
+}
+
+func (s *PasswordResetTestSuite) SetupTest() {
+	viper.Set("PASSWORD_RESET_EXPIRE_AT", "30m")
+	viper.Set("PASSWORD_RESET_URL", "https://todolist.example.com/reset-password?lang=zh")
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.mockOneTimeTokenRepo = repository.NewMockOneTimeTokensRepository(s.T())
+	s.mockMailer = mailer.NewMockMailer(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		OneTimeTokenRepo: s.mockOneTimeTokenRepo,
+		Mailer:           s.mockMailer,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	s.user = &entity.User{
+		ID:    uuid.New(),
+		Name:  "test",
+		Email: "test@example.com",
+	}
+
+	token, tokenHash, err := utils.NewOpaqueToken()
+	s.Require().NoError(err)
+
+	s.token = token
+	s.resetToken = &entity.OneTimeToken{
+		ID:        uuid.New(),
+		UserID:    s.user.ID,
+		Purpose:   entity.OneTimeTokenPasswordReset,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(time.Minute),
+		CreatedAt: time.Now(),
+	}
+}
+
+func (s *PasswordResetTestSuite) assertInvalidToken(err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.InvalidArgument, rpcErr.Code())
+	s.Assert().Equal("invalid or expired reset token", rpcErr.Message())
+}
+
+func (s *PasswordResetTestSuite) Test_RequestPasswordReset_SendsMail() {
+	sent := make(chan *mailer.Message, 1)
+	var created *entity.OneTimeToken
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.user.Email).Return(s.user, nil)
+	s.mockOneTimeTokenRepo.EXPECT().Create(mock.Anything, mock.MatchedBy(func(t *entity.OneTimeToken) bool {
+		created = t
+		return t.UserID == s.user.ID && t.Purpose == entity.OneTimeTokenPasswordReset
+	})).Return(nil)
+	s.mockMailer.EXPECT().Send(mock.Anything, mock.Anything).Run(func(ctx context.Context, msg *mailer.Message) {
+		sent <- msg
+	}).Return(nil)
+
+	// execute
+	resp, err := s.userService.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: s.user.Email})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+
+	var msg *mailer.Message
+	select {
+	case msg = <-sent:
+	case <-time.After(5 * time.Second):
+		s.FailNow("reset email not sent")
+	}
+
+	s.Assert().Equal(s.user.Email, msg.To)
+	s.Assert().WithinDuration(time.Now().Add(30*time.Minute), created.ExpiresAt, 5*time.Second)
+
+	// the link carries the token , only the hash is stored
+	var link *url.URL
+	for _, field := range strings.Fields(msg.Body) {
+		if strings.HasPrefix(field, "https://") {
+			link, err = url.Parse(field)
+			s.Require().NoError(err)
+		}
+	}
+	s.Require().NotNil(link)
+	s.Assert().Equal("zh", link.Query().Get("lang"))
+	s.Assert().Equal(created.TokenHash, utils.HashOpaqueToken(link.Query().Get("token")))
+	s.Assert().NotContains(msg.Body, created.TokenHash)
+}
+
+func (s *PasswordResetTestSuite) Test_RequestPasswordReset_UnknownEmail() {
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), "unknown@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: "unknown@example.com"})
+
+	// assert, same response as a registered email
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *PasswordResetTestSuite) Test_RequestPasswordReset_DbError() {
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.user.Email).Return(nil, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.RequestPasswordReset(context.Background(), &pb.RequestPasswordResetRequest{Email: s.user.Email})
+
+	// assert, same response as a registered email
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_Success() {
+	// input
+	req := &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "new_password"}
+
+	// mock
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, s.resetToken.TokenHash).Return(s.resetToken, nil)
+	s.mockOneTimeTokenRepo.EXPECT().ResetPassword(context.Background(), s.resetToken, mock.MatchedBy(func(hash string) bool {
+		match, _ := (&entity.User{Password: hash}).CheckPassword("new_password")
+		return match
+	}), mock.Anything).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(context.Background(), s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(context.Background(), s.user.ID, mock.Anything).Return(nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_UnlocksAccount() {
+	// input
+	req := &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "new_password"}
+	s.user.Status = entity.UserStatusLocked
+
+	// mock
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, s.resetToken.TokenHash).Return(s.resetToken, nil)
+	s.mockOneTimeTokenRepo.EXPECT().ResetPassword(context.Background(), s.resetToken, mock.Anything, mock.Anything).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(context.Background(), s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(context.Background(), s.user.ID, mock.Anything).Return(nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().ChangeStatus(context.Background(), mock.MatchedBy(func(change *entity.UserStatusChange) bool {
+		// the email is not verified
+		return change.FromStatus == entity.UserStatusLocked && change.ToStatus == entity.UserStatusPendingVerification &&
+			change.Reason == "password reset" && *change.ActorID == s.user.ID
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_PolicyViolation() {
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "short"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_NotFound() {
+	// mock
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), &pb.ConfirmPasswordResetRequest{Token: "unknown", NewPassword: "new_password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertInvalidToken(err)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_Expired() {
+	// input
+	s.resetToken.ExpiresAt = time.Now().Add(-time.Second)
+
+	// mock
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, s.resetToken.TokenHash).Return(s.resetToken, nil)
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "new_password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertInvalidToken(err)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_AlreadyUsed() {
+	// input
+	usedAt := time.Now().Add(-time.Second)
+	s.resetToken.UsedAt = &usedAt
+
+	// mock
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, s.resetToken.TokenHash).Return(s.resetToken, nil)
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "new_password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertInvalidToken(err)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_UsedConcurrently() {
+	// mock
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, s.resetToken.TokenHash).Return(s.resetToken, nil)
+	s.mockOneTimeTokenRepo.EXPECT().ResetPassword(context.Background(), s.resetToken, mock.Anything, mock.Anything).Return(repository.ErrOneTimeTokenUsed)
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "new_password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertInvalidToken(err)
+}
+
+func (s *PasswordResetTestSuite) Test_ConfirmPasswordReset_DbError() {
+	// mock , the transaction is rolled back and the token can be used again
+	s.mockOneTimeTokenRepo.EXPECT().GetByHash(context.Background(), entity.OneTimeTokenPasswordReset, s.resetToken.TokenHash).Return(s.resetToken, nil)
+	s.mockOneTimeTokenRepo.EXPECT().ResetPassword(context.Background(), s.resetToken, mock.Anything, mock.Anything).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.ConfirmPasswordReset(context.Background(), &pb.ConfirmPasswordResetRequest{Token: s.token, NewPassword: "new_password"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
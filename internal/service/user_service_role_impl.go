@@ -0,0 +1,432 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+)
+
+// roleNamePattern keeps the role names usable in the token claims and in the configs of other services
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// ListPermissions returns the permissions roles can be made of
+func (u *userServiceImpl) ListPermissions(ctx context.Context, req *pb.ListPermissionsRequest) (resp *pb.ListPermissionsResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesRead); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	permissions, err := u.roleRepo.ListPermissions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("ListPermissions error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	resp = &pb.ListPermissionsResponse{Permissions: make([]*pb.Permission, 0, len(permissions))}
+	for _, permission := range permissions {
+		resp.Permissions = append(resp.Permissions, &pb.Permission{
+			Name:        permission.Name,
+			Description: permission.Description,
+		})
+	}
+
+	return resp, nil
+}
+
+// ListRoles returns all the roles with their permissions
+func (u *userServiceImpl) ListRoles(ctx context.Context, req *pb.ListRolesRequest) (resp *pb.ListRolesResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesRead); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	roles, err := u.roleRepo.ListRoles(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("ListRoles error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return &pb.ListRolesResponse{Roles: toRoles(roles)}, nil
+}
+
+// CreateRole creates a role , the caller can only grant the permissions it has
+func (u *userServiceImpl) CreateRole(ctx context.Context, req *pb.CreateRoleRequest) (resp *pb.Role, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesWrite); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	permissions, err := validateRole(name, req.Description, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkGrantable(ctx, permissions); err != nil {
+		return nil, err
+	}
+
+	role := &entity.Role{
+		ID:          uuid.New(),
+		Name:        name,
+		Description: req.Description,
+	}
+	role.Permissions = rolePermissions(role.ID, permissions)
+
+	err = u.roleRepo.CreateRole(ctx, role)
+	if errors.Is(err, repository.ErrRoleAlreadyExists) {
+		return nil, status.Error(codes.AlreadyExists, mErr.ErrRoleAlreadyExists)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("CreateRole error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	log.Info().Str("role", role.Name).Strs("permissions", permissions).Msg("role created")
+
+	return toRole(role), nil
+}
+
+// UpdateRole replaces the description and the permissions of a role.
+// The caller must have the permissions of the role before and after the update ,
+// the users of the role get the new permissions when they refresh their tokens.
+func (u *userServiceImpl) UpdateRole(ctx context.Context, req *pb.UpdateRoleRequest) (resp *pb.Role, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesWrite); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	permissions, err := validateRole(name, req.Description, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := u.getRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkGrantable(ctx, append(role.PermissionNames(), permissions...)); err != nil {
+		return nil, err
+	}
+
+	role.Description = req.Description
+	role.Permissions = rolePermissions(role.ID, permissions)
+	role.UpdatedAt = time.Now()
+
+	if err = u.roleRepo.UpdateRole(ctx, role); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, mErr.ErrRoleNotFound)
+		}
+		log.Error().Err(err).Msg("UpdateRole error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.reissueRoleTokens(ctx, role.ID); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("role", role.Name).Strs("permissions", permissions).Msg("role updated")
+
+	return toRole(role), nil
+}
+
+// DeleteRole deletes a role and unassigns it from its users
+func (u *userServiceImpl) DeleteRole(ctx context.Context, req *pb.DeleteRoleRequest) (resp *protobuf.EmptyResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesWrite); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	role, err := u.getRole(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkGrantable(ctx, role.PermissionNames()); err != nil {
+		return nil, err
+	}
+
+	// the users are listed before the assignments are gone
+	userIDs, err := u.roleRepo.ListRoleUsers(ctx, role.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListRoleUsers error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.roleRepo.DeleteRole(ctx, role.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, mErr.ErrRoleNotFound)
+		}
+		log.Error().Err(err).Msg("DeleteRole error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.reissueTokens(ctx, userIDs...); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("role", role.Name).Msg("role deleted")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// AssignRole gives a role to a user , the caller must have the permissions of the role
+func (u *userServiceImpl) AssignRole(ctx context.Context, req *pb.AssignRoleRequest) (resp *protobuf.EmptyResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesAssign); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	userID, role, err := u.getUserRole(ctx, req.UserId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.roleRepo.AssignRole(ctx, userID, role.ID); err != nil {
+		log.Error().Err(err).Msg("AssignRole error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.reissueTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", userID.String()).Str("role", role.Name).Msg("role assigned")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// UnassignRole takes a role from a user , the caller must have the permissions of the role
+func (u *userServiceImpl) UnassignRole(ctx context.Context, req *pb.UnassignRoleRequest) (resp *protobuf.EmptyResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionRolesAssign); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	userID, role, err := u.getUserRole(ctx, req.UserId, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.roleRepo.UnassignRole(ctx, userID, role.ID); err != nil {
+		log.Error().Err(err).Msg("UnassignRole error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = u.reissueTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", userID.String()).Str("role", role.Name).Msg("role unassigned")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// ListUserRoles returns the roles of a user
+func (u *userServiceImpl) ListUserRoles(ctx context.Context, req *pb.ListUserRolesRequest) (resp *pb.ListUserRolesResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionUsersRead); err != nil {
+		return nil, err
+	}
+
+	if err = u.checkRolesConfigured(); err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidUserID)
+	}
+
+	if _, err = u.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	roles, err := u.roleRepo.ListUserRoles(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListUserRoles error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return &pb.ListUserRolesResponse{Roles: toRoles(roles)}, nil
+}
+
+// validateRole validates the fields of a role , it returns the permissions sorted without duplicates
+func validateRole(name string, description string, permissions []string) ([]string, error) {
+	v := validation.New()
+	v.Length("name", name, 1, entity.RoleNameMaxLength)
+	v.Check(name == "" || roleNamePattern.MatchString(name), "name",
+		"must start with a lowercase letter and contain only lowercase letters , digits , - and _")
+	v.Length("description", description, 0, entity.RoleDescriptionMaxLength)
+
+	known := permissionNames()
+	for _, permission := range permissions {
+		v.Check(slices.Contains(known, permission), "permissions", "unknown permission "+permission)
+	}
+
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	permissions = slices.Clone(permissions)
+	slices.Sort(permissions)
+	return slices.Compact(permissions), nil
+}
+
+// checkGrantable rejects the change of a role with the permissions unless the caller has them all ,
+// so a user can't give more than it has , also not to itself
+func (u *userServiceImpl) checkGrantable(ctx context.Context, permissions []string) error {
+	claims, ok := interceptor.ClaimsFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	if _, ok := u.adminUserIDs[claims.Subject]; ok {
+		return nil
+	}
+
+	for _, permission := range permissions {
+		if !claims.HasPermission(permission) {
+			return status.Error(codes.PermissionDenied, mErr.ErrPermissionNotGrantable)
+		}
+	}
+
+	return nil
+}
+
+func (u *userServiceImpl) getRole(ctx context.Context, name string) (*entity.Role, error) {
+	role, err := u.roleRepo.GetRole(ctx, strings.TrimSpace(name))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, mErr.ErrRoleNotFound)
+		}
+		log.Error().Err(err).Msg("GetRole error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return role, nil
+}
+
+// getUserRole looks up the user and the role of an assignment , the caller must be able to grant the role
+func (u *userServiceImpl) getUserRole(ctx context.Context, id string, roleName string) (uuid.UUID, *entity.Role, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidUserID)
+	}
+
+	if _, err = u.getUser(ctx, userID); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	role, err := u.getRole(ctx, roleName)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	if err = u.checkGrantable(ctx, role.PermissionNames()); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return userID, role, nil
+}
+
+// reissueRoleTokens makes the users of the role refresh their tokens
+func (u *userServiceImpl) reissueRoleTokens(ctx context.Context, roleID uuid.UUID) error {
+	userIDs, err := u.roleRepo.ListRoleUsers(ctx, roleID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListRoleUsers error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return u.reissueTokens(ctx, userIDs...)
+}
+
+// reissueTokens revokes the access tokens of the users , their roles in the tokens are outdated.
+// The refresh tokens stay valid , the next RefreshToken issues an access token with the current roles.
+func (u *userServiceImpl) reissueTokens(ctx context.Context, userIDs ...uuid.UUID) error {
+	now := time.Now()
+
+	for _, userID := range userIDs {
+		if err := u.revokedTokenRepo.RevokeUserTokens(ctx, userID, now); err != nil {
+			log.Error().Err(err).Msg("RevokeUserTokens error")
+			return status.Error(codes.Internal, mErr.ErrInternalServerError)
+		}
+	}
+
+	return nil
+}
+
+func rolePermissions(roleID uuid.UUID, permissions []string) []entity.RolePermission {
+	rolePermissions := make([]entity.RolePermission, 0, len(permissions))
+	for _, permission := range permissions {
+		rolePermissions = append(rolePermissions, entity.RolePermission{RoleID: roleID, Permission: permission})
+	}
+	return rolePermissions
+}
+
+func toRoles(roles []*entity.Role) []*pb.Role {
+	result := make([]*pb.Role, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, toRole(role))
+	}
+	return result
+}
+
+func toRole(role *entity.Role) *pb.Role {
+	return &pb.Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.PermissionNames(),
+		CreatedAt:   timestamppb.New(role.CreatedAt),
+		UpdatedAt:   timestamppb.New(role.UpdatedAt),
+	}
+}
+
+// checkRolesConfigured fails when the service runs without the roles repository
+func (u *userServiceImpl) checkRolesConfigured() error {
+	if u.roleRepo == nil {
+		return status.Error(codes.FailedPrecondition, mErr.ErrRolesNotConfigured)
+	}
+
+	return nil
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestRoleTestSuite(t *testing.T) {
+	suite.Run(t, new(RoleTestSuite))
+}
+
+type RoleTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	mockRoleRepo         *repository.MockRolesRepository
+	adminID              string
+	role                 *entity.Role
+}
+
+func (s *RoleTestSuite) SetupTest() {
+	s.adminID = uuid.NewString()
+	viper.Set("AUTH_ADMIN_USER_IDS", []string{s.adminID})
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.mockRoleRepo = repository.NewMockRolesRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+		RoleRepo:         s.mockRoleRepo,
+	})
+
+	roleID := uuid.New()
+	s.role = &entity.Role{
+		ID:          roleID,
+		Name:        "support",
+		Description: "support team",
+		Permissions: rolePermissions(roleID, []string{PermissionUsersRead}),
+	}
+}
+
+// permissionContext is the context of a request with a token granting the permissions
+func (s *RoleTestSuite) permissionContext(permissions ...string) context.Context {
+	return interceptor.ContextWithClaims(context.Background(), &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()},
+		Permissions:      permissions,
+	})
+}
+
+func (s *RoleTestSuite) assertCode(code codes.Code, message string, err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(code, rpcErr.Code())
+	s.Assert().Equal(message, rpcErr.Message())
+}
+
+func (s *RoleTestSuite) Test_ListRoles_PermissionDenied() {
+	// execute
+	resp, err := s.userService.ListRoles(s.permissionContext(PermissionUsersRead), &pb.ListRolesRequest{})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, mErr.ErrPermissionDenied, err)
+}
+
+func (s *RoleTestSuite) Test_ListRoles_Success() {
+	ctx := s.permissionContext(PermissionRolesRead)
+
+	// mock
+	s.mockRoleRepo.EXPECT().ListRoles(ctx).Return([]*entity.Role{s.role}, nil)
+
+	// execute
+	resp, err := s.userService.ListRoles(ctx, &pb.ListRolesRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(resp.Roles, 1)
+	s.Assert().Equal("support", resp.Roles[0].Name)
+	s.Assert().Equal([]string{PermissionUsersRead}, resp.Roles[0].Permissions)
+}
+
+func (s *RoleTestSuite) Test_ListPermissions_Success() {
+	ctx := s.permissionContext(PermissionRolesRead)
+
+	// mock
+	s.mockRoleRepo.EXPECT().ListPermissions(ctx).Return(Permissions, nil)
+
+	// execute
+	resp, err := s.userService.ListPermissions(ctx, &pb.ListPermissionsRequest{})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Len(resp.Permissions, len(Permissions))
+}
+
+func (s *RoleTestSuite) Test_CreateRole_InvalidArgument() {
+	tests := map[string]*pb.CreateRoleRequest{
+		"empty name":         {Name: " "},
+		"upper case name":    {Name: "Support"},
+		"space in name":      {Name: "support team"},
+		"unknown permission": {Name: "support", Permissions: []string{"users:delete"}},
+	}
+
+	for name, req := range tests {
+		s.Run(name, func() {
+			// execute
+			resp, err := s.userService.CreateRole(s.permissionContext(PermissionRolesWrite), req)
+
+			// assert
+			s.Assert().Nil(resp)
+			s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func (s *RoleTestSuite) Test_CreateRole_NotGrantable() {
+	// execute
+	resp, err := s.userService.CreateRole(s.permissionContext(PermissionRolesWrite), &pb.CreateRoleRequest{
+		Name:        "support",
+		Permissions: []string{PermissionUsersRead},
+	})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, mErr.ErrPermissionNotGrantable, err)
+}
+
+func (s *RoleTestSuite) Test_CreateRole_AlreadyExists() {
+	ctx := s.permissionContext(PermissionRolesWrite)
+
+	// mock
+	s.mockRoleRepo.EXPECT().CreateRole(ctx, mock.Anything).Return(repository.ErrRoleAlreadyExists)
+
+	// execute
+	resp, err := s.userService.CreateRole(ctx, &pb.CreateRoleRequest{Name: "support"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.AlreadyExists, mErr.ErrRoleAlreadyExists, err)
+}
+
+func (s *RoleTestSuite) Test_CreateRole_Success() {
+	ctx := s.permissionContext(PermissionRolesWrite, PermissionUsersRead, PermissionRolesRead)
+
+	// mock
+	s.mockRoleRepo.EXPECT().CreateRole(ctx, mock.MatchedBy(func(role *entity.Role) bool {
+		return role.Name == "support" && role.Permissions[0].RoleID == role.ID
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.CreateRole(ctx, &pb.CreateRoleRequest{
+		Name:        " support ",
+		Description: "support team",
+		Permissions: []string{PermissionUsersRead, PermissionRolesRead, PermissionUsersRead},
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("support", resp.Name)
+	s.Assert().Equal([]string{PermissionRolesRead, PermissionUsersRead}, resp.Permissions)
+}
+
+func (s *RoleTestSuite) Test_UpdateRole_NotFound() {
+	ctx := s.permissionContext(PermissionRolesWrite)
+
+	// mock
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.UpdateRole(ctx, &pb.UpdateRoleRequest{Name: "support"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.NotFound, mErr.ErrRoleNotFound, err)
+}
+
+func (s *RoleTestSuite) Test_UpdateRole_NotGrantable() {
+	// the caller can't take away a permission it doesn't have either
+	ctx := s.permissionContext(PermissionRolesWrite)
+
+	// mock
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(s.role, nil)
+
+	// execute
+	resp, err := s.userService.UpdateRole(ctx, &pb.UpdateRoleRequest{Name: "support"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, mErr.ErrPermissionNotGrantable, err)
+}
+
+func (s *RoleTestSuite) Test_UpdateRole_Success() {
+	ctx := s.permissionContext(PermissionRolesWrite, PermissionUsersRead, PermissionRolesRead)
+	holderID := uuid.New()
+
+	// mock
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(s.role, nil)
+	s.mockRoleRepo.EXPECT().UpdateRole(ctx, s.role).Return(nil)
+	s.mockRoleRepo.EXPECT().ListRoleUsers(ctx, s.role.ID).Return([]uuid.UUID{holderID}, nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(ctx, holderID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.UpdateRole(ctx, &pb.UpdateRoleRequest{
+		Name:        "support",
+		Description: "read only",
+		Permissions: []string{PermissionRolesRead},
+	})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().Equal("read only", resp.Description)
+	s.Assert().Equal([]string{PermissionRolesRead}, resp.Permissions)
+}
+
+func (s *RoleTestSuite) Test_DeleteRole_Success() {
+	ctx := interceptor.ContextWithUserID(context.Background(), s.adminID)
+	holderID := uuid.New()
+
+	// mock
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(s.role, nil)
+	s.mockRoleRepo.EXPECT().ListRoleUsers(ctx, s.role.ID).Return([]uuid.UUID{holderID}, nil)
+	s.mockRoleRepo.EXPECT().DeleteRole(ctx, s.role.ID).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(ctx, holderID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.DeleteRole(ctx, &pb.DeleteRoleRequest{Name: "support"})
+
+	// assert
+	s.Assert().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *RoleTestSuite) Test_AssignRole_InvalidUserID() {
+	// execute
+	resp, err := s.userService.AssignRole(s.permissionContext(PermissionRolesAssign), &pb.AssignRoleRequest{UserId: "invalid", Role: "support"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.InvalidArgument, mErr.ErrInvalidUserID, err)
+}
+
+func (s *RoleTestSuite) Test_AssignRole_UserNotFound() {
+	ctx := s.permissionContext(PermissionRolesAssign)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.AssignRole(ctx, &pb.AssignRoleRequest{UserId: userID.String(), Role: "support"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.NotFound, mErr.ErrUserNotFound, err)
+}
+
+func (s *RoleTestSuite) Test_AssignRole_NotGrantable() {
+	// a user with roles:assign alone can't give itself more permissions
+	ctx := s.permissionContext(PermissionRolesAssign)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(&entity.User{ID: userID}, nil)
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(s.role, nil)
+
+	// execute
+	resp, err := s.userService.AssignRole(ctx, &pb.AssignRoleRequest{UserId: userID.String(), Role: "support"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, mErr.ErrPermissionNotGrantable, err)
+}
+
+func (s *RoleTestSuite) Test_AssignRole_Success() {
+	ctx := s.permissionContext(PermissionRolesAssign, PermissionUsersRead)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(&entity.User{ID: userID}, nil)
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(s.role, nil)
+	s.mockRoleRepo.EXPECT().AssignRole(ctx, userID, s.role.ID).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(ctx, userID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.AssignRole(ctx, &pb.AssignRoleRequest{UserId: userID.String(), Role: "support"})
+
+	// assert
+	s.Assert().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *RoleTestSuite) Test_UnassignRole_Success() {
+	ctx := s.permissionContext(PermissionRolesAssign, PermissionUsersRead)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(&entity.User{ID: userID}, nil)
+	s.mockRoleRepo.EXPECT().GetRole(ctx, "support").Return(s.role, nil)
+	s.mockRoleRepo.EXPECT().UnassignRole(ctx, userID, s.role.ID).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(ctx, userID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.UnassignRole(ctx, &pb.UnassignRoleRequest{UserId: userID.String(), Role: "support"})
+
+	// assert
+	s.Assert().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *RoleTestSuite) Test_ListUserRoles_Success() {
+	ctx := s.permissionContext(PermissionUsersRead)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(&entity.User{ID: userID}, nil)
+	s.mockRoleRepo.EXPECT().ListUserRoles(ctx, userID).Return([]*entity.Role{s.role}, nil)
+
+	// execute
+	resp, err := s.userService.ListUserRoles(ctx, &pb.ListUserRolesRequest{UserId: userID.String()})
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(resp.Roles, 1)
+	s.Assert().Equal("support", resp.Roles[0].Name)
+}
+
+func (s *RoleTestSuite) Test_ListUserRoles_RepoError() {
+	ctx := s.permissionContext(PermissionUsersRead)
+	userID := uuid.New()
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(ctx, userID).Return(&entity.User{ID: userID}, nil)
+	s.mockRoleRepo.EXPECT().ListUserRoles(ctx, userID).Return(nil, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.ListUserRoles(ctx, &pb.ListUserRolesRequest{UserId: userID.String()})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Internal, mErr.ErrInternalServerError, err)
+}
+
+func (s *RoleTestSuite) Test_tokenRoles() {
+	userService := s.userService.(*userServiceImpl)
+	ctx := context.Background()
+	otherRole := &entity.Role{ID: uuid.New(), Name: "auditor"}
+	otherRole.Permissions = rolePermissions(otherRole.ID, []string{PermissionRolesRead, PermissionUsersRead})
+
+	// a user gets the union of the permissions of its roles
+	userID := uuid.New()
+	s.mockRoleRepo.EXPECT().ListUserRoles(ctx, userID).Return([]*entity.Role{otherRole, s.role}, nil)
+
+	roles, permissions, err := userService.tokenRoles(ctx, userID)
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"auditor", "support"}, roles)
+	s.Assert().Equal([]string{PermissionRolesRead, PermissionUsersRead}, permissions)
+
+	// the users of AUTH_ADMIN_USER_IDS have every permission
+	adminID := uuid.MustParse(s.adminID)
+	s.mockRoleRepo.EXPECT().ListUserRoles(ctx, adminID).Return([]*entity.Role{}, nil)
+
+	roles, permissions, err = userService.tokenRoles(ctx, adminID)
+	s.Require().NoError(err)
+	s.Assert().Empty(roles)
+	s.Assert().ElementsMatch(permissionNames(), permissions)
+}
@@ -0,0 +1,91 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/repository"
+)
+
+func TestRoles_Assignment(t *testing.T) {
+	db := newIntegrationDB(t, true)
+	require.NoError(t, db.AutoMigrate(&entity.Permission{}, &entity.Role{}, &entity.RolePermission{}, &entity.UserRole{}))
+
+	const (
+		roleName = "integration-support"
+		email    = "roles@example.com"
+	)
+	cleanup := func() {
+		require.NoError(t, db.Unscoped().Where("email_canonical = ?", email).Delete(&entity.User{}).Error)
+		var roleIDs []uuid.UUID
+		require.NoError(t, db.Model(&entity.Role{}).Where("name = ?", roleName).Pluck("id", &roleIDs).Error)
+		for _, roleID := range roleIDs {
+			require.NoError(t, repository.NewRolesRepository(db).DeleteRole(context.Background(), roleID))
+		}
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	ctx := context.Background()
+	userRepo := repository.NewUsersRepository(db)
+	roleRepo := repository.NewRolesRepository(db)
+
+	require.NoError(t, roleRepo.SyncPermissions(ctx, Permissions))
+
+	user := &entity.User{ID: uuid.New(), Name: "roles", Email: email, EmailCanonical: email, Password: "x"}
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	role := &entity.Role{ID: uuid.New(), Name: roleName}
+	role.Permissions = rolePermissions(role.ID, []string{PermissionUsersRead})
+	require.NoError(t, roleRepo.CreateRole(ctx, role))
+	assert.ErrorIs(t, roleRepo.CreateRole(ctx, &entity.Role{ID: uuid.New(), Name: roleName}), repository.ErrRoleAlreadyExists)
+
+	// assigning twice is not an error
+	require.NoError(t, roleRepo.AssignRole(ctx, user.ID, role.ID))
+	require.NoError(t, roleRepo.AssignRole(ctx, user.ID, role.ID))
+
+	roles, err := roleRepo.ListUserRoles(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	assert.Equal(t, []string{PermissionUsersRead}, roles[0].PermissionNames())
+
+	users, err := userRepo.ListUsers(ctx, repository.UserFilter{Role: roleName}, repository.UserOrder{}, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, user.ID, users[0].ID)
+
+	// the permissions are replaced
+	role.Permissions = rolePermissions(role.ID, []string{PermissionRolesRead, PermissionRolesWrite})
+	require.NoError(t, roleRepo.UpdateRole(ctx, role))
+
+	found, err := roleRepo.GetRole(ctx, roleName)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{PermissionRolesRead, PermissionRolesWrite}, found.PermissionNames())
+
+	// a permission removed from the code is removed from the roles
+	require.NoError(t, roleRepo.SyncPermissions(ctx, Permissions[:3]))
+	found, err = roleRepo.GetRole(ctx, roleName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{PermissionRolesRead}, found.PermissionNames())
+	require.NoError(t, roleRepo.SyncPermissions(ctx, Permissions))
+
+	userIDs, err := roleRepo.ListRoleUsers(ctx, role.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{user.ID}, userIDs)
+
+	require.NoError(t, roleRepo.UnassignRole(ctx, user.ID, role.ID))
+	roles, err = roleRepo.ListUserRoles(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	require.NoError(t, roleRepo.DeleteRole(ctx, role.ID))
+	assert.ErrorIs(t, roleRepo.DeleteRole(ctx, role.ID), gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/internal/validation"
+)
+
+// the reasons of the status changes made by the service itself
+const (
+	statusReasonEmailVerified    = "email verified"
+	statusReasonAccountDeleted   = "deleted by the user"
+	statusReasonAccountRestored  = "restored by the user"
+	statusReasonRefreshTokenUsed = "used refresh token presented again"
+	statusReasonPasswordReset    = "password reset"
+)
+
+// DisableAccount disables an account and logs out every session , the user can't log in until EnableAccount
+func (u *userServiceImpl) DisableAccount(ctx context.Context, req *pb.DisableAccountRequest) (resp *protobuf.EmptyResponse, err error) {
+	actorID, user, reason, err := u.statusChangeTarget(ctx, req.UserId, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ID == actorID {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrCannotDisableSelf)
+	}
+	if user.Status == entity.UserStatusDisabled {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrAccountAlreadyDisabled)
+	}
+
+	now := time.Now()
+	if err = u.changeStatus(ctx, user, entity.UserStatusDisabled, reason, &actorID); err != nil {
+		return nil, err
+	}
+
+	if err = u.revokeAllTokens(ctx, user.ID, now); err != nil {
+		return nil, err
+	}
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// EnableAccount enables a disabled account again , it is pending_verification until the email is verified
+func (u *userServiceImpl) EnableAccount(ctx context.Context, req *pb.EnableAccountRequest) (resp *protobuf.EmptyResponse, err error) {
+	actorID, user, reason, err := u.statusChangeTarget(ctx, req.UserId, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != entity.UserStatusDisabled {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrAccountNotDisabled)
+	}
+
+	if err = u.changeStatus(ctx, user, user.UsableStatus(), reason, &actorID); err != nil {
+		return nil, err
+	}
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// UnlockAccount unlocks an account locked by the service , like ConfirmPasswordReset does
+func (u *userServiceImpl) UnlockAccount(ctx context.Context, req *pb.UnlockAccountRequest) (resp *protobuf.EmptyResponse, err error) {
+	actorID, user, reason, err := u.statusChangeTarget(ctx, req.UserId, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != entity.UserStatusLocked {
+		return nil, status.Error(codes.FailedPrecondition, mErr.ErrAccountNotLocked)
+	}
+
+	if err = u.changeStatus(ctx, user, user.UsableStatus(), reason, &actorID); err != nil {
+		return nil, err
+	}
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// ListAccountStatusChanges returns the status changes of an account , the oldest first
+func (u *userServiceImpl) ListAccountStatusChanges(ctx context.Context, req *pb.ListAccountStatusChangesRequest) (resp *pb.ListAccountStatusChangesResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionUsersRead); err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidUserID)
+	}
+
+	if _, err = u.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	changes, err := u.userRepo.ListStatusChanges(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Msg("ListStatusChanges error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	resp = &pb.ListAccountStatusChangesResponse{
+		Changes: make([]*pb.AccountStatusChange, 0, len(changes)),
+	}
+	for _, change := range changes {
+		resp.Changes = append(resp.Changes, toAccountStatusChange(change))
+	}
+
+	return resp, nil
+}
+
+// statusChangeTarget checks the permission and the request of the admin status rpcs ,
+// it returns the caller , the user to change and the trimmed reason
+func (u *userServiceImpl) statusChangeTarget(ctx context.Context, id string, reason string) (actorID uuid.UUID, user *entity.User, trimmed string, err error) {
+	if err = u.checkPermission(ctx, PermissionUsersManage); err != nil {
+		return uuid.Nil, nil, "", err
+	}
+
+	actorID, err = authenticatedUserID(ctx)
+	if err != nil {
+		return uuid.Nil, nil, "", err
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, nil, "", status.Error(codes.InvalidArgument, mErr.ErrInvalidUserID)
+	}
+
+	trimmed = strings.TrimSpace(reason)
+	v := validation.New()
+	v.Length("reason", trimmed, 1, entity.UserStatusReasonMaxLength)
+	if err = v.Err(); err != nil {
+		return uuid.Nil, nil, "", err
+	}
+
+	user, err = u.getUser(ctx, userID)
+	if err != nil {
+		return uuid.Nil, nil, "", err
+	}
+
+	return actorID, user, trimmed, nil
+}
+
+// changeStatus changes the status of the user and records the change , actorID is nil for the service itself.
+// It fails with FailedPrecondition when the status can't change to status to , and with Aborted
+// when the status was changed concurrently.
+func (u *userServiceImpl) changeStatus(ctx context.Context, user *entity.User, to entity.UserStatus, reason string, actorID *uuid.UUID) error {
+	if !user.Status.CanTransitionTo(to) {
+		return status.Error(codes.FailedPrecondition, mErr.ErrInvalidStatusTransition)
+	}
+
+	change := entity.NewUserStatusChange(user, to, reason, actorID)
+
+	err := u.userRepo.ChangeStatus(ctx, change)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return status.Error(codes.Aborted, mErr.ErrAccountStatusChanged)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return status.Error(codes.NotFound, mErr.ErrUserNotFound)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("ChangeStatus error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	user.Status = to
+
+	event := log.Info().
+		Str("user_id", user.ID.String()).
+		Str("from", string(change.FromStatus)).
+		Str("to", string(to)).
+		Str("reason", reason)
+	if actorID != nil {
+		event = event.Str("actor_id", actorID.String())
+	}
+	event.Msg("account status changed")
+
+	return nil
+}
+
+// lockAccount locks the account of a refresh token presented after it was used , the token or its successor
+// may be stolen. The user unlocks it with a password reset. Failures are only logged.
+func (u *userServiceImpl) lockAccount(ctx context.Context, userID uuid.UUID, now time.Time) {
+	user, err := u.userRepo.Get(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Error().Err(err).Msg("Get user error")
+		}
+		return
+	}
+
+	// a disabled account stays disabled
+	if !user.Status.CanTransitionTo(entity.UserStatusLocked) {
+		return
+	}
+
+	if err = u.changeStatus(ctx, user, entity.UserStatusLocked, statusReasonRefreshTokenUsed, nil); err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("lock account error")
+		return
+	}
+
+	if err = u.revokeAllTokens(ctx, userID, now); err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("revoke tokens of locked account error")
+	}
+}
+
+// unlockAfterPasswordReset unlocks the account of the user when it is locked , the other statuses stay
+func (u *userServiceImpl) unlockAfterPasswordReset(ctx context.Context, userID uuid.UUID) error {
+	user, err := u.userRepo.Get(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Get user error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if user.Status != entity.UserStatusLocked {
+		return nil
+	}
+
+	return u.changeStatus(ctx, user, user.UsableStatus(), statusReasonPasswordReset, &userID)
+}
+
+// checkLoginStatus refuses to log in a disabled or locked user , and a user without a verified email
+// when AUTH_REQUIRE_EMAIL_VERIFIED is on
+func (u *userServiceImpl) checkLoginStatus(user *entity.User) error {
+	if err := interceptor.AccountStatusError(user.Status); err != nil {
+		return err
+	}
+
+	if u.verifyConfig.Required && !user.IsEmailVerified() {
+		return status.Error(codes.FailedPrecondition, mErr.ErrEmailNotVerified)
+	}
+
+	return nil
+}
+
+func toAccountStatusChange(change *entity.UserStatusChange) *pb.AccountStatusChange {
+	result := &pb.AccountStatusChange{
+		FromStatus: string(change.FromStatus),
+		ToStatus:   string(change.ToStatus),
+		Reason:     change.Reason,
+		ChangedAt:  timestamppb.New(change.CreatedAt),
+	}
+
+	if change.ActorID != nil {
+		result.ActorId = change.ActorID.String()
+	}
+
+	return result
+}
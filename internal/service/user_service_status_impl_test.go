@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestAccountStatusTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountStatusTestSuite))
+}
+
+type AccountStatusTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	actorID              uuid.UUID
+	ctx                  context.Context
+	user                 *entity.User
+}
+
+func (s *AccountStatusTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	s.actorID = uuid.New()
+	s.ctx = s.permissionContext(s.actorID, PermissionUsersManage, PermissionUsersRead)
+
+	verifiedAt := time.Now().Add(-time.Hour)
+	s.user = &entity.User{
+		ID:              uuid.New(),
+		Name:            "test",
+		Email:           "test@example.com",
+		Status:          entity.UserStatusActive,
+		EmailVerifiedAt: &verifiedAt,
+	}
+}
+
+// permissionContext is the context of a request of the user with a token granting the permissions
+func (s *AccountStatusTestSuite) permissionContext(userID uuid.UUID, permissions ...string) context.Context {
+	return interceptor.ContextWithClaims(context.Background(), &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID.String()},
+		Permissions:      permissions,
+	})
+}
+
+func (s *AccountStatusTestSuite) assertCode(code codes.Code, message string, err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(code, rpcErr.Code())
+	s.Assert().Equal(message, rpcErr.Message())
+}
+
+// expectStatusChange expects the change of the user to status to by the actor
+func (s *AccountStatusTestSuite) expectStatusChange(to entity.UserStatus, reason string) {
+	from := s.user.Status
+	s.mockUserRepo.EXPECT().ChangeStatus(s.ctx, mock.MatchedBy(func(change *entity.UserStatusChange) bool {
+		return change.UserID == s.user.ID && change.FromStatus == from && change.ToStatus == to &&
+			change.Reason == reason && change.ActorID != nil && *change.ActorID == s.actorID
+	})).Return(nil)
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_Success() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.expectStatusChange(entity.UserStatusDisabled, "spam")
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(s.ctx, s.user.ID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(s.ctx, s.user.ID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.DisableAccount(s.ctx, &pb.DisableAccountRequest{UserId: s.user.ID.String(), Reason: " spam "})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_PermissionDenied() {
+	// execute , users:read is not enough
+	ctx := s.permissionContext(s.actorID, PermissionUsersRead)
+	resp, err := s.userService.DisableAccount(ctx, &pb.DisableAccountRequest{UserId: s.user.ID.String(), Reason: "spam"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, mErr.ErrPermissionDenied, err)
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_InvalidArgument() {
+	tests := map[string]*pb.DisableAccountRequest{
+		"invalid user id": {UserId: "not_uuid", Reason: "spam"},
+		"no reason":       {UserId: s.user.ID.String(), Reason: "  "},
+		"long reason":     {UserId: s.user.ID.String(), Reason: strings.Repeat("a", entity.UserStatusReasonMaxLength+1)},
+	}
+
+	for name, req := range tests {
+		s.Run(name, func() {
+			// execute
+			resp, err := s.userService.DisableAccount(s.ctx, req)
+
+			// assert
+			s.Assert().Nil(resp)
+			s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_UserNotFound() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.DisableAccount(s.ctx, &pb.DisableAccountRequest{UserId: s.user.ID.String(), Reason: "spam"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.NotFound, mErr.ErrUserNotFound, err)
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_Self() {
+	// input
+	s.user.ID = s.actorID
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.DisableAccount(s.ctx, &pb.DisableAccountRequest{UserId: s.user.ID.String(), Reason: "spam"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, mErr.ErrCannotDisableSelf, err)
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_AlreadyDisabled() {
+	// input
+	s.user.Status = entity.UserStatusDisabled
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.DisableAccount(s.ctx, &pb.DisableAccountRequest{UserId: s.user.ID.String(), Reason: "spam"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, mErr.ErrAccountAlreadyDisabled, err)
+}
+
+func (s *AccountStatusTestSuite) Test_DisableAccount_ConcurrentChange() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().ChangeStatus(s.ctx, mock.Anything).Return(repository.ErrStatusChanged)
+
+	// execute
+	resp, err := s.userService.DisableAccount(s.ctx, &pb.DisableAccountRequest{UserId: s.user.ID.String(), Reason: "spam"})
+
+	// assert , the tokens are not revoked
+	s.Assert().Nil(resp)
+	s.assertCode(codes.Aborted, mErr.ErrAccountStatusChanged, err)
+}
+
+func (s *AccountStatusTestSuite) Test_EnableAccount_Success() {
+	// input , the email was never verified
+	s.user.Status = entity.UserStatusDisabled
+	s.user.EmailVerifiedAt = nil
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.expectStatusChange(entity.UserStatusPendingVerification, "appeal accepted")
+
+	// execute
+	resp, err := s.userService.EnableAccount(s.ctx, &pb.EnableAccountRequest{UserId: s.user.ID.String(), Reason: "appeal accepted"})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *AccountStatusTestSuite) Test_EnableAccount_NotDisabled() {
+	// input , a locked account is unlocked with UnlockAccount
+	s.user.Status = entity.UserStatusLocked
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.EnableAccount(s.ctx, &pb.EnableAccountRequest{UserId: s.user.ID.String(), Reason: "appeal accepted"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, mErr.ErrAccountNotDisabled, err)
+}
+
+func (s *AccountStatusTestSuite) Test_UnlockAccount_Success() {
+	// input
+	s.user.Status = entity.UserStatusLocked
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.expectStatusChange(entity.UserStatusActive, "owner confirmed")
+
+	// execute
+	resp, err := s.userService.UnlockAccount(s.ctx, &pb.UnlockAccountRequest{UserId: s.user.ID.String(), Reason: "owner confirmed"})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *AccountStatusTestSuite) Test_UnlockAccount_NotLocked() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.UnlockAccount(s.ctx, &pb.UnlockAccountRequest{UserId: s.user.ID.String(), Reason: "owner confirmed"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.FailedPrecondition, mErr.ErrAccountNotLocked, err)
+}
+
+func (s *AccountStatusTestSuite) Test_ListAccountStatusChanges_Success() {
+	// input
+	changedAt := time.Now().Add(-time.Hour)
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(s.ctx, s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().ListStatusChanges(s.ctx, s.user.ID).Return([]*entity.UserStatusChange{
+		{UserID: s.user.ID, FromStatus: entity.UserStatusActive, ToStatus: entity.UserStatusLocked, Reason: statusReasonRefreshTokenUsed, CreatedAt: changedAt},
+		{UserID: s.user.ID, FromStatus: entity.UserStatusLocked, ToStatus: entity.UserStatusActive, Reason: "owner confirmed", ActorID: &s.actorID, CreatedAt: changedAt},
+	}, nil)
+
+	// execute
+	resp, err := s.userService.ListAccountStatusChanges(s.ctx, &pb.ListAccountStatusChangesRequest{UserId: s.user.ID.String()})
+
+	// assert
+	s.Require().NoError(err)
+	s.Require().Len(resp.Changes, 2)
+	s.Assert().Equal("locked", resp.Changes[0].ToStatus)
+	s.Assert().Empty(resp.Changes[0].ActorId)
+	s.Assert().Equal(s.actorID.String(), resp.Changes[1].ActorId)
+	s.Assert().True(changedAt.Equal(resp.Changes[1].ChangedAt.AsTime()))
+}
+
+func (s *AccountStatusTestSuite) Test_ListAccountStatusChanges_PermissionDenied() {
+	// execute
+	ctx := s.permissionContext(s.actorID, PermissionUsersManage)
+	resp, err := s.userService.ListAccountStatusChanges(ctx, &pb.ListAccountStatusChangesRequest{UserId: s.user.ID.String()})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertCode(codes.PermissionDenied, mErr.ErrPermissionDenied, err)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// tokenPair is an access token and the refresh token that renews it
+type tokenPair struct {
+	accessToken      string
+	accessExpiresAt  time.Time
+	refreshToken     string
+	refreshExpiresAt time.Time
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token , the old refresh token is used up.
+// A refresh token used a second time revokes its whole family , as the token or its successor may be stolen.
+func (u *userServiceImpl) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (resp *pb.RefreshTokenResponse, err error) {
+	now := time.Now()
+
+	current, err := u.refreshTokenRepo.GetByHash(ctx, utils.HashOpaqueToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidRefreshToken)
+		}
+		log.Error().Err(err).Msg("GetByHash error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if current.RevokedAt != nil {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidRefreshToken)
+	}
+
+	if current.UsedAt != nil {
+		u.revokeRefreshTokenFamily(ctx, current, now)
+		if u.accountConfig.LockOnRefreshTokenReuse {
+			u.lockAccount(ctx, current.UserID, now)
+		}
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidRefreshToken)
+	}
+
+	if current.IsExpired(now) || current.DeviceID != req.DeviceId {
+		return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidRefreshToken)
+	}
+
+	// the user may be deleted , disabled or locked since login
+	user, err := u.userRepo.Get(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidRefreshToken)
+		}
+		log.Error().Err(err).Msg("Get user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if err = interceptor.AccountStatusError(user.Status); err != nil {
+		return nil, err
+	}
+
+	// the login time is kept , a refreshed token doesn't prove the user entered the credentials again
+	var authTime time.Time
+	if current.AuthTime != nil {
+		authTime = *current.AuthTime
+	}
+
+	tokens, next, err := u.newTokenPair(ctx, current.UserID, current.FamilyID, current.DeviceID, authTime)
+	if err != nil {
+		log.Error().Err(err).Msg("Generate token error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	err = u.refreshTokenRepo.Rotate(ctx, current, next)
+	if err != nil {
+		// used by a concurrent request
+		if errors.Is(err, repository.ErrRefreshTokenUsed) {
+			u.revokeRefreshTokenFamily(ctx, current, now)
+			return nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidRefreshToken)
+		}
+		log.Error().Err(err).Msg("Rotate refresh token error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	resp = &pb.RefreshTokenResponse{
+		Token:            tokens.accessToken,
+		ExpiresIn:        timestamppb.New(tokens.accessExpiresAt),
+		RefreshToken:     tokens.refreshToken,
+		RefreshExpiresIn: timestamppb.New(tokens.refreshExpiresAt),
+	}
+
+	return
+}
+
+// Logout revokes the access token of the request , and the refresh token family when the refresh token is given
+func (u *userServiceImpl) Logout(ctx context.Context, req *pb.LogoutRequest) (resp *protobuf.EmptyResponse, err error) {
+	claims, userID, err := authenticatedClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.revokedTokenRepo.RevokeToken(ctx, claims.ID, userID, claims.ExpiresAt.Time)
+	if err != nil {
+		log.Error().Err(err).Msg("RevokeToken error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if req.RefreshToken != "" {
+		refreshToken, err := u.refreshTokenRepo.GetByHash(ctx, utils.HashOpaqueToken(req.RefreshToken))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Error().Err(err).Msg("GetByHash error")
+			return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+		}
+
+		// a refresh token of another user is ignored
+		if err == nil && refreshToken.UserID == userID {
+			if err := u.refreshTokenRepo.RevokeFamily(ctx, refreshToken.FamilyID, time.Now()); err != nil {
+				log.Error().Err(err).Msg("RevokeFamily error")
+				return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+			}
+		}
+	}
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// RevokeUserTokens invalidates every access and refresh token of a user
+func (u *userServiceImpl) RevokeUserTokens(ctx context.Context, req *pb.RevokeUserTokensRequest) (resp *protobuf.EmptyResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionUsersRevokeTokens); err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(req.UserId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidUserID)
+	}
+
+	if err = u.revokeAllTokens(ctx, userID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", userID.String()).Msg("user tokens revoked")
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// revokeAllTokens invalidates the access tokens issued before now and all refresh tokens of the user
+func (u *userServiceImpl) revokeAllTokens(ctx context.Context, userID uuid.UUID, now time.Time) error {
+	if err := u.revokedTokenRepo.RevokeUserTokens(ctx, userID, now); err != nil {
+		log.Error().Err(err).Msg("RevokeUserTokens error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	// the user revocation misses the tokens issued in the same second , the token of the call is revoked by its jti
+	if claims, ok := interceptor.ClaimsFromContext(ctx); ok && claims.Subject == userID.String() && claims.ID != "" && claims.ExpiresAt != nil {
+		if err := u.revokedTokenRepo.RevokeToken(ctx, claims.ID, userID, claims.ExpiresAt.Time); err != nil {
+			log.Error().Err(err).Msg("RevokeToken error")
+			return status.Error(codes.Internal, mErr.ErrInternalServerError)
+		}
+	}
+
+	if err := u.refreshTokenRepo.RevokeByUser(ctx, userID, now); err != nil {
+		log.Error().Err(err).Msg("RevokeByUser error")
+		return status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	return nil
+}
+
+// authenticatedClaims returns the token claims put in ctx by the auth interceptor
+func authenticatedClaims(ctx context.Context) (claims *utils.Claims, userID uuid.UUID, err error) {
+	claims, ok := interceptor.ClaimsFromContext(ctx)
+	if !ok {
+		return nil, uuid.Nil, status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	userID, err = uuid.Parse(claims.Subject)
+	if err != nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, uuid.Nil, status.Error(codes.Unauthenticated, mErr.ErrInvalidToken)
+	}
+
+	return claims, userID, nil
+}
+
+// checkPermission rejects the call unless the token grants the permission or the user is in AUTH_ADMIN_USER_IDS ,
+// the permission interceptor checks it before already , this keeps the rpcs safe when the interceptor is left out
+func (u *userServiceImpl) checkPermission(ctx context.Context, permission string) error {
+	claims, ok := interceptor.ClaimsFromContext(ctx)
+	if !ok || claims.Subject == "" {
+		return status.Error(codes.Unauthenticated, mErr.ErrMissingToken)
+	}
+
+	if _, ok := u.adminUserIDs[claims.Subject]; ok {
+		return nil
+	}
+
+	if !claims.HasPermission(permission) {
+		return status.Error(codes.PermissionDenied, mErr.ErrPermissionDenied)
+	}
+
+	return nil
+}
+
+// tokenRoles returns the role names and the permissions put in the access tokens of the user ,
+// the users in AUTH_ADMIN_USER_IDS have every permission
+func (u *userServiceImpl) tokenRoles(ctx context.Context, userID uuid.UUID) (roles []string, permissions []string, err error) {
+	if _, ok := u.adminUserIDs[userID.String()]; ok {
+		permissions = permissionNames()
+	}
+
+	if u.roleRepo == nil {
+		return nil, permissions, nil
+	}
+
+	userRoles, err := u.roleRepo.ListUserRoles(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, role := range userRoles {
+		roles = append(roles, role.Name)
+		permissions = append(permissions, role.PermissionNames()...)
+	}
+
+	slices.Sort(permissions)
+	return roles, slices.Compact(permissions), nil
+}
+
+// newTokenPair generates an access token and a refresh token in the family , the refresh token is not saved yet.
+// authTime is when the user entered the credentials , it is zero for the families that predate it.
+func (u *userServiceImpl) newTokenPair(ctx context.Context, userID uuid.UUID, familyID uuid.UUID, deviceID string, authTime time.Time) (tokens *tokenPair, refreshToken *entity.RefreshToken, err error) {
+	roles, permissions, err := u.tokenRoles(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+
+	accessToken, err := utils.GenerateToken(userID.String(), roles, permissions, authTime, u.jwtConfig.KeyRing.SigningKey(), u.jwtConfig.Issuer, u.jwtConfig.ExpireAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, tokenHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken = &entity.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		DeviceID:  deviceID,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(time.Duration(u.jwtConfig.RefreshExpireAt) * time.Hour),
+		CreatedAt: now,
+	}
+	if !authTime.IsZero() {
+		refreshToken.AuthTime = &authTime
+	}
+
+	tokens = &tokenPair{
+		accessToken:      accessToken,
+		accessExpiresAt:  now.Add(time.Duration(u.jwtConfig.ExpireAt) * time.Hour),
+		refreshToken:     token,
+		refreshExpiresAt: refreshToken.ExpiresAt,
+	}
+
+	return tokens, refreshToken, nil
+}
+
+func (u *userServiceImpl) revokeRefreshTokenFamily(ctx context.Context, token *entity.RefreshToken, now time.Time) {
+	log.Warn().
+		Str("user_id", token.UserID.String()).
+		Str("family_id", token.FamilyID.String()).
+		Msg("refresh token reused , revoke the token family")
+
+	if err := u.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID, now); err != nil {
+		log.Error().Err(err).Msg("RevokeFamily error")
+	}
+}
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestRefreshTokenTestSuite(t *testing.T) {
+	suite.Run(t, new(RefreshTokenTestSuite))
+}
+
+type RefreshTokenTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	keyRing              *utils.KeyRing
+	token                string
+	current              *entity.RefreshToken
+	input                struct {
+		ctx context.Context
+		req *pb.RefreshTokenRequest
+	}
+}
+
+func (s *RefreshTokenTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.keyRing = newTestKeyRing(s.T(), utils.NewHMACKey("", "secret"))
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+	})
+
+	token, tokenHash, err := utils.NewOpaqueToken()
+	s.Require().NoError(err)
+
+	s.token = token
+	s.current = &entity.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		DeviceID:  "device",
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now().Add(-time.Hour),
+	}
+
+	s.input.ctx = context.Background()
+	s.input.req = &pb.RefreshTokenRequest{
+		RefreshToken: token,
+		DeviceId:     "device",
+	}
+}
+
+func (s *RefreshTokenTestSuite) assertUnauthenticated(resp *pb.RefreshTokenResponse, err error) {
+	s.Assert().Nil(resp)
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.Unauthenticated, rpcErr.Code())
+	s.Assert().Equal("invalid refresh token", rpcErr.Message())
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_NotFound() {
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), utils.HashOpaqueToken(s.token)).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_GetByHash_DbError() {
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(nil, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_Revoked() {
+	revokedAt := time.Now().Add(-time.Minute)
+	s.current.RevokedAt = &revokedAt
+
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_Reused_RevokeFamily() {
+	usedAt := time.Now().Add(-time.Minute)
+	s.current.UsedAt = &usedAt
+
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeFamily(context.Background(), s.current.FamilyID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_Reused_LockAccount() {
+	viper.Set("ACCOUNT_LOCK_ON_REFRESH_TOKEN_REUSE", true)
+	s.T().Cleanup(viper.Reset)
+	userService := newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          s.keyRing,
+	})
+
+	usedAt := time.Now().Add(-time.Minute)
+	s.current.UsedAt = &usedAt
+
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeFamily(context.Background(), s.current.FamilyID, mock.Anything).Return(nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.current.UserID).
+		Return(&entity.User{ID: s.current.UserID, Status: entity.UserStatusActive}, nil)
+	s.mockUserRepo.EXPECT().ChangeStatus(context.Background(), mock.MatchedBy(func(change *entity.UserStatusChange) bool {
+		// locked by the service itself
+		return change.UserID == s.current.UserID && change.FromStatus == entity.UserStatusActive &&
+			change.ToStatus == entity.UserStatusLocked && change.ActorID == nil
+	})).Return(nil)
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(context.Background(), s.current.UserID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(context.Background(), s.current.UserID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_AccountStatus() {
+	tests := map[entity.UserStatus]codes.Code{
+		entity.UserStatusDisabled: codes.PermissionDenied,
+		entity.UserStatusLocked:   codes.Unauthenticated,
+	}
+
+	for accountStatus, code := range tests {
+		s.Run(string(accountStatus), func() {
+			// mock
+			s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil).Once()
+			s.mockUserRepo.EXPECT().Get(context.Background(), s.current.UserID).
+				Return(&entity.User{ID: s.current.UserID, Status: accountStatus}, nil).Once()
+
+			// execute
+			resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+			// assert , no new tokens
+			s.Assert().Nil(resp)
+			s.Assert().Equal(code, status.Code(err))
+		})
+	}
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_Expired() {
+	s.current.ExpiresAt = time.Now().Add(-time.Second)
+
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_OtherDevice() {
+	s.input.req.DeviceId = "other_device"
+
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_UserDeleted() {
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.current.UserID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_ConcurrentUse_RevokeFamily() {
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.current.UserID).Return(&entity.User{ID: s.current.UserID}, nil)
+	s.mockRefreshTokenRepo.EXPECT().Rotate(context.Background(), s.current, mock.Anything).Return(repository.ErrRefreshTokenUsed)
+	s.mockRefreshTokenRepo.EXPECT().RevokeFamily(context.Background(), s.current.FamilyID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.assertUnauthenticated(resp, err)
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_Rotate_DbError() {
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), mock.Anything).Return(s.current, nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.current.UserID).Return(&entity.User{ID: s.current.UserID}, nil)
+	s.mockRefreshTokenRepo.EXPECT().Rotate(context.Background(), s.current, mock.Anything).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *RefreshTokenTestSuite) Test_RefreshToken_Success() {
+	// input
+	authTime := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	s.current.AuthTime = &authTime
+	var next *entity.RefreshToken
+
+	// mock
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(context.Background(), utils.HashOpaqueToken(s.token)).Return(s.current, nil)
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.current.UserID).Return(&entity.User{ID: s.current.UserID}, nil)
+	s.mockRefreshTokenRepo.EXPECT().Rotate(context.Background(), s.current, mock.MatchedBy(func(token *entity.RefreshToken) bool {
+		return token.ID != s.current.ID &&
+			token.UserID == s.current.UserID &&
+			token.FamilyID == s.current.FamilyID &&
+			token.DeviceID == s.current.DeviceID &&
+			token.TokenHash != s.current.TokenHash
+	})).RunAndReturn(func(ctx context.Context, current *entity.RefreshToken, token *entity.RefreshToken) error {
+		next = token
+		return nil
+	})
+
+	// execute
+	resp, err := s.userService.RefreshToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().Nil(err)
+	s.Assert().NotEmpty(resp.Token)
+	s.Assert().NotNil(resp.ExpiresIn)
+	s.Assert().NotNil(resp.RefreshExpiresIn)
+	s.Assert().NotEqual(s.token, resp.RefreshToken)
+	s.Require().NotNil(next)
+	s.Assert().Equal(utils.HashOpaqueToken(resp.RefreshToken), next.TokenHash)
+
+	// the family keeps the time of the login
+	s.Require().NotNil(next.AuthTime)
+	s.Assert().True(authTime.Equal(*next.AuthTime))
+
+	claims, err := utils.ParseToken(resp.Token, s.keyRing, "")
+	s.Require().NoError(err)
+	s.Assert().Equal(s.current.UserID.String(), claims.Subject)
+	s.Require().NotNil(claims.AuthTime)
+	s.Assert().True(authTime.Equal(claims.AuthTime.Time))
+}
+
+func TestLogoutTestSuite(t *testing.T) {
+	suite.Run(t, new(LogoutTestSuite))
+}
+
+type LogoutTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	userID               uuid.UUID
+	claims               *utils.Claims
+	input                struct {
+		ctx context.Context
+		req *pb.LogoutRequest
+	}
+}
+
+func (s *LogoutTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+
+	s.userID = uuid.New()
+	s.claims = &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   s.userID.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	s.input.ctx = interceptor.ContextWithClaims(context.Background(), s.claims)
+	s.input.req = &pb.LogoutRequest{}
+}
+
+func (s *LogoutTestSuite) Test_Logout_Unauthenticated() {
+	// execute
+	resp, err := s.userService.Logout(context.Background(), s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *LogoutTestSuite) Test_Logout_RevokeToken_DbError() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(s.input.ctx, s.claims.ID, s.userID, s.claims.ExpiresAt.Time).Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.Logout(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *LogoutTestSuite) Test_Logout_AccessTokenOnly() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(s.input.ctx, s.claims.ID, s.userID, s.claims.ExpiresAt.Time).Return(nil)
+
+	// execute
+	resp, err := s.userService.Logout(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *LogoutTestSuite) Test_Logout_WithRefreshToken() {
+	s.input.req.RefreshToken = "refresh_token"
+	familyID := uuid.New()
+
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(s.input.ctx, s.claims.ID, s.userID, s.claims.ExpiresAt.Time).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(s.input.ctx, utils.HashOpaqueToken("refresh_token")).Return(&entity.RefreshToken{
+		UserID:   s.userID,
+		FamilyID: familyID,
+	}, nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeFamily(s.input.ctx, familyID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.Logout(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *LogoutTestSuite) Test_Logout_RefreshTokenOfOtherUser() {
+	s.input.req.RefreshToken = "refresh_token"
+
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().RevokeToken(s.input.ctx, s.claims.ID, s.userID, s.claims.ExpiresAt.Time).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().GetByHash(s.input.ctx, mock.Anything).Return(&entity.RefreshToken{
+		UserID:   uuid.New(),
+		FamilyID: uuid.New(),
+	}, nil)
+
+	// execute
+	resp, err := s.userService.Logout(s.input.ctx, s.input.req)
+
+	// assert , RevokeFamily is not called
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+}
+
+func TestRevokeUserTokensTestSuite(t *testing.T) {
+	suite.Run(t, new(RevokeUserTokensTestSuite))
+}
+
+type RevokeUserTokensTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	adminID              string
+}
+
+func (s *RevokeUserTokensTestSuite) SetupTest() {
+	s.adminID = uuid.NewString()
+	viper.Set("AUTH_ADMIN_USER_IDS", []string{s.adminID})
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), utils.NewHMACKey("", "secret")),
+	})
+}
+
+func (s *RevokeUserTokensTestSuite) Test_RevokeUserTokens_NotAdmin() {
+	ctx := interceptor.ContextWithUserID(context.Background(), uuid.NewString())
+
+	// execute
+	resp, err := s.userService.RevokeUserTokens(ctx, &pb.RevokeUserTokensRequest{UserId: uuid.NewString()})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *RevokeUserTokensTestSuite) Test_RevokeUserTokens_InvalidUserID() {
+	ctx := interceptor.ContextWithUserID(context.Background(), s.adminID)
+
+	// execute
+	resp, err := s.userService.RevokeUserTokens(ctx, &pb.RevokeUserTokensRequest{UserId: "invalid"})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *RevokeUserTokensTestSuite) Test_RevokeUserTokens_Success() {
+	ctx := interceptor.ContextWithUserID(context.Background(), s.adminID)
+	userID := uuid.New()
+
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().RevokeUserTokens(ctx, userID, mock.Anything).Return(nil)
+	s.mockRefreshTokenRepo.EXPECT().RevokeByUser(ctx, userID, mock.Anything).Return(nil)
+
+	// execute
+	resp, err := s.userService.RevokeUserTokens(ctx, &pb.RevokeUserTokensRequest{UserId: userID.String()})
+
+	// assert
+	s.Assert().Nil(err)
+	s.Assert().NotNil(resp)
+}
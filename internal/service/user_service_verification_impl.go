@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itmrchow/todolist-proto/protobuf"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/mailer"
+	"github.com/itmrchow/todolist-user/internal/validation"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// VerifyEmail confirms the email of the user with the token sent on Register or ResendVerification.
+// A token of an email the user no longer has is invalid , verifying twice is not an error.
+func (u *userServiceImpl) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (resp *protobuf.EmptyResponse, err error) {
+	claims, err := utils.ParsePurposeToken(req.Token, u.jwtConfig.KeyRing, u.jwtConfig.Issuer, utils.PurposeEmailVerification)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidVerifyToken)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidVerifyToken)
+	}
+
+	user, err := u.userRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidVerifyToken)
+		}
+		log.Error().Err(err).Msg("Get user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if claims.Email != user.Email {
+		return nil, status.Error(codes.InvalidArgument, mErr.ErrInvalidVerifyToken)
+	}
+
+	if !user.IsEmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+
+		if err = u.userRepo.Update(ctx, user, "email_verified_at"); err != nil {
+			log.Error().Err(err).Msg("Update email_verified_at error")
+			return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+		}
+
+		log.Info().Str("user_id", user.ID.String()).Msg("email verified")
+	}
+
+	// also when the status change of a former call failed , a disabled or locked account keeps its status
+	if user.Status == entity.UserStatusPendingVerification {
+		if err = u.changeStatus(ctx, user, entity.UserStatusActive, statusReasonEmailVerified, &user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &protobuf.EmptyResponse{}, nil
+}
+
+// ResendVerification sends the verification email again , at most once per resend interval.
+// Like RequestPasswordReset the response is the same for every email , so it doesn't tell which email is registered ,
+// already verified or throttled.
+func (u *userServiceImpl) ResendVerification(ctx context.Context, req *pb.ResendVerificationRequest) (resp *protobuf.EmptyResponse, err error) {
+	v := validation.New()
+	email := v.CanonicalEmail("email", req.Email, entity.UserEmailMaxLength, u.lowercaseEmailLocalPart)
+	if err = v.Err(); err != nil {
+		return nil, err
+	}
+
+	resp = &protobuf.EmptyResponse{}
+	now := time.Now()
+
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Error().Err(err).Msg("GetByEmail error")
+		}
+		return resp, nil
+	}
+
+	if user.IsEmailVerified() {
+		return resp, nil
+	}
+
+	if sentAt := user.EmailVerificationSentAt; sentAt != nil && now.Before(sentAt.Add(u.verifyConfig.ResendInterval)) {
+		log.Info().Str("user_id", user.ID.String()).Msg("verification email throttled")
+		return resp, nil
+	}
+
+	user.EmailVerificationSentAt = &now
+	if err = u.userRepo.Update(ctx, user, "email_verification_sent_at"); err != nil {
+		log.Error().Err(err).Msg("Update email_verification_sent_at error")
+		return resp, nil
+	}
+
+	u.sendInBackground("email verification", func(ctx context.Context) {
+		u.sendEmailVerification(ctx, user)
+	})
+
+	return resp, nil
+}
+
+// sendEmailVerification emails a verification link to the user , failures are only logged
+func (u *userServiceImpl) sendEmailVerification(ctx context.Context, user *entity.User) {
+	token, err := utils.GeneratePurposeToken(
+		user.ID.String(),
+		utils.PurposeEmailVerification,
+		user.Email,
+		u.jwtConfig.KeyRing.SigningKey(),
+		u.jwtConfig.Issuer,
+		u.verifyConfig.ExpireAt,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("generate verification token error")
+		return
+	}
+
+	msg := &mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Use the link below to verify your email , it expires in %s.\n\n"+
+			"%s\n\n"+
+			"If you didn't create an account , you can ignore this email.\n",
+			user.Name, u.verifyConfig.ExpireAt, tokenLink(u.verifyConfig.URL, token)),
+	}
+
+	if err = u.mailer.Send(ctx, msg); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("send verification email error")
+	}
+}
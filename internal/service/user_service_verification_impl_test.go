@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	"github.com/itmrchow/todolist-user/internal/mailer"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestEmailVerificationTestSuite(t *testing.T) {
+	suite.Run(t, new(EmailVerificationTestSuite))
+}
+
+type EmailVerificationTestSuite struct {
+	suite.Suite
+	userService  pb.UserServiceServer
+	mockUserRepo *repository.MockUsersRepository
+	mockMailer   *mailer.MockMailer
+	key          *utils.SigningKey
+	user         *entity.User
+	token        string
+}
+
+func (s *EmailVerificationTestSuite) SetupTest() {
+	viper.Set("EMAIL_VERIFICATION_EXPIRE_AT", "24h")
+	viper.Set("EMAIL_VERIFICATION_RESEND_INTERVAL", "1m")
+	viper.Set("EMAIL_VERIFICATION_URL", "https://todolist.example.com/verify")
+	s.T().Cleanup(viper.Reset)
+
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockMailer = mailer.NewMockMailer(s.T())
+	s.key = utils.NewHMACKey("", "secret")
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: repository.NewMockRefreshTokensRepository(s.T()),
+		RevokedTokenRepo: repository.NewMockRevokedTokensRepository(s.T()),
+		Mailer:           s.mockMailer,
+		KeyRing:          newTestKeyRing(s.T(), s.key),
+	})
+
+	s.user = &entity.User{
+		ID:     uuid.New(),
+		Name:   "test",
+		Email:  "test@example.com",
+		Status: entity.UserStatusPendingVerification,
+	}
+
+	token, err := utils.GeneratePurposeToken(s.user.ID.String(), utils.PurposeEmailVerification, s.user.Email, s.key, "", time.Hour)
+	s.Require().NoError(err)
+	s.token = token
+}
+
+func (s *EmailVerificationTestSuite) assertInvalidToken(err error) {
+	rpcErr, ok := status.FromError(err)
+	s.Assert().True(ok)
+	s.Assert().Equal(codes.InvalidArgument, rpcErr.Code())
+	s.Assert().Equal("invalid or expired verification token", rpcErr.Message())
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_Success() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(context.Background(), mock.MatchedBy(func(u *entity.User) bool {
+		return u.ID == s.user.ID && u.IsEmailVerified()
+	}), "email_verified_at").Return(nil)
+	s.mockUserRepo.EXPECT().ChangeStatus(context.Background(), mock.MatchedBy(func(change *entity.UserStatusChange) bool {
+		return change.UserID == s.user.ID && change.FromStatus == entity.UserStatusPendingVerification &&
+			change.ToStatus == entity.UserStatusActive && *change.ActorID == s.user.ID
+	})).Return(nil)
+
+	// execute
+	resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: s.token})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+	s.Assert().Equal(entity.UserStatusActive, s.user.Status)
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_Disabled() {
+	// input
+	s.user.Status = entity.UserStatusDisabled
+
+	// mock , the account stays disabled
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(context.Background(), mock.Anything, "email_verified_at").Return(nil)
+
+	// execute
+	resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: s.token})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_AlreadyVerified() {
+	// input
+	verifiedAt := time.Now().Add(-time.Hour)
+	s.user.EmailVerifiedAt = &verifiedAt
+	s.user.Status = entity.UserStatusActive
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: s.token})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_EmailChanged() {
+	// input
+	s.user.Email = "new@example.com"
+
+	// mock
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: s.token})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertInvalidToken(err)
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_InvalidToken() {
+	// input
+	accessToken, err := utils.GenerateToken(s.user.ID.String(), nil, nil, time.Now(), s.key, "", 1)
+	s.Require().NoError(err)
+	expired, err := utils.GeneratePurposeToken(s.user.ID.String(), utils.PurposeEmailVerification, s.user.Email, s.key, "", -time.Minute)
+	s.Require().NoError(err)
+
+	for name, token := range map[string]string{
+		"garbage":      "invalid_token",
+		"access token": accessToken,
+		"expired":      expired,
+	} {
+		s.Run(name, func() {
+			// execute
+			resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: token})
+
+			// assert
+			s.Assert().Nil(resp)
+			s.assertInvalidToken(err)
+		})
+	}
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_UserNotFound() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: s.token})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.assertInvalidToken(err)
+}
+
+func (s *EmailVerificationTestSuite) Test_VerifyEmail_DbError() {
+	// mock
+	s.mockUserRepo.EXPECT().Get(context.Background(), s.user.ID).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(context.Background(), mock.Anything, "email_verified_at").Return(errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.VerifyEmail(context.Background(), &pb.VerifyEmailRequest{Token: s.token})
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *EmailVerificationTestSuite) Test_ResendVerification_Sends() {
+	sent := make(chan *mailer.Message, 1)
+
+	// input
+	sentAt := time.Now().Add(-2 * time.Minute)
+	s.user.EmailVerificationSentAt = &sentAt
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.user.Email).Return(s.user, nil)
+	s.mockUserRepo.EXPECT().Update(context.Background(), mock.MatchedBy(func(u *entity.User) bool {
+		return u.EmailVerificationSentAt.After(sentAt)
+	}), "email_verification_sent_at").Return(nil)
+	s.mockMailer.EXPECT().Send(mock.Anything, mock.Anything).Run(func(ctx context.Context, msg *mailer.Message) {
+		sent <- msg
+	}).Return(nil)
+
+	// execute
+	resp, err := s.userService.ResendVerification(context.Background(), &pb.ResendVerificationRequest{Email: s.user.Email})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+
+	select {
+	case msg := <-sent:
+		s.Assert().Equal(s.user.Email, msg.To)
+		s.Assert().Contains(msg.Body, "https://todolist.example.com/verify?token=")
+	case <-time.After(5 * time.Second):
+		s.Fail("verification email not sent")
+	}
+}
+
+func (s *EmailVerificationTestSuite) Test_ResendVerification_Throttled() {
+	// input
+	sentAt := time.Now().Add(-30 * time.Second)
+	s.user.EmailVerificationSentAt = &sentAt
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.user.Email).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.ResendVerification(context.Background(), &pb.ResendVerificationRequest{Email: s.user.Email})
+
+	// assert, same response but nothing is sent
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *EmailVerificationTestSuite) Test_ResendVerification_AlreadyVerified() {
+	// input
+	verifiedAt := time.Now()
+	s.user.EmailVerifiedAt = &verifiedAt
+
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), s.user.Email).Return(s.user, nil)
+
+	// execute
+	resp, err := s.userService.ResendVerification(context.Background(), &pb.ResendVerificationRequest{Email: s.user.Email})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
+
+func (s *EmailVerificationTestSuite) Test_ResendVerification_UnknownEmail() {
+	// mock
+	s.mockUserRepo.EXPECT().GetByEmail(context.Background(), "unknown@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.ResendVerification(context.Background(), &pb.ResendVerificationRequest{Email: "unknown@example.com"})
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().NotNil(resp)
+}
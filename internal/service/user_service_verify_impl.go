@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// the reason an inactive token is rejected
+const (
+	TokenReasonInvalid      = "invalid_token"
+	TokenReasonExpired      = "token_expired"
+	TokenReasonRevoked      = "token_revoked"
+	TokenReasonUserNotFound = "user_not_found"
+	// the account is disabled or locked since the token was issued
+	TokenReasonAccountDisabled = tokenReasonAccountPrefix + string(entity.UserStatusDisabled)
+	TokenReasonAccountLocked   = tokenReasonAccountPrefix + string(entity.UserStatusLocked)
+)
+
+// tokenReasonAccountPrefix is followed by the status of an account which can't be used
+const tokenReasonAccountPrefix = "account_"
+
+// VerifyToken checks an access token for other services , modeled on RFC 7662 token introspection.
+// The caller authenticates with its own token , which needs the tokens:introspect permission.
+// A token which can't be used is not an error , the response is inactive with the reason.
+func (u *userServiceImpl) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (resp *pb.VerifyTokenResponse, err error) {
+	if err = u.checkPermission(ctx, PermissionTokensIntrospect); err != nil {
+		return nil, err
+	}
+
+	claims, err := utils.ParseToken(req.Token, u.jwtConfig.KeyRing, u.jwtConfig.Issuer)
+	if err != nil {
+		if errors.Is(err, &mErr.Err401TokenExpired) {
+			return inactiveToken(TokenReasonExpired), nil
+		}
+		return inactiveToken(TokenReasonInvalid), nil
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil || claims.ID == "" || claims.IssuedAt == nil {
+		return inactiveToken(TokenReasonInvalid), nil
+	}
+
+	revoked, err := u.revokedTokenRepo.IsRevoked(ctx, claims.ID, userID, claims.IssuedAt.Time)
+	if err != nil {
+		log.Error().Err(err).Msg("IsRevoked error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+	if revoked {
+		return inactiveToken(TokenReasonRevoked), nil
+	}
+
+	// the user may be deleted , disabled or locked since the token was issued
+	user, err := u.userRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return inactiveToken(TokenReasonUserNotFound), nil
+		}
+		log.Error().Err(err).Msg("Get user error")
+		return nil, status.Error(codes.Internal, mErr.ErrInternalServerError)
+	}
+
+	if interceptor.AccountStatusError(user.Status) != nil {
+		return inactiveToken(tokenReasonAccountPrefix + string(user.Status)), nil
+	}
+
+	resp = &pb.VerifyTokenResponse{
+		Active:      true,
+		Subject:     claims.Subject,
+		Issuer:      claims.Issuer,
+		TokenId:     claims.ID,
+		Scopes:      claims.Scopes(),
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		IssuedAt:    timestamppb.New(claims.IssuedAt.Time),
+		ExpiresAt:   timestamppb.New(claims.ExpiresAt.Time),
+	}
+
+	return
+}
+
+func inactiveToken(reason string) *pb.VerifyTokenResponse {
+	return &pb.VerifyTokenResponse{
+		Active: false,
+		Reason: reason,
+	}
+}
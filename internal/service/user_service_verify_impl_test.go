@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	pb "github.com/itmrchow/todolist-proto/protobuf/user"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/itmrchow/todolist-user/internal/entity"
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/repository"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestVerifyTokenTestSuite(t *testing.T) {
+	suite.Run(t, new(VerifyTokenTestSuite))
+}
+
+type VerifyTokenTestSuite struct {
+	suite.Suite
+	userService          pb.UserServiceServer
+	mockUserRepo         *repository.MockUsersRepository
+	mockRefreshTokenRepo *repository.MockRefreshTokensRepository
+	mockRevokedTokenRepo *repository.MockRevokedTokensRepository
+	key                  *utils.SigningKey
+	userID               uuid.UUID
+	input                struct {
+		ctx context.Context
+		req *pb.VerifyTokenRequest
+	}
+}
+
+func (s *VerifyTokenTestSuite) SetupTest() {
+	s.mockUserRepo = repository.NewMockUsersRepository(s.T())
+	s.mockRefreshTokenRepo = repository.NewMockRefreshTokensRepository(s.T())
+	s.mockRevokedTokenRepo = repository.NewMockRevokedTokensRepository(s.T())
+	s.key = utils.NewHMACKey("", "secret")
+	s.userService = newTestUserService(s.T(), UserServiceDeps{
+		UserRepo:         s.mockUserRepo,
+		RefreshTokenRepo: s.mockRefreshTokenRepo,
+		RevokedTokenRepo: s.mockRevokedTokenRepo,
+		KeyRing:          newTestKeyRing(s.T(), s.key),
+	})
+	s.userID = uuid.New()
+
+	token, err := utils.GenerateToken(s.userID.String(), []string{"support"}, []string{PermissionUsersRead}, time.Now(), s.key, "", 1)
+	s.Require().NoError(err)
+
+	// the calling service
+	s.input.ctx = interceptor.ContextWithClaims(context.Background(), &utils.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()},
+		Permissions:      []string{PermissionTokensIntrospect},
+	})
+	s.input.req = &pb.VerifyTokenRequest{
+		Token: token,
+	}
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_Active() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(s.input.ctx, mock.Anything, s.userID, mock.Anything).Return(false, nil)
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.userID).Return(&entity.User{ID: s.userID}, nil)
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().True(resp.Active)
+	s.Assert().Equal(s.userID.String(), resp.Subject)
+	s.Assert().NotEmpty(resp.TokenId)
+	s.Assert().Equal([]string{"support"}, resp.Roles)
+	s.Assert().Equal([]string{PermissionUsersRead}, resp.Permissions)
+	s.Assert().Empty(resp.Reason)
+	s.Assert().WithinDuration(time.Now().Add(time.Hour), resp.ExpiresAt.AsTime(), 5*time.Second)
+	s.Assert().WithinDuration(time.Now(), resp.IssuedAt.AsTime(), 5*time.Second)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_CallerNotAllowed() {
+	tests := map[string]struct {
+		ctx  context.Context
+		code codes.Code
+		msg  string
+	}{
+		"no token": {context.Background(), codes.Unauthenticated, mErr.ErrMissingToken},
+		"no permission": {
+			interceptor.ContextWithClaims(context.Background(), &utils.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: uuid.NewString()},
+				Permissions:      []string{PermissionUsersRead},
+			}),
+			codes.PermissionDenied, mErr.ErrPermissionDenied,
+		},
+	}
+
+	for name, tt := range tests {
+		s.Run(name, func() {
+			// execute
+			resp, err := s.userService.VerifyToken(tt.ctx, s.input.req)
+
+			// assert , the token is not checked
+			s.Assert().Nil(resp)
+			s.Assert().Equal(tt.code, status.Code(err))
+			s.Assert().Equal(tt.msg, status.Convert(err).Message())
+		})
+	}
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_Invalid() {
+	// input
+	s.input.req.Token = "invalid_token"
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.Active)
+	s.Assert().Equal(TokenReasonInvalid, resp.Reason)
+	s.Assert().Empty(resp.Subject)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_WrongKey() {
+	// input
+	token, err := utils.GenerateToken(s.userID.String(), nil, nil, time.Now(), utils.NewHMACKey("", "other_secret"), "", 1)
+	s.Require().NoError(err)
+	s.input.req.Token = token
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.Active)
+	s.Assert().Equal(TokenReasonInvalid, resp.Reason)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_Expired() {
+	// input
+	token, err := utils.GenerateToken(s.userID.String(), nil, nil, time.Now(), s.key, "", -1)
+	s.Require().NoError(err)
+	s.input.req.Token = token
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.Active)
+	s.Assert().Equal(TokenReasonExpired, resp.Reason)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_NotUUIDSubject() {
+	// input
+	token, err := utils.GenerateToken("not_uuid", nil, nil, time.Now(), s.key, "", 1)
+	s.Require().NoError(err)
+	s.input.req.Token = token
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.Active)
+	s.Assert().Equal(TokenReasonInvalid, resp.Reason)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_Revoked() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(s.input.ctx, mock.Anything, s.userID, mock.Anything).Return(true, nil)
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.Active)
+	s.Assert().Equal(TokenReasonRevoked, resp.Reason)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_IsRevoked_DbError() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(s.input.ctx, mock.Anything, s.userID, mock.Anything).Return(false, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_UserDeleted() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(s.input.ctx, mock.Anything, s.userID, mock.Anything).Return(false, nil)
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.userID).Return(nil, gorm.ErrRecordNotFound)
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Require().NoError(err)
+	s.Assert().False(resp.Active)
+	s.Assert().Equal(TokenReasonUserNotFound, resp.Reason)
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_AccountStatus() {
+	tests := map[entity.UserStatus]string{
+		entity.UserStatusDisabled: TokenReasonAccountDisabled,
+		entity.UserStatusLocked:   TokenReasonAccountLocked,
+	}
+
+	for accountStatus, reason := range tests {
+		s.Run(string(accountStatus), func() {
+			// mock
+			s.mockRevokedTokenRepo.EXPECT().IsRevoked(s.input.ctx, mock.Anything, s.userID, mock.Anything).Return(false, nil).Once()
+			s.mockUserRepo.EXPECT().Get(s.input.ctx, s.userID).Return(&entity.User{ID: s.userID, Status: accountStatus}, nil).Once()
+
+			// execute
+			resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+			// assert
+			s.Require().NoError(err)
+			s.Assert().False(resp.Active)
+			s.Assert().Equal(reason, resp.Reason)
+		})
+	}
+}
+
+func (s *VerifyTokenTestSuite) Test_VerifyToken_GetUser_DbError() {
+	// mock
+	s.mockRevokedTokenRepo.EXPECT().IsRevoked(s.input.ctx, mock.Anything, s.userID, mock.Anything).Return(false, nil)
+	s.mockUserRepo.EXPECT().Get(s.input.ctx, s.userID).Return(nil, errors.New("db error"))
+
+	// execute
+	resp, err := s.userService.VerifyToken(s.input.ctx, s.input.req)
+
+	// assert
+	s.Assert().Nil(resp)
+	s.Assert().Equal(codes.Internal, status.Code(err))
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+// Validator collects the field violations of a request ,
+// Err returns them as one InvalidArgument status with google.rpc.BadRequest details.
+//
+//	v := validation.New()
+//	v.Email("email", req.Email, entity.UserEmailMaxLength)
+//	v.Length("name", req.Name, 1, entity.UserNameMaxLength)
+//	if err := v.Err(); err != nil {
+//		return nil, err
+//	}
+type Validator struct {
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+// AddViolation adds a violation of the field , field is the proto field name
+func (v *Validator) AddViolation(field string, description string) {
+	v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	})
+}
+
+// Check adds a violation when ok is false
+func (v *Validator) Check(ok bool, field string, description string) {
+	if !ok {
+		v.AddViolation(field, description)
+	}
+}
+
+// Required checks the value is not blank
+func (v *Validator) Required(field string, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "must not be empty")
+}
+
+// Length checks the value has min to max characters , not bytes , like the size of a varchar column
+func (v *Validator) Length(field string, value string, min int, max int) {
+	length := utf8.RuneCountInString(value)
+	switch {
+	case length < min && min == 1:
+		v.AddViolation(field, "must not be empty")
+	case length < min:
+		v.AddViolation(field, fmt.Sprintf("must be at least %d characters", min))
+	case length > max:
+		v.AddViolation(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// Email checks a bare RFC 5322 address , without display name or comments , of at most maxLength characters
+func (v *Validator) Email(field string, email string, maxLength int) {
+	if email == "" {
+		v.AddViolation(field, "must not be empty")
+		return
+	}
+	if utf8.RuneCountInString(email) > maxLength {
+		v.AddViolation(field, fmt.Sprintf("must be at most %d characters", maxLength))
+		return
+	}
+
+	v.Check(IsEmail(email), field, "must be a valid email address")
+}
+
+// CanonicalEmail checks the email like Email and returns its canonical form , see utils.NormalizeEmail.
+// The canonical form is empty when there is a violation.
+func (v *Validator) CanonicalEmail(field string, email string, maxLength int, lowercaseLocalPart bool) string {
+	canonical, err := utils.NormalizeEmail(email, lowercaseLocalPart)
+	if err != nil {
+		if strings.TrimSpace(email) == "" {
+			v.AddViolation(field, "must not be empty")
+		} else {
+			v.AddViolation(field, "must be a valid email address")
+		}
+		return ""
+	}
+
+	// checked after the normalization , an IDNA domain can be longer than the one the user typed
+	before := len(v.violations)
+	v.Email(field, canonical, maxLength)
+	if len(v.violations) > before {
+		return ""
+	}
+
+	return canonical
+}
+
+// Password checks the password against the policy
+func (v *Validator) Password(field string, password string, policy *utils.PasswordPolicy) {
+	if err := policy.Check(password); err != nil {
+		v.AddViolation(field, err.Error())
+	}
+}
+
+// Valid reports whether there is no violation
+func (v *Validator) Valid() bool {
+	return len(v.violations) == 0
+}
+
+// Err returns nil when there is no violation , otherwise an InvalidArgument status with the violations
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, mErr.ErrInvalidArgument)
+
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v.violations})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// IsEmail reports whether s is a bare email address like user@example.com
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return false
+	}
+
+	at := strings.LastIndexByte(s, '@')
+	domain := s[at+1:]
+
+	// a domain with at least one dot , no leading or trailing dot
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// FieldViolations returns the field violations in the details of err
+func FieldViolations(err error) []*errdetails.BadRequest_FieldViolation {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			return badRequest.GetFieldViolations()
+		}
+	}
+
+	return nil
+}
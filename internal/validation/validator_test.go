@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/itmrchow/todolist-user/utils"
+)
+
+func TestIsEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"test@example.com", true},
+		{"first.last+tag@sub.example.co", true},
+		{"", false},
+		{"test", false},
+		{"test@", false},
+		{"@example.com", false},
+		{"test@localhost", false},
+		{"test@.example.com", false},
+		{"test@example.com.", false},
+		{"Test <test@example.com>", false},
+		{"<test@example.com>", false},
+		{" test@example.com", false},
+		{"test@example.com\r\nBcc: other@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsEmail(tt.email))
+		})
+	}
+}
+
+func TestValidator_Valid(t *testing.T) {
+	v := New()
+	v.Required("token", "abc")
+	v.Length("name", "名字", 1, 2)
+	v.Email("email", "test@example.com", 255)
+	v.Password("password", "correct horse battery", utils.DefaultPasswordPolicy())
+
+	assert.True(t, v.Valid())
+	assert.NoError(t, v.Err())
+}
+
+func TestValidator_Err(t *testing.T) {
+	v := New()
+	v.Required("token", "  ")
+	v.Length("name", "", 1, 20)
+	v.Length("bio", strings.Repeat("a", 11), 0, 10)
+	v.Length("code", "12", 6, 6)
+	v.Email("email", strings.Repeat("a", 250)+"@example.com", 255)
+	v.Password("password", "short", utils.DefaultPasswordPolicy())
+	v.Check(false, "update_mask", `unknown field "email"`)
+
+	err := v.Err()
+
+	assert.False(t, v.Valid())
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid argument", status.Convert(err).Message())
+
+	violations := FieldViolations(err)
+	require.Len(t, violations, 7)
+
+	got := map[string]string{}
+	for _, violation := range violations {
+		got[violation.GetField()] = violation.GetDescription()
+	}
+	assert.Equal(t, map[string]string{
+		"token":       "must not be empty",
+		"name":        "must not be empty",
+		"bio":         "must be at most 10 characters",
+		"code":        "must be at least 6 characters",
+		"email":       "must be at most 255 characters",
+		"password":    "password is too short: must be at least 8 characters",
+		"update_mask": `unknown field "email"`,
+	}, got)
+}
+
+func TestFieldViolations_NoDetails(t *testing.T) {
+	assert.Nil(t, FieldViolations(nil))
+	assert.Nil(t, FieldViolations(status.Error(codes.InvalidArgument, "invalid argument")))
+}
+
+func TestValidator_CanonicalEmail(t *testing.T) {
+	v := New()
+
+	assert.Equal(t, "bob@xn--bcher-kva.de", v.CanonicalEmail("email", " Bob@Bücher.de ", 255, true))
+	assert.Equal(t, "Bob@example.com", v.CanonicalEmail("email", "Bob@EXAMPLE.com", 255, false))
+	assert.True(t, v.Valid())
+
+	assert.Empty(t, v.CanonicalEmail("empty", "  ", 255, true))
+	assert.Empty(t, v.CanonicalEmail("no_domain", "bob@", 255, true))
+	assert.Empty(t, v.CanonicalEmail("display_name", "Bob <bob@example.com>", 255, true))
+	// 20 runes typed , 27 characters after the IDNA conversion
+	assert.Empty(t, v.CanonicalEmail("long", "b@bücherbücherbü.de", 20, true))
+
+	got := map[string]string{}
+	for _, violation := range FieldViolations(v.Err()) {
+		got[violation.GetField()] = violation.GetDescription()
+	}
+	assert.Equal(t, map[string]string{
+		"empty":        "must not be empty",
+		"no_domain":    "must be a valid email address",
+		"display_name": "must be a valid email address",
+		"long":         "must be at most 20 characters",
+	}, got)
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrQueueFull  = errors.New("worker queue is full")
+	ErrPoolClosed = errors.New("worker pool is shut down")
+)
+
+// Pool runs the tasks an rpc doesn't wait for , e.g. sending emails , on a fixed number of goroutines.
+// The queue is bounded , a task over it is refused instead of piling up goroutines.
+// Shutdown runs the queued tasks before the server exits.
+type Pool struct {
+	tasks   chan func(ctx context.Context)
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPool starts workers goroutines taking the tasks from a queue of queueSize ,
+// each task gets a context that ends after timeout.
+func NewPool(workers int, queueSize int, timeout time.Duration) (*Pool, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("worker pool: workers must be at least 1 , got %d", workers)
+	}
+	if queueSize < 0 {
+		return nil, fmt.Errorf("worker pool: negative queue size %d", queueSize)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("worker pool: timeout must be positive , got %s", timeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		tasks:   make(chan func(ctx context.Context), queueSize),
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p, nil
+}
+
+// Submit queues the task , it never waits for a free worker.
+// It returns ErrQueueFull when the queue is full and ErrPoolClosed after Shutdown.
+func (p *Pool) Submit(task func(ctx context.Context)) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown refuses new tasks and waits until the queued and running tasks end.
+// When ctx ends first the contexts of the running tasks are canceled , the tasks still queued are dropped.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for task := range p.tasks {
+		p.run(task)
+	}
+}
+
+// run runs one task , a panic is logged and doesn't stop the worker
+func (p *Pool) run(task func(ctx context.Context)) {
+	if p.ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Bytes("stack", debug.Stack()).Msg("background task panic")
+		}
+	}()
+
+	task(ctx)
+}
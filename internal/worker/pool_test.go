@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_ShutdownRunsQueuedTasks(t *testing.T) {
+	pool, err := NewPool(1, 10, time.Second)
+	require.NoError(t, err)
+
+	var ran atomic.Int32
+	for i := 0; i < 10; i++ {
+		require.NoError(t, pool.Submit(func(ctx context.Context) {
+			time.Sleep(time.Millisecond)
+			ran.Add(1)
+		}))
+	}
+
+	require.NoError(t, pool.Shutdown(context.Background()))
+
+	assert.Equal(t, int32(10), ran.Load())
+	assert.ErrorIs(t, pool.Submit(func(ctx context.Context) {}), ErrPoolClosed)
+}
+
+func TestPool_QueueFull(t *testing.T) {
+	pool, err := NewPool(1, 1, time.Second)
+	require.NoError(t, err)
+
+	// the worker holds the first task , the second one fills the queue
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, pool.Submit(func(ctx context.Context) {
+		close(started)
+		<-release
+	}))
+	<-started
+	require.NoError(t, pool.Submit(func(ctx context.Context) {}))
+
+	assert.ErrorIs(t, pool.Submit(func(ctx context.Context) {}), ErrQueueFull)
+
+	close(release)
+	require.NoError(t, pool.Shutdown(context.Background()))
+}
+
+func TestPool_Panic(t *testing.T) {
+	pool, err := NewPool(1, 10, time.Second)
+	require.NoError(t, err)
+
+	var ran atomic.Bool
+	require.NoError(t, pool.Submit(func(ctx context.Context) { panic("boom") }))
+	require.NoError(t, pool.Submit(func(ctx context.Context) { ran.Store(true) }))
+
+	require.NoError(t, pool.Shutdown(context.Background()))
+
+	// the worker survives the panic
+	assert.True(t, ran.Load())
+}
+
+func TestPool_ShutdownTimeout(t *testing.T) {
+	pool, err := NewPool(1, 10, time.Minute)
+	require.NoError(t, err)
+
+	canceled := make(chan struct{})
+	require.NoError(t, pool.Submit(func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+
+	// the running task is told to stop
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("task context not canceled")
+	}
+}
+
+func TestNewPool_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		workers   int
+		queueSize int
+		timeout   time.Duration
+	}{
+		{"no workers", 0, 10, time.Second},
+		{"negative queue", 1, -1, time.Second},
+		{"no timeout", 1, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPool(tt.workers, tt.queueSize, tt.timeout)
+
+			assert.Error(t, err)
+		})
+	}
+}
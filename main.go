@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/itmrchow/todolist-proto/protobuf/user"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -11,9 +18,15 @@ import (
 	"google.golang.org/grpc/reflection"
 	"gorm.io/gorm"
 
+	"github.com/itmrchow/todolist-user/internal/handler"
 	"github.com/itmrchow/todolist-user/internal/infra"
+	"github.com/itmrchow/todolist-user/internal/interceptor"
+	"github.com/itmrchow/todolist-user/internal/limiter"
+	"github.com/itmrchow/todolist-user/internal/mailer"
 	"github.com/itmrchow/todolist-user/internal/repository"
 	"github.com/itmrchow/todolist-user/internal/service"
+	"github.com/itmrchow/todolist-user/internal/worker"
+	"github.com/itmrchow/todolist-user/utils"
 )
 
 func main() {
@@ -26,9 +39,61 @@ func main() {
 
 	// repo
 	repo := repository.NewUsersRepository(mysqlConn)
+	refreshTokenRepo := repository.NewRefreshTokensRepository(mysqlConn)
+	revokedTokenRepo := repository.NewRevokedTokensRepository(mysqlConn)
+	oneTimeTokenRepo := repository.NewOneTimeTokensRepository(mysqlConn)
+	mfaRepo := repository.NewMFARepository(mysqlConn)
+	webAuthnRepo := repository.NewWebAuthnRepository(mysqlConn)
+	roleRepo := repository.NewRolesRepository(mysqlConn)
+	syncPermissions(roleRepo)
+	go cleanRevokedTokens(revokedTokenRepo)
+	go cleanWebAuthnSessions(webAuthnRepo)
+	go purgeDeletedUsers(repo)
+
+	// mail
+	mailer := initMailer()
+	mailWorkers := initMailWorkerPool()
+
+	// password policy
+	passwordPolicy := initPasswordPolicy()
+
+	// login brute force protection
+	loginLimiter := initLoginLimiter(mysqlConn)
+	go cleanLoginAttempts(loginLimiter)
+
+	// two factor authentication
+	secretBox := initMFASecretBox()
+
+	// passkey
+	webAuthn := initWebAuthn()
+
+	// jwt keys
+	keyRing := initKeyRing()
+	go watchKeyRing(keyRing)
+
+	// jwks
+	go RunJwksHandler(keyRing)
 
 	// grpc
-	log.Fatal().Err(RunGrpcHandler(repo)).Msg("failed to listen")
+	deps := service.UserServiceDeps{
+		UserRepo:         repo,
+		RefreshTokenRepo: refreshTokenRepo,
+		RevokedTokenRepo: revokedTokenRepo,
+		OneTimeTokenRepo: oneTimeTokenRepo,
+		Mailer:           mailer,
+		MailWorkers:      mailWorkers,
+		KeyRing:          keyRing,
+		PasswordPolicy:   passwordPolicy,
+		LoginLimiter:     loginLimiter,
+		MFARepo:          mfaRepo,
+		SecretBox:        secretBox,
+		WebAuthnRepo:     webAuthnRepo,
+		WebAuthn:         webAuthn,
+		RoleRepo:         roleRepo,
+	}
+	if err := RunGrpcHandler(deps); err != nil {
+		log.Fatal().Err(err).Msg("failed to serve")
+	}
 }
 
 func initConfig() {
@@ -52,10 +117,261 @@ func initMysqlDb() *gorm.DB {
 	return db
 }
 
-func RunGrpcHandler(userRepo repository.UsersRepository) (err error) {
+// syncPermissions writes the permissions of the rpcs into the permissions table
+func syncPermissions(roleRepo repository.RolesRepository) {
+	if err := roleRepo.SyncPermissions(context.Background(), service.Permissions); err != nil {
+		log.Fatal().Err(err).Msg("failed to sync permissions")
+	}
+
+	log.Info().Int("count", len(service.Permissions)).Msg("permissions synced")
+}
+
+func initMailer() mailer.Mailer {
+	m, err := infra.InitMailer()
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init mailer")
+	}
+
+	log.Info().Str("driver", viper.GetString("mail_driver")).Msg("mailer initialized")
+
+	return m
+}
+
+func initMailWorkerPool() *worker.Pool {
+	pool, err := infra.InitMailWorkerPool()
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init mail workers")
+	}
+
+	log.Info().Int("workers", viper.GetInt("mail_workers")).Msg("mail workers started")
+
+	return pool
+}
+
+func initPasswordPolicy() *utils.PasswordPolicy {
+	policy, err := infra.InitPasswordPolicy()
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init password policy")
+	}
+
+	log.Info().
+		Int("min_length", policy.MinLength).
+		Int("max_length", policy.MaxLength).
+		Strs("required_classes", policy.RequiredClasses).
+		Msg("password policy loaded")
+
+	return policy
+}
+
+func initLoginLimiter(db *gorm.DB) *limiter.LoginLimiter {
+	loginLimiter, err := infra.InitLoginLimiter(db)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init login limiter")
+	}
+
+	log.Info().Str("store", viper.GetString("login_limit_store")).Msg("login limiter initialized")
+
+	return loginLimiter
+}
+
+func initMFASecretBox() *utils.SecretBox {
+	secretBox, err := infra.InitMFASecretBox()
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init mfa encryption key")
+	}
+
+	if secretBox == nil {
+		log.Warn().Msg("mfa_encryption_key is not set , two factor authentication is disabled")
+	}
+
+	return secretBox
+}
+
+func initWebAuthn() *webauthn.WebAuthn {
+	webAuthn, err := infra.InitWebAuthn()
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init webauthn")
+	}
+
+	if webAuthn == nil {
+		log.Warn().Msg("webauthn_rp_id is not set , passkeys are disabled")
+		return nil
+	}
+
+	log.Info().
+		Str("rp_id", webAuthn.Config.RPID).
+		Strs("rp_origins", webAuthn.Config.RPOrigins).
+		Msg("webauthn initialized")
+
+	return webAuthn
+}
+
+func initKeyRing() *utils.KeyRing {
+	keyRing, err := infra.InitKeyRing()
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init jwt keys")
+	}
+
+	logKeyRing(keyRing)
+
+	return keyRing
+}
+
+// watchKeyRing reloads the jwt keys every jwt_key_reload_interval and on SIGHUP ,
+// a failed reload keeps the current keys
+func watchKeyRing(keyRing *utils.KeyRing) {
 
 	var (
-		grpcPort = viper.GetString("server_port")
+		reloadInterval = viper.GetDuration("jwt_key_reload_interval")
+	)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if reloadInterval > 0 {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+		case <-tick:
+		}
+
+		if err := infra.ReloadKeyRing(keyRing); err != nil {
+			log.Error().Err(err).Msg("failed to reload jwt keys")
+			continue
+		}
+
+		logKeyRing(keyRing)
+	}
+}
+
+func logKeyRing(keyRing *utils.KeyRing) {
+	kids := []string{}
+	for _, key := range keyRing.VerificationKeys() {
+		kids = append(kids, key.ID)
+	}
+
+	log.Info().
+		Str("kid", keyRing.SigningKey().ID).
+		Str("alg", keyRing.SigningKey().Method.Alg()).
+		Strs("verification_kids", kids).
+		Msg("jwt keys loaded")
+}
+
+// RunJwksHandler serves the public signing keys over http , disabled when jwks_port is empty
+func RunJwksHandler(keyRing *utils.KeyRing) {
+
+	var (
+		jwksPort = viper.GetString("jwks_port")
+	)
+
+	if jwksPort == "" {
+		return
+	}
+
+	log.Info().Msg("jwks http server listen in port:" + jwksPort)
+
+	mux := http.NewServeMux()
+	mux.Handle(handler.JwksPath, handler.NewJwksHandler(keyRing))
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%s", jwksPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	if err := server.ListenAndServe(); err != nil {
+		log.Error().Err(err).Msg("jwks http server error")
+	}
+}
+
+// cleanRevokedTokens deletes the revoked tokens that are expired anyway
+func cleanRevokedTokens(revokedTokenRepo repository.RevokedTokensRepository) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := revokedTokenRepo.DeleteExpired(context.Background(), time.Now()); err != nil {
+			log.Error().Err(err).Msg("failed to delete expired revoked tokens")
+		}
+	}
+}
+
+// cleanLoginAttempts deletes the failed logins that don't count anymore
+func cleanLoginAttempts(loginLimiter *limiter.LoginLimiter) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := loginLimiter.Clean(context.Background()); err != nil {
+			log.Error().Err(err).Msg("failed to delete expired login attempts")
+		}
+	}
+}
+
+// cleanWebAuthnSessions deletes the passkey ceremonies that were never finished
+func cleanWebAuthnSessions(webAuthnRepo repository.WebAuthnRepository) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := webAuthnRepo.DeleteExpiredSessions(context.Background(), time.Now()); err != nil {
+			log.Error().Err(err).Msg("failed to delete expired webauthn sessions")
+		}
+	}
+}
+
+// purgeDeletedUsers hard deletes the users deleted more than account_deletion_grace_period ago
+func purgeDeletedUsers(userRepo repository.UsersRepository) {
+
+	var (
+		gracePeriod = viper.GetDuration("account_deletion_grace_period")
+	)
+
+	const batchSize = 100
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for {
+			purged, err := userRepo.PurgeDeleted(context.Background(), time.Now().Add(-gracePeriod), batchSize)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to purge deleted users")
+				break
+			}
+
+			if purged > 0 {
+				log.Info().Int64("count", purged).Msg("deleted users purged")
+			}
+
+			if purged < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// RunGrpcHandler serves the user service , the interceptors share the repositories and keys of deps.
+// On SIGINT or SIGTERM it stops taking calls , waits for the running ones and sends the queued emails ,
+// for at most SHUTDOWN_TIMEOUT.
+func RunGrpcHandler(deps service.UserServiceDeps) (err error) {
+
+	var (
+		grpcPort      = viper.GetString("server_port")
+		publicMethods = viper.GetStringSlice("auth_public_methods")
 	)
 
 	log.Info().Msg("grpc server listen in port:" + grpcPort)
@@ -65,22 +381,95 @@ func RunGrpcHandler(userRepo repository.UsersRepository) (err error) {
 		log.Fatal().Err(err).Msg("failed to listen")
 		return
 	}
+
+	// interceptor
+	authInterceptor := interceptor.NewAuthInterceptor(
+		deps.KeyRing,
+		viper.GetString("server_name"),
+		deps.RevokedTokenRepo,
+		deps.UserRepo,
+		publicMethods,
+	)
+
+	permissionInterceptor := interceptor.NewPermissionInterceptor(service.MethodPermissions, viper.GetStringSlice("auth_admin_user_ids"))
+
+	clientIPInterceptor, err := interceptor.NewClientIPInterceptor(viper.GetStringSlice("trusted_proxies"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid trusted proxies")
+		return
+	}
+
+	rateLimitInterceptor, err := infra.InitRateLimitInterceptor(publicMethods)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid rate limits")
+		return
+	}
+
+	// the rate limits come before auth , so the rejected calls don't look up the revocations and the account status
 	opts := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(
-		// TODO: add interceptor
+			clientIPInterceptor.Unary(),
+			rateLimitInterceptor.Unary(),
+			authInterceptor.Unary(),
+			permissionInterceptor.Unary(),
+		),
+		grpc.ChainStreamInterceptor(
+			clientIPInterceptor.Stream(),
+			rateLimitInterceptor.Stream(),
+			authInterceptor.Stream(),
+			permissionInterceptor.Stream(),
 		),
 	}
 
 	// user service impl
-	userService := service.NewUserService(userRepo)
+	userService, err := service.NewUserService(deps)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to init user service")
+		return
+	}
 
 	s := grpc.NewServer(opts...)
 	user.RegisterUserServiceServer(s, userService)
 
 	reflection.Register(s)
-	err = s.Serve(lis)
 
-	return err
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(lis)
+	}()
+
+	select {
+	case err = <-served:
+		return err
+	case <-stop.Done():
+	}
+
+	log.Info().Msg("shutting down")
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), viper.GetDuration("shutdown_timeout"))
+	defer cancelTimeout()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Stop()
+	}
+
+	// the emails still queued after the timeout are not sent
+	if err := deps.MailWorkers.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("mail workers shutdown error")
+	}
+
+	return nil
 
 	// user.RegisterUserServiceServer(grpcServer, &service.UserServiceImpl{})
 
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// NormalizeEmail returns the canonical form of an email , two emails of the same mailbox have the same canonical form.
+// It trims the spaces , converts the domain to lowercase ASCII (IDNA , bücher.de becomes xn--bcher-kva.de)
+// and lowercases the local part when lowercaseLocalPart is true.
+// It doesn't check the syntax of the local part , use it together with validation.Validator.Email.
+func NormalizeEmail(email string, lowercaseLocalPart bool) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	local, domain := email[:at], email[at+1:]
+
+	// Lookup maps the domain to lowercase before the conversion
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidEmail, err)
+	}
+
+	if lowercaseLocalPart {
+		local = strings.ToLower(local)
+	}
+
+	return local + "@" + strings.ToLower(domain), nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name               string
+		email              string
+		lowercaseLocalPart bool
+		want               string
+	}{
+		{"already canonical", "bob@example.com", true, "bob@example.com"},
+		{"spaces", "  bob@example.com \t", true, "bob@example.com"},
+		{"uppercase", "Bob@X.COM", true, "bob@x.com"},
+		{"keep local part case", "Bob@X.COM", false, "Bob@x.com"},
+		{"idn domain", "bob@Bücher.de", true, "bob@xn--bcher-kva.de"},
+		{"punycode domain", "bob@xn--bcher-kva.de", true, "bob@xn--bcher-kva.de"},
+		{"at in quoted local part", `"bob@home"@example.com`, true, `"bob@home"@example.com`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.email, tt.lowercaseLocalPart)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalizeEmail_Invalid(t *testing.T) {
+	for _, email := range []string{"", "   ", "bob", "@example.com", "bob@", "bob@exa mple.com", "bob@-example.com"} {
+		t.Run(email, func(t *testing.T) {
+			_, err := NormalizeEmail(email, true)
+
+			assert.ErrorIs(t, err, ErrInvalidEmail)
+		})
+	}
+}
@@ -1,64 +1,176 @@
 package utils
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	mErr "github.com/itmrchow/todolist-user/internal/errors"
 )
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID string, secretKey string, issuer string, expireAt int) (tokenStr string, err error) {
+// tokenLeeway is the allowed clock skew when validating time based claims
+const tokenLeeway = 5 * time.Second
+
+// token purposes other than access
+const (
+	PurposeEmailVerification = "email_verification"
+	// PurposeMFA is the challenge of a login that still needs the second factor
+	PurposeMFA = "mfa"
+)
+
+// Claims are the claims of an access token , or of a purpose token
+type Claims struct {
+	jwt.RegisteredClaims
+	// Scope is a space separated list , RFC 8693
+	Scope string `json:"scope,omitempty"`
+	// Purpose is only set on tokens that are not access tokens , e.g. email verification
+	Purpose string `json:"purpose,omitempty"`
+	// Email is the email the purpose token was issued for
+	Email string `json:"email,omitempty"`
+	// Roles are the role names of the user when the access token was issued
+	Roles []string `json:"roles,omitempty"`
+	// Permissions are the permissions of the roles , other services can authorize with them without a call
+	Permissions []string `json:"permissions,omitempty"`
+	// AuthTime is when the user entered the credentials , RefreshToken keeps it , OIDC auth_time
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+}
+
+// Scopes returns the scopes of the token
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasPermission reports whether the token grants the permission
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+// GenerateToken generates a JWT token for a user with the roles and permissions of the user ,
+// signed by key with its kid in the header.
+// Every token has a unique jti so it can be revoked alone. A zero authTime leaves auth_time out.
+func GenerateToken(userID string, roles []string, permissions []string, authTime time.Time, key *SigningKey, issuer string, expireAt int) (tokenStr string, err error) {
 	now := time.Now()
 
-	registeredClaims := jwt.RegisteredClaims{
-		IssuedAt:  jwt.NewNumericDate(now),
-		NotBefore: jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour * time.Duration(expireAt))),
-		Issuer:    issuer,
-		Subject:   userID,
-		Audience:  []string{userID},
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour * time.Duration(expireAt))),
+			Issuer:    issuer,
+			Subject:   userID,
+			Audience:  []string{userID},
+			ID:        uuid.NewString(),
+		},
+		Roles:       roles,
+		Permissions: permissions,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, registeredClaims)
+	return signToken(claims, key)
+}
+
+// GeneratePurposeToken generates a short lived token that can only be used for purpose , not as access token.
+// The email is put in the token so it becomes invalid when the user changes the email.
+func GeneratePurposeToken(userID string, purpose string, email string, key *SigningKey, issuer string, expiresIn time.Duration) (tokenStr string, err error) {
+	now := time.Now()
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Issuer:    issuer,
+			Subject:   userID,
+			ID:        uuid.NewString(),
+		},
+		Purpose: purpose,
+		Email:   email,
+	}
 
-	tokenStr, err = token.SignedString([]byte(secretKey))
+	return signToken(claims, key)
+}
+
+func signToken(claims *Claims, key *SigningKey) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	return token.SignedString(key.PrivateKey)
+}
+
+// ValidateToken validates a JWT token and returns the user id in its subject
+func ValidateToken(tokenStr string, keys KeyStore, issuer string) (userID string, err error) {
+	claims, err := ParseToken(tokenStr, keys, issuer)
 	if err != nil {
 		return "", err
 	}
 
-	return
+	return claims.Subject, nil
 }
 
-// ValidateToken validates a JWT token
-func ValidateToken(tokenStr string, secretKey string, issuer string) (userID string, err error) {
+// ParseToken validates an access token and returns its claims.
+// The key is looked up by the kid header , and only the algorithm of that key is accepted.
+// The error is mErr.Err401TokenExpired when the token is expired , otherwise mErr.Err401Unauthorized.
+func ParseToken(tokenStr string, keys KeyStore, issuer string) (claims *Claims, err error) {
+	return parseToken(tokenStr, keys, issuer, "")
+}
 
-	if tokenStr == "" {
-		return "", &mErr.Err401Unauthorized
+// ParsePurposeToken validates a token made by GeneratePurposeToken for purpose and returns its claims
+func ParsePurposeToken(tokenStr string, keys KeyStore, issuer string, purpose string) (claims *Claims, err error) {
+	if purpose == "" {
+		return nil, &mErr.Err401Unauthorized
 	}
 
-	// parse token
-	tokenClaims, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	}, jwt.WithLeeway(5*time.Second))
+	return parseToken(tokenStr, keys, issuer, purpose)
+}
 
-	if err != nil {
-		return
-	}
+// parseToken validates a token , an empty purpose is an access token
+func parseToken(tokenStr string, keys KeyStore, issuer string, purpose string) (claims *Claims, err error) {
 
-	claims, ok := tokenClaims.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return "", &mErr.Err401Unauthorized
+	if tokenStr == "" {
+		return nil, &mErr.Err401Unauthorized
 	}
 
-	if claims.Subject == "" {
-		return "", &mErr.Err401Unauthorized
+	// parse token
+	claims = &Claims{}
+	_, err = jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := keys.VerificationKey(kid)
+		if !ok {
+			return nil, errors.New("unknown kid")
+		}
+
+		// pin the algorithm to the key , jwt.WithValidMethods can't know the key yet
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+
+		return key.PublicKey, nil
+	},
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(tokenLeeway),
+	)
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, &mErr.Err401TokenExpired
+		}
+		return nil, &mErr.Err401Unauthorized
 	}
 
-	if claims.Issuer != issuer {
-		return "", &mErr.Err401Unauthorized
+	// a purpose token is never an access token , nor the other way round
+	if claims.Subject == "" || claims.Purpose != purpose {
+		return nil, &mErr.Err401Unauthorized
 	}
 
-	return
+	return claims, nil
 }
@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported signing key")
+
+// SigningKey is a key that signs and verifies tokens.
+// For HMAC both PrivateKey and PublicKey are the secret , for asymmetric keys only
+// PublicKey is needed to verify a token.
+// A key with RetireAfter set no longer verifies tokens after that time.
+type SigningKey struct {
+	ID          string
+	Method      jwt.SigningMethod
+	PrivateKey  any
+	PublicKey   any
+	RetireAfter time.Time
+}
+
+// NewHMACKey creates a HS512 key from a shared secret
+func NewHMACKey(kid string, secretKey string) *SigningKey {
+	return &SigningKey{
+		ID:         kid,
+		Method:     jwt.SigningMethodHS512,
+		PrivateKey: []byte(secretKey),
+		PublicKey:  []byte(secretKey),
+	}
+}
+
+// ParsePrivateKeyPEM creates a key from a PEM encoded RSA , ECDSA or Ed25519 private key.
+// The signing method is RS256 for RSA , ES256/ES384/ES512 for the P-256/P-384/P-521 curves and EdDSA for Ed25519.
+// When kid is empty the RFC 7638 thumbprint of the public key is used.
+func ParsePrivateKeyPEM(kid string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var (
+		privateKey any
+		err        error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPrivateKey(kid, privateKey)
+}
+
+// ParseKeyPEM creates a key from a PEM encoded private key , or from a PKIX public key
+// which can only verify tokens
+func ParseKeyPEM(kid string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if block.Type != "PUBLIC KEY" {
+		return ParsePrivateKeyPEM(kid, pemBytes)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPublicKey(kid, publicKey)
+}
+
+// NewPrivateKey creates a key from a *rsa.PrivateKey , *ecdsa.PrivateKey or ed25519.PrivateKey
+func NewPrivateKey(kid string, privateKey any) (*SigningKey, error) {
+	key := &SigningKey{
+		ID:         kid,
+		PrivateKey: privateKey,
+	}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.PublicKey = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		method, err := ecdsaSigningMethod(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		key.Method = method
+		key.PublicKey = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.PublicKey = k.Public()
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, privateKey)
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+
+	return key, nil
+}
+
+// NewPublicKey creates a verify only key from a *rsa.PublicKey , *ecdsa.PublicKey or ed25519.PublicKey
+func NewPublicKey(kid string, publicKey any) (*SigningKey, error) {
+	key := &SigningKey{
+		ID:        kid,
+		PublicKey: publicKey,
+	}
+
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		method, err := ecdsaSigningMethod(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		key.Method = method
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, publicKey)
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+
+	return key, nil
+}
+
+func ecdsaSigningMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, fmt.Errorf("%w: ecdsa curve %s", ErrUnsupportedKey, curve.Params().Name)
+	}
+}
+
+// IsSymmetric reports whether the key is a shared secret which must not be published
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// JWK is a public key in the RFC 7517 JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC , OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a RFC 7517 JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public part of the key , symmetric keys can't be published
+func (k *SigningKey) JWK() (JWK, error) {
+	jwk := JWK{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, k.PublicKey)
+	}
+
+	return jwk, nil
+}
+
+// Thumbprint returns the RFC 7638 JWK thumbprint of the public key
+func (k *SigningKey) Thumbprint() (string, error) {
+	jwk, err := k.JWK()
+	if err != nil {
+		return "", err
+	}
+
+	// required members only , in lexicographic order
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewJWKS returns the public keys as a JSON Web Key Set , symmetric keys are skipped
+func NewJWKS(keys ...*SigningKey) JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range keys {
+		if key == nil || key.IsSymmetric() {
+			continue
+		}
+
+		jwk, err := key.JWK()
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
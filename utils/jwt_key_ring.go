@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyStore finds the key that verifies a token by the kid in its header
+type KeyStore interface {
+	VerificationKey(kid string) (*SigningKey, bool)
+}
+
+var (
+	_ KeyStore = &SigningKey{}
+	_ KeyStore = &KeyRing{}
+)
+
+// VerificationKey makes a single key a KeyStore , the kid must match unless one of them is empty
+func (k *SigningKey) VerificationKey(kid string) (*SigningKey, bool) {
+	if kid != "" && k.ID != "" && kid != k.ID {
+		return nil, false
+	}
+
+	return k, true
+}
+
+// IsRetired reports whether the key should no longer verify tokens
+func (k *SigningKey) IsRetired(now time.Time) bool {
+	return !k.RetireAfter.IsZero() && now.After(k.RetireAfter)
+}
+
+// KeyRing holds the current signing key and the older keys that still verify tokens.
+// It is safe for concurrent use and can be replaced at runtime when the keys are rotated.
+type KeyRing struct {
+	mu      sync.RWMutex
+	current *SigningKey
+	keys    map[string]*SigningKey
+	now     func() time.Time
+}
+
+// NewKeyRing creates a KeyRing , current signs new tokens and verifyKeys only verify
+func NewKeyRing(current *SigningKey, verifyKeys ...*SigningKey) (*KeyRing, error) {
+	ring := &KeyRing{
+		now: time.Now,
+	}
+
+	if err := ring.Replace(current, verifyKeys...); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// Replace swaps all keys of the ring at once
+func (r *KeyRing) Replace(current *SigningKey, verifyKeys ...*SigningKey) error {
+	if current == nil {
+		return errors.New("key ring: no current signing key")
+	}
+	if current.PrivateKey == nil {
+		return fmt.Errorf("key ring: current key %q has no private key", current.ID)
+	}
+	if current.IsRetired(r.now()) {
+		return fmt.Errorf("key ring: current key %q is retired", current.ID)
+	}
+
+	keys := make(map[string]*SigningKey, len(verifyKeys)+1)
+	keys[current.ID] = current
+
+	for _, key := range verifyKeys {
+		if _, ok := keys[key.ID]; ok {
+			return fmt.Errorf("key ring: duplicate kid %q", key.ID)
+		}
+		keys[key.ID] = key
+	}
+
+	if len(keys) > 1 && current.ID == "" {
+		return errors.New("key ring: the current key needs a kid when there is more than one key")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current = current
+	r.keys = keys
+
+	return nil
+}
+
+// SigningKey returns the key that signs new tokens
+func (r *KeyRing) SigningKey() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current
+}
+
+// VerificationKey returns the not retired key with the kid.
+// A token without kid is verified by the key without kid , e.g. the secret used before rotation was set up.
+func (r *KeyRing) VerificationKey(kid string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[kid]
+	if !ok || key.IsRetired(r.now()) {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// VerificationKeys returns all not retired keys ordered by kid , the current key included
+func (r *KeyRing) VerificationKeys() []*SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.IsRetired(now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
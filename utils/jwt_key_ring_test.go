@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+)
+
+func newTestEd25519Key(t *testing.T, kid string) *SigningKey {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key, err := NewPrivateKey(kid, privateKey)
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+
+	oldKey := newTestEd25519Key(t, "old")
+	newKey := newTestEd25519Key(t, "new")
+
+	ring, err := NewKeyRing(oldKey)
+	require.NoError(t, err)
+
+	oldToken, err := GenerateToken(testUserID, nil, nil, time.Now(), ring.SigningKey(), testIssuer, 1)
+	require.NoError(t, err)
+
+	// rotate , the old key only verifies
+	require.NoError(t, ring.Replace(newKey, verifyOnly(oldKey)))
+	assert.Equal(t, "new", ring.SigningKey().ID)
+
+	newToken, err := GenerateToken(testUserID, nil, nil, time.Now(), ring.SigningKey(), testIssuer, 1)
+	require.NoError(t, err)
+
+	for _, tokenStr := range []string{oldToken, newToken} {
+		userID, err := ValidateToken(tokenStr, ring, testIssuer)
+		assert.NoError(t, err)
+		assert.Equal(t, testUserID, userID)
+	}
+
+	// drop the old key
+	require.NoError(t, ring.Replace(newKey))
+
+	_, err = ValidateToken(oldToken, ring, testIssuer)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+
+	_, err = ValidateToken(newToken, ring, testIssuer)
+	assert.NoError(t, err)
+}
+
+func TestKeyRing_RetireAfter(t *testing.T) {
+
+	now := time.Now()
+
+	oldKey := newTestEd25519Key(t, "old")
+	oldKey.RetireAfter = now.Add(time.Minute)
+	newKey := newTestEd25519Key(t, "new")
+
+	ring, err := NewKeyRing(newKey, oldKey)
+	require.NoError(t, err)
+	ring.now = func() time.Time { return now }
+
+	oldToken, err := GenerateToken(testUserID, nil, nil, time.Now(), oldKey, testIssuer, 1)
+	require.NoError(t, err)
+
+	_, err = ValidateToken(oldToken, ring, testIssuer)
+	assert.NoError(t, err)
+	assert.Len(t, ring.VerificationKeys(), 2)
+
+	// after retire_after
+	ring.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+	_, err = ValidateToken(oldToken, ring, testIssuer)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+
+	keys := ring.VerificationKeys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, "new", keys[0].ID)
+}
+
+func TestKeyRing_TokenWithoutKid(t *testing.T) {
+
+	// the secret used before the rotation was set up , tokens have no kid
+	legacyKey := NewHMACKey("", testSecretKey)
+	legacyToken, err := GenerateToken(testUserID, nil, nil, time.Now(), legacyKey, testIssuer, 1)
+	require.NoError(t, err)
+
+	ring, err := NewKeyRing(newTestEd25519Key(t, "new"), legacyKey)
+	require.NoError(t, err)
+
+	userID, err := ValidateToken(legacyToken, ring, testIssuer)
+	assert.NoError(t, err)
+	assert.Equal(t, testUserID, userID)
+
+	// not published
+	assert.Len(t, NewJWKS(ring.VerificationKeys()...).Keys, 1)
+}
+
+func TestKeyRing_WrongAlgorithmForKid(t *testing.T) {
+
+	edKey := newTestEd25519Key(t, "ed")
+	hmacKey := NewHMACKey("hmac", testSecretKey)
+
+	ring, err := NewKeyRing(edKey, hmacKey)
+	require.NoError(t, err)
+
+	// HS512 token that claims the kid of the ed25519 key
+	forged, err := GenerateToken(testUserID, nil, nil, time.Now(), NewHMACKey("ed", testSecretKey), testIssuer, 1)
+	require.NoError(t, err)
+
+	_, err = ValidateToken(forged, ring, testIssuer)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+}
+
+func TestKeyRing_Replace_Invalid(t *testing.T) {
+
+	key := newTestEd25519Key(t, "key")
+
+	retired := newTestEd25519Key(t, "retired")
+	retired.RetireAfter = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		current    *SigningKey
+		verifyKeys []*SigningKey
+	}{
+		{name: "no current key", current: nil},
+		{name: "current key without private key", current: verifyOnly(key)},
+		{name: "current key retired", current: retired},
+		{name: "duplicate kid", current: key, verifyKeys: []*SigningKey{verifyOnly(key)}},
+		{name: "current key without kid", current: NewHMACKey("", testSecretKey), verifyKeys: []*SigningKey{key}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyRing(key)
+			require.NoError(t, err)
+
+			err = ring.Replace(tt.current, tt.verifyKeys...)
+
+			assert.Error(t, err)
+			// the keys are kept
+			assert.Equal(t, key, ring.SigningKey())
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+)
+
+func newTestPrivateKeys(t *testing.T) map[string]any {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return map[string]any{
+		"RS256": rsaKey,
+		"ES256": ecKey,
+		"EdDSA": edKey,
+	}
+}
+
+// verifyOnly drops the private key , like a downstream service that only has the jwks
+func verifyOnly(key *SigningKey) *SigningKey {
+	return &SigningKey{
+		ID:        key.ID,
+		Method:    key.Method,
+		PublicKey: key.PublicKey,
+	}
+}
+
+func TestAsymmetricKey_GenerateAndValidate(t *testing.T) {
+
+	for alg, privateKey := range newTestPrivateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewPrivateKey("", privateKey)
+			require.NoError(t, err)
+			assert.Equal(t, alg, key.Method.Alg())
+			assert.NotEmpty(t, key.ID)
+			assert.False(t, key.IsSymmetric())
+
+			tokenStr, err := GenerateToken(testUserID, nil, nil, time.Now(), key, testIssuer, 1)
+			require.NoError(t, err)
+
+			// kid header
+			token, _, err := jwt.NewParser().ParseUnverified(tokenStr, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, token.Header["kid"])
+			assert.Equal(t, alg, token.Header["alg"])
+
+			// verify with the public key only
+			userID, err := ValidateToken(tokenStr, verifyOnly(key), testIssuer)
+			assert.NoError(t, err)
+			assert.Equal(t, testUserID, userID)
+
+			// unknown kid
+			otherKid := verifyOnly(key)
+			otherKid.ID = "other"
+			_, err = ValidateToken(tokenStr, otherKid, testIssuer)
+			assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+		})
+	}
+}
+
+func TestAsymmetricKey_WrongAlgorithm(t *testing.T) {
+
+	keys := newTestPrivateKeys(t)
+
+	rsaKey, err := NewPrivateKey("kid", keys["RS256"])
+	require.NoError(t, err)
+	ecKey, err := NewPrivateKey("kid", keys["ES256"])
+	require.NoError(t, err)
+
+	tokenStr, err := GenerateToken(testUserID, nil, nil, time.Now(), rsaKey, testIssuer, 1)
+	require.NoError(t, err)
+
+	_, err = ValidateToken(tokenStr, verifyOnly(ecKey), testIssuer)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+
+	// HS256 signed with the public key bytes must not pass as RS256
+	publicDer, err := x509.MarshalPKIXPublicKey(rsaKey.PublicKey)
+	require.NoError(t, err)
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+	confused := signTestToken(t, jwt.SigningMethodHS256, publicPem, jwt.RegisteredClaims{
+		Issuer:    testIssuer,
+		Subject:   testUserID,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	_, err = ValidateToken(confused, verifyOnly(rsaKey), testIssuer)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+
+	keys := newTestPrivateKeys(t)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(keys["RS256"].(*rsa.PrivateKey)),
+	})
+
+	sec1Der, err := x509.MarshalECPrivateKey(keys["ES256"].(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	sec1 := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1Der})
+
+	pkcs8Der, err := x509.MarshalPKCS8PrivateKey(keys["EdDSA"])
+	require.NoError(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Der})
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantAlg string
+		wantErr bool
+	}{
+		{name: "pkcs1 rsa", pem: pkcs1, wantAlg: "RS256"},
+		{name: "sec1 ecdsa", pem: sec1, wantAlg: "ES256"},
+		{name: "pkcs8 ed25519", pem: pkcs8, wantAlg: "EdDSA"},
+		{name: "not pem", pem: []byte("secret"), wantErr: true},
+		{name: "public key", pem: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte{1}}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePrivateKeyPEM("kid", tt.pem)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "kid", key.ID)
+			assert.Equal(t, tt.wantAlg, key.Method.Alg())
+		})
+	}
+}
+
+func TestNewJWKS(t *testing.T) {
+
+	keys := newTestPrivateKeys(t)
+
+	rsaKey, err := NewPrivateKey("rsa", keys["RS256"])
+	require.NoError(t, err)
+	ecKey, err := NewPrivateKey("ec", keys["ES256"])
+	require.NoError(t, err)
+	edKey, err := NewPrivateKey("ed", keys["EdDSA"])
+	require.NoError(t, err)
+
+	jwks := NewJWKS(testKey, rsaKey, ecKey, edKey)
+
+	// the hmac secret is never published
+	require.Len(t, jwks.Keys, 3)
+
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(string(data), testSecretKey))
+
+	assert.Equal(t, JWK{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: "RS256", N: jwks.Keys[0].N, E: "AQAB"}, jwks.Keys[0])
+	assert.Equal(t, "EC", jwks.Keys[1].Kty)
+	assert.Equal(t, "P-256", jwks.Keys[1].Crv)
+	assert.Len(t, jwks.Keys[1].X, 43)
+	assert.Len(t, jwks.Keys[1].Y, 43)
+	assert.Equal(t, "OKP", jwks.Keys[2].Kty)
+	assert.Equal(t, "Ed25519", jwks.Keys[2].Crv)
+}
+
+func TestSigningKey_Thumbprint(t *testing.T) {
+
+	// RFC 8037 appendix A.3
+	publicKey := ed25519.PublicKey{
+		0xd7, 0x5a, 0x98, 0x01, 0x82, 0xb1, 0x0a, 0xb7, 0xd5, 0x4b, 0xfe, 0xd3, 0xc9, 0x64, 0x07, 0x3a,
+		0x0e, 0xe1, 0x72, 0xf3, 0xda, 0xa6, 0x23, 0x25, 0xaf, 0x02, 0x1a, 0x68, 0xf7, 0x07, 0x51, 0x1a,
+	}
+	key := &SigningKey{Method: jwt.SigningMethodEdDSA, PublicKey: publicKey}
+
+	thumbprint, err := key.Thumbprint()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint)
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mErr "github.com/itmrchow/todolist-user/internal/errors"
+)
+
+const (
+	testSecretKey = "secret"
+	testIssuer    = "todolist-user"
+	testUserID    = "0195b9f6-6c48-7d3f-a0c4-1c2d6a0c9f11"
+)
+
+var testKey = NewHMACKey("", testSecretKey)
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	tokenStr, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+
+	return tokenStr
+}
+
+func testClaims(expiresAt time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		Issuer:    testIssuer,
+		Subject:   testUserID,
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+
+	tokenStr, err := GenerateToken(testUserID, nil, nil, time.Now(), testKey, testIssuer, 1)
+	require.NoError(t, err)
+
+	claims, err := ParseToken(tokenStr, testKey, testIssuer)
+	require.NoError(t, err)
+
+	assert.Equal(t, testUserID, claims.Subject)
+	assert.Equal(t, testIssuer, claims.Issuer)
+	assert.NotEmpty(t, claims.ID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), claims.ExpiresAt.Time, 5*time.Second)
+
+	// unique jti
+	otherTokenStr, err := GenerateToken(testUserID, nil, nil, time.Now(), testKey, testIssuer, 1)
+	require.NoError(t, err)
+	otherClaims, err := ParseToken(otherTokenStr, testKey, testIssuer)
+	require.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID)
+	assert.Empty(t, otherClaims.Roles)
+	assert.False(t, otherClaims.HasPermission("users:read"))
+}
+
+func TestGenerateToken_RolesAndPermissions(t *testing.T) {
+
+	tokenStr, err := GenerateToken(testUserID, []string{"support"}, []string{"users:read", "roles:read"}, time.Now(), testKey, testIssuer, 1)
+	require.NoError(t, err)
+
+	claims, err := ParseToken(tokenStr, testKey, testIssuer)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"support"}, claims.Roles)
+	assert.True(t, claims.HasPermission("users:read"))
+	assert.False(t, claims.HasPermission("roles:write"))
+}
+
+func TestValidateToken(t *testing.T) {
+
+	validToken, err := GenerateToken(testUserID, nil, nil, time.Now(), testKey, testIssuer, 1)
+	require.NoError(t, err)
+
+	// change the subject in the payload , keep the signature
+	parts := strings.Split(validToken, ".")
+	tamperedClaims := testClaims(time.Now().Add(time.Hour))
+	tamperedClaims.Subject = "other_user"
+	tamperedParts := strings.Split(signTestToken(t, jwt.SigningMethodHS512, []byte("other"), tamperedClaims), ".")
+	tamperedToken := parts[0] + "." + tamperedParts[1] + "." + parts[2]
+
+	noExpClaims := testClaims(time.Now())
+	noExpClaims.ExpiresAt = nil
+
+	noSubjectClaims := testClaims(time.Now().Add(time.Hour))
+	noSubjectClaims.Subject = ""
+
+	wrongIssuerClaims := testClaims(time.Now().Add(time.Hour))
+	wrongIssuerClaims.Issuer = "other"
+
+	tests := []struct {
+		name       string
+		tokenStr   string
+		wantUserID string
+		wantErr    error
+	}{
+		{
+			name:       "valid token",
+			tokenStr:   validToken,
+			wantUserID: testUserID,
+		},
+		{
+			name:     "empty token",
+			tokenStr: "",
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "malformed token",
+			tokenStr: "not.a.token",
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "expired",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS512, []byte(testSecretKey), testClaims(time.Now().Add(-time.Minute))),
+			wantErr:  &mErr.Err401TokenExpired,
+		},
+		{
+			name:       "expired within leeway",
+			tokenStr:   signTestToken(t, jwt.SigningMethodHS512, []byte(testSecretKey), testClaims(time.Now().Add(-2*time.Second))),
+			wantUserID: testUserID,
+		},
+		{
+			name:     "expired just over leeway",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS512, []byte(testSecretKey), testClaims(time.Now().Add(-tokenLeeway-2*time.Second))),
+			wantErr:  &mErr.Err401TokenExpired,
+		},
+		{
+			name:     "no expiration",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS512, []byte(testSecretKey), noExpClaims),
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "no subject",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS512, []byte(testSecretKey), noSubjectClaims),
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "wrong issuer",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS512, []byte(testSecretKey), wrongIssuerClaims),
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "wrong algorithm HS256",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS256, []byte(testSecretKey), testClaims(time.Now().Add(time.Hour))),
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "wrong algorithm none",
+			tokenStr: signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, testClaims(time.Now().Add(time.Hour))),
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "wrong secret key",
+			tokenStr: signTestToken(t, jwt.SigningMethodHS512, []byte("other"), testClaims(time.Now().Add(time.Hour))),
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+		{
+			name:     "tampered payload",
+			tokenStr: tamperedToken,
+			wantErr:  &mErr.Err401Unauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := ValidateToken(tt.tokenStr, testKey, testIssuer)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, userID)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantUserID, userID)
+		})
+	}
+}
+
+func TestParseToken_Scope(t *testing.T) {
+
+	claims := &Claims{
+		RegisteredClaims: testClaims(time.Now().Add(time.Hour)),
+		Scope:            "task:read task:write",
+	}
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(testSecretKey))
+	require.NoError(t, err)
+
+	parsed, err := ParseToken(tokenStr, testKey, testIssuer)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task:read", "task:write"}, parsed.Scopes())
+}
+
+func TestPurposeToken(t *testing.T) {
+
+	tokenStr, err := GeneratePurposeToken(testUserID, PurposeEmailVerification, "test@example.com", testKey, testIssuer, time.Hour)
+	require.NoError(t, err)
+
+	claims, err := ParsePurposeToken(tokenStr, testKey, testIssuer, PurposeEmailVerification)
+	require.NoError(t, err)
+	assert.Equal(t, testUserID, claims.Subject)
+	assert.Equal(t, "test@example.com", claims.Email)
+
+	// not an access token
+	_, err = ParseToken(tokenStr, testKey, testIssuer)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+
+	// not for another purpose
+	_, err = ParsePurposeToken(tokenStr, testKey, testIssuer, "other")
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+
+	// an access token is not a purpose token
+	accessToken, err := GenerateToken(testUserID, nil, nil, time.Now(), testKey, testIssuer, 1)
+	require.NoError(t, err)
+	_, err = ParsePurposeToken(accessToken, testKey, testIssuer, PurposeEmailVerification)
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+	_, err = ParsePurposeToken(accessToken, testKey, testIssuer, "")
+	assert.ErrorIs(t, err, &mErr.Err401Unauthorized)
+}
+
+func TestPurposeToken_Expired(t *testing.T) {
+
+	tokenStr, err := GeneratePurposeToken(testUserID, PurposeEmailVerification, "test@example.com", testKey, testIssuer, -time.Minute)
+	require.NoError(t, err)
+
+	_, err = ParsePurposeToken(tokenStr, testKey, testIssuer, PurposeEmailVerification)
+	assert.ErrorIs(t, err, &mErr.Err401TokenExpired)
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const opaqueTokenBytes = 32
+
+// NewOpaqueToken returns a random url safe token and the hash to store instead of the token
+func NewOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken hashes a token made by NewOpaqueToken with sha256 ,
+// the token is random enough that a slow hash is not needed
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2id parameters, follow the OWASP password storage recommendation
+const (
+	argon2Memory  uint32 = 19 * 1024
+	argon2Time    uint32 = 2
+	argon2Threads uint8  = 1
+	argon2SaltLen        = 16
+	argon2KeyLen  uint32 = 32
+)
+
+// upper bounds for the parameters read from a stored hash,
+// a tampered hash must not make a login allocate or compute without limit
+const (
+	argon2MaxMemory  uint32 = 256 * 1024
+	argon2MaxTime    uint32 = 10
+	argon2MaxThreads uint8  = 16
+	argon2MaxSaltLen        = 64
+	argon2MaxKeyLen         = 64
+)
+
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// HashPassword hashes a password with argon2id and a random salt,
+// the result is a PHC string: $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		argon2Memory,
+		argon2Time,
+		argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword checks a password against a PHC string made by HashPassword.
+// needsRehash is true when the hash was made with other parameters than the current ones.
+func VerifyPassword(encodedHash string, password string) (match bool, needsRehash bool, err error) {
+	params, salt, key, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return false, false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+
+	match = subtle.ConstantTimeCompare(key, otherKey) == 1
+	needsRehash = params.memory != argon2Memory ||
+		params.time != argon2Time ||
+		params.threads != argon2Threads ||
+		uint32(len(key)) != argon2KeyLen
+
+	return match, needsRehash, nil
+}
+
+// IsPasswordHash reports whether the string is a PHC string made by HashPassword
+func IsPasswordHash(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func decodeArgon2Hash(encodedHash string) (params argon2Params, salt []byte, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	if params.memory > argon2MaxMemory || params.time > argon2MaxTime || params.threads > argon2MaxThreads {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 || len(salt) > argon2MaxSaltLen {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > argon2MaxKeyLen {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// character classes a password policy can require
+const (
+	CharClassLower  = "lower"
+	CharClassUpper  = "upper"
+	CharClassDigit  = "digit"
+	CharClassSymbol = "symbol"
+)
+
+// default password policy , follows NIST SP 800-63B: a minimum length , no composition rules and no common passwords
+const (
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 128
+)
+
+var (
+	ErrPasswordTooShort     = errors.New("password is too short")
+	ErrPasswordTooLong      = errors.New("password is too long")
+	ErrPasswordMissingClass = errors.New("password is missing a character class")
+	ErrPasswordCommon       = errors.New("password is too common")
+	ErrUnknownCharClass     = errors.New("unknown character class")
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy is what a new password must meet , the length is counted in characters
+type PasswordPolicy struct {
+	MinLength       int
+	MaxLength       int
+	RequiredClasses []string
+	blocked         map[string]struct{}
+}
+
+// NewPasswordPolicy creates a policy that also blocks the built in common passwords and the blocked ones ,
+// blocked passwords are compared case insensitive
+func NewPasswordPolicy(minLength int, maxLength int, requiredClasses []string, blocked ...string) (*PasswordPolicy, error) {
+	if minLength <= 0 {
+		minLength = defaultPasswordMinLength
+	}
+	if maxLength <= 0 {
+		maxLength = defaultPasswordMaxLength
+	}
+	if minLength > maxLength {
+		return nil, fmt.Errorf("password min length %d is greater than max length %d", minLength, maxLength)
+	}
+
+	for _, class := range requiredClasses {
+		if charClassFunc(class) == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCharClass, class)
+		}
+	}
+
+	policy := &PasswordPolicy{
+		MinLength:       minLength,
+		MaxLength:       maxLength,
+		RequiredClasses: requiredClasses,
+		blocked:         map[string]struct{}{},
+	}
+
+	for _, password := range strings.Split(commonPasswords, "\n") {
+		policy.block(password)
+	}
+	for _, password := range blocked {
+		policy.block(password)
+	}
+
+	return policy, nil
+}
+
+// DefaultPasswordPolicy is 8 to 128 characters and no common password
+func DefaultPasswordPolicy() *PasswordPolicy {
+	policy, _ := NewPasswordPolicy(defaultPasswordMinLength, defaultPasswordMaxLength, nil)
+	return policy
+}
+
+func (p *PasswordPolicy) block(password string) {
+	password = strings.TrimSpace(password)
+	if password == "" || strings.HasPrefix(password, "#") {
+		return
+	}
+
+	p.blocked[strings.ToLower(password)] = struct{}{}
+}
+
+// Check returns the first rule the password breaks , wrapping one of the ErrPassword errors
+func (p *PasswordPolicy) Check(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, p.MinLength)
+	}
+	if length > p.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrPasswordTooLong, p.MaxLength)
+	}
+
+	for _, class := range p.RequiredClasses {
+		if !strings.ContainsFunc(password, charClassFunc(class)) {
+			return fmt.Errorf("%w: must contain a character of class %s", ErrPasswordMissingClass, class)
+		}
+	}
+
+	if _, ok := p.blocked[strings.ToLower(password)]; ok {
+		return ErrPasswordCommon
+	}
+
+	return nil
+}
+
+func charClassFunc(class string) func(rune) bool {
+	switch class {
+	case CharClassLower:
+		return unicode.IsLower
+	case CharClassUpper:
+		return unicode.IsUpper
+	case CharClassDigit:
+		return unicode.IsDigit
+	case CharClassSymbol:
+		return func(r rune) bool {
+			return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r)
+		}
+	default:
+		return nil
+	}
+}
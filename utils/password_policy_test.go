@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPasswordPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "ok", password: "correct horse battery"},
+		{name: "unicode", password: "密碼密碼密碼密碼"},
+		{name: "too short", password: "Ab1!xyz", wantErr: ErrPasswordTooShort},
+		{name: "empty", password: "", wantErr: ErrPasswordTooShort},
+		{name: "max length", password: strings.Repeat("a", 128)},
+		{name: "too long", password: strings.Repeat("a", 129), wantErr: ErrPasswordTooLong},
+		{name: "common", password: "password123", wantErr: ErrPasswordCommon},
+		{name: "common , case insensitive", password: "PassWord123", wantErr: ErrPasswordCommon},
+	}
+
+	policy := DefaultPasswordPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPasswordPolicy_RequiredClasses(t *testing.T) {
+
+	policy, err := NewPasswordPolicy(10, 64, []string{CharClassUpper, CharClassDigit, CharClassSymbol}, "Company2025!")
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Check("Tr0ub4dor&3x"))
+	assert.ErrorIs(t, policy.Check("tr0ub4dor&3x"), ErrPasswordMissingClass)
+	assert.ErrorIs(t, policy.Check("Troubador&xx"), ErrPasswordMissingClass)
+	assert.ErrorIs(t, policy.Check("Tr0ub4dor3xx"), ErrPasswordMissingClass)
+	assert.ErrorIs(t, policy.Check("Tr0b&3x"), ErrPasswordTooShort)
+	assert.ErrorIs(t, policy.Check("COMPANY2025!"), ErrPasswordCommon)
+}
+
+func TestNewPasswordPolicy_Invalid(t *testing.T) {
+
+	_, err := NewPasswordPolicy(8, 64, []string{"emoji"})
+	assert.ErrorIs(t, err, ErrUnknownCharClass)
+
+	_, err = NewPasswordPolicy(64, 8, nil)
+	assert.Error(t, err)
+}
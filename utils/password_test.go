@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	hash, err := HashPassword("password")
+	assert.NoError(t, err)
+
+	match, needsRehash, err := VerifyPassword(hash, "password")
+
+	assert.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+
+	match, _, err = VerifyPassword(hash, "other")
+
+	assert.NoError(t, err)
+	assert.False(t, match)
+}
+
+func TestVerifyPassword_InvalidParams(t *testing.T) {
+	hash, err := HashPassword("password")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{"zero memory", "m=0,t=2,p=1"},
+		{"memory too large", "m=4294967295,t=2,p=1"},
+		{"time too large", "m=19456,t=1000000,p=1"},
+		{"threads too large", "m=19456,t=2,p=255"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := strings.Replace(hash, "m=19456,t=2,p=1", tt.params, 1)
+
+			_, _, err := VerifyPassword(tampered, "password")
+
+			assert.ErrorIs(t, err, ErrInvalidPasswordHash)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+const (
+	// recoveryCodeAlphabet leaves out the characters that are easily confused , 0/o and 1/i/l
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeHalf     = 5
+)
+
+// NewRecoveryCodes returns n random codes formatted as xxxxx-xxxxx and the hashes to store instead of the codes
+func NewRecoveryCodes(n int) (codes []string, hashes []string, err error) {
+	codes = make([]string, 0, n)
+	hashes = make([]string, 0, n)
+
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for range n {
+		var b strings.Builder
+		for i := range recoveryCodeHalf * 2 {
+			if i == recoveryCodeHalf {
+				b.WriteByte('-')
+			}
+			idx, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, nil, err
+			}
+			b.WriteByte(recoveryCodeAlphabet[idx.Int64()])
+		}
+
+		code := b.String()
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed by the user ,
+// the case , spaces and dashes don't matter
+func HashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+
+	return HashOpaqueToken(normalized)
+}
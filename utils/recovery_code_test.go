@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(10)
+
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Len(t, hashes, 10)
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		assert.Regexp(t, regexp.MustCompile(`^[a-z2-9]{5}-[a-z2-9]{5}$`), code)
+		assert.Equal(t, HashRecoveryCode(code), hashes[i])
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghjk")
+
+	assert.Equal(t, want, HashRecoveryCode("ABCDE-FGHJK"))
+	assert.Equal(t, want, HashRecoveryCode(" abcdefghjk "))
+	assert.Equal(t, want, HashRecoveryCode("abcde fghjk"))
+	assert.NotEqual(t, want, HashRecoveryCode("abcde-fghjm"))
+}
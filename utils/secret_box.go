@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// SecretBoxKeySize is the key size of AES-256
+const SecretBoxKeySize = 32
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// SecretBox encrypts small secrets at rest with AES-256-GCM , e.g. the TOTP secrets.
+// The additional data binds a ciphertext to its owner , a ciphertext copied to another row doesn't open.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a SecretBox from a 32 byte key
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != SecretBoxKeySize {
+		return nil, fmt.Errorf("secret box: key must be %d bytes , got %d", SecretBoxKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts plaintext , the result is base64(nonce | ciphertext)
+func (b *SecretBox) Seal(plaintext string, additionalData string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a string made by Seal with the same additional data
+func (b *SecretBox) Open(sealed string, additionalData string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
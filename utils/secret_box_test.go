@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(bytes.Repeat([]byte{1}, SecretBoxKeySize))
+	require.NoError(t, err)
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP", "user-1")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	// random nonce
+	other, err := box.Seal("JBSWY3DPEHPK3PXP", "user-1")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, other)
+
+	plaintext, err := box.Open(sealed, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+
+	// other owner
+	_, err = box.Open(sealed, "user-2")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	// other key
+	otherBox, err := NewSecretBox(bytes.Repeat([]byte{2}, SecretBoxKeySize))
+	require.NoError(t, err)
+	_, err = otherBox.Open(sealed, "user-1")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = box.Open("not base64!", "user-1")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
+
+func TestNewSecretBox_KeySize(t *testing.T) {
+	_, err := NewSecretBox([]byte("too short"))
+	assert.Error(t, err)
+}